@@ -15,11 +15,26 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"sort"
 
 	"multi-cri/pkg/cmd"
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/plugin"
 	"multi-cri/pkg/cri/runtime"
 
+	// Built-in adapters. They register themselves in the adapter registry.
+	_ "multi-cri/pkg/cri/adapters/condor"
+	_ "multi-cri/pkg/cri/adapters/flux"
+	_ "multi-cri/pkg/cri/adapters/local"
+	_ "multi-cri/pkg/cri/adapters/lsf"
+	_ "multi-cri/pkg/cri/adapters/pbs"
+	_ "multi-cri/pkg/cri/adapters/remotehost"
+	_ "multi-cri/pkg/cri/adapters/sge"
+	_ "multi-cri/pkg/cri/adapters/slurm"
+	_ "multi-cri/pkg/cri/adapters/tes"
+
 	"k8s.io/klog"
 	"github.com/opencontainers/selinux/go-selinux"
 	"github.com/spf13/pflag"
@@ -35,6 +50,16 @@ func main() {
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
+	if o.ListAdapters {
+		printAdapters()
+		return
+	}
+
+	if o.ServeAdapter != "" {
+		serveAdapter(o)
+		return
+	}
+
 	if !o.EnableSelinux {
 		selinux.SetDisabled()
 	}
@@ -43,6 +68,8 @@ func main() {
 	klog.Infof("Run multi-cri grpc server on socket")
 	s, err := runtime.NewMulticriService(
 		o.AdapterName,
+		o.AdapterConfig,
+		o.RuntimeHandlers,
 		o.SocketPath,
 		o.NetworkPluginBinDir,
 		o.NetworkPluginConfDir,
@@ -54,6 +81,10 @@ func main() {
 		o.EnablePodPersistence,
 		o.EnablePodNetwork,
 		o.RemoteRuntime,
+		o.AdapterTimeout,
+		o.AdapterTimeouts,
+		o.AdapterRetries,
+		o.AdapterRetryBackoff,
 	)
 
 	if err != nil {
@@ -65,3 +96,35 @@ func main() {
 		klog.Exitf("Failed to run multi-cri grpc server: %v", err)
 	}
 }
+
+// serveAdapter runs a single adapter out of process, to be reached through the proxy adapter
+func serveAdapter(o *cmd.CRIMulticriOptions) {
+	if o.AdapterSocket == "" {
+		klog.Exitf("--adapter-socket is required to serve an adapter")
+	}
+	config, err := adapters.ParseAdapterConfig(o.AdapterConfig)
+	if err != nil {
+		klog.Exitf("Failed to parse adapter config: %v", err)
+	}
+	a, err := adapters.NewAdapter(o.ServeAdapter, config)
+	if err != nil {
+		klog.Exitf("Failed to create adapter %s: %v", o.ServeAdapter, err)
+	}
+	if err := plugin.Serve(a, o.AdapterSocket); err != nil {
+		klog.Exitf("Failed to serve adapter %s: %v", o.ServeAdapter, err)
+	}
+}
+
+func printAdapters() {
+	for _, a := range adapters.ListAdapters() {
+		fmt.Printf("%s\t%s\n", a.Name, a.Description)
+		var options []string
+		for k := range a.Options {
+			options = append(options, k)
+		}
+		sort.Strings(options)
+		for _, k := range options {
+			fmt.Printf("    %-20s %s\n", k, a.Options[k])
+		}
+	}
+}
@@ -16,7 +16,12 @@ package cmd
 
 import (
 	"flag"
+	"os"
 	"os/user"
+	"time"
+
+	"multi-cri/pkg/cri/adapters/middleware"
+	"multi-cri/pkg/cri/runtime"
 
 	"github.com/spf13/pflag"
 )
@@ -27,6 +32,24 @@ const defaultUnixSock = "/var/run/multi-cri.sock"
 type CRIMulticriOptions struct {
 	//Adapter Name
 	AdapterName string
+	// AdapterConfig contains the adapter options in "key=value,key=value" format
+	AdapterConfig string
+	// RuntimeHandlers maps runtime handlers to adapters in "handler:adapter,handler:adapter" format
+	RuntimeHandlers string
+	// ServeAdapter is the adapter served to the proxy adapter of another multi-cri process
+	ServeAdapter string
+	// AdapterSocket is the unix socket on which ServeAdapter is served
+	AdapterSocket string
+	// AdapterTimeout is the deadline of the adapter operations
+	AdapterTimeout time.Duration
+	// AdapterTimeouts overrides the deadline of some operations in "Operation=duration,..." format
+	AdapterTimeouts string
+	// AdapterRetries sets the attempts of the retried adapter calls in "Method=attempts,..." format
+	AdapterRetries string
+	// AdapterRetryBackoff is the wait before the first retry of an adapter call
+	AdapterRetryBackoff time.Duration
+	// ListAdapters indicates to print the adapters multi-cri was built with
+	ListAdapters bool
 	// SocketPath is the path to the socket which multi-cri serves on.
 	SocketPath string
 	// PrintVersion indicates to print version information of multi-cri.
@@ -67,7 +90,28 @@ func NewCRIMulticriOptions() *CRIMulticriOptions {
 // AddFlags adds multi-cri command line options to pflag.
 func (c *CRIMulticriOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&c.AdapterName, "adapter-name",
-		"fale", "Adapter name. For instance, \"slurm\" is supported")
+		"slurm", "Adapter name. Use --list-adapters to see the available adapters")
+	fs.StringVar(&c.AdapterConfig, "adapter-config",
+		"", "Adapter options in \"key=value,key=value\" format")
+	fs.StringVar(&c.RuntimeHandlers, "runtime-handlers",
+		"", "Local runtime handlers in \"handler:adapter,handler:adapter\" format. The first one is the default. "+
+			"If empty, the --adapter-name adapter serves the multicri runtime handler")
+	fs.StringVar(&c.ServeAdapter, "serve-adapter",
+		"", "Serve this adapter on --adapter-socket instead of running the CRI, so it runs out of process behind the proxy adapter")
+	fs.StringVar(&c.AdapterSocket, "adapter-socket",
+		os.Getenv("MULTICRI_ADAPTER_SOCKET"), "Unix socket used by --serve-adapter. The proxy adapter sets it through MULTICRI_ADAPTER_SOCKET")
+	fs.DurationVar(&c.AdapterTimeout, "adapter-timeout",
+		runtime.DefaultAdapterTimeout, "Deadline of the adapter operations. Zero disables it")
+	fs.StringVar(&c.AdapterTimeouts, "adapter-timeouts",
+		runtime.DefaultAdapterTimeouts, "Deadline of some adapter operations in \"Operation=duration,Operation=duration\" format. "+
+			"The operations are named after the adapter methods")
+	fs.StringVar(&c.AdapterRetries, "adapter-retries",
+		middleware.DefaultRetries, "Attempts of the adapter calls retried on transient errors in \"Method=attempts,Method=attempts\" format. "+
+			"Only idempotent methods should be retried")
+	fs.DurationVar(&c.AdapterRetryBackoff, "adapter-retry-backoff",
+		middleware.DefaultRetryBackoff, "Wait before the first retry of an adapter call. It doubles on every retry")
+	fs.BoolVar(&c.ListAdapters, "list-adapters", false,
+		"List the available adapters and their options, then exit")
 	fs.StringVar(&c.SocketPath, "socket-path",
 		defaultUnixSock, "Path to the socket which multi-cri serves on.")
 	fs.BoolVar(&c.EnablePodNetwork, "enable-pod-network", false,
@@ -15,38 +15,60 @@
 package adapters
 
 import (
+	"reflect"
+
 	"multi-cri/pkg/cri/store"
 
+	"golang.org/x/net/context"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
 )
 
 const VolumeContainer = "/multicri"
 
+// AdapterInterface is implemented by the adapters. The context is done when the CRI request is
+// cancelled or its deadline expires, and the adapters must abort their remote commands then.
 type AdapterInterface interface {
 	//Sandbox
-	RunPodSandbox(sandbox *store.SandboxMetadata) error
-	StopPodSandbox(sandbox *store.SandboxMetadata) error
-	RemovePodSandbox(sandbox *store.SandboxMetadata) error
-	PodSandboxStatus(sandbox *store.SandboxMetadata) error
+	RunPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error
+	StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error
+	RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error
+	PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error
 	//CRI Version
-	Version() (*runtimeApi.VersionResponse, error)
+	Version(ctx context.Context) (*runtimeApi.VersionResponse, error)
 	//Container
-	CreateContainer(cm *store.ContainerMetadata) error
-	StartContainer(cm *store.ContainerMetadata) error
-	StopContainer(cm *store.ContainerMetadata) error
-	ContainerStatus(cm *store.ContainerMetadata) error
-	ReopenContainerLog(cm *store.ContainerMetadata) error
-	UpdateContainerResources(cm *store.ContainerMetadata) error
+	CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error
+	StartContainer(ctx context.Context, cm *store.ContainerMetadata) error
+	StopContainer(ctx context.Context, cm *store.ContainerMetadata) error
+	ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error
+	ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error
+	UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error
 	//Pull Image
-	PullImage(image *store.ImageMetadata) error
-	ListImages(images []*runtimeApi.Image) error
-	ImageStatus(image *store.ImageMetadata) error
-	ImageFsInfo() (*runtimeApi.ImageFsInfoResponse, error)
-	RemoveImage(image *store.ImageMetadata) error
-	//Stream exec
-	ExecSync(cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error)
-	Exec(cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error)
-	Attach(cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error)
+	PullImage(ctx context.Context, image *store.ImageMetadata) error
+	ListImages(ctx context.Context, images []*runtimeApi.Image) error
+	ImageStatus(ctx context.Context, image *store.ImageMetadata) error
+	ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error)
+	RemoveImage(ctx context.Context, image *store.ImageMetadata) error
+	//Capabilities reports the optional operations the adapter supports
+	Capabilities() Capabilities
+	//Stream exec. Exec and Attach return a nil response when the runtime streaming server serves the
+	//request with the streaming.Runtime of NewStreamRuntime.
+	ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error)
+	Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error)
+	Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error)
 	NewStreamRuntime(c store.ContainerStoreInterface) streaming.Runtime
 }
+
+// Restorer is implemented by the adapters which keep state of the containers in memory. The runtime calls
+// Restore with the stored containers of the adapter when it starts, before serving any request, so the
+// state is rebuilt after restarts.
+type Restorer interface {
+	Restore(ctx context.Context, containers []*store.ContainerMetadata) error
+}
+
+// IsOperation reports whether the name is an AdapterInterface method taking a context. The runtime
+// configuration, like the operation timeouts, names the adapter operations after them.
+func IsOperation(name string) bool {
+	method, ok := reflect.TypeOf((*AdapterInterface)(nil)).Elem().MethodByName(name)
+	return ok && method.Type.NumIn() > 0 && method.Type.In(0) == reflect.TypeOf((*context.Context)(nil)).Elem()
+}
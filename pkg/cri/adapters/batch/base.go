@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/client-go/tools/remotecommand"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+// ImageBuilder pulls the images of the containers. The builders of the slurm/builder package implement it.
+type ImageBuilder interface {
+	PullImage(ctx context.Context, cm *store.ContainerMetadata) error
+	PullImageInCluster(ctx context.Context, cm *store.ContainerMetadata) error
+}
+
+// Base implements the adapter methods shared by the batch scheduler adapters, which embed it:
+// containers can not be accessed once they are submitted, pods are not created in the cluster
+// and images are pulled with the Builder.
+type Base struct {
+	NoStreaming
+	NoSandbox
+	// MountPath is the working directory in the cluster, relative to $HOME
+	MountPath string
+	Builder   ImageBuilder
+}
+
+// NewBase returns the base of the adapter, named name in the errors
+func NewBase(name, mountPath string, builder ImageBuilder) Base {
+	return Base{NoStreaming: NoStreaming{Name: name}, MountPath: mountPath, Builder: builder}
+}
+
+func (b Base) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	if image.RepoType == store.UnknownImageRepo {
+		return adapters.InvalidArgumentError("Image repository type not supported by multi-cri %s ", image.RemotePath)
+	}
+	container := &store.ContainerMetadata{Image: image}
+	return b.Builder.PullImage(ctx, container)
+}
+
+func (b Base) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	return nil
+}
+
+func (b Base) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
+
+func (b Base) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	//todo control it properly
+	filesystems := []*runtimeApi.FilesystemUsage{
+		{
+			Timestamp: time.Now().UnixNano(),
+			UsedBytes: &runtimeApi.UInt64Value{Value: uint64(0)},
+			FsId: &runtimeApi.FilesystemIdentifier{
+				Mountpoint: b.MountPath,
+			},
+		},
+	}
+	return &runtimeApi.ImageFsInfoResponse{ImageFilesystems: filesystems}, nil
+}
+
+func (b Base) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
+
+// NoSandbox implements the sandbox methods of the adapters which do not create pods in the cluster
+type NoSandbox struct{}
+
+func (NoSandbox) RunPodSandbox(ctx context.Context, metadata *store.SandboxMetadata) error {
+	return nil
+}
+
+func (NoSandbox) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+
+func (NoSandbox) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+
+func (NoSandbox) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+
+// NoStreaming implements the exec, attach and streaming methods of the adapters which can not
+// access the containers. Name identifies the adapter in the errors.
+type NoStreaming struct {
+	Name string
+}
+
+func (n NoStreaming) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	return nil, adapters.UnimplementedError("ExecSync not implemented for %s Adapter", n.Name)
+}
+
+func (n NoStreaming) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
+	return nil, adapters.UnimplementedError("Exec not implemented for %s Adapter", n.Name)
+}
+
+func (n NoStreaming) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
+	return nil, adapters.UnimplementedError("Attach not implemented for %s Adapter", n.Name)
+}
+
+func (n NoStreaming) NewStreamRuntime(c store.ContainerStoreInterface) streaming.Runtime {
+	return &streamRuntime{name: strings.ToUpper(n.Name)}
+}
+
+type streamRuntime struct {
+	name string
+}
+
+func (r *streamRuntime) Attach(containerID string, in io.Reader, out, err io.WriteCloser, tty bool,
+	resize <-chan remotecommand.TerminalSize) error {
+	return fmt.Errorf("%s: streamRuntime Attach still not implemented", r.name)
+}
+
+func (r *streamRuntime) Exec(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.WriteCloser,
+	tty bool, resize <-chan remotecommand.TerminalSize) error {
+	return fmt.Errorf("%s: streamRuntime Exec still not implemented", r.name)
+}
+
+func (r *streamRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	return fmt.Errorf("%s: streamRuntime PortForward still not implemented", r.name)
+}
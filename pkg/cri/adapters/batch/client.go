@@ -0,0 +1,187 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/common/ssh"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+// ErrorPattern classifies the scheduler errors containing the message, in lower case
+type ErrorPattern struct {
+	Message string
+	Kind    adapters.ErrorKind
+}
+
+// ClassifyError sets the kind of the error of a remote command from its output. SSH connection
+// errors are Unavailable, or PermissionDenied when the credentials are rejected.
+func ClassifyError(patterns []ErrorPattern, response string, err error) error {
+	if err == nil {
+		return nil
+	}
+	message := strings.ToLower(response + " " + err.Error())
+	if ssh.IsConnectionError(err) {
+		if strings.Contains(message, "unable to authenticate") {
+			return adapters.WrapError(adapters.KindPermissionDenied, err)
+		}
+		return adapters.WrapError(adapters.KindUnavailable, err)
+	}
+	for _, p := range patterns {
+		if strings.Contains(message, p.Message) {
+			return adapters.WrapError(p.Kind, err)
+		}
+	}
+	return err
+}
+
+// WrapError adds context to the error message, keeping its kind
+func WrapError(err error, format string, a ...interface{}) error {
+	return &adapters.Error{Kind: adapters.ErrorKindOf(err), Message: fmt.Sprintf(format, a...), Cause: err}
+}
+
+// Client runs commands in the cluster through SSH, with the credentials of the container
+type Client struct {
+	sshClient *ssh.SSH
+	logPath   string
+	errors    []ErrorPattern
+}
+
+// NewClient returns the client of the cluster set in the CLUSTER_* variables of the container.
+// The errors of the commands are classified with the patterns.
+func NewClient(cm *store.ContainerMetadata, errors []ErrorPattern) (*Client, error) {
+	sshClient, err := NewSSH(cm)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{sshClient: sshClient, logPath: cm.LogFile, errors: errors}, nil
+}
+
+// NewSSH returns the SSH client of the host set in the CLUSTER_* variables of the container
+func NewSSH(cm *store.ContainerMetadata) (*ssh.SSH, error) {
+	user := cm.Environment["CLUSTER_USERNAME"]
+	host := cm.Environment["CLUSTER_HOST"]
+	port := cm.Environment["CLUSTER_PORT"]
+	var sshClient ssh.SSH
+	if key, ok := cm.Environment["CLUSTER_KEYVALUE"]; ok && key != "" {
+		sshClient = ssh.NewSSH(user, host, port, nil, nil, []byte(key))
+	} else if password, ok := cm.Environment["CLUSTER_PASSWORD"]; ok && password != "" {
+		sshClient = ssh.NewSSH(user, host, port, nil, &password, nil)
+	} else {
+		return nil, adapters.InvalidArgumentError("KeyPath or password must be setup")
+	}
+	return &sshClient, nil
+}
+
+// Run runs the command and writes its output in the container log
+func (c *Client) Run(ctx context.Context, cmd string) (string, error) {
+	klog.V(4).Infof("Execute command %s", cmd)
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(c.logPath, false, 100)
+	if err != nil {
+		return "", fmt.Errorf("failed to start container logger: %s", err)
+	}
+	defer func() {
+		stderrWC.Close()
+		stdoutWC.Close()
+	}()
+	response, _, err := c.sshClient.Run(ctx, cmd, stdoutWC, stderrWC, true)
+	if err != nil {
+		return "", WrapError(ClassifyError(c.errors, response, err), "%s. %s", response, err)
+	}
+	return response, nil
+}
+
+// Output runs the command and returns its output without logging it, for the status queries
+func (c *Client) Output(ctx context.Context, cmd string) (string, error) {
+	klog.V(5).Infof("Execute command %s", cmd)
+	response, stderr, err := c.sshClient.Run(ctx, cmd, nil, nil, false)
+	if err != nil {
+		return "", WrapError(ClassifyError(c.errors, response+" "+stderr, err), "%s %s. %s", response, stderr, err)
+	}
+	return response, nil
+}
+
+// WriteFile writes the lines in a file of the cluster
+func (c *Client) WriteFile(ctx context.Context, remotePath string, lines []string) error {
+	f, err := ioutil.TempFile("", "multicri-batch")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		return err
+	}
+	if err := c.sshClient.CopyTo(ctx, f.Name(), remotePath); err != nil {
+		return WrapError(ClassifyError(c.errors, "", err), "Error copying file %s to the cluster. %s", remotePath, err)
+	}
+	return nil
+}
+
+// MakeDir creates the directory in the cluster
+func (c *Client) MakeDir(ctx context.Context, path string) error {
+	_, err := c.Run(ctx, fmt.Sprintf("mkdir -p %s", path))
+	return err
+}
+
+// CopyOutput writes the job output files in the container log
+func (c *Client) CopyOutput(ctx context.Context, cm *store.ContainerMetadata) {
+	for _, name := range []string{SterrFile, StdoutFile} {
+		if _, err := c.Run(ctx, fmt.Sprintf("cat %s", RMFile(cm, name))); err != nil {
+			klog.V(4).Infof("Job output %s can not be read: %v", name, err)
+		}
+	}
+}
+
+// Submit writes the batch script, the prerun script when CLUSTER_CONFIG is set, and the run script,
+// which exports the environment and runs the submit command in the container path. It returns the
+// output of the submission.
+func (c *Client) Submit(ctx context.Context, cm *store.ContainerMetadata, settings JobSettings, batch []string, submit string) (string, error) {
+	path := cm.Extra["RMPath"]
+	if settings.ClusterConfig != "" {
+		if err := c.WriteFile(ctx, RMFile(cm, PreRunScript), []string{settings.ClusterConfig}); err != nil {
+			return "", err
+		}
+	}
+	if err := c.WriteFile(ctx, RMFile(cm, BatchScript), batch); err != nil {
+		return "", err
+	}
+	run := []string{"#!/bin/bash"}
+	run = append(run, ExportEnvironment(FilterEnvironment(cm))...)
+	run = append(run, fmt.Sprintf("cd %s", path))
+	if settings.ClusterConfig != "" {
+		run = append(run, fmt.Sprintf("source %s", PreRunScript))
+	}
+	run = append(run, submit)
+	if err := c.WriteFile(ctx, RMFile(cm, RunScript), run); err != nil {
+		return "", err
+	}
+	return c.Run(ctx, RMFile(cm, RunScript))
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+
+	"k8s.io/klog"
+)
+
+const (
+	// StdoutFile and SterrFile are the job output files in the container path
+	StdoutFile = "stdout.out"
+	SterrFile  = "sterr.out"
+	// RunScript submits the job. It exports the container environment first.
+	RunScript = "run.sh"
+	// BatchScript is the job script, with the scheduler directives
+	BatchScript = "batch.sh"
+	// PreRunScript contains the CLUSTER_CONFIG commands, sourced before the submission
+	PreRunScript = "prerun.sh"
+)
+
+// SetupContainerPaths sets the path of the container in the cluster, RMPath, relative to $HOME.
+// When the container mounts the multicri volume, its results are stored in the volume.
+func SetupContainerPaths(cm *store.ContainerMetadata, mountPath string) {
+	mounts := make(map[string]string)
+	for _, m := range cm.Config.Mounts {
+		mounts[m.ContainerPath] = m.HostPath
+	}
+	mountPoint := mountPath
+	if val, ok := mounts[adapters.VolumeContainer]; ok {
+		cm.Extra["VolumePath"] = val
+		cm.Extra["LocalPath"] = fmt.Sprintf("%s/%s/%s", val, cm.PodSandbox.ID, cm.ID)
+		volName := filepath.Base(val)
+		mountPoint = fmt.Sprintf("%s/%s", mountPoint, volName)
+		LogString(cm.LogFile, fmt.Sprintf("---\nContainer mounted in \"%s\" Volume.\nResults stored in directory:  \"%s/%s\" \n---",
+			volName, cm.PodSandbox.ID, cm.ID))
+	}
+	cm.Extra["RMVolumePath"] = mountPoint
+	cm.Extra["RMPath"] = fmt.Sprintf("%s/%s/%s", mountPoint, cm.PodSandbox.ID, cm.ID)
+}
+
+// RMFile returns the path of a file of the container in the cluster
+func RMFile(cm *store.ContainerMetadata, name string) string {
+	return fmt.Sprintf("%s/%s", cm.Extra["RMPath"], name)
+}
+
+// LogString writes the message in the container log
+func LogString(logPath, message string) {
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(logPath, false, 0)
+	if err != nil {
+		klog.Errorf("failed to start container logger: %s", err)
+		return
+	}
+	defer func() {
+		stderrWC.Close()
+		stdoutWC.Close()
+	}()
+	stdoutWC.Write([]byte(message))
+}
@@ -0,0 +1,435 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package batch contains the parts shared by the adapters of batch schedulers: the job settings read
+// from the container environment, the container paths in the cluster, the job command and the SSH client.
+package batch
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+)
+
+var (
+	// mpiProcesses matches the number of processes in the mpirun flags, e.g. "-np 4"
+	mpiProcesses = regexp.MustCompile(`(?:^|\s)(?:-np|--np|-n|--n|-c)\s+(\d+)`)
+	// queueName matches the names of the queues, e.g. "gpu-long" or "batch@server"
+	queueName = regexp.MustCompile(`^[A-Za-z0-9_.\-@]+$`)
+	// gresRequest matches a generic resource request, name[:type][:count], e.g. "gpu:tesla:2"
+	gresRequest = regexp.MustCompile(`^[A-Za-z0-9_]+(:[A-Za-z0-9_.\-]+)?(:[1-9][0-9]*)?$`)
+)
+
+// JobAnnotationPrefix is the namespace of the annotations of the job settings, e.g. multicri.atrio.io/partition
+const JobAnnotationPrefix = "multicri.atrio.io/"
+
+// JobSetting is a job setting which is set by an annotation of the container, by an annotation of its pod,
+// or by an environment variable, in that order. The environment variables are the fallback of the annotations,
+// which do not leak into the container environment.
+type JobSetting struct {
+	Annotation string
+	Env        string
+}
+
+// The job settings which can be set with annotations
+var (
+	PartitionSetting    = JobSetting{JobAnnotationPrefix + "partition", "JOB_QUEUE"}
+	TimeSetting         = JobSetting{JobAnnotationPrefix + "time", "JOB_TIME_LIMIT"}
+	AccountSetting      = JobSetting{JobAnnotationPrefix + "account", "JOB_ACCOUNT"}
+	QOSSetting          = JobSetting{JobAnnotationPrefix + "qos", "JOB_QOS"}
+	ConstraintSetting   = JobSetting{JobAnnotationPrefix + "constraint", "JOB_CONSTRAINT"}
+	GresSetting         = JobSetting{JobAnnotationPrefix + "gres", "JOB_GPU"}
+	NodesSetting        = JobSetting{JobAnnotationPrefix + "nodes", "JOB_NUM_NODES"}
+	CoresSetting        = JobSetting{JobAnnotationPrefix + "cores", "JOB_NUM_CORES"}
+	CoresPerNodeSetting = JobSetting{JobAnnotationPrefix + "cores-per-node", "JOB_NUM_CORES_NODE"}
+	TasksPerNodeSetting = JobSetting{JobAnnotationPrefix + "tasks-per-node", "JOB_NUM_TASKS_NODE"}
+	ArraySetting        = JobSetting{JobAnnotationPrefix + "array", "JOB_ARRAY"}
+	ArrayPolicySetting  = JobSetting{JobAnnotationPrefix + "array-policy", "JOB_ARRAY_POLICY"}
+	CustomConfigSetting = JobSetting{JobAnnotationPrefix + "custom-config", "JOB_CUSTOM_CONFIG"}
+)
+
+// jobSettings are every job setting, which the schedulers support or reject
+var jobSettings = []JobSetting{PartitionSetting, TimeSetting, AccountSetting, QOSSetting, ConstraintSetting,
+	GresSetting, NodesSetting, CoresSetting, CoresPerNodeSetting, TasksPerNodeSetting, ArraySetting,
+	ArrayPolicySetting, CustomConfigSetting}
+
+// Lookup returns the value of the setting in the container, and where it is set, e.g. "annotation
+// multicri.atrio.io/time of the container". Both are empty when the setting is not set. Empty annotations
+// are not set.
+func (j JobSetting) Lookup(cm *store.ContainerMetadata) (value, source string) {
+	if value := cm.Config.Annotations[j.Annotation]; value != "" {
+		return value, fmt.Sprintf("annotation %s of the container", j.Annotation)
+	}
+	if value := cm.PodSandbox.Config.Annotations[j.Annotation]; value != "" {
+		return value, fmt.Sprintf("annotation %s of the pod", j.Annotation)
+	}
+	if value, ok := cm.Environment[j.Env]; ok {
+		return value, fmt.Sprintf("environment variable %s", j.Env)
+	}
+	return "", ""
+}
+
+// value returns the value of the setting in the container
+func (j JobSetting) value(cm *store.ContainerMetadata) string {
+	value, _ := j.Lookup(cm)
+	return value
+}
+
+// JobSettingRule is a job setting which a scheduler supports, with the validation of its values and their
+// description in the errors
+type JobSettingRule struct {
+	Setting JobSetting
+	Valid   func(value string) bool
+	Format  string
+}
+
+// The rules of the job settings which the schedulers read the same way
+var (
+	QueueRule        = JobSettingRule{PartitionSetting, queueName.MatchString, "a queue name"}
+	GresRule         = JobSettingRule{GresSetting, ListOf(gresRequest), "a list of generic resources name[:type][:count] separated by commas, e.g. gpu:tesla:2"}
+	NodesRule        = JobSettingRule{NodesSetting, validCount, "a positive number"}
+	CoresRule        = JobSettingRule{CoresSetting, validCount, "a positive number"}
+	CoresPerNodeRule = JobSettingRule{CoresPerNodeSetting, validCount, "a positive number"}
+	TasksPerNodeRule = JobSettingRule{TasksPerNodeSetting, validCount, "a positive number"}
+	TimeRule         = JobSettingRule{TimeSetting, validTimeLimit, "a time limit with the format minutes, minutes:seconds, " +
+		"hours:minutes:seconds, days-hours, days-hours:minutes, days-hours:minutes:seconds or UNLIMITED"}
+	CustomConfigRule = JobSettingRule{CustomConfigSetting, func(string) bool { return true }, "a list of directives"}
+)
+
+// ListOf returns the validation of the lists of values separated by commas, which match the pattern
+func ListOf(pattern *regexp.Regexp) func(string) bool {
+	return func(value string) bool {
+		for _, item := range strings.Split(value, ",") {
+			if !pattern.MatchString(item) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// validCount returns whether the value is a positive number
+func validCount(value string) bool {
+	n, err := strconv.Atoi(value)
+	return err == nil && n > 0
+}
+
+// TimeLimitUnlimited is the time limit of the jobs which run without limit, UNLIMITED or INFINITE in Slurm
+const TimeLimitUnlimited = -1
+
+// ParseTimeLimit parses a time limit in the Slurm format in seconds. The formats are "minutes",
+// "minutes:seconds", "hours:minutes:seconds", "days-hours", "days-hours:minutes" and
+// "days-hours:minutes:seconds". UNLIMITED and INFINITE, in any case, are TimeLimitUnlimited.
+func ParseTimeLimit(limit string) (int, error) {
+	if strings.EqualFold(limit, "UNLIMITED") || strings.EqualFold(limit, "INFINITE") {
+		return TimeLimitUnlimited, nil
+	}
+	days, clock := 0, limit
+	if parts := strings.SplitN(limit, "-", 2); len(parts) == 2 {
+		var err error
+		if days, err = strconv.Atoi(parts[0]); err != nil || days < 0 {
+			return 0, fmt.Errorf("wrong time limit %s", limit)
+		}
+		clock = parts[1]
+	}
+	var values []int
+	for _, part := range strings.Split(clock, ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("wrong time limit %s", limit)
+		}
+		values = append(values, value)
+	}
+	if len(values) > 3 {
+		return 0, fmt.Errorf("wrong time limit %s", limit)
+	}
+	// The fields are hours, minutes and seconds with days, and minutes and seconds without them
+	var hours, minutes, seconds int
+	switch {
+	case clock != limit:
+		hours = values[0]
+		if len(values) > 1 {
+			minutes = values[1]
+		}
+		if len(values) > 2 {
+			seconds = values[2]
+		}
+	case len(values) == 3:
+		hours, minutes, seconds = values[0], values[1], values[2]
+	case len(values) == 2:
+		minutes, seconds = values[0], values[1]
+	default:
+		minutes = values[0]
+	}
+	total := ((days*24+hours)*60+minutes)*60 + seconds
+	if total == 0 {
+		return 0, fmt.Errorf("wrong time limit %s", limit)
+	}
+	return total, nil
+}
+
+// validTimeLimit returns whether the time limit has a Slurm format
+func validTimeLimit(limit string) bool {
+	_, err := ParseTimeLimit(limit)
+	return err == nil
+}
+
+// ValidateJobSettings checks the job settings of the container for the scheduler, which supports the settings
+// of the rules. The annotations are the rest of the annotations of the namespace which the scheduler reads,
+// by name, or by prefix when they end with a dot. The errors are invalid arguments which name the annotation
+// or the environment variable of the wrong settings and of the settings which the scheduler does not support,
+// and the unknown annotations of the namespace.
+func ValidateJobSettings(cm *store.ContainerMetadata, scheduler string, rules []JobSettingRule, annotations ...string) error {
+	supported := make(map[JobSetting]bool)
+	for _, rule := range rules {
+		supported[rule.Setting] = true
+		value, source := rule.Setting.Lookup(cm)
+		if value == "" || rule.Valid(value) {
+			continue
+		}
+		return adapters.InvalidArgumentError("Wrong %s of container %s: %q is not %s", source, cm.Name, value, rule.Format)
+	}
+	known := make(map[string]bool)
+	for _, setting := range jobSettings {
+		known[setting.Annotation] = true
+		if value, source := setting.Lookup(cm); value != "" && !supported[setting] {
+			return adapters.InvalidArgumentError("Unsupported %s of container %s: %s does not support it", source,
+				cm.Name, scheduler)
+		}
+	}
+	for _, owner := range []struct {
+		name        string
+		annotations map[string]string
+	}{{"container", cm.Config.Annotations}, {"pod", cm.PodSandbox.Config.Annotations}} {
+		var keys []string
+		for key := range owner.annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.HasPrefix(key, JobAnnotationPrefix) && !known[key] && !knownAnnotation(key, annotations) {
+				return adapters.InvalidArgumentError("Unknown annotation %s of the %s of container %s: it is not a "+
+					"job setting of %s", key, owner.name, cm.Name, scheduler)
+			}
+		}
+	}
+	return nil
+}
+
+// knownAnnotation returns whether the key is one of the annotations, or has the prefix of one which ends
+// with a dot
+func knownAnnotation(key string, annotations []string) bool {
+	for _, annotation := range annotations {
+		if key == annotation || (strings.HasSuffix(annotation, ".") && strings.HasPrefix(key, annotation)) {
+			return true
+		}
+	}
+	return false
+}
+
+// JobSettings are the scheduler independent job options of a container. They are read from the annotations
+// of the container and its pod, and from its environment, so one pod spec can target any scheduler by
+// changing only the RuntimeClass.
+type JobSettings struct {
+	// Name of the job
+	Name string
+	// Queue or partition (multicri.atrio.io/partition, JOB_QUEUE)
+	Queue string
+	// GPU is the generic resource request, e.g. "gpu:2" (multicri.atrio.io/gres, JOB_GPU)
+	GPU string
+	// Nodes is the number of nodes (multicri.atrio.io/nodes, JOB_NUM_NODES)
+	Nodes string
+	// CoresPerNode is the number of cores per node (multicri.atrio.io/cores-per-node, JOB_NUM_CORES_NODE)
+	CoresPerNode string
+	// Cores is the total number of cores (multicri.atrio.io/cores, JOB_NUM_CORES)
+	Cores string
+	// TasksPerNode is the number of tasks per node (multicri.atrio.io/tasks-per-node, JOB_NUM_TASKS_NODE)
+	TasksPerNode string
+	// TimeLimit is the time limit of the job in the Slurm format, e.g. "1-12:00:00" (multicri.atrio.io/time,
+	// JOB_TIME_LIMIT). HTCondor does not support it.
+	TimeLimit string
+	// Account is the account charged for the job (multicri.atrio.io/account, JOB_ACCOUNT), QOS its quality
+	// of service (multicri.atrio.io/qos, JOB_QOS) and Constraint the features of its nodes, e.g. "intel&ib"
+	// (multicri.atrio.io/constraint, JOB_CONSTRAINT). Only Slurm supports them.
+	Account    string
+	QOS        string
+	Constraint string
+	// Array is the job array specification, e.g. "0-99%10", which runs the container once per array
+	// task (multicri.atrio.io/array, JOB_ARRAY). Only Slurm supports arrays.
+	Array string
+	// ArrayPolicy is the rule which aggregates the array tasks in the container exit code, "all" or "any"
+	// (multicri.atrio.io/array-policy, JOB_ARRAY_POLICY)
+	ArrayPolicy string
+	// CustomConfig is added to the script directives as is (multicri.atrio.io/custom-config, JOB_CUSTOM_CONFIG)
+	CustomConfig string
+	// ClusterConfig is sourced before submitting the job (CLUSTER_CONFIG)
+	ClusterConfig string
+	// MPIVersion runs the job with mpirun when it is set (MPI_VERSION)
+	MPIVersion string
+	// MPIFlags are the mpirun flags (MPI_FLAGS)
+	MPIFlags string
+}
+
+// ParseJobSettings reads the job settings of the container
+func ParseJobSettings(cm *store.ContainerMetadata) JobSettings {
+	return JobSettings{
+		Name:          cm.Name,
+		Queue:         PartitionSetting.value(cm),
+		GPU:           GresSetting.value(cm),
+		Nodes:         NodesSetting.value(cm),
+		CoresPerNode:  CoresPerNodeSetting.value(cm),
+		Cores:         CoresSetting.value(cm),
+		TasksPerNode:  TasksPerNodeSetting.value(cm),
+		TimeLimit:     TimeSetting.value(cm),
+		Account:       AccountSetting.value(cm),
+		QOS:           QOSSetting.value(cm),
+		Constraint:    ConstraintSetting.value(cm),
+		Array:         ArraySetting.value(cm),
+		ArrayPolicy:   ArrayPolicySetting.value(cm),
+		CustomConfig:  CustomConfigSetting.value(cm),
+		ClusterConfig: cm.Environment["CLUSTER_CONFIG"],
+		MPIVersion:    cm.Environment["MPI_VERSION"],
+		MPIFlags:      cm.Environment["MPI_FLAGS"],
+	}
+}
+
+// TimeLimitSeconds returns the time limit of the job in seconds, or TimeLimitUnlimited. It is 0 when the
+// time limit is not set or is wrong, which the validation of the settings rejects.
+func (s JobSettings) TimeLimitSeconds() int {
+	seconds, _ := ParseTimeLimit(s.TimeLimit)
+	return seconds
+}
+
+// Walltime returns the time limit in hours:minutes:seconds, e.g. "36:00:00" for "1-12:00:00"
+func Walltime(seconds int) string {
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// IsMPI returns whether the job runs with mpirun
+func (s JobSettings) IsMPI() bool {
+	return s.MPIVersion != ""
+}
+
+// GPUCount returns the number of GPUs of the generic resource request, e.g. 2 for "gpu:2" or
+// "gpu:tesla:2". It is 1 when the request does not set a number, and 0 without request.
+func (s JobSettings) GPUCount() int {
+	if s.GPU == "" {
+		return 0
+	}
+	split := strings.Split(s.GPU, ":")
+	if n, err := strconv.Atoi(split[len(split)-1]); err == nil {
+		return n
+	}
+	return 1
+}
+
+// NodeCount returns the number of nodes, 1 when it is not set
+func (s JobSettings) NodeCount() int {
+	if n, err := strconv.Atoi(s.Nodes); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// CoresPerNodeCount returns the cores per node, or the total cores split among the nodes.
+// It is 0 when neither is set.
+func (s JobSettings) CoresPerNodeCount() int {
+	if n, err := strconv.Atoi(s.CoresPerNode); err == nil && n > 0 {
+		return n
+	}
+	if n, err := strconv.Atoi(s.Cores); err == nil && n > 0 {
+		nodes := s.NodeCount()
+		return (n + nodes - 1) / nodes
+	}
+	return 0
+}
+
+// CoreCount returns the total number of cores, 0 when neither the cores nor the cores per node are set
+func (s JobSettings) CoreCount() int {
+	if n, err := strconv.Atoi(s.Cores); err == nil && n > 0 {
+		return n
+	}
+	return s.CoresPerNodeCount() * s.NodeCount()
+}
+
+// TaskCount returns the tasks of the job, for the schedulers which allocate slots instead of nodes:
+// the cores, the tasks of all the nodes, or the MPI processes of the mpirun flags. Multi-node jobs have
+// a task per node at least. It is 0 when none of them is set.
+func (s JobSettings) TaskCount() int {
+	tasks := s.CoreCount()
+	if n, err := strconv.Atoi(s.TasksPerNode); err == nil && n > 0 && tasks == 0 {
+		tasks = n * s.NodeCount()
+	}
+	if m := mpiProcesses.FindStringSubmatch(s.MPIFlags); m != nil && tasks == 0 && s.IsMPI() {
+		tasks, _ = strconv.Atoi(m[1])
+	}
+	if s.Nodes != "" && tasks < s.NodeCount() {
+		tasks = s.NodeCount()
+	}
+	return tasks
+}
+
+// Command returns the command which runs the container image with singularity, with mpirun for MPI jobs
+func (s JobSettings) Command(cm *store.ContainerMetadata, imagePath string) string {
+	var command []string
+	if s.IsMPI() {
+		command = append(command, "mpirun")
+		if s.MPIFlags != "" {
+			command = append(command, s.MPIFlags)
+		}
+	}
+	command = append(command, "singularity", "exec", imagePath)
+	for _, c := range cm.Command {
+		command = append(command, common.EscapeSpeciaCharacters(c))
+	}
+	return strings.Join(command, " ")
+}
+
+// FilterEnvironment returns the container variables set in the job. The variables of Kubernetes and
+// the cluster and job settings are not set.
+func FilterEnvironment(cm *store.ContainerMetadata) map[string]string {
+	jobEnv := make(map[string]string)
+	for k, v := range cm.Environment {
+		if strings.HasPrefix(k, "KUBERNETES_") {
+			continue
+		}
+		if strings.HasPrefix(k, "CLUSTER_") {
+			continue
+		}
+		if strings.HasPrefix(k, "JOB_") {
+			continue
+		}
+		if strings.EqualFold(k, "MPI_FLAGS") {
+			continue
+		}
+		jobEnv[k] = v
+	}
+	return jobEnv
+}
+
+// ExportEnvironment returns the export lines of the variables, sorted so the scripts are stable
+func ExportEnvironment(env map[string]string) []string {
+	var lines []string
+	for key, value := range env {
+		lines = append(lines, fmt.Sprintf("export %s=\"%s\"", key, value))
+	}
+	sort.Strings(lines)
+	return lines
+}
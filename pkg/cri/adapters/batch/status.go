@@ -0,0 +1,66 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"multi-cri/pkg/cri/store"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Reasons of the exited containers
+const (
+	ReasonCompleted = "Completed"
+	ReasonError     = "Error"
+	// ReasonCannotRun is used when the scheduler fails to run the job
+	ReasonCannotRun = "ContainerCannotRun"
+	// ReasonKilled is used when the job is cancelled or killed by a signal
+	ReasonKilled = "Killed"
+	// ReasonOOMKilled and ReasonDeadlineExceeded are used when the job exceeds its memory or time limit
+	ReasonOOMKilled        = "OOMKilled"
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+)
+
+// JobStatus is the status of a job, translated to the container status
+type JobStatus struct {
+	State    runtimeApi.ContainerState
+	ExitCode int
+	Reason   string
+	// StartedAt and FinishedAt are in nanoseconds, 0 when they are unknown
+	StartedAt  int64
+	FinishedAt int64
+}
+
+// Apply sets the status in the container. Unknown times are not changed.
+func (s *JobStatus) Apply(cm *store.ContainerMetadata) {
+	cm.State = s.State
+	if s.StartedAt != 0 {
+		cm.StartedAt = s.StartedAt
+	}
+	if s.State != runtimeApi.ContainerState_CONTAINER_EXITED {
+		return
+	}
+	cm.ExitCode = s.ExitCode
+	cm.Reason = s.Reason
+	if cm.Reason == "" {
+		cm.Reason = ReasonCompleted
+		if s.ExitCode != 0 {
+			cm.Reason = ReasonError
+		}
+	}
+	if s.FinishedAt != 0 {
+		cm.FinishedAt = s.FinishedAt
+	}
+}
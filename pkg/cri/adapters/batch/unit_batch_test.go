@@ -0,0 +1,162 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package batch
+
+import (
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the job settings are read from the container environment
+func TestUnitJobSettings(t *testing.T) {
+	cm := &store.ContainerMetadata{
+		Name:    "test",
+		Command: []string{"echo", "(hello)"},
+		Environment: map[string]string{
+			"JOB_QUEUE":     "batch",
+			"JOB_GPU":       "gpu:tesla:2",
+			"JOB_NUM_NODES": "3",
+			"JOB_NUM_CORES": "8",
+			"MPI_VERSION":   "3",
+			"MPI_FLAGS":     "-np 8",
+			"CLUSTER_HOST":  "host",
+			"VARIABLE":      "value",
+		},
+	}
+	settings := ParseJobSettings(cm)
+	if settings.Queue != "batch" || settings.GPUCount() != 2 || settings.NodeCount() != 3 ||
+		settings.CoresPerNodeCount() != 3 || settings.CoreCount() != 8 {
+		t.Errorf("Job settings wrong: %+v", settings)
+	}
+	if command := settings.Command(cm, "image.sif"); command != `mpirun -np 8 singularity exec image.sif echo \(hello\)` {
+		t.Errorf("Job command wrong: %s", command)
+	}
+	settings.MPIFlags = ""
+	if command := settings.Command(cm, "image.sif"); command != `mpirun singularity exec image.sif echo \(hello\)` {
+		t.Errorf("Job command without MPI flags wrong: %s", command)
+	}
+	env := FilterEnvironment(cm)
+	if len(env) != 2 || env["VARIABLE"] != "value" || env["MPI_VERSION"] != "3" {
+		t.Errorf("Job environment wrong: %v", env)
+	}
+	if (JobSettings{}).GPUCount() != 0 || (JobSettings{}).CoreCount() != 0 {
+		t.Errorf("Empty settings should not request resources")
+	}
+}
+
+//Test the annotations of the container override the annotations of its pod, which override the environment
+func TestUnitJobSettingAnnotations(t *testing.T) {
+	cm := &store.ContainerMetadata{
+		Config: runtimeApi.ContainerConfig{Annotations: map[string]string{
+			JobAnnotationPrefix + "partition": "gpu",
+		}},
+		PodSandbox: store.SandboxMetadata{Config: runtimeApi.PodSandboxConfig{Annotations: map[string]string{
+			JobAnnotationPrefix + "partition": "cpu",
+			JobAnnotationPrefix + "time":      "1:00:00",
+			JobAnnotationPrefix + "account":   "physics",
+		}}},
+		Environment: map[string]string{"JOB_QUEUE": "batch", "JOB_TIME_LIMIT": "30", "JOB_QOS": "high", "JOB_GPU": "gpu:1"},
+	}
+	settings := ParseJobSettings(cm)
+	if settings.Queue != "gpu" || settings.TimeLimit != "1:00:00" || settings.Account != "physics" ||
+		settings.QOS != "high" || settings.GPU != "gpu:1" || settings.Constraint != "" {
+		t.Errorf("Job settings wrong: %+v", settings)
+	}
+	for setting, expected := range map[JobSetting]string{
+		PartitionSetting:  "annotation multicri.atrio.io/partition of the container",
+		TimeSetting:       "annotation multicri.atrio.io/time of the pod",
+		QOSSetting:        "environment variable JOB_QOS",
+		ConstraintSetting: "",
+	} {
+		if _, source := setting.Lookup(cm); source != expected {
+			t.Errorf("Wrong source of %s: %q", setting.Annotation, source)
+		}
+	}
+}
+
+//Test the Slurm time limits are parsed in seconds, and translated to walltimes
+func TestUnitParseTimeLimit(t *testing.T) {
+	for limit, expected := range map[string]int{
+		"30":         1800,
+		"30:30":      1830,
+		"2:00:00":    7200,
+		"1-12":       129600,
+		"1-00:00:01": 86401,
+		"unlimited":  TimeLimitUnlimited,
+	} {
+		if seconds, err := ParseTimeLimit(limit); err != nil || seconds != expected {
+			t.Errorf("Time limit %s should be %d seconds: %d %v", limit, expected, seconds, err)
+		}
+	}
+	for _, limit := range []string{"", "0", "1:2:3:4", "1h"} {
+		if _, err := ParseTimeLimit(limit); err == nil {
+			t.Errorf("Time limit %q should be wrong", limit)
+		}
+	}
+	if settings := (JobSettings{TimeLimit: "1-12:00:30"}); Walltime(settings.TimeLimitSeconds()) != "36:00:30" {
+		t.Errorf("Wrong walltime of %s: %s", settings.TimeLimit, Walltime(settings.TimeLimitSeconds()))
+	}
+	if (JobSettings{}).TimeLimitSeconds() != 0 {
+		t.Errorf("Jobs without time limit should have no walltime")
+	}
+}
+
+//Test the job settings are validated with the rules of the scheduler, which rejects the settings it does not
+//support and the unknown annotations of the namespace
+func TestUnitValidateJobSettings(t *testing.T) {
+	rules := []JobSettingRule{QueueRule, NodesRule, CoresRule, CustomConfigRule}
+	newContainer := func(annotations, pod, env map[string]string) *store.ContainerMetadata {
+		return &store.ContainerMetadata{Name: "compute", Config: runtimeApi.ContainerConfig{Annotations: annotations},
+			PodSandbox: store.SandboxMetadata{Config: runtimeApi.PodSandboxConfig{Annotations: pod}}, Environment: env}
+	}
+	valid := newContainer(map[string]string{
+		JobAnnotationPrefix + "partition":     "batch@server",
+		JobAnnotationPrefix + "nodes":         "2",
+		JobAnnotationPrefix + "custom-config": "#PBS -m abe",
+	}, map[string]string{JobAnnotationPrefix + "cores": "8", JobAnnotationPrefix + "depends-on.compute": "setup"},
+		map[string]string{"JOB_TIME_LIMIT": ""})
+	if err := ValidateJobSettings(valid, "PBS", rules, JobAnnotationPrefix+"depends-on."); err != nil {
+		t.Errorf("Job settings should be valid: %v", err)
+	}
+	if settings := ParseJobSettings(valid); settings.Nodes != "2" || settings.Cores != "8" || settings.CustomConfig != "#PBS -m abe" {
+		t.Errorf("Job settings of the annotations wrong: %+v", settings)
+	}
+	for _, c := range []struct {
+		cm       *store.ContainerMetadata
+		expected string
+	}{
+		{newContainer(map[string]string{JobAnnotationPrefix + "nodes": "0"}, nil, nil),
+			`Wrong annotation multicri.atrio.io/nodes of the container of container compute: "0" is not a positive number`},
+		{newContainer(nil, nil, map[string]string{"JOB_NUM_CORES": "many"}),
+			`Wrong environment variable JOB_NUM_CORES of container compute: "many" is not a positive number`},
+		{newContainer(nil, map[string]string{JobAnnotationPrefix + "qos": "high"}, nil),
+			"Unsupported annotation multicri.atrio.io/qos of the pod of container compute: PBS does not support it"},
+		{newContainer(nil, nil, map[string]string{"JOB_ACCOUNT": "physics"}),
+			"Unsupported environment variable JOB_ACCOUNT of container compute: PBS does not support it"},
+		{newContainer(map[string]string{JobAnnotationPrefix + "partiton": "batch"}, nil, nil),
+			"Unknown annotation multicri.atrio.io/partiton of the container of container compute: it is not a job setting of PBS"},
+		{newContainer(nil, map[string]string{JobAnnotationPrefix + "hetjob": "a,b"}, nil),
+			"Unknown annotation multicri.atrio.io/hetjob of the pod of container compute: it is not a job setting of PBS"},
+	} {
+		err := ValidateJobSettings(c.cm, "PBS", rules, JobAnnotationPrefix+"depends-on.")
+		if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument || err.Error() != c.expected {
+			t.Errorf("Job settings should be the invalid argument %q: %v", c.expected, err)
+		}
+	}
+}
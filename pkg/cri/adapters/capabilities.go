@@ -0,0 +1,62 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapters
+
+// Capabilities lists the optional operations supported by an adapter. The runtime rejects the
+// unsupported ones with codes.Unimplemented before calling the adapter.
+type Capabilities struct {
+	ExecSync                 bool `json:"execSync"`
+	Exec                     bool `json:"exec"`
+	Attach                   bool `json:"attach"`
+	PortForward              bool `json:"portForward"`
+	ReopenContainerLog       bool `json:"reopenContainerLog"`
+	UpdateContainerResources bool `json:"updateContainerResources"`
+	ImageFsInfo              bool `json:"imageFsInfo"`
+}
+
+// AllCapabilities returns the capabilities of an adapter which supports every operation
+func AllCapabilities() Capabilities {
+	return Capabilities{
+		ExecSync:                 true,
+		Exec:                     true,
+		Attach:                   true,
+		PortForward:              true,
+		ReopenContainerLog:       true,
+		UpdateContainerResources: true,
+		ImageFsInfo:              true,
+	}
+}
+
+// Supports returns whether the operation, named after the AdapterInterface method, is supported.
+// The operations which are not optional are always supported.
+func (c Capabilities) Supports(operation string) bool {
+	switch operation {
+	case "ExecSync":
+		return c.ExecSync
+	case "Exec":
+		return c.Exec
+	case "Attach":
+		return c.Attach
+	case "PortForward":
+		return c.PortForward
+	case "ReopenContainerLog":
+		return c.ReopenContainerLog
+	case "UpdateContainerResources":
+		return c.UpdateContainerResources
+	case "ImageFsInfo":
+		return c.ImageFsInfo
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package condor implements the adapter of HTCondor pools. Containers are submitted as vanilla
+// universe jobs with condor_submit through SSH. The image and the container files are moved with the
+// HTCondor file transfer, so the pool does not need the NFS volume of the Slurm adapter.
+package condor
+
+import (
+	"fmt"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	CONDORADAPTERVERSION = "0.1.0"
+	CONDORNAME           = "Adapter HTCondor"
+	MOUNTHPATH           = "multi-cri"
+)
+
+type CondorAdapter struct {
+	// The images are pulled in the submit host, from which they are transferred to the execute nodes
+	batch.Base
+	ImageRemoteMount string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "condor",
+		Description: "Submits containers as vanilla universe jobs to HTCondor pools",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the submit host, relative to $HOME (CRI_CONDOR_MOUNT_PATH)",
+			"image-remote-mount": "Path of the submit host in which the images are built (CRI_CONDOR_IMAGE_REMOTE_MOUNT)",
+		},
+		Validate: validateConfig,
+		New:      NewCondorAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	return nil
+}
+
+// NewCondorAdapter creates the HTCondor adapter configured with the CRI_CONDOR_* environment variables
+func NewCondorAdapter() (adapters.AdapterInterface, error) {
+	return NewCondorAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewCondorAdapterWithConfig creates the HTCondor adapter. Options not set in the config
+// are read from the CRI_CONDOR_* environment variables. Images are always built in the submit host.
+func NewCondorAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	q := MOUNTHPATH
+	remoteDefault := ""
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_CONDOR_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_CONDOR_MOUNT_PATH", &q))
+	build, err := builder.NewImageBuilderInCluster(mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return CondorAdapter{Base: batch.NewBase("HTCondor", mountP, build),
+		ImageRemoteMount: imageRemoteMountPath}, nil
+}
+
+func (c CondorAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           CONDORADAPTERVERSION,
+		RuntimeName:       CONDORNAME,
+		RuntimeVersion:    CONDORADAPTERVERSION,
+		RuntimeApiVersion: CONDORADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted
+func (c CondorAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ImageFsInfo: true}
+}
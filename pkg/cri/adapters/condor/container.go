@@ -0,0 +1,138 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package condor
+
+import (
+	"fmt"
+	"path"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// jobSettingRules are the job settings which HTCondor supports
+var jobSettingRules = []batch.JobSettingRule{batch.GresRule, batch.NodesRule, batch.CoresRule,
+	batch.CoresPerNodeRule, batch.CustomConfigRule}
+
+func (c CondorAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	klog.Infof("Creating container path in server")
+	if err := batch.ValidateJobSettings(cm, c.Name, jobSettingRules); err != nil {
+		return err
+	}
+	batch.SetupContainerPaths(cm, c.MountPath)
+	client, err := batch.NewClient(cm, condorErrors)
+	if err != nil {
+		return err
+	}
+	//Ensure container path exists in the submit host
+	if err := client.MakeDir(ctx, cm.Extra["RMPath"]); err != nil {
+		return err
+	}
+	//Pull image in the submit host
+	if err := c.Builder.PullImageInCluster(ctx, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container path in server with id %s", cm.ID)
+	return nil
+}
+
+func (c CondorAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, condorErrors)
+	if err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	imagePath := builder.GetRMImagePath(cm, c.MountPath, c.ImageRemoteMount)
+	// The image is transferred to the scratch directory of the job
+	executable := []string{"#!/bin/bash", settings.Command(cm, "./"+path.Base(imagePath))}
+	description := submitDescription(cm, settings, imagePath)
+	if err := client.WriteFile(ctx, batch.RMFile(cm, SubmitFile), description); err != nil {
+		return err
+	}
+	response, err := client.Submit(ctx, cm, settings, executable, fmt.Sprintf("condor_submit %s", SubmitFile))
+	if err != nil {
+		return err
+	}
+	cm.Pid, err = parseClusterId(response)
+	return err
+}
+
+func (c CondorAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, condorErrors)
+	if err != nil {
+		return err
+	}
+	_, err = client.Run(ctx, fmt.Sprintf("condor_rm %d", cm.Pid))
+	if adapters.IsNotFound(err) {
+		// The job already left the queue
+		return nil
+	}
+	return err
+}
+
+func (c CondorAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Pid == 0 {
+		return nil
+	}
+	client, err := batch.NewClient(cm, condorErrors)
+	if err != nil {
+		return err
+	}
+	ad, err := jobAd(ctx, client, cm.Pid)
+	if err != nil {
+		return err
+	}
+	status := ad.status()
+	status.Apply(cm)
+	if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+		if ad.HoldReason != "" {
+			batch.LogString(cm.LogFile, fmt.Sprintf("Job held: %s\n", ad.HoldReason))
+		}
+		client.CopyOutput(ctx, cm)
+	}
+	return nil
+}
+
+// jobAd queries the job with condor_q, and with condor_history once it left the queue
+func jobAd(ctx context.Context, client *batch.Client, cluster int) (*classAd, error) {
+	for _, command := range []string{"condor_q", "condor_history"} {
+		out, err := client.Output(ctx, fmt.Sprintf("%s -json -attributes %s %d", command, classAdAttributes, cluster))
+		if err != nil {
+			return nil, batch.WrapError(err, "Retrieve job info fails %s ", err)
+		}
+		ad, err := parseClassAds(out)
+		if err != nil {
+			return nil, err
+		}
+		if ad != nil {
+			return ad, nil
+		}
+	}
+	return nil, adapters.NotFoundError("Job %d not found", cluster)
+}
+
+func (c CondorAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("HTCONDOR: ReopenContainerLog not implemented")
+}
+
+func (c CondorAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("HTCONDOR: UpdateContainerResources not implemented")
+}
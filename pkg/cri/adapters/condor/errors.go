@@ -0,0 +1,34 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package condor
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// condorErrors classifies the HTCondor command errors by their message
+var condorErrors = []batch.ErrorPattern{
+	{"error in submit file", adapters.KindInvalidArgument},
+	{"parse error", adapters.KindInvalidArgument},
+	{"couldn't find/remove all jobs", adapters.KindNotFound},
+	{"does not exist", adapters.KindNotFound},
+	{"not found", adapters.KindNotFound},
+	{"not authorized", adapters.KindPermissionDenied},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"failed to connect", adapters.KindUnavailable},
+	{"can't find address", adapters.KindUnavailable},
+	{"cannot connect", adapters.KindUnavailable},
+}
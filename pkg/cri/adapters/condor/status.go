@@ -0,0 +1,122 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package condor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// HTCondor JobStatus codes
+const (
+	jobIdle                = 1
+	jobRunning             = 2
+	jobRemoved             = 3
+	jobCompleted           = 4
+	jobHeld                = 5
+	jobTransferringOutput  = 6
+	jobSuspended           = 7
+	classAdAttributes      = "JobStatus,ExitCode,ExitBySignal,ExitSignal,HoldReason,JobCurrentStartDate,CompletionDate"
+	signalExitCode         = 128
+	heldExitCode           = 1
+	holdReasonMemory       = "memory"
+	holdReasonTimeExceeded = "time exceeded"
+)
+
+// classAd contains the job attributes queried by the adapter. Dates are Unix timestamps.
+type classAd struct {
+	JobStatus           int
+	ExitCode            int
+	ExitBySignal        bool
+	ExitSignal          int
+	HoldReason          string
+	JobCurrentStartDate int64
+	CompletionDate      int64
+}
+
+// parseClassAds parses the output of condor_q -json or condor_history -json. It returns nil
+// when the output has no jobs, which the commands print as an empty output.
+func parseClassAds(output string) (*classAd, error) {
+	if strings.TrimSpace(output) == "" {
+		return nil, nil
+	}
+	var ads []classAd
+	if err := json.Unmarshal([]byte(output), &ads); err != nil {
+		return nil, fmt.Errorf("Job ClassAd cannot be parsed: %v", err)
+	}
+	if len(ads) == 0 {
+		return nil, nil
+	}
+	return &ads[0], nil
+}
+
+// status maps the job status to the container status. Held jobs are not released by the adapter,
+// so they are exited with the reason derived from HoldReason.
+func (ad classAd) status() *batch.JobStatus {
+	status := &batch.JobStatus{
+		StartedAt:  unixNano(ad.JobCurrentStartDate),
+		FinishedAt: unixNano(ad.CompletionDate),
+	}
+	switch ad.JobStatus {
+	case jobIdle:
+		status.State = runtimeApi.ContainerState_CONTAINER_CREATED
+	case jobRunning, jobTransferringOutput, jobSuspended:
+		status.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	case jobRemoved:
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = signalExitCode + 15
+		status.Reason = batch.ReasonKilled
+	case jobCompleted:
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = ad.ExitCode
+		if ad.ExitBySignal {
+			status.ExitCode = signalExitCode + ad.ExitSignal
+			status.Reason = batch.ReasonKilled
+		}
+	case jobHeld:
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = heldExitCode
+		status.Reason = holdReason(ad.HoldReason)
+	default:
+		status.State = runtimeApi.ContainerState_CONTAINER_UNKNOWN
+	}
+	return status
+}
+
+// holdReason maps the HoldReason of a held job to the container reason
+func holdReason(reason string) string {
+	reason = strings.ToLower(reason)
+	switch {
+	case strings.Contains(reason, holdReasonMemory):
+		return batch.ReasonOOMKilled
+	case strings.Contains(reason, holdReasonTimeExceeded):
+		return batch.ReasonDeadlineExceeded
+	default:
+		return batch.ReasonCannotRun
+	}
+}
+
+func unixNano(seconds int64) int64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Unix(seconds, 0).UnixNano()
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package condor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+)
+
+const (
+	// SubmitFile is the submit description of the job
+	SubmitFile = "submit.sub"
+	// EventLogFile is the user log written by HTCondor with the job events
+	EventLogFile = "condor.log"
+)
+
+var clusterIdRegex = regexp.MustCompile(`submitted to cluster (\d+)`)
+
+// submitDescription renders the submit description of the container. The wrapper script, batch.sh,
+// is the executable of the job. The image and the JOB_INPUT_FILES are transferred to the execute node,
+// and the JOB_OUTPUT_FILES are transferred back when the job exits. JOB_QUEUE is not supported
+// and the vanilla universe runs the job in a single slot, which requests all the cores of the job.
+func submitDescription(cm *store.ContainerMetadata, settings batch.JobSettings, imagePath string) []string {
+	inputFiles := []string{strings.Replace(imagePath, "$HOME/", "$ENV(HOME)/", 1)}
+	if files := cm.Environment["JOB_INPUT_FILES"]; files != "" {
+		inputFiles = append(inputFiles, files)
+	}
+	lines := []string{
+		"universe = vanilla",
+		fmt.Sprintf("executable = %s", batch.BatchScript),
+		fmt.Sprintf("output = %s", batch.StdoutFile),
+		fmt.Sprintf("error = %s", batch.SterrFile),
+		fmt.Sprintf("log = %s", EventLogFile),
+		"should_transfer_files = YES",
+		"when_to_transfer_output = ON_EXIT",
+		fmt.Sprintf("transfer_input_files = %s", strings.Join(inputFiles, ",")),
+	}
+	if files := cm.Environment["JOB_OUTPUT_FILES"]; files != "" {
+		lines = append(lines, fmt.Sprintf("transfer_output_files = %s", files))
+	}
+	if env := environment(batch.FilterEnvironment(cm)); env != "" {
+		lines = append(lines, fmt.Sprintf("environment = %s", env))
+	}
+	if cpus := settings.CoreCount(); cpus > 0 {
+		lines = append(lines, fmt.Sprintf("request_cpus = %d", cpus))
+	}
+	if gpus := settings.GPUCount(); gpus > 0 {
+		lines = append(lines, fmt.Sprintf("request_gpus = %d", gpus))
+	}
+	if settings.Name != "" {
+		lines = append(lines, fmt.Sprintf("batch_name = %s", settings.Name))
+	}
+	if settings.CustomConfig != "" {
+		lines = append(lines, settings.CustomConfig)
+	}
+	return append(lines, "queue")
+}
+
+// environment renders the environment in the new submit syntax: the whole value is double quoted,
+// the values are single quoted and the quotes are escaped by repeating them
+func environment(env map[string]string) string {
+	if len(env) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	vars := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Replace(env[key], "'", "''", -1)
+		value = strings.Replace(value, "\"", "\"\"", -1)
+		vars = append(vars, fmt.Sprintf("%s='%s'", key, value))
+	}
+	return fmt.Sprintf("\"%s\"", strings.Join(vars, " "))
+}
+
+// parseClusterId parses the cluster id from the condor_submit output,
+// e.g. "1 job(s) submitted to cluster 42."
+func parseClusterId(response string) (int, error) {
+	match := clusterIdRegex.FindStringSubmatch(response)
+	if match == nil {
+		return 0, fmt.Errorf("Not submitted batch job id found: %s ", response)
+	}
+	return strconv.Atoi(match[1])
+}
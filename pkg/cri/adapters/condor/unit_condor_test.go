@@ -0,0 +1,206 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package condor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the submit description transfers the files and sets the environment and resources
+func TestUnitSubmitDescription(t *testing.T) {
+	cm := &store.ContainerMetadata{
+		Environment: map[string]string{
+			"JOB_NUM_CORES":    "4",
+			"JOB_GPU":          "gpu:1",
+			"JOB_INPUT_FILES":  "data.csv",
+			"JOB_OUTPUT_FILES": "results",
+			"CLUSTER_HOST":     "host",
+			"MESSAGE":          `it's "quoted"`,
+			"VARIABLE":         "value",
+		},
+	}
+	settings := batch.ParseJobSettings(cm)
+	description := strings.Join(submitDescription(cm, settings, "$HOME/multi-cri/.images/image.sif"), "\n")
+	for _, line := range []string{
+		"executable = batch.sh",
+		"transfer_input_files = $ENV(HOME)/multi-cri/.images/image.sif,data.csv",
+		"transfer_output_files = results",
+		`environment = "MESSAGE='it''s ""quoted""' VARIABLE='value'"`,
+		"request_cpus = 4",
+		"request_gpus = 1",
+	} {
+		if !strings.Contains(description, line) {
+			t.Errorf("Submit description should contain %q:\n%s", line, description)
+		}
+	}
+	if !strings.HasSuffix(description, "\nqueue") {
+		t.Errorf("Submit description should end with queue:\n%s", description)
+	}
+}
+
+//Test condor_submit cluster ids are parsed
+func TestUnitParseClusterId(t *testing.T) {
+	if pid, err := parseClusterId("Submitting job(s).\n1 job(s) submitted to cluster 42.\n"); err != nil || pid != 42 {
+		t.Errorf("Cluster id should be 42: %d %v", pid, err)
+	}
+	if _, err := parseClusterId("ERROR: on Line 3 of submit file"); err == nil {
+		t.Errorf("Wrong cluster ids should fail")
+	}
+}
+
+//Test the job ClassAds are mapped to the container states
+func TestUnitJobStatus(t *testing.T) {
+	for _, c := range []struct {
+		output   string
+		state    runtimeApi.ContainerState
+		exitCode int
+		reason   string
+	}{
+		{`[{"JobStatus": 1}]`, runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{`[{"JobStatus": 2, "JobCurrentStartDate": 1571047200}]`, runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{`[{"JobStatus": 4, "ExitCode": 0, "ExitBySignal": false}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 0, ""},
+		{`[{"JobStatus": 4, "ExitCode": 3, "ExitBySignal": false}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 3, ""},
+		{`[{"JobStatus": 4, "ExitBySignal": true, "ExitSignal": 9}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 137, batch.ReasonKilled},
+		{`[{"JobStatus": 3}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonKilled},
+		{`[{"JobStatus": 5, "HoldReason": "Job has gone over memory limit of 2048 megabytes."}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonOOMKilled},
+		{`[{"JobStatus": 5, "HoldReason": "Error from slot1@node: Failed to execute"}]`, runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonCannotRun},
+	} {
+		ad, err := parseClassAds(c.output)
+		if err != nil || ad == nil {
+			t.Fatalf("ClassAd %s should be parsed: %v", c.output, err)
+		}
+		status := ad.status()
+		if status.State != c.state || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("Status of %s wrong: %+v", c.output, status)
+		}
+	}
+	if ad, _ := parseClassAds(`[{"JobStatus": 2, "JobCurrentStartDate": 1571047200}]`); ad.status().StartedAt != 1571047200*1e9 {
+		t.Errorf("Start date should be converted to nanoseconds")
+	}
+	if ad, err := parseClassAds("\n"); ad != nil || err != nil {
+		t.Errorf("Empty output should have no jobs: %v %v", ad, err)
+	}
+}
+
+//Test HTCondor errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		kind     adapters.ErrorKind
+	}{
+		{"ERROR: Failed to connect to local queue manager", adapters.KindUnavailable},
+		{"Couldn't find/remove all jobs in cluster 42", adapters.KindNotFound},
+		{"ERROR: on Line 3 of submit file: Parse error in expression", adapters.KindInvalidArgument},
+		{"ERROR: permission denied to remove job", adapters.KindPermissionDenied},
+	} {
+		err := batch.ClassifyError(condorErrors, c.response, fmt.Errorf("exit status 1"))
+		if kind := adapters.ErrorKindOf(err); kind != c.kind {
+			t.Errorf("Error %q should be %q instead of %q", c.response, c.kind, kind)
+		}
+	}
+}
+
+// startCluster starts a fake cluster with the HTCondor commands of the adapter: condor_submit submits the
+// executable of the submit description with its output and error files, condor_rm removes the job,
+// condor_q shows it while it is in the queue and condor_history once it left the queue
+func startCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.AddBuiltin("condor_submit", func(s fakecluster.Session, args []string) int {
+		f, err := os.Open(filepath.Join(s.Dir, args[0]))
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "ERROR: Can't open \"%s\" with flags 00 (No such file or directory)\n", args[0])
+			return 1
+		}
+		defer f.Close()
+		job := fakecluster.Job{WorkDir: s.Dir}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			parts := strings.SplitN(scanner.Text(), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value := strings.TrimSpace(parts[1])
+			switch strings.TrimSpace(parts[0]) {
+			case "executable":
+				job.Script = filepath.Join(s.Dir, value)
+			case "output":
+				job.Stdout = value
+			case "error":
+				job.Stderr = value
+			case "batch_name":
+				job.Name = value
+			}
+		}
+		fmt.Fprintf(s.Stdout, "Submitting job(s).\n1 job(s) submitted to cluster %d.\n", cluster.Submit(job, s.Env))
+		return 0
+	})
+	cluster.AddBuiltin("condor_rm", cluster.CancelCommand(fakecluster.Scheduler{
+		Unknown: "Couldn't find/remove all jobs in cluster %s\n", UnknownStatus: 1}))
+	// show returns condor_q, which shows the queued jobs, or condor_history, which shows the finished ones
+	show := func(queued bool) fakecluster.Builtin {
+		return func(s fakecluster.Session, args []string) int {
+			id, _ := strconv.Atoi(args[len(args)-1])
+			job, ok := cluster.Job(id)
+			if !ok || queued == !job.EndTime.IsZero() {
+				return 0
+			}
+			ad := classAd{JobStatus: jobIdle}
+			if !job.StartTime.IsZero() {
+				ad.JobStatus = jobRunning
+				ad.JobCurrentStartDate = job.StartTime.Unix()
+			}
+			if !job.EndTime.IsZero() {
+				ad.JobStatus = jobCompleted
+				ad.ExitCode = job.ExitCode
+				ad.CompletionDate = job.EndTime.Unix()
+			}
+			if job.State == fakecluster.StateCancelled {
+				ad.JobStatus = jobRemoved
+			}
+			json.NewEncoder(s.Stdout).Encode([]classAd{ad})
+			return 0
+		}
+	}
+	cluster.AddBuiltin("condor_q", show(true))
+	cluster.AddBuiltin("condor_history", show(false))
+	return cluster
+}
+
+//Test the adapter passes the conformance suite against a fake HTCondor pool
+func TestUnitConformance(t *testing.T) {
+	cluster := startCluster(t)
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewCondorAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
@@ -0,0 +1,393 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conformance contains the test suite every adapter must pass. Adapter packages run it from
+// their own tests:
+//
+//	func TestUnitConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Config{New: NewMyAdapter})
+//	}
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultPollInterval = time.Second
+)
+
+// Fixtures contains the metadata passed to the adapter under test
+type Fixtures struct {
+	Sandbox   *store.SandboxMetadata
+	Container *store.ContainerMetadata
+	Image     *store.ImageMetadata
+}
+
+// Config describes the adapter under test
+type Config struct {
+	// New creates the adapter
+	New func() (adapters.AdapterInterface, error)
+	// Fixtures creates the metadata of each test. DefaultFixtures is used when it is nil.
+	// Adapters which need credentials or options in the container environment set them here.
+	Fixtures func(t *testing.T) *Fixtures
+	// StartedStates are the states accepted once the container is started. RUNNING and EXITED
+	// when empty. Batch adapters add CREATED, because queued jobs are reported as created.
+	StartedStates []runtimeApi.ContainerState
+	// StoppedStates are the states accepted once the container is stopped. EXITED when empty.
+	StoppedStates []runtimeApi.ContainerState
+	// Timeout is the time the container has to reach the expected state
+	Timeout time.Duration
+	// PollInterval is the time between ContainerStatus calls while waiting for a state
+	PollInterval time.Duration
+	// Credentials are the variables of the container environment with the credentials of the backend, which
+	// CreateContainer requires. The errors suite creates a container without them when they are set.
+	Credentials []string
+}
+
+// step is a call of the lifecycle and the container states accepted after it
+type step struct {
+	name   string
+	call   func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error
+	states []runtimeApi.ContainerState
+}
+
+// Run runs the conformance suite against the adapter
+func Run(t *testing.T, config Config) {
+	if config.Fixtures == nil {
+		config.Fixtures = DefaultFixtures
+	}
+	if len(config.StartedStates) == 0 {
+		config.StartedStates = []runtimeApi.ContainerState{
+			runtimeApi.ContainerState_CONTAINER_RUNNING,
+			runtimeApi.ContainerState_CONTAINER_EXITED,
+		}
+	}
+	if len(config.StoppedStates) == 0 {
+		config.StoppedStates = []runtimeApi.ContainerState{runtimeApi.ContainerState_CONTAINER_EXITED}
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.PollInterval == 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	t.Run("Lifecycle", func(t *testing.T) { testLifecycle(t, config) })
+	t.Run("Images", func(t *testing.T) { testImages(t, config) })
+	t.Run("Capabilities", func(t *testing.T) { testCapabilities(t, config) })
+	t.Run("Errors", func(t *testing.T) { testErrors(t, config) })
+}
+
+// DefaultFixtures returns a sandbox with a container which runs a short command of the alpine image
+func DefaultFixtures(t *testing.T) *Fixtures {
+	dir, err := ioutil.TempDir("", "multicri-conformance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := fmt.Sprintf("conformance-%d", time.Now().UnixNano())
+	sandbox := &store.SandboxMetadata{ID: id, LogPath: filepath.Join(dir, "logs")}
+	if err := os.MkdirAll(sandbox.LogPath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	image := &store.ImageMetadata{
+		ID:         id,
+		ImageName:  "alpine:latest",
+		RemotePath: "docker://alpine:latest",
+		LocalPath:  filepath.Join(dir, "images"),
+		RepoType:   store.DockerRepositoryImageRepo,
+		PodSandbox: *sandbox,
+	}
+	container := &store.ContainerMetadata{
+		ID:          id,
+		Name:        "conformance",
+		PodSandbox:  *sandbox,
+		Command:     []string{"sleep", "5"},
+		LogFile:     store.GenerateContainerLogPath(sandbox.LogPath, id),
+		Environment: map[string]string{},
+		Extra:       map[string]string{},
+	}
+	return &Fixtures{Sandbox: sandbox, Container: container, Image: image}
+}
+
+// EnvironmentFixtures returns the default fixtures with the variables in the container environment, such as
+// the CLUSTER_* credentials of the batch adapters
+func EnvironmentFixtures(env map[string]string) func(t *testing.T) *Fixtures {
+	return func(t *testing.T) *Fixtures {
+		f := DefaultFixtures(t)
+		for key, value := range env {
+			f.Container.Environment[key] = value
+		}
+		return f
+	}
+}
+
+// FakeClusterConfig returns the configuration of a batch adapter against a fake cluster with the CLUSTER_*
+// credentials, which reports the queued jobs as created
+func FakeClusterConfig(new func() (adapters.AdapterInterface, error), credentials map[string]string) Config {
+	var keys []string
+	for key := range credentials {
+		keys = append(keys, key)
+	}
+	return Config{
+		Credentials: keys,
+		New:         new,
+		Fixtures:    EnvironmentFixtures(credentials),
+		StartedStates: []runtimeApi.ContainerState{
+			runtimeApi.ContainerState_CONTAINER_CREATED,
+			runtimeApi.ContainerState_CONTAINER_RUNNING,
+			runtimeApi.ContainerState_CONTAINER_EXITED,
+		},
+		Timeout:      10 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	}
+}
+
+func newAdapter(t *testing.T, config Config) adapters.AdapterInterface {
+	adapter, err := config.New()
+	if err != nil {
+		t.Fatalf("Adapter can not be created: %v", err)
+	}
+	return adapter
+}
+
+// callContext returns the context of each adapter call
+func callContext(config Config) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), config.Timeout)
+}
+
+func testLifecycle(t *testing.T, config Config) {
+	adapter := newAdapter(t, config)
+	f := config.Fixtures(t)
+	steps := []step{
+		{"RunPodSandbox", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			return a.RunPodSandbox(ctx, f.Sandbox)
+		}, nil},
+		{"PodSandboxStatus", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			return a.PodSandboxStatus(ctx, f.Sandbox)
+		}, nil},
+		{"PullImage", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			f.Container.Image = f.Image
+			return a.PullImage(ctx, f.Image)
+		}, nil},
+		{"CreateContainer", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			// The runtime sets the states, and ContainerStatus updates them with the ones of the backend
+			f.Container.State = runtimeApi.ContainerState_CONTAINER_CREATED
+			return a.CreateContainer(ctx, f.Container)
+		}, []runtimeApi.ContainerState{runtimeApi.ContainerState_CONTAINER_CREATED}},
+		{"StartContainer", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			if err := a.StartContainer(ctx, f.Container); err != nil {
+				return err
+			}
+			f.Container.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+			return nil
+		}, config.StartedStates},
+		{"StopContainer", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			return a.StopContainer(ctx, f.Container)
+		}, config.StoppedStates},
+		{"StopPodSandbox", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			return a.StopPodSandbox(ctx, f.Sandbox)
+		}, nil},
+		{"RemovePodSandbox", func(ctx context.Context, a adapters.AdapterInterface, f *Fixtures) error {
+			return a.RemovePodSandbox(ctx, f.Sandbox)
+		}, nil},
+	}
+	for _, s := range steps {
+		ctx, cancel := callContext(config)
+		err := s.call(ctx, adapter, f)
+		cancel()
+		if err != nil {
+			t.Fatalf("%s fails: %v", s.name, err)
+		}
+		if s.states != nil {
+			waitState(t, config, adapter, f.Container, s.name, s.states)
+		}
+	}
+}
+
+// waitState calls ContainerStatus until the container is in one of the states
+func waitState(t *testing.T, config Config, adapter adapters.AdapterInterface, cm *store.ContainerMetadata,
+	name string, states []runtimeApi.ContainerState) {
+	deadline := time.Now().Add(config.Timeout)
+	for {
+		ctx, cancel := callContext(config)
+		err := adapter.ContainerStatus(ctx, cm)
+		cancel()
+		if err != nil {
+			t.Fatalf("ContainerStatus after %s fails: %v", name, err)
+		}
+		for _, s := range states {
+			if cm.State == s {
+				return
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Container state after %s is %s instead of one of %v", name, cm.State, states)
+		}
+		time.Sleep(config.PollInterval)
+	}
+}
+
+func testImages(t *testing.T, config Config) {
+	adapter := newAdapter(t, config)
+	f := config.Fixtures(t)
+	ctx, cancel := callContext(config)
+	defer cancel()
+	if err := adapter.PullImage(ctx, f.Image); err != nil {
+		t.Fatalf("PullImage fails: %v", err)
+	}
+	if err := adapter.ImageStatus(ctx, f.Image); err != nil {
+		t.Errorf("ImageStatus fails: %v", err)
+	}
+	images := []*runtimeApi.Image{store.ParseToK8sImage(f.Image)}
+	if err := adapter.ListImages(ctx, images); err != nil {
+		t.Errorf("ListImages fails: %v", err)
+	}
+	if err := adapter.RemoveImage(ctx, f.Image); err != nil {
+		t.Errorf("RemoveImage fails: %v", err)
+	}
+}
+
+// testCapabilities checks the unsupported operations are reported as unimplemented
+func testCapabilities(t *testing.T, config Config) {
+	adapter := newAdapter(t, config)
+	f := config.Fixtures(t)
+	capabilities := adapter.Capabilities()
+	calls := map[string]func(ctx context.Context) error{
+		"ExecSync": func(ctx context.Context) error {
+			_, err := adapter.ExecSync(ctx, f.Container, []string{"true"})
+			return err
+		},
+		"Exec": func(ctx context.Context) error {
+			_, err := adapter.Exec(ctx, f.Container, &runtimeApi.ExecRequest{ContainerId: f.Container.ID, Cmd: []string{"true"}})
+			return err
+		},
+		"Attach": func(ctx context.Context) error {
+			_, err := adapter.Attach(ctx, f.Container, &runtimeApi.AttachRequest{ContainerId: f.Container.ID})
+			return err
+		},
+		"ReopenContainerLog": func(ctx context.Context) error {
+			return adapter.ReopenContainerLog(ctx, f.Container)
+		},
+		"UpdateContainerResources": func(ctx context.Context) error {
+			return adapter.UpdateContainerResources(ctx, f.Container)
+		},
+		"ImageFsInfo": func(ctx context.Context) error {
+			_, err := adapter.ImageFsInfo(ctx)
+			return err
+		},
+	}
+	for operation, call := range calls {
+		if capabilities.Supports(operation) {
+			continue
+		}
+		ctx, cancel := callContext(config)
+		err := call(ctx)
+		cancel()
+		if !adapters.IsUnimplemented(err) {
+			t.Errorf("Unsupported %s must fail as unimplemented: %v", operation, err)
+		}
+	}
+}
+
+// unknownPid is the job id of the containers whose job the backend does not know
+const unknownPid = 999999
+
+// testErrors checks the errors of the calls which fail have the kind the runtime translates to their gRPC codes
+func testErrors(t *testing.T, config Config) {
+	adapter := newAdapter(t, config)
+	// create pulls the image of the container and creates it
+	create := func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) {
+		ctx, cancel := callContext(config)
+		defer cancel()
+		if err := a.PullImage(ctx, f.Image); err != nil {
+			t.Fatalf("PullImage fails: %v", err)
+		}
+		f.Container.Image = f.Image
+		f.Container.State = runtimeApi.ContainerState_CONTAINER_CREATED
+		if err := a.CreateContainer(ctx, f.Container); err != nil {
+			t.Fatalf("CreateContainer fails: %v", err)
+		}
+	}
+	// unknown returns the container as running the job which the backend does not know
+	unknown := func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) *store.ContainerMetadata {
+		create(t, a, f)
+		f.Container.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+		f.Container.Pid = unknownPid
+		f.Container.StartedAt = time.Now().UnixNano()
+		return f.Container
+	}
+	notFoundOrNil := func(err error) bool { return err == nil || adapters.IsNotFound(err) }
+	type errorCase struct {
+		name     string
+		call     func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error
+		valid    func(err error) bool
+		expected string
+	}
+	cases := []errorCase{
+		{"PullImage of unknown repository", func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error {
+			f.Image.RepoType = store.UnknownImageRepo
+			ctx, cancel := callContext(config)
+			defer cancel()
+			return a.PullImage(ctx, f.Image)
+		}, func(err error) bool { return adapters.ErrorKindOf(err) == adapters.KindInvalidArgument }, "an invalid argument"},
+		{"StartContainer with cancelled context", func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error {
+			create(t, a, f)
+			cancelled, cancelNow := context.WithCancel(context.Background())
+			cancelNow()
+			return a.StartContainer(cancelled, f.Container)
+		}, func(err error) bool { return errors.Is(err, context.Canceled) }, "the error of the context"},
+		{"ContainerStatus of unknown job", func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error {
+			cm := unknown(t, a, f)
+			ctx, cancel := callContext(config)
+			defer cancel()
+			return a.ContainerStatus(ctx, cm)
+		}, notFoundOrNil, "not found or succeed"},
+		{"StopContainer of unknown job", func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error {
+			cm := unknown(t, a, f)
+			ctx, cancel := callContext(config)
+			defer cancel()
+			return a.StopContainer(ctx, cm)
+		}, notFoundOrNil, "not found or succeed"},
+	}
+	if len(config.Credentials) > 0 {
+		cases = append(cases, errorCase{"CreateContainer without credentials", func(t *testing.T, a adapters.AdapterInterface, f *Fixtures) error {
+			for _, key := range config.Credentials {
+				delete(f.Container.Environment, key)
+			}
+			f.Container.Image = f.Image
+			ctx, cancel := callContext(config)
+			defer cancel()
+			return a.CreateContainer(ctx, f.Container)
+		}, func(err error) bool { return adapters.ErrorKindOf(err) == adapters.KindInvalidArgument }, "an invalid argument"})
+	}
+	for _, c := range cases {
+		err := c.call(t, adapter, config.Fixtures(t))
+		if !c.valid(err) {
+			t.Errorf("%s must be %s: %v", c.name, c.expected, err)
+		}
+	}
+}
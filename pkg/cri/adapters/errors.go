@@ -0,0 +1,112 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapters
+
+import (
+	"fmt"
+)
+
+// ErrorKind classifies the adapter errors, so the runtime returns the proper gRPC status code to the kubelet
+type ErrorKind string
+
+const (
+	// KindUnknown is the kind of the errors which are not classified
+	KindUnknown ErrorKind = ""
+	// KindNotFound is used when the job, container or image does not exist in the backend
+	KindNotFound ErrorKind = "NotFound"
+	// KindUnimplemented is used when the adapter does not support the operation
+	KindUnimplemented ErrorKind = "Unimplemented"
+	// KindUnavailable is used for transient failures, such as an unreachable cluster. The kubelet retries them
+	KindUnavailable ErrorKind = "Unavailable"
+	// KindInvalidArgument is used for configuration errors of the user, such as a bad JOB_QUEUE
+	KindInvalidArgument ErrorKind = "InvalidArgument"
+	// KindPermissionDenied is used when the backend rejects the credentials
+	KindPermissionDenied ErrorKind = "PermissionDenied"
+)
+
+// Error is an adapter error of a known kind
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	// Cause is the wrapped error, such as the error of the context of an aborted command
+	Cause error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// NewError returns an error of that kind. Errors of unknown kind are plain errors
+func NewError(kind ErrorKind, format string, a ...interface{}) error {
+	if kind == KindUnknown {
+		return fmt.Errorf(format, a...)
+	}
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// WrapError classifies the error with that kind, keeping its message
+func WrapError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Message: err.Error(), Cause: err}
+}
+
+// NotFoundError returns a KindNotFound error
+func NotFoundError(format string, a ...interface{}) error {
+	return NewError(KindNotFound, format, a...)
+}
+
+// UnimplementedError returns a KindUnimplemented error
+func UnimplementedError(format string, a ...interface{}) error {
+	return NewError(KindUnimplemented, format, a...)
+}
+
+// UnavailableError returns a KindUnavailable error
+func UnavailableError(format string, a ...interface{}) error {
+	return NewError(KindUnavailable, format, a...)
+}
+
+// InvalidArgumentError returns a KindInvalidArgument error
+func InvalidArgumentError(format string, a ...interface{}) error {
+	return NewError(KindInvalidArgument, format, a...)
+}
+
+// PermissionDeniedError returns a KindPermissionDenied error
+func PermissionDeniedError(format string, a ...interface{}) error {
+	return NewError(KindPermissionDenied, format, a...)
+}
+
+// ErrorKindOf returns the kind of the error, or KindUnknown if it is not classified
+func ErrorKindOf(err error) ErrorKind {
+	if e, ok := err.(*Error); ok {
+		return e.Kind
+	}
+	return KindUnknown
+}
+
+// IsNotFound returns whether the error is a KindNotFound error
+func IsNotFound(err error) bool {
+	return ErrorKindOf(err) == KindNotFound
+}
+
+// IsUnimplemented returns whether the error is a KindUnimplemented error
+func IsUnimplemented(err error) bool {
+	return ErrorKindOf(err) == KindUnimplemented
+}
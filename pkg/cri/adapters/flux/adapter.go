@@ -0,0 +1,105 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flux implements the adapter of Flux Framework instances. Containers are submitted as jobs
+// with flux batch or flux submit through SSH, like in the Slurm adapter, and their times and exit
+// status are read from the job event log.
+package flux
+
+import (
+	"fmt"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	FLUXADAPTERVERSION = "0.1.0"
+	FLUXNAME           = "Adapter Flux"
+	MOUNTHPATH         = "multi-cri"
+)
+
+type FluxAdapter struct {
+	batch.Base
+	ImageRemoteMount string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "flux",
+		Description: "Submits containers as jobs to Flux Framework instances",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the Flux cluster, relative to $HOME (CRI_FLUX_MOUNT_PATH)",
+			"image-remote-mount": "Path in which the images are built (CRI_FLUX_IMAGE_REMOTE_MOUNT)",
+			"build-in-cluster":   "Build images directly in the Flux cluster (CRI_FLUX_BUILD_IN_CLUSTER)",
+		},
+		Validate: validateConfig,
+		New:      NewFluxAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if _, err := config.GetBool("build-in-cluster", false); err != nil {
+		return err
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	return nil
+}
+
+// NewFluxAdapter creates the Flux adapter configured with the CRI_FLUX_* environment variables
+func NewFluxAdapter() (adapters.AdapterInterface, error) {
+	return NewFluxAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewFluxAdapterWithConfig creates the Flux adapter. Options not set in the config
+// are read from the CRI_FLUX_* environment variables
+func NewFluxAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	q := MOUNTHPATH
+	b := false
+	remoteDefault := ""
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_FLUX_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_FLUX_MOUNT_PATH", &q))
+	buildInCluster, err := config.GetBool("build-in-cluster", common.GetBoolEnv("CRI_FLUX_BUILD_IN_CLUSTER", &b))
+	if err != nil {
+		return nil, err
+	}
+	build, err := builder.NewImageBuilder(buildInCluster, mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return FluxAdapter{Base: batch.NewBase("FLUX", mountP, build),
+		ImageRemoteMount: imageRemoteMountPath}, nil
+}
+
+func (f FluxAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           FLUXADAPTERVERSION,
+		RuntimeName:       FLUXNAME,
+		RuntimeVersion:    FLUXADAPTERVERSION,
+		RuntimeApiVersion: FLUXADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted
+func (f FluxAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ImageFsInfo: true}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flux
+
+import (
+	"fmt"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// jobSettingRules are the job settings which Flux supports
+var jobSettingRules = []batch.JobSettingRule{batch.QueueRule, batch.TimeRule, batch.GresRule, batch.NodesRule,
+	batch.CoresRule, batch.CoresPerNodeRule, batch.TasksPerNodeRule, batch.CustomConfigRule}
+
+func (f FluxAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	klog.Infof("Creating container path in server")
+	if err := batch.ValidateJobSettings(cm, f.Name, jobSettingRules); err != nil {
+		return err
+	}
+	batch.SetupContainerPaths(cm, f.MountPath)
+	client, err := batch.NewClient(cm, fluxErrors)
+	if err != nil {
+		return err
+	}
+	//Ensure container path exists in Flux cluster
+	if err := client.MakeDir(ctx, cm.Extra["RMPath"]); err != nil {
+		return err
+	}
+	//Pull image in Flux cluster
+	if err := f.Builder.PullImageInCluster(ctx, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container path in server with id %s", cm.ID)
+	return nil
+}
+
+func (f FluxAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, fluxErrors)
+	if err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	command := settings.Command(cm, builder.GetRMImagePath(cm, f.MountPath, f.ImageRemoteMount))
+	response, err := client.Submit(ctx, cm, settings, batchScript(settings, command), submitCommand(settings))
+	if err != nil {
+		return err
+	}
+	cm.Pid, err = parseJobId(response)
+	return err
+}
+
+func (f FluxAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, fluxErrors)
+	if err != nil {
+		return err
+	}
+	_, err = client.Run(ctx, fmt.Sprintf("flux cancel %d", cm.Pid))
+	if adapters.IsNotFound(err) {
+		// The job already finished
+		return nil
+	}
+	return err
+}
+
+func (f FluxAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Pid == 0 {
+		return nil
+	}
+	client, err := batch.NewClient(cm, fluxErrors)
+	if err != nil {
+		return err
+	}
+	out, err := client.Output(ctx, fmt.Sprintf("flux jobs --json %d", cm.Pid))
+	if err != nil {
+		return batch.WrapError(err, "Retrieve job info fails %s ", err)
+	}
+	state, err := parseJobs(out, cm.Pid)
+	if err != nil {
+		return err
+	}
+	var events []event
+	if jobState(state) != runtimeApi.ContainerState_CONTAINER_CREATED {
+		// The event log has the exact start and finish times, and the exit status
+		out, err := client.Output(ctx, fmt.Sprintf("flux job eventlog --format=json %d", cm.Pid))
+		if err != nil {
+			return batch.WrapError(err, "Retrieve job events fails %s ", err)
+		}
+		if events, err = parseEventlog(out); err != nil {
+			return err
+		}
+	}
+	jobStatus(state, events).Apply(cm)
+	if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+		client.CopyOutput(ctx, cm)
+	}
+	return nil
+}
+
+func (f FluxAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("FLUX: ReopenContainerLog not implemented")
+}
+
+func (f FluxAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("FLUX: UpdateContainerResources not implemented")
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flux
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// fluxErrors classifies the Flux command errors by their message
+var fluxErrors = []batch.ErrorPattern{
+	{"unsatisfiable request", adapters.KindInvalidArgument},
+	{"invalid queue", adapters.KindInvalidArgument},
+	{"unrecognized arguments", adapters.KindInvalidArgument},
+	{"no such job", adapters.KindNotFound},
+	{"unknown job id", adapters.KindNotFound},
+	{"job is inactive", adapters.KindNotFound},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"operation not permitted", adapters.KindPermissionDenied},
+	{"unable to connect to flux", adapters.KindUnavailable},
+	{"flux_open", adapters.KindUnavailable},
+	{"connection refused", adapters.KindUnavailable},
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+const (
+	// directivePrefix marks the submission options in the flux batch scripts
+	directivePrefix = "#flux:"
+	// f58Alphabet is the base58 alphabet of the FLUID job ids in F58 encoding, e.g. "ƒ2Sh6VgRa"
+	f58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// useBatch returns whether the job runs in a new Flux instance with flux batch, as the MPI and multi-node
+// jobs, in which mpirun launches the tasks. Other jobs are a single task submitted with flux submit.
+func useBatch(settings batch.JobSettings) bool {
+	return settings.IsMPI() || settings.NodeCount() > 1
+}
+
+// submitOptions returns the options of flux batch or flux submit, which Flux translates to the jobspec
+// of the job. The JOB_CUSTOM_CONFIG lines are added as is, with or without the #flux: prefix.
+func submitOptions(settings batch.JobSettings) []string {
+	options := []string{
+		fmt.Sprintf("--job-name=%s", settings.Name),
+		fmt.Sprintf("--output=%s", batch.StdoutFile),
+		fmt.Sprintf("--error=%s", batch.SterrFile),
+	}
+	if settings.Queue != "" {
+		options = append(options, fmt.Sprintf("--queue=%s", settings.Queue))
+	}
+	// The time limit is a Flux standard duration, in seconds or inf
+	if seconds := settings.TimeLimitSeconds(); seconds == batch.TimeLimitUnlimited {
+		options = append(options, "--time-limit=inf")
+	} else if seconds > 0 {
+		options = append(options, fmt.Sprintf("--time-limit=%ds", seconds))
+	}
+	gpus := settings.GPUCount()
+	if useBatch(settings) {
+		// The instance has a slot per node, with the cores of the tasks which run in the node
+		nodes := settings.NodeCount()
+		options = append(options, fmt.Sprintf("--nodes=%d", nodes), fmt.Sprintf("--nslots=%d", nodes))
+		cores := settings.CoresPerNodeCount()
+		if tasks := settings.TaskCount(); cores == 0 && tasks > 0 {
+			cores = (tasks + nodes - 1) / nodes
+		}
+		if cores > 0 {
+			options = append(options, fmt.Sprintf("--cores-per-slot=%d", cores))
+		}
+		if gpus > 0 {
+			options = append(options, fmt.Sprintf("--gpus-per-slot=%d", gpus))
+		}
+	} else {
+		options = append(options, "--ntasks=1")
+		if cores := settings.CoreCount(); cores > 0 {
+			options = append(options, fmt.Sprintf("--cores-per-task=%d", cores))
+		}
+		if gpus > 0 {
+			options = append(options, fmt.Sprintf("--gpus-per-task=%d", gpus))
+		}
+	}
+	for _, line := range strings.Split(settings.CustomConfig, "\n") {
+		if line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), directivePrefix)); line != "" {
+			options = append(options, line)
+		}
+	}
+	return options
+}
+
+// batchScript returns the job script. The options are #flux: directives for flux batch, and
+// command line options for flux submit, which does not read the directives.
+func batchScript(settings batch.JobSettings, command string) []string {
+	lines := []string{"#!/bin/bash"}
+	if useBatch(settings) {
+		for _, option := range submitOptions(settings) {
+			lines = append(lines, fmt.Sprintf("%s %s", directivePrefix, option))
+		}
+	}
+	return append(lines, command)
+}
+
+// submitCommand returns the command which submits the batch script
+func submitCommand(settings batch.JobSettings) string {
+	if useBatch(settings) {
+		return fmt.Sprintf("flux batch %s", batch.BatchScript)
+	}
+	return fmt.Sprintf("flux submit %s ./%s", strings.Join(submitOptions(settings), " "), batch.BatchScript)
+}
+
+// parseJobId returns the job id printed by flux batch and flux submit, in decimal or F58 encoding
+func parseJobId(response string) (int, error) {
+	id := strings.TrimSpace(response)
+	if lines := strings.Split(id, "\n"); len(lines) > 1 {
+		id = strings.TrimSpace(lines[len(lines)-1])
+	}
+	if pid, err := strconv.Atoi(id); err == nil && pid > 0 {
+		return pid, nil
+	}
+	if pid, ok := decodeF58(id); ok && pid > 0 {
+		return pid, nil
+	}
+	return 0, fmt.Errorf("Not submitted batch job id found: %s ", response)
+}
+
+// decodeF58 decodes the F58 job ids, prefixed with "ƒ", or with "f" when Flux prints ASCII only
+func decodeF58(id string) (int, bool) {
+	switch {
+	case strings.HasPrefix(id, "ƒ"):
+		id = strings.TrimPrefix(id, "ƒ")
+	case strings.HasPrefix(id, "f"):
+		id = strings.TrimPrefix(id, "f")
+	default:
+		return 0, false
+	}
+	if id == "" {
+		return 0, false
+	}
+	var pid uint64
+	for _, c := range id {
+		digit := strings.IndexRune(f58Alphabet, c)
+		if digit < 0 {
+			return 0, false
+		}
+		pid = pid*uint64(len(f58Alphabet)) + uint64(digit)
+	}
+	return int(pid), true
+}
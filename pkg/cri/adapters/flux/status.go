@@ -0,0 +1,184 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// cancelExitCode is the exit code of the cancelled jobs without exit status, as killed by SIGTERM
+	cancelExitCode = 143
+	// signalExitStatus is added to the signal number in the exit code of the killed jobs
+	signalExitStatus = 128
+)
+
+// fluxJob contains the fields of the flux jobs --json output used by the adapter
+type fluxJob struct {
+	Id    int    `json:"id"`
+	State string `json:"state"`
+}
+
+// parseJobs returns the state of the job in the flux jobs --json output, which is the job object
+// when a single job is listed, and the jobs list in older Flux versions
+func parseJobs(output string, pid int) (string, error) {
+	var out struct {
+		Jobs []fluxJob `json:"jobs"`
+		fluxJob
+	}
+	if err := json.Unmarshal([]byte(output), &out); err != nil {
+		return "", fmt.Errorf("flux jobs output cannot be parsed: %v", err)
+	}
+	for _, job := range append(out.Jobs, out.fluxJob) {
+		if job.Id == pid && job.State != "" {
+			return job.State, nil
+		}
+	}
+	return "", adapters.NotFoundError("Job %d not found in flux jobs output", pid)
+}
+
+// jobState maps the Flux job states to the container states. Jobs waiting for their dependencies,
+// priority or resources are created; running jobs and the ones running their epilog are running.
+func jobState(state string) runtimeApi.ContainerState {
+	switch state {
+	case "NEW", "DEPEND", "PRIORITY", "SCHED":
+		return runtimeApi.ContainerState_CONTAINER_CREATED
+	case "RUN", "CLEANUP":
+		return runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "INACTIVE":
+		return runtimeApi.ContainerState_CONTAINER_EXITED
+	default:
+		return runtimeApi.ContainerState_CONTAINER_UNKNOWN
+	}
+}
+
+// event is an entry of the job event log. The timestamp is kept as a decimal number, so it is
+// converted to nanoseconds without rounding.
+type event struct {
+	Timestamp json.Number `json:"timestamp"`
+	Name      string      `json:"name"`
+	Context   struct {
+		// Status is the wait status of the finish event
+		Status *int `json:"status"`
+		// Type, Severity and Note describe the exception events. Severity 0 exceptions are fatal.
+		Type     string `json:"type"`
+		Severity *int   `json:"severity"`
+		Note     string `json:"note"`
+	} `json:"context"`
+}
+
+// parseEventlog parses the output of flux job eventlog --format=json, an event per line
+func parseEventlog(output string) ([]event, error) {
+	var events []event
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var e event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("flux job eventlog output cannot be parsed: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// jobStatus returns the status of the job in the state, with the start and finish times and the exit
+// status of the event log. Fatal exceptions set the reason of the exited jobs.
+func jobStatus(state string, events []event) *batch.JobStatus {
+	status := &batch.JobStatus{State: jobState(state)}
+	var waitStatus *int
+	var exception *event
+	for i, e := range events {
+		switch e.Name {
+		case "start":
+			status.StartedAt = timestamp(e.Timestamp)
+		case "finish":
+			status.FinishedAt = timestamp(e.Timestamp)
+			waitStatus = e.Context.Status
+		case "exception":
+			if e.Context.Severity != nil && *e.Context.Severity == 0 && exception == nil {
+				exception = &events[i]
+			}
+		}
+	}
+	if status.State != runtimeApi.ContainerState_CONTAINER_EXITED {
+		return status
+	}
+	if waitStatus != nil {
+		setWaitStatus(status, *waitStatus)
+	}
+	if exception == nil {
+		return status
+	}
+	if status.FinishedAt == 0 {
+		status.FinishedAt = timestamp(exception.Timestamp)
+	}
+	switch exception.Context.Type {
+	case "cancel":
+		status.Reason = batch.ReasonKilled
+		if waitStatus == nil {
+			status.ExitCode = cancelExitCode
+		}
+	case "timeout":
+		status.Reason = batch.ReasonDeadlineExceeded
+	case "oom":
+		status.Reason = batch.ReasonOOMKilled
+	default:
+		if status.StartedAt == 0 {
+			status.Reason = batch.ReasonCannotRun
+		}
+	}
+	if status.ExitCode == 0 {
+		status.ExitCode = 1
+	}
+	return status
+}
+
+// setWaitStatus translates the wait status of the job shell, which has the signal of the killed jobs
+// in the low bits and the exit code in the next byte
+func setWaitStatus(status *batch.JobStatus, waitStatus int) {
+	if signal := waitStatus & 0x7f; signal != 0 {
+		status.ExitCode = signalExitStatus + signal
+		status.Reason = batch.ReasonKilled
+		return
+	}
+	status.ExitCode = (waitStatus >> 8) & 0xff
+}
+
+// timestamp converts the event timestamp, in seconds, to nanoseconds. It is 0 when it can not be parsed.
+func timestamp(value json.Number) int64 {
+	parts := strings.SplitN(value.String(), ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	var nanos int64
+	if len(parts) == 2 {
+		fraction := (parts[1] + "000000000")[:9]
+		if nanos, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return 0
+		}
+	}
+	return seconds*1e9 + nanos
+}
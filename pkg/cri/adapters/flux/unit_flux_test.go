@@ -0,0 +1,207 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flux
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test single task jobs are submitted with flux submit and MPI jobs with flux batch
+func TestUnitSubmitCommand(t *testing.T) {
+	settings := batch.JobSettings{Name: "test", Queue: "pdebug", Cores: "4", GPU: "gpu:1", TimeLimit: "1:30:00"}
+	command := submitCommand(settings)
+	for _, option := range []string{"flux submit", "--queue=pdebug", "--time-limit=5400s", "--ntasks=1", "--cores-per-task=4",
+		"--gpus-per-task=1", "./batch.sh"} {
+		if !strings.Contains(command, option) {
+			t.Errorf("Submit command should contain %q: %s", option, command)
+		}
+	}
+	settings = batch.JobSettings{Name: "test", Nodes: "2", MPIVersion: "3", MPIFlags: "-np 8", TimeLimit: "UNLIMITED",
+		CustomConfig: "#flux: --setattr=system.bank=hpc"}
+	if command := submitCommand(settings); command != "flux batch batch.sh" {
+		t.Errorf("MPI jobs should be submitted with flux batch: %s", command)
+	}
+	script := strings.Join(batchScript(settings, "mpirun -np 8 singularity exec image hostname"), "\n")
+	for _, line := range []string{"#flux: --nodes=2", "#flux: --nslots=2", "#flux: --cores-per-slot=4", "#flux: --time-limit=inf",
+		"#flux: --setattr=system.bank=hpc"} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Batch script should contain %q:\n%s", line, script)
+		}
+	}
+}
+
+//Test decimal and F58 job ids are parsed
+func TestUnitParseJobId(t *testing.T) {
+	for response, expected := range map[string]int{"1234567890123\n": 1234567890123, "ƒZRwY92z\n": 1234567890123, "fZRwY92z": 1234567890123} {
+		if pid, err := parseJobId(response); err != nil || pid != expected {
+			t.Errorf("Job id of %q should be %d: %d %v", response, expected, pid, err)
+		}
+	}
+	if _, err := parseJobId("flux-submit: ERROR: unsatisfiable request"); err == nil {
+		t.Errorf("Wrong job ids should fail")
+	}
+}
+
+//Test the job state and the event log are translated to container statuses with exact times
+func TestUnitJobStatus(t *testing.T) {
+	state, err := parseJobs(`{"id": 1234, "state": "INACTIVE", "result": "COMPLETED"}`, 1234)
+	if err != nil || state != "INACTIVE" {
+		t.Fatalf("Job state should be parsed: %s %v", state, err)
+	}
+	if _, err := parseJobs(`{"jobs": []}`, 1234); !adapters.IsNotFound(err) {
+		t.Errorf("Missing jobs should not be found: %v", err)
+	}
+	eventlog := `{"timestamp":1571047200.123456,"name":"submit","context":{"userid":1000,"urgency":16,"flags":0,"version":1}}
+{"timestamp":1571047200.5,"name":"alloc"}
+{"timestamp":1571047201.000001,"name":"start"}
+%s
+{"timestamp":1571047300.9,"name":"clean"}
+`
+	for _, c := range []struct {
+		state    string
+		events   string
+		expected runtimeApi.ContainerState
+		exitCode int
+		reason   string
+	}{
+		{"RUN", "", runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{"INACTIVE", `{"timestamp":1571047260.25,"name":"finish","context":{"status":0}}`, runtimeApi.ContainerState_CONTAINER_EXITED, 0, ""},
+		{"INACTIVE", `{"timestamp":1571047260.25,"name":"finish","context":{"status":768}}`, runtimeApi.ContainerState_CONTAINER_EXITED, 3, ""},
+		{"INACTIVE", `{"timestamp":1571047260.25,"name":"finish","context":{"status":9}}`, runtimeApi.ContainerState_CONTAINER_EXITED, 137, batch.ReasonKilled},
+		{"INACTIVE", `{"timestamp":1571047250.0,"name":"exception","context":{"type":"timeout","severity":0,"note":"resource allocation expired"}}
+{"timestamp":1571047260.25,"name":"finish","context":{"status":15}}`, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonDeadlineExceeded},
+		{"INACTIVE", `{"timestamp":1571047260.25,"name":"exception","context":{"type":"cancel","severity":0,"userid":1000,"note":""}}`, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonKilled},
+	} {
+		events, err := parseEventlog(fmt.Sprintf(eventlog, c.events))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := jobStatus(c.state, events)
+		if status.State != c.expected || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("Status of %s %s wrong: %+v", c.state, c.events, status)
+		}
+		if status.StartedAt != 1571047201000001000 {
+			t.Errorf("Start time should be exact: %d", status.StartedAt)
+		}
+		if c.expected == runtimeApi.ContainerState_CONTAINER_EXITED && status.FinishedAt != 1571047260250000000 {
+			t.Errorf("Finish time should be exact: %d", status.FinishedAt)
+		}
+	}
+	events, _ := parseEventlog(`{"timestamp":1571047200.1,"name":"submit"}
+{"timestamp":1571047200.2,"name":"exception","context":{"type":"alloc","severity":0,"note":"unsatisfiable request"}}`)
+	if status := jobStatus("INACTIVE", events); status.ExitCode != 1 || status.Reason != batch.ReasonCannotRun {
+		t.Errorf("Jobs which did not start should not run: %+v", status)
+	}
+}
+
+//Test Flux errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		kind     adapters.ErrorKind
+	}{
+		{"flux-submit: ERROR: unsatisfiable request", adapters.KindInvalidArgument},
+		{"flux-cancel: ƒZRwY92z: job is inactive", adapters.KindNotFound},
+		{"flux-jobs: ERROR: unknown job id", adapters.KindNotFound},
+		{"flux-jobs: ERROR: Unable to connect to Flux: broker socket not found", adapters.KindUnavailable},
+		{"flux-cancel: ERROR: Operation not permitted", adapters.KindPermissionDenied},
+	} {
+		err := batch.ClassifyError(fluxErrors, c.response, fmt.Errorf("exit status 1"))
+		if kind := adapters.ErrorKindOf(err); kind != c.kind {
+			t.Errorf("Error %q should be %q instead of %q", c.response, c.kind, kind)
+		}
+	}
+}
+
+// startCluster starts a fake cluster with the Flux commands of the adapter: flux submit and flux batch submit
+// the script, flux cancel cancels the job, flux jobs --json shows its state and flux job eventlog its start,
+// finish and cancel events
+func startCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := fakecluster.Scheduler{Directives: directivePrefix, Name: "--job-name", Stdout: "--output", Stderr: "--error",
+		Submitted: "%d\n", Unknown: "flux-cancel: %s: unknown job id\n", UnknownStatus: 1}
+	submit, cancel := cluster.SubmitCommand(scheduler), cluster.CancelCommand(scheduler)
+	cluster.AddBuiltin("flux", func(s fakecluster.Session, args []string) int {
+		if len(args) < 2 {
+			fmt.Fprintf(s.Stderr, "flux: unrecognized arguments %v\n", args)
+			return 1
+		}
+		id, _ := strconv.Atoi(args[len(args)-1])
+		job, found := cluster.Job(id)
+		switch args[0] {
+		case "submit", "batch":
+			return submit(s, args[1:])
+		case "cancel":
+			return cancel(s, args[1:])
+		case "jobs":
+			if !found {
+				fmt.Fprintf(s.Stderr, "flux-jobs: ERROR: unknown job id %d\n", id)
+				return 1
+			}
+			state := "SCHED"
+			if !job.EndTime.IsZero() {
+				state = "INACTIVE"
+			} else if !job.StartTime.IsZero() {
+				state = "RUN"
+			}
+			json.NewEncoder(s.Stdout).Encode(fluxJob{Id: id, State: state})
+		case "job":
+			if !found {
+				fmt.Fprintf(s.Stderr, "flux-job: ERROR: unknown job id %d\n", id)
+				return 1
+			}
+			event := func(at time.Time, name, context string) {
+				fmt.Fprintf(s.Stdout, "{\"timestamp\":%d.%09d,\"name\":\"%s\",\"context\":%s}\n",
+					at.Unix(), at.Nanosecond(), name, context)
+			}
+			event(job.SubmitTime, "submit", "{}")
+			if !job.StartTime.IsZero() {
+				event(job.StartTime, "start", "{}")
+			}
+			switch {
+			case job.State == fakecluster.StateCancelled:
+				event(job.EndTime, "exception", `{"type":"cancel","severity":0,"note":""}`)
+			case !job.EndTime.IsZero():
+				event(job.EndTime, "finish", fmt.Sprintf(`{"status":%d}`, job.ExitCode<<8))
+			}
+		}
+		return 0
+	})
+	return cluster
+}
+
+//Test the adapter passes the conformance suite against a fake Flux instance
+func TestUnitConformance(t *testing.T) {
+	cluster := startCluster(t)
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewFluxAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local implements the adapter which runs the containers in the CRI node with Singularity or
+// Apptainer, for development and edge nodes. Each container is a Singularity instance joined to the
+// network namespace of its sandbox, in which the container command and the exec commands are executed.
+// Unlike the batch adapters, it supports exec, attach and port forwarding.
+package local
+
+import (
+	"sync"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/common/cmd"
+	"multi-cri/pkg/cri/network"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	LOCALADAPTERVERSION = "0.1.0"
+	LOCALNAME           = "Adapter Local Singularity"
+	// instancePrefix is prepended to the container ids in the instance names
+	instancePrefix = "multicri-"
+)
+
+type LocalAdapter struct {
+	cli     cmd.SingularityCLI
+	Builder builder.ImageBuilder
+	// processes are the main processes of the started containers, by container id
+	processes map[string]*process
+	lock      sync.Mutex
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "local",
+		Description: "Runs containers in the CRI node with Singularity or Apptainer",
+		Options: map[string]string{
+			"singularity-path": "Path of the singularity or apptainer binary, searched in the PATH by default (CRI_LOCAL_SINGULARITY_PATH)",
+		},
+		New: NewLocalAdapterWithConfig,
+	})
+}
+
+// NewLocalAdapter creates the local adapter configured with the CRI_LOCAL_* environment variables
+func NewLocalAdapter() (adapters.AdapterInterface, error) {
+	return NewLocalAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewLocalAdapterWithConfig creates the local adapter. Options not set in the config
+// are read from the CRI_LOCAL_* environment variables. Images are pulled in the CRI node.
+func NewLocalAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	pathDefault := ""
+	singularityPath := config.Get("singularity-path", common.GetEnv("CRI_LOCAL_SINGULARITY_PATH", &pathDefault))
+	cli, err := cmd.NewSingularityCLI(singularityPath, cmd.CLIConfig{})
+	if err != nil {
+		return nil, err
+	}
+	build, err := builder.NewImageBuilderInCRI("")
+	if err != nil {
+		return nil, err
+	}
+	return &LocalAdapter{cli: cli, Builder: build, processes: make(map[string]*process)}, nil
+}
+
+func (l *LocalAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           LOCALADAPTERVERSION,
+		RuntimeName:       LOCALNAME,
+		RuntimeVersion:    LOCALADAPTERVERSION,
+		RuntimeApiVersion: LOCALADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that the container resources can not be updated
+func (l *LocalAdapter) Capabilities() adapters.Capabilities {
+	capabilities := adapters.AllCapabilities()
+	capabilities.UpdateContainerResources = false
+	return capabilities
+}
+
+func (l *LocalAdapter) getProcess(containerID string) *process {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.processes[containerID]
+}
+
+func (l *LocalAdapter) setProcess(containerID string, p *process) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if p == nil {
+		delete(l.processes, containerID)
+		return
+	}
+	l.processes[containerID] = p
+}
+
+// instanceName returns the name of the Singularity instance of the container
+func instanceName(cm *store.ContainerMetadata) string {
+	return instancePrefix + cm.ID
+}
+
+// inSandboxNetwork runs f in the network namespace of the sandbox, or in the host network
+// when the sandbox has no namespace
+func inSandboxNetwork(sandbox store.SandboxMetadata, f func() error) error {
+	if sandbox.NetNSPath == "" {
+		return f()
+	}
+	return network.RunInNetNS(sandbox.NetNSPath, f)
+}
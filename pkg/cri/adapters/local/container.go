@@ -0,0 +1,198 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"syscall"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// stopTimeout is the time the container process has to exit after SIGTERM before it is killed
+	stopTimeout = 10 * time.Second
+	// instanceStopTimeout bounds the instance stop, which runs after the request may be done
+	instanceStopTimeout = 30 * time.Second
+	// runscript is run when the container has no command, as singularity run does
+	runscript = "/.singularity.d/runscript"
+	// envPrefix passes the container environment to singularity and apptainer
+	envPrefix = "SINGULARITYENV_"
+	// lostExitCode is the exit code of the containers whose process was lost when multi-cri restarted
+	lostExitCode = 255
+)
+
+func (l *LocalAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	if _, err := os.Stat(cm.Image.LocalPath); err != nil {
+		return adapters.NotFoundError("Image %s of container %s not found: %v", cm.Image.LocalPath, cm.ID, err)
+	}
+	return nil
+}
+
+// StartContainer starts the instance of the container and executes the container command in it.
+// Both run in the network namespace of the sandbox, and the command output is written in the CRI log
+// while it runs.
+func (l *LocalAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	name := instanceName(cm)
+	err := inSandboxNetwork(cm.PodSandbox, func() error {
+		return l.cli.InstanceStart(ctx, cm.Image.LocalPath, name, instanceArgs(cm))
+	})
+	if err != nil {
+		return adapters.WrapError(adapters.KindUnavailable, fmt.Errorf("instance %s can not be started: %w", name, err))
+	}
+	p, err := newProcess(l.execCommand(cm, containerCommand(cm)), cm.LogFile, cm.Config.Stdin)
+	if err == nil {
+		err = inSandboxNetwork(cm.PodSandbox, p.start)
+	}
+	if err != nil {
+		l.stopInstance(cm)
+		return fmt.Errorf("container %s can not be started: %w", cm.ID, err)
+	}
+	l.setProcess(cm.ID, p)
+	go func() {
+		<-p.done
+		l.stopInstance(cm)
+	}()
+	klog.Infof("Started container %s in instance %s", cm.ID, name)
+	return nil
+}
+
+// StopContainer sends SIGTERM to the container command, and kills it when it does not exit in time
+func (l *LocalAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	p := l.getProcess(cm.ID)
+	if p == nil {
+		l.stopInstance(cm)
+		return nil
+	}
+	if !p.exited() {
+		p.signal(syscall.SIGTERM)
+		select {
+		case <-p.done:
+		case <-time.After(stopTimeout):
+			p.signal(syscall.SIGKILL)
+			<-p.done
+		case <-ctx.Done():
+			p.signal(syscall.SIGKILL)
+			<-p.done
+		}
+	}
+	l.setExited(cm, p)
+	return nil
+}
+
+func (l *LocalAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.State != runtimeApi.ContainerState_CONTAINER_RUNNING {
+		return nil
+	}
+	p := l.getProcess(cm.ID)
+	if p == nil {
+		// The process was lost when multi-cri restarted
+		cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		cm.ExitCode = lostExitCode
+		cm.Reason = "Error"
+		cm.FinishedAt = time.Now().UnixNano()
+		return nil
+	}
+	if p.exited() {
+		l.setExited(cm, p)
+	}
+	return nil
+}
+
+// setExited sets the exit status of the process in the container, which does not need it anymore
+func (l *LocalAdapter) setExited(cm *store.ContainerMetadata, p *process) {
+	cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
+	cm.ExitCode = p.exitCode
+	cm.FinishedAt = p.finishedAt
+	cm.Reason = "Completed"
+	if p.exitCode != 0 {
+		cm.Reason = "Error"
+	}
+	l.setProcess(cm.ID, nil)
+}
+
+// ReopenContainerLog reopens the CRI log of the running container, after it was rotated
+func (l *LocalAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	p := l.getProcess(cm.ID)
+	if p == nil || p.exited() {
+		return nil
+	}
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(cm.LogFile, false, 0)
+	if err != nil {
+		return err
+	}
+	p.stdout.setLog(stdoutWC)
+	p.stderr.setLog(stderrWC)
+	return nil
+}
+
+func (l *LocalAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("LOCAL: UpdateContainerResources not implemented")
+}
+
+func (l *LocalAdapter) stopInstance(cm *store.ContainerMetadata) {
+	ctx, cancel := context.WithTimeout(context.Background(), instanceStopTimeout)
+	defer cancel()
+	if err := l.cli.InstanceStop(ctx, instanceName(cm)); err != nil {
+		klog.V(4).Infof("Instance of container %s can not be stopped: %v", cm.ID, err)
+	}
+}
+
+// execCommand returns the command which executes the command in the instance of the container,
+// in its working directory and with its environment
+func (l *LocalAdapter) execCommand(cm *store.ContainerMetadata, command []string) *osexec.Cmd {
+	var args []string
+	if cm.Config.WorkingDir != "" {
+		args = append(args, "--pwd", cm.Config.WorkingDir)
+	}
+	cmd := l.cli.ExecCommand(instanceName(cm), args, command)
+	cmd.Env = os.Environ()
+	for key, value := range cm.Environment {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s%s=%s", envPrefix, key, value))
+	}
+	return cmd
+}
+
+// instanceArgs returns the instance start options, which bind the container mounts
+func instanceArgs(cm *store.ContainerMetadata) []string {
+	var args []string
+	for _, m := range cm.Config.Mounts {
+		bind := fmt.Sprintf("%s:%s", m.HostPath, m.ContainerPath)
+		if m.Readonly {
+			bind += ":ro"
+		}
+		args = append(args, "--bind", bind)
+	}
+	return args
+}
+
+// containerCommand returns the command and the arguments of the container,
+// or the runscript of the image when it has no command
+func containerCommand(cm *store.ContainerMetadata) []string {
+	command := append(append([]string{}, cm.Command...), cm.Args...)
+	if len(command) == 0 {
+		return []string{runscript}
+	}
+	return command
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"fmt"
+	osexec "os/exec"
+
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// ExecSync executes the command in the instance of the container and returns its output.
+// The command is killed when the context is done.
+func (l *LocalAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := l.execCommand(cm, command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := runCommand(ctx, cm.PodSandbox, cmd)
+	if _, ok := err.(*osexec.ExitError); err != nil && !ok {
+		return nil, fmt.Errorf("exec in container %s fails: %v", cm.ID, err)
+	}
+	return &runtimeApi.ExecSyncResponse{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: int32(exitCode(err)),
+	}, nil
+}
+
+// Exec is served by the runtime streaming server, with the stream runtime of the adapter
+func (l *LocalAdapter) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
+	return nil, nil
+}
+
+// Attach is served by the runtime streaming server, with the stream runtime of the adapter
+func (l *LocalAdapter) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
+	return nil, nil
+}
+
+// runCommand runs the command in the network namespace of the sandbox, and kills it when
+// the context is done
+func runCommand(ctx context.Context, sandbox store.SandboxMetadata, cmd *osexec.Cmd) error {
+	if err := inSandboxNetwork(sandbox, cmd.Start); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"os"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+func (l *LocalAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	switch image.RepoType {
+	case store.UnknownImageRepo, store.LocalDefinitionFile:
+		return adapters.InvalidArgumentError("Image repository type not supported by the local adapter %s ", image.RemotePath)
+	case store.LocalImageRepo:
+		// Local images are already in the CRI node
+		if _, err := os.Stat(image.RemotePath); err != nil {
+			return adapters.NotFoundError("Local image %s not found: %v", image.RemotePath, err)
+		}
+		image.LocalPath = image.RemotePath
+		return nil
+	}
+	container := &store.ContainerMetadata{Image: image}
+	return l.Builder.PullImage(ctx, container)
+}
+
+func (l *LocalAdapter) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	return nil
+}
+
+func (l *LocalAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
+
+func (l *LocalAdapter) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	//todo control it properly
+	filesystems := []*runtimeApi.FilesystemUsage{
+		{
+			Timestamp: time.Now().UnixNano(),
+			UsedBytes: &runtimeApi.UInt64Value{Value: uint64(0)},
+			FsId: &runtimeApi.FilesystemIdentifier{
+				Mountpoint: "/",
+			},
+		},
+	}
+	return &runtimeApi.ImageFsInfoResponse{ImageFilesystems: filesystems}, nil
+}
+
+// RemoveImage removes the pulled image files. Local images are not removed.
+func (l *LocalAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	if image.RepoType == store.LocalImageRepo || image.LocalPath == "" {
+		return nil
+	}
+	if err := os.Remove(image.LocalPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
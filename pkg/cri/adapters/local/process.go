@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"io"
+	osexec "os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"multi-cri/pkg/cri/common"
+
+	"k8s.io/klog"
+)
+
+// output copies the output of a container process to its CRI log and to the attached streams
+type output struct {
+	lock     sync.Mutex
+	log      io.WriteCloser
+	attached map[io.Writer]struct{}
+}
+
+func newOutput(log io.WriteCloser) *output {
+	return &output{log: log, attached: make(map[io.Writer]struct{})}
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for w := range o.attached {
+		if _, err := w.Write(p); err != nil {
+			// The attached client is gone
+			delete(o.attached, w)
+		}
+	}
+	return o.log.Write(p)
+}
+
+func (o *output) attach(w io.Writer) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.attached[w] = struct{}{}
+}
+
+func (o *output) detach(w io.Writer) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	delete(o.attached, w)
+}
+
+// setLog replaces the log writer, closing the previous one
+func (o *output) setLog(log io.WriteCloser) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.log.Close()
+	o.log = log
+}
+
+func (o *output) close() {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.log.Close()
+}
+
+// process is the main process of a container, which runs the container command in its instance
+type process struct {
+	cmd    *osexec.Cmd
+	stdin  io.WriteCloser
+	stdout *output
+	stderr *output
+	// done is closed when the process exits, and then exitCode and finishedAt are set
+	done       chan struct{}
+	exitCode   int
+	finishedAt int64
+}
+
+// newProcess prepares the command to write its output in the CRI log. The standard input is kept
+// open for attached clients when the container has it.
+func newProcess(cmd *osexec.Cmd, logPath string, stdin bool) (*process, error) {
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(logPath, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd, stdout: newOutput(stdoutWC), stderr: newOutput(stderrWC), done: make(chan struct{})}
+	cmd.Stdout = p.stdout
+	cmd.Stderr = p.stderr
+	if stdin {
+		if p.stdin, err = cmd.StdinPipe(); err != nil {
+			p.closeLogs()
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *process) start() error {
+	if err := p.cmd.Start(); err != nil {
+		p.closeLogs()
+		return err
+	}
+	go p.wait()
+	return nil
+}
+
+// wait waits for the process and for its output to be logged
+func (p *process) wait() {
+	err := p.cmd.Wait()
+	p.exitCode = exitCode(err)
+	p.finishedAt = time.Now().UnixNano()
+	p.closeLogs()
+	klog.V(4).Infof("Container process %d exited with code %d", p.cmd.Process.Pid, p.exitCode)
+	close(p.done)
+}
+
+func (p *process) closeLogs() {
+	p.stdout.close()
+	p.stderr.close()
+}
+
+// exited returns whether the process exited
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// signal sends the signal to the process. singularity exec forwards it to the container command.
+func (p *process) signal(sig syscall.Signal) {
+	if err := p.cmd.Process.Signal(sig); err != nil {
+		klog.V(4).Infof("Signal %s can not be sent to process %d: %v", sig, p.cmd.Process.Pid, err)
+	}
+}
+
+// exitCode returns the exit code of a finished command, which is 128 plus the signal number when
+// the command was killed by a signal
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*osexec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+	return 1
+}
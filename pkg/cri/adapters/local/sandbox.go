@@ -0,0 +1,39 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+)
+
+// The runtime creates the network namespace of the sandbox, which the containers join
+
+func (l *LocalAdapter) RunPodSandbox(ctx context.Context, metadata *store.SandboxMetadata) error {
+	return nil
+}
+
+func (l *LocalAdapter) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+
+func (l *LocalAdapter) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+
+func (l *LocalAdapter) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
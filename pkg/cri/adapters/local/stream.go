@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	osexec "os/exec"
+
+	"multi-cri/pkg/cri/store"
+
+	"github.com/kr/pty"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+	utilexec "k8s.io/utils/exec"
+)
+
+type streamRuntime struct {
+	adapter *LocalAdapter
+	c       store.ContainerStoreInterface
+}
+
+func (l *LocalAdapter) NewStreamRuntime(c store.ContainerStoreInterface) streaming.Runtime {
+	return &streamRuntime{adapter: l, c: c}
+}
+
+// Attach copies the output of the container process to the client, and the client input to the process
+// when the container has stdin. It returns when the process exits or the client closes its input.
+func (r *streamRuntime) Attach(containerID string, in io.Reader, out, err io.WriteCloser, tty bool,
+	resize <-chan remotecommand.TerminalSize) error {
+	cm, e := r.c.Get(containerID)
+	if e != nil {
+		return e
+	}
+	p := r.adapter.getProcess(containerID)
+	if p == nil || p.exited() {
+		return fmt.Errorf("container %s is not running", containerID)
+	}
+	if out != nil {
+		p.stdout.attach(out)
+		defer p.stdout.detach(out)
+	}
+	if err != nil {
+		p.stderr.attach(err)
+		defer p.stderr.detach(err)
+	}
+	inDone := make(chan struct{})
+	if in != nil && p.stdin != nil {
+		go func() {
+			defer close(inDone)
+			io.Copy(p.stdin, in)
+			if cm.Config.StdinOnce {
+				p.stdin.Close()
+			}
+		}()
+	}
+	select {
+	case <-p.done:
+	case <-inDone:
+	}
+	return nil
+}
+
+// Exec executes the command in the instance of the container, in a terminal when tty is requested
+func (r *streamRuntime) Exec(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.WriteCloser,
+	tty bool, resize <-chan remotecommand.TerminalSize) error {
+	cm, err := r.c.Get(containerID)
+	if err != nil {
+		return err
+	}
+	command := r.adapter.execCommand(cm, cmd)
+	if tty {
+		err = execTTY(cm.PodSandbox, command, stdin, stdout, resize)
+	} else {
+		command.Stdin = stdin
+		command.Stdout = stdout
+		command.Stderr = stderr
+		if err = inSandboxNetwork(cm.PodSandbox, command.Start); err == nil {
+			err = command.Wait()
+		}
+	}
+	if _, ok := err.(*osexec.ExitError); ok {
+		return &utilexec.CodeExitError{Err: err, Code: exitCode(err)}
+	}
+	return err
+}
+
+// execTTY runs the command in a pseudo terminal, which is resized as the client terminal
+func execTTY(sandbox store.SandboxMetadata, command *osexec.Cmd, stdin io.Reader, stdout io.WriteCloser,
+	resize <-chan remotecommand.TerminalSize) error {
+	var terminal *os.File
+	err := inSandboxNetwork(sandbox, func() error {
+		var err error
+		terminal, err = pty.Start(command)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer terminal.Close()
+	go func() {
+		for size := range resize {
+			if err := pty.Setsize(terminal, &pty.Winsize{Rows: size.Height, Cols: size.Width}); err != nil {
+				klog.V(4).Infof("Terminal can not be resized: %v", err)
+			}
+		}
+	}()
+	if stdin != nil {
+		go io.Copy(terminal, stdin)
+	}
+	if stdout != nil {
+		// The copy ends when the command exits and the terminal is closed
+		io.Copy(stdout, terminal)
+	}
+	return command.Wait()
+}
+
+// PortForward forwards the stream to the port of the sandbox, which is dialed in its network namespace
+func (r *streamRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	defer stream.Close()
+	containers := r.c.List("", podSandboxID)
+	if len(containers) == 0 {
+		return fmt.Errorf("sandbox %s has no containers", podSandboxID)
+	}
+	var conn net.Conn
+	err := inSandboxNetwork(containers[0].PodSandbox, func() error {
+		var err error
+		conn, err = net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("port %d of sandbox %s can not be forwarded: %v", port, podSandboxID, err)
+	}
+	defer conn.Close()
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(stream, conn)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/common/cmd"
+	"multi-cri/pkg/cri/store"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// singularityScript replaces singularity in the tests: instances do nothing, pull creates an empty image
+// and exec runs the command in the current directory
+const singularityScript = `#!/bin/sh
+case "$1" in
+instance) exit 0 ;;
+pull) touch "$2" ;;
+exec)
+	shift
+	if [ "$1" = "--pwd" ]; then shift 2; fi
+	shift
+	exec "$@" ;;
+*) exit 1 ;;
+esac
+`
+
+type logBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *logBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+//Test the container output is logged and copied to the attached streams until they are detached
+func TestUnitOutput(t *testing.T) {
+	log := &logBuffer{}
+	o := newOutput(log)
+	var attached bytes.Buffer
+	o.attach(&attached)
+	o.Write([]byte("first\n"))
+	o.detach(&attached)
+	o.Write([]byte("second\n"))
+	if log.String() != "first\nsecond\n" || attached.String() != "first\n" {
+		t.Errorf("Wrong output, log %q and attached %q", log.String(), attached.String())
+	}
+	rotated := &logBuffer{}
+	o.setLog(rotated)
+	o.Write([]byte("third\n"))
+	o.close()
+	if !log.closed || !rotated.closed || rotated.String() != "third\n" {
+		t.Errorf("Rotated log should be written and closed: %q", rotated.String())
+	}
+}
+
+//Test the exit codes of the container processes
+func TestUnitExitCode(t *testing.T) {
+	if code := exitCode(nil); code != 0 {
+		t.Errorf("Exit code should be 0: %d", code)
+	}
+	if code := exitCode(errors.New("not started")); code != 1 {
+		t.Errorf("Exit code should be 1: %d", code)
+	}
+	if code := exitCode(osexec.Command("sh", "-c", "exit 3").Run()); code != 3 {
+		t.Errorf("Exit code should be 3: %d", code)
+	}
+	if code := exitCode(osexec.Command("sh", "-c", "kill -9 $$").Run()); code != 137 {
+		t.Errorf("Exit code of killed processes should be 137: %d", code)
+	}
+}
+
+//Test the container mounts, command and environment are passed to singularity
+func TestUnitContainerCommand(t *testing.T) {
+	cm := &store.ContainerMetadata{ID: "c1", Environment: map[string]string{"A": "1"}}
+	cm.Config.WorkingDir = "/work"
+	cm.Config.Mounts = []*runtimeApi.Mount{{HostPath: "/data", ContainerPath: "/mnt", Readonly: true}}
+	if args := strings.Join(instanceArgs(cm), " "); args != "--bind /data:/mnt:ro" {
+		t.Errorf("Wrong instance arguments: %s", args)
+	}
+	if command := containerCommand(cm); len(command) != 1 || command[0] != runscript {
+		t.Errorf("Containers without command should run the runscript: %v", command)
+	}
+	cm.Command, cm.Args = []string{"echo"}, []string{"hello"}
+	if command := strings.Join(containerCommand(cm), " "); command != "echo hello" {
+		t.Errorf("Wrong container command: %s", command)
+	}
+	cli, err := cmd.NewSingularityCLI("/bin/sh", cmd.CLIConfig{})
+	if err != nil {
+		t.Fatalf("CLI can not be created: %v", err)
+	}
+	l := &LocalAdapter{cli: cli, processes: make(map[string]*process)}
+	command := l.execCommand(cm, containerCommand(cm))
+	if args := strings.Join(command.Args, " "); !strings.Contains(args, "--pwd /work instance://multicri-c1 echo hello") {
+		t.Errorf("Wrong exec command: %s", args)
+	}
+	if env := strings.Join(command.Env, " "); !strings.Contains(env, "SINGULARITYENV_A=1") {
+		t.Errorf("Container environment should be passed to singularity: %s", env)
+	}
+}
+
+//Test the adapter passes the conformance suite with a fake singularity
+func TestUnitConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multicri-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	singularity := filepath.Join(dir, "singularity")
+	if err := ioutil.WriteFile(singularity, []byte(singularityScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The images are pulled with the singularity of the PATH
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	conformance.Run(t, conformance.Config{
+		New: func() (adapters.AdapterInterface, error) {
+			return NewLocalAdapterWithConfig(adapters.AdapterConfig{"singularity-path": singularity})
+		},
+		Timeout:      20 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lsf implements the adapter of IBM Spectrum LSF clusters. Containers are submitted as
+// batch jobs with bsub through SSH, like in the Slurm adapter.
+package lsf
+
+import (
+	"fmt"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	LSFADAPTERVERSION = "0.1.0"
+	LSFNAME           = "Adapter LSF"
+	MOUNTHPATH        = "multi-cri"
+)
+
+type LSFAdapter struct {
+	batch.Base
+	ImageRemoteMount string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "lsf",
+		Description: "Submits containers as batch jobs to IBM Spectrum LSF clusters",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the LSF cluster, relative to $HOME (CRI_LSF_MOUNT_PATH)",
+			"image-remote-mount": "Path in which the images are built (CRI_LSF_IMAGE_REMOTE_MOUNT)",
+			"build-in-cluster":   "Build images directly in the LSF cluster (CRI_LSF_BUILD_IN_CLUSTER)",
+		},
+		Validate: validateConfig,
+		New:      NewLSFAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if _, err := config.GetBool("build-in-cluster", false); err != nil {
+		return err
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	return nil
+}
+
+// NewLSFAdapter creates the LSF adapter configured with the CRI_LSF_* environment variables
+func NewLSFAdapter() (adapters.AdapterInterface, error) {
+	return NewLSFAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewLSFAdapterWithConfig creates the LSF adapter. Options not set in the config
+// are read from the CRI_LSF_* environment variables
+func NewLSFAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	q := MOUNTHPATH
+	b := false
+	remoteDefault := ""
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_LSF_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_LSF_MOUNT_PATH", &q))
+	buildInCluster, err := config.GetBool("build-in-cluster", common.GetBoolEnv("CRI_LSF_BUILD_IN_CLUSTER", &b))
+	if err != nil {
+		return nil, err
+	}
+	build, err := builder.NewImageBuilder(buildInCluster, mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return LSFAdapter{Base: batch.NewBase("LSF", mountP, build),
+		ImageRemoteMount: imageRemoteMountPath}, nil
+}
+
+func (l LSFAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           LSFADAPTERVERSION,
+		RuntimeName:       LSFNAME,
+		RuntimeVersion:    LSFADAPTERVERSION,
+		RuntimeApiVersion: LSFADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted
+func (l LSFAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ImageFsInfo: true}
+}
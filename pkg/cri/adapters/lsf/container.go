@@ -0,0 +1,154 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// loggedLines is the container Extra key with the number of stdout lines already in the container log
+const loggedLines = "LoggedLines"
+
+// jobSettingRules are the job settings which LSF supports
+var jobSettingRules = []batch.JobSettingRule{batch.QueueRule, batch.TimeRule, batch.GresRule, batch.NodesRule,
+	batch.CoresRule, batch.CoresPerNodeRule, batch.TasksPerNodeRule, batch.CustomConfigRule}
+
+func (l LSFAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	klog.Infof("Creating container path in server")
+	if err := batch.ValidateJobSettings(cm, l.Name, jobSettingRules); err != nil {
+		return err
+	}
+	batch.SetupContainerPaths(cm, l.MountPath)
+	client, err := batch.NewClient(cm, lsfErrors)
+	if err != nil {
+		return err
+	}
+	//Ensure container path exists in LSF cluster
+	if err := client.MakeDir(ctx, cm.Extra["RMPath"]); err != nil {
+		return err
+	}
+	//Pull image in LSF cluster
+	if err := l.Builder.PullImageInCluster(ctx, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container path in server with id %s", cm.ID)
+	return nil
+}
+
+func (l LSFAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, lsfErrors)
+	if err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	command := settings.Command(cm, builder.GetRMImagePath(cm, l.MountPath, l.ImageRemoteMount))
+	// bsub reads the #BSUB directives of the script from its standard input
+	response, err := client.Submit(ctx, cm, settings, batchScript(settings, command),
+		fmt.Sprintf("bsub < %s", batch.BatchScript))
+	if err != nil {
+		return err
+	}
+	cm.Pid, err = parseJobId(response)
+	return err
+}
+
+func (l LSFAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, lsfErrors)
+	if err != nil {
+		return err
+	}
+	_, err = client.Run(ctx, fmt.Sprintf("bkill %d", cm.Pid))
+	if adapters.IsNotFound(err) {
+		// The job already finished
+		return nil
+	}
+	return err
+}
+
+func (l LSFAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Pid == 0 {
+		return nil
+	}
+	client, err := batch.NewClient(cm, lsfErrors)
+	if err != nil {
+		return err
+	}
+	out, err := client.Output(ctx, fmt.Sprintf("bjobs -json -o \"%s\" %d", bjobsFields, cm.Pid))
+	if err != nil {
+		return batch.WrapError(err, "Retrieve job info fails %s ", err)
+	}
+	status, err := parseBjobs(out)
+	if err != nil {
+		return err
+	}
+	status.Apply(cm)
+	switch cm.State {
+	case runtimeApi.ContainerState_CONTAINER_RUNNING:
+		peekOutput(ctx, client, cm)
+	case runtimeApi.ContainerState_CONTAINER_EXITED:
+		copyOutput(ctx, client, cm)
+	}
+	return nil
+}
+
+// peekOutput writes the new output lines of the running job in the container log, so it is shown
+// before the job finishes
+func peekOutput(ctx context.Context, client *batch.Client, cm *store.ContainerMetadata) {
+	out, err := client.Output(ctx, fmt.Sprintf("bpeek %d", cm.Pid))
+	if err != nil {
+		klog.V(4).Infof("Output of job %d can not be read: %v", cm.Pid, err)
+		return
+	}
+	lines := parseBpeek(out)
+	logged, _ := strconv.Atoi(cm.Extra[loggedLines])
+	if len(lines) <= logged {
+		return
+	}
+	batch.LogString(cm.LogFile, strings.Join(lines[logged:], "\n")+"\n")
+	cm.Extra[loggedLines] = strconv.Itoa(len(lines))
+}
+
+// copyOutput writes the job output files in the container log, skipping the lines written by peekOutput
+func copyOutput(ctx context.Context, client *batch.Client, cm *store.ContainerMetadata) {
+	logged, _ := strconv.Atoi(cm.Extra[loggedLines])
+	commands := []string{
+		fmt.Sprintf("cat %s", batch.RMFile(cm, batch.SterrFile)),
+		fmt.Sprintf("tail -n +%d %s", logged+1, batch.RMFile(cm, batch.StdoutFile)),
+	}
+	for _, command := range commands {
+		if _, err := client.Run(ctx, command); err != nil {
+			klog.V(4).Infof("Job output can not be read: %v", err)
+		}
+	}
+}
+
+func (l LSFAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("LSF: ReopenContainerLog not implemented")
+}
+
+func (l LSFAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("LSF: UpdateContainerResources not implemented")
+}
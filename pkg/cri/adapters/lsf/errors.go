@@ -0,0 +1,37 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsf
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// lsfErrors classifies the LSF command errors by their message
+var lsfErrors = []batch.ErrorPattern{
+	{"no such queue", adapters.KindInvalidArgument},
+	{"bad resource requirement syntax", adapters.KindInvalidArgument},
+	{"too many processors requested", adapters.KindInvalidArgument},
+	{"too many tasks requested", adapters.KindInvalidArgument},
+	{"is not found", adapters.KindNotFound},
+	{"job has already finished", adapters.KindNotFound},
+	{"user cannot use the queue", adapters.KindPermissionDenied},
+	{"user permission denied", adapters.KindPermissionDenied},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"lsf is down", adapters.KindUnavailable},
+	{"batch system not available", adapters.KindUnavailable},
+	{"cannot connect to lsf", adapters.KindUnavailable},
+	{"failed in an lsf library call", adapters.KindUnavailable},
+}
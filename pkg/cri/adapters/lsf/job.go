@@ -0,0 +1,102 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsf
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// batchScript returns the job script with the #BSUB directives of the job settings
+func batchScript(settings batch.JobSettings, command string) []string {
+	lines := []string{
+		"#!/bin/bash",
+		fmt.Sprintf("#BSUB -J %s", settings.Name),
+		fmt.Sprintf("#BSUB -o %s", batch.StdoutFile),
+		fmt.Sprintf("#BSUB -e %s", batch.SterrFile),
+	}
+	if settings.Queue != "" {
+		lines = append(lines, fmt.Sprintf("#BSUB -q %s", settings.Queue))
+	}
+	slots, span := slotRequest(settings)
+	if slots > 0 {
+		lines = append(lines, fmt.Sprintf("#BSUB -n %d", slots))
+	}
+	if span != "" {
+		lines = append(lines, fmt.Sprintf("#BSUB -R \"span[%s]\"", span))
+	}
+	if gpus := settings.GPUCount(); gpus > 0 {
+		lines = append(lines, fmt.Sprintf("#BSUB -gpu \"num=%d\"", gpus))
+	}
+	// The run limit is in hours:minutes, rounded up. LSF has no unlimited run limit, the jobs without it get
+	// the limit of the queue.
+	if seconds := settings.TimeLimitSeconds(); seconds > 0 {
+		minutes := (seconds + 59) / 60
+		lines = append(lines, fmt.Sprintf("#BSUB -W %d:%02d", minutes/60, minutes%60))
+	}
+	if settings.CustomConfig != "" {
+		lines = append(lines, settings.CustomConfig)
+	}
+	return append(lines, command)
+}
+
+// slotRequest returns the number of slots of the job and how they span the hosts. The slots per host
+// are the tasks per node, or the cores per node. Slots span any host when only the cores are set.
+func slotRequest(settings batch.JobSettings) (int, string) {
+	ptile, _ := strconv.Atoi(settings.TasksPerNode)
+	if ptile <= 0 && (settings.Nodes != "" || settings.CoresPerNode != "") {
+		ptile = settings.CoresPerNodeCount()
+	}
+	slots := settings.CoreCount()
+	if ptile > 0 && settings.Nodes != "" && settings.Cores == "" {
+		slots = ptile * settings.NodeCount()
+	}
+	if slots == 0 && settings.Nodes != "" {
+		slots = settings.NodeCount()
+		ptile = 1
+	}
+	if ptile > 0 {
+		return slots, fmt.Sprintf("ptile=%d", ptile)
+	}
+	return slots, ""
+}
+
+var submittedJob = regexp.MustCompile(`Job <(\d+)> is submitted`)
+
+// parseJobId returns the job id printed by bsub, e.g. 1234 for "Job <1234> is submitted to queue <normal>."
+func parseJobId(response string) (int, error) {
+	m := submittedJob.FindStringSubmatch(response)
+	if m == nil {
+		return 0, fmt.Errorf("Not submitted batch job id found: %s ", response)
+	}
+	return strconv.Atoi(m[1])
+}
+
+// parseBpeek returns the output lines of bpeek, without its headers such as "<< output from stdout >>"
+func parseBpeek(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "<<") && strings.HasSuffix(trimmed, ">>") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// bjobsFields are the fields requested to bjobs -json -o
+const bjobsFields = "jobid stat exit_code exit_reason start_time finish_time pend_reason"
+
+// bjobsTimeLayouts are the time formats of bjobs, which does not show the year
+var bjobsTimeLayouts = []string{"Jan _2 15:04:05", "Jan _2 15:04"}
+
+type bjobsRecord struct {
+	JobID      string `json:"JOBID"`
+	Stat       string `json:"STAT"`
+	ExitCode   string `json:"EXIT_CODE"`
+	ExitReason string `json:"EXIT_REASON"`
+	StartTime  string `json:"START_TIME"`
+	FinishTime string `json:"FINISH_TIME"`
+	PendReason string `json:"PEND_REASON"`
+	Error      string `json:"ERROR"`
+}
+
+type bjobsOutput struct {
+	Records []bjobsRecord `json:"RECORDS"`
+}
+
+// parseBjobs parses the output of bjobs -json for a single job
+func parseBjobs(output string) (*batch.JobStatus, error) {
+	var out bjobsOutput
+	if err := json.Unmarshal([]byte(output), &out); err != nil {
+		return nil, fmt.Errorf("bjobs output cannot be parsed: %v", err)
+	}
+	if len(out.Records) == 0 {
+		return nil, adapters.NotFoundError("Job not found in bjobs output")
+	}
+	record := out.Records[0]
+	if record.Error != "" {
+		if strings.Contains(record.Error, "not found") {
+			return nil, adapters.NotFoundError("%s", record.Error)
+		}
+		return nil, fmt.Errorf("bjobs fails: %s", record.Error)
+	}
+	status := &batch.JobStatus{
+		State:      jobState(record.Stat),
+		StartedAt:  parseTime(record.StartTime, time.Now()),
+		FinishedAt: parseTime(record.FinishTime, time.Now()),
+	}
+	switch record.Stat {
+	case "DONE":
+		status.Reason = batch.ReasonCompleted
+	case "EXIT":
+		status.ExitCode, status.Reason = exitStatus(record.ExitCode, record.ExitReason)
+	case "ZOMBI":
+		status.ExitCode, status.Reason = 1, batch.ReasonKilled
+	}
+	return status, nil
+}
+
+// jobState maps the LSF job states to the container states. Pending jobs, also when suspended, are
+// created; running jobs, also when suspended or unknown to the master, are running; done, failed and
+// killed jobs are exited.
+func jobState(stat string) runtimeApi.ContainerState {
+	switch stat {
+	case "RUN", "USUSP", "SSUSP", "UNKWN", "PROV":
+		return runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "DONE", "EXIT", "ZOMBI":
+		return runtimeApi.ContainerState_CONTAINER_EXITED
+	default:
+		return runtimeApi.ContainerState_CONTAINER_CREATED
+	}
+}
+
+// exitStatus returns the exit code and the reason of a failed job. LSF leaves the exit code empty
+// when it kills the job.
+func exitStatus(exitCode, exitReason string) (int, string) {
+	code, err := strconv.Atoi(strings.TrimSpace(exitCode))
+	if err != nil || code == 0 {
+		code = 1
+	}
+	reason := strings.ToLower(exitReason)
+	switch {
+	case strings.Contains(reason, "memlimit") || strings.Contains(reason, "memory limit"):
+		return code, batch.ReasonOOMKilled
+	case strings.Contains(reason, "runlimit") || strings.Contains(reason, "run limit"):
+		return code, batch.ReasonDeadlineExceeded
+	case strings.Contains(reason, "kill") || strings.Contains(reason, "owner") || strings.Contains(reason, "admin"):
+		return code, batch.ReasonKilled
+	}
+	return code, batch.ReasonError
+}
+
+// parseTime returns the time in nanoseconds, or 0 when it can not be parsed. bjobs does not show the
+// year, so the last occurrence of the date before now is taken. Marks such as " L" (time at the job
+// location) are ignored.
+func parseTime(value string, now time.Time) int64 {
+	value = strings.TrimSpace(value)
+	if i := strings.LastIndex(value, " "); i > 0 && len(value)-i == 2 {
+		value = value[:i]
+	}
+	for _, layout := range bjobsTimeLayouts {
+		t, err := time.ParseInLocation(layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+		t = t.AddDate(now.Year(), 0, 0)
+		if t.After(now) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t.UnixNano()
+	}
+	return 0
+}
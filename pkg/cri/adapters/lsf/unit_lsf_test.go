@@ -0,0 +1,210 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lsf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the job settings are translated to LSF slots
+func TestUnitSlotRequest(t *testing.T) {
+	for _, c := range []struct {
+		settings batch.JobSettings
+		slots    int
+		span     string
+	}{
+		{batch.JobSettings{}, 0, ""},
+		{batch.JobSettings{Cores: "8"}, 8, ""},
+		{batch.JobSettings{Nodes: "2", CoresPerNode: "4"}, 8, "ptile=4"},
+		{batch.JobSettings{Nodes: "2", TasksPerNode: "2", CoresPerNode: "8"}, 4, "ptile=2"},
+		{batch.JobSettings{Nodes: "2", Cores: "6"}, 6, "ptile=3"},
+		{batch.JobSettings{Nodes: "3"}, 3, "ptile=1"},
+	} {
+		if slots, span := slotRequest(c.settings); slots != c.slots || span != c.span {
+			t.Errorf("Slots of %+v should be %d %q instead of %d %q", c.settings, c.slots, c.span, slots, span)
+		}
+	}
+	script := strings.Join(batchScript(batch.JobSettings{Name: "test", Queue: "normal", GPU: "gpu:2", Cores: "4",
+		TimeLimit: "1-00:00:30"}, "hostname"), "\n")
+	for _, line := range []string{"#BSUB -J test", "#BSUB -q normal", "#BSUB -n 4", "#BSUB -gpu \"num=2\"", "#BSUB -o stdout.out",
+		"#BSUB -W 24:01"} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Batch script should contain %q:\n%s", line, script)
+		}
+	}
+	if script := strings.Join(batchScript(batch.JobSettings{Name: "test", TimeLimit: "UNLIMITED"}, "hostname"), "\n"); strings.Contains(script, "-W") {
+		t.Errorf("Unlimited jobs should have the run limit of the queue:\n%s", script)
+	}
+}
+
+//Test bsub job ids are parsed
+func TestUnitParseJobId(t *testing.T) {
+	if pid, err := parseJobId("Job <1234> is submitted to queue <normal>.\n"); err != nil || pid != 1234 {
+		t.Errorf("Job id should be 1234: %d %v", pid, err)
+	}
+	if _, err := parseJobId("No such queue. Job not submitted."); err == nil {
+		t.Errorf("Wrong job ids should fail")
+	}
+}
+
+//Test bjobs JSON output is translated to container states and reasons
+func TestUnitParseBjobs(t *testing.T) {
+	bjobs := `{"COMMAND":"bjobs","JOBS":1,"RECORDS":[{"JOBID":"1234","STAT":"%s","EXIT_CODE":"%s","EXIT_REASON":"%s","START_TIME":"Oct 14 10:00","FINISH_TIME":"Oct 14 10:05 L","PEND_REASON":""}]}`
+	for _, c := range []struct {
+		stat, exitCode, exitReason string
+		state                      runtimeApi.ContainerState
+		code                       int
+		reason                     string
+	}{
+		{"PEND", "", "", runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{"PSUSP", "", "", runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{"RUN", "", "", runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{"SSUSP", "", "", runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{"DONE", "", "", runtimeApi.ContainerState_CONTAINER_EXITED, 0, batch.ReasonCompleted},
+		{"EXIT", "3", "", runtimeApi.ContainerState_CONTAINER_EXITED, 3, batch.ReasonError},
+		{"EXIT", "", "TERM_OWNER: job killed by owner", runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonKilled},
+		{"EXIT", "", "TERM_RUNLIMIT: job killed after reaching LSF run time limit", runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonDeadlineExceeded},
+		{"EXIT", "", "TERM_MEMLIMIT: job killed after reaching LSF memory usage limit", runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonOOMKilled},
+	} {
+		status, err := parseBjobs(fmt.Sprintf(bjobs, c.stat, c.exitCode, c.exitReason))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != c.state || status.ExitCode != c.code || status.Reason != c.reason {
+			t.Errorf("State %s %s %s wrong: %+v", c.stat, c.exitCode, c.exitReason, status)
+		}
+		if status.StartedAt == 0 || status.FinishedAt <= status.StartedAt {
+			t.Errorf("Times should be parsed: %+v", status)
+		}
+	}
+	_, err := parseBjobs(`{"COMMAND":"bjobs","JOBS":1,"RECORDS":[{"JOBID":"1234","ERROR":"Job <1234> is not found"}]}`)
+	if !adapters.IsNotFound(err) {
+		t.Errorf("Missing jobs should not be found: %v", err)
+	}
+}
+
+//Test bjobs times without year are taken before now
+func TestUnitParseTime(t *testing.T) {
+	now := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	if got := time.Unix(0, parseTime("Dec 31 23:00 L", now)).UTC(); got != time.Date(2019, time.December, 31, 23, 0, 0, 0, time.UTC) {
+		t.Errorf("Time of the last year wrong: %v", got)
+	}
+	if got := time.Unix(0, parseTime("Jan  1 10:00:05", now)).UTC(); got != time.Date(2020, time.January, 1, 10, 0, 5, 0, time.UTC) {
+		t.Errorf("Time with seconds wrong: %v", got)
+	}
+	if parseTime("-", now) != 0 {
+		t.Errorf("Unknown times should be 0")
+	}
+}
+
+//Test bpeek headers are removed
+func TestUnitParseBpeek(t *testing.T) {
+	lines := parseBpeek("<< output from stdout >>\nline 1\nline 2\n")
+	if len(lines) != 2 || lines[0] != "line 1" {
+		t.Errorf("bpeek lines wrong: %q", lines)
+	}
+}
+
+//Test LSF errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		kind     adapters.ErrorKind
+	}{
+		{"No such queue. Job not submitted.", adapters.KindInvalidArgument},
+		{"Job <1234>: Job has already finished", adapters.KindNotFound},
+		{"User cannot use the queue. Job not submitted.", adapters.KindPermissionDenied},
+		{"LSF is down; please wait", adapters.KindUnavailable},
+	} {
+		err := batch.ClassifyError(lsfErrors, c.response, fmt.Errorf("exit status 255"))
+		if kind := adapters.ErrorKindOf(err); kind != c.kind {
+			t.Errorf("Error %q should be %q instead of %q", c.response, c.kind, kind)
+		}
+	}
+}
+
+// startCluster starts a fake cluster with the LSF commands of the adapter: bsub submits the script of its
+// standard input, bkill cancels the job, bjobs -json shows it and bpeek shows its output
+func startCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := fakecluster.Scheduler{Directives: "#BSUB", Name: "-J", Stdout: "-o", Stderr: "-e",
+		Submitted: "Job <%d> is submitted to default queue <normal>.\n",
+		Unknown:   "Job <%s>: No matching job found, job is not found\n", UnknownStatus: 255}
+	cluster.AddBuiltin("bsub", cluster.SubmitCommand(scheduler))
+	cluster.AddBuiltin("bkill", cluster.CancelCommand(scheduler))
+	cluster.AddBuiltin("bjobs", func(s fakecluster.Session, args []string) int {
+		id, _ := strconv.Atoi(args[len(args)-1])
+		record := bjobsRecord{JobID: args[len(args)-1], Stat: "PEND"}
+		if job, ok := cluster.Job(id); !ok {
+			record.Error = fmt.Sprintf("Job <%d> is not found", id)
+		} else {
+			if !job.StartTime.IsZero() {
+				record.Stat = "RUN"
+				record.StartTime = job.StartTime.Format(bjobsTimeLayouts[0])
+			}
+			switch job.State {
+			case fakecluster.StateCompleted:
+				record.Stat = "DONE"
+			case fakecluster.StateCancelled:
+				record.Stat, record.ExitReason = "EXIT", "KILLED: killed by owner"
+			case fakecluster.StateFailed:
+				record.Stat, record.ExitCode = "EXIT", strconv.Itoa(job.ExitCode)
+			}
+			if !job.EndTime.IsZero() {
+				record.FinishTime = job.EndTime.Format(bjobsTimeLayouts[0])
+			}
+		}
+		json.NewEncoder(s.Stdout).Encode(bjobsOutput{Records: []bjobsRecord{record}})
+		return 0
+	})
+	cluster.AddBuiltin("bpeek", func(s fakecluster.Session, args []string) int {
+		id, _ := strconv.Atoi(args[0])
+		job, ok := cluster.Job(id)
+		if !ok {
+			fmt.Fprintf(s.Stderr, "Job <%d> is not found\n", id)
+			return 255
+		}
+		output, _ := ioutil.ReadFile(filepath.Join(job.WorkDir, job.Stdout))
+		fmt.Fprintf(s.Stdout, "<< output from stdout >>\n%s", output)
+		return 0
+	})
+	return cluster
+}
+
+//Test the adapter passes the conformance suite against a fake LSF cluster
+func TestUnitConformance(t *testing.T) {
+	cluster := startCluster(t)
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewLSFAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+// Logging logs every adapter call in key=value format. Successful calls are logged with verbosity 4
+// and failed ones with verbosity 2.
+func Logging(handler string) Interceptor {
+	return func(ctx context.Context, method string, next Call) error {
+		start := time.Now()
+		err := next(ctx)
+		duration := time.Since(start)
+		if err != nil {
+			klog.V(2).Infof("Adapter call handler=%s method=%s duration=%s kind=%s error=%q",
+				handler, method, duration, errorKindName(err), err)
+		} else {
+			klog.V(4).Infof("Adapter call handler=%s method=%s duration=%s", handler, method, duration)
+		}
+		return err
+	}
+}
+
+// errorKindName returns the kind of the error, or Unknown for the errors not classified
+func errorKindName(err error) string {
+	if kind := adapters.ErrorKindOf(err); kind != adapters.KindUnknown {
+		return string(kind)
+	}
+	return "Unknown"
+}
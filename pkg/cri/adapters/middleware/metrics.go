@@ -0,0 +1,87 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// MethodMetrics contains the calls, errors and latency of an adapter method
+type MethodMetrics struct {
+	Calls uint64 `json:"calls"`
+	// Errors counts the failed calls by error kind
+	Errors map[string]uint64 `json:"errors,omitempty"`
+	// TotalLatency is the sum of the latency of every call
+	TotalLatency time.Duration `json:"totalLatency"`
+	MaxLatency   time.Duration `json:"maxLatency"`
+}
+
+// Metrics collects the metrics of the calls to an adapter
+type Metrics struct {
+	lock    sync.Mutex
+	methods map[string]*MethodMetrics
+}
+
+// NewMetrics returns empty metrics
+func NewMetrics() *Metrics {
+	return &Metrics{methods: make(map[string]*MethodMetrics)}
+}
+
+// Interceptor records the latency and the error of every call
+func (m *Metrics) Interceptor() Interceptor {
+	return func(ctx context.Context, method string, next Call) error {
+		start := time.Now()
+		err := next(ctx)
+		m.record(method, time.Since(start), err)
+		return err
+	}
+}
+
+func (m *Metrics) record(method string, latency time.Duration, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	mm, ok := m.methods[method]
+	if !ok {
+		mm = &MethodMetrics{Errors: make(map[string]uint64)}
+		m.methods[method] = mm
+	}
+	mm.Calls++
+	mm.TotalLatency += latency
+	if latency > mm.MaxLatency {
+		mm.MaxLatency = latency
+	}
+	if err != nil {
+		mm.Errors[errorKindName(err)]++
+	}
+}
+
+// Snapshot returns a copy of the metrics of every called method
+func (m *Metrics) Snapshot() map[string]MethodMetrics {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	out := make(map[string]MethodMetrics, len(m.methods))
+	for method, mm := range m.methods {
+		c := *mm
+		c.Errors = make(map[string]uint64, len(mm.Errors))
+		for kind, n := range mm.Errors {
+			c.Errors[kind] = n
+		}
+		out[method] = c
+	}
+	return out
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package middleware wraps adapters in interceptors, such as retries, metrics and call logging,
+// without changing the adapters themselves.
+package middleware
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+// Call runs an adapter method with the context
+type Call func(ctx context.Context) error
+
+// Interceptor runs around every adapter call. The method is named after the AdapterInterface method,
+// and next calls the following interceptor or the adapter itself.
+type Interceptor func(ctx context.Context, method string, next Call) error
+
+// Wrap returns the adapter with the interceptors. The first interceptor is the outermost one.
+// Capabilities and NewStreamRuntime are not intercepted.
+func Wrap(adapter adapters.AdapterInterface, interceptors ...Interceptor) adapters.AdapterInterface {
+	if len(interceptors) == 0 {
+		return adapter
+	}
+	return &wrappedAdapter{adapter: adapter, interceptors: interceptors}
+}
+
+type wrappedAdapter struct {
+	adapter      adapters.AdapterInterface
+	interceptors []Interceptor
+}
+
+// call runs the method through the interceptors
+func (w *wrappedAdapter) call(ctx context.Context, method string, call Call) error {
+	next := call
+	for i := len(w.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := w.interceptors[i], next
+		next = func(ctx context.Context) error {
+			return interceptor(ctx, method, inner)
+		}
+	}
+	return next(ctx)
+}
+
+func (w *wrappedAdapter) RunPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return w.call(ctx, "RunPodSandbox", func(ctx context.Context) error {
+		return w.adapter.RunPodSandbox(ctx, sandbox)
+	})
+}
+
+func (w *wrappedAdapter) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return w.call(ctx, "StopPodSandbox", func(ctx context.Context) error {
+		return w.adapter.StopPodSandbox(ctx, sandbox)
+	})
+}
+
+func (w *wrappedAdapter) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return w.call(ctx, "RemovePodSandbox", func(ctx context.Context) error {
+		return w.adapter.RemovePodSandbox(ctx, sandbox)
+	})
+}
+
+func (w *wrappedAdapter) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return w.call(ctx, "PodSandboxStatus", func(ctx context.Context) error {
+		return w.adapter.PodSandboxStatus(ctx, sandbox)
+	})
+}
+
+func (w *wrappedAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	var response *runtimeApi.VersionResponse
+	err := w.call(ctx, "Version", func(ctx context.Context) (err error) {
+		response, err = w.adapter.Version(ctx)
+		return err
+	})
+	return response, err
+}
+
+func (w *wrappedAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "CreateContainer", func(ctx context.Context) error {
+		return w.adapter.CreateContainer(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "StartContainer", func(ctx context.Context) error {
+		return w.adapter.StartContainer(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "StopContainer", func(ctx context.Context) error {
+		return w.adapter.StopContainer(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "ContainerStatus", func(ctx context.Context) error {
+		return w.adapter.ContainerStatus(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "ReopenContainerLog", func(ctx context.Context) error {
+		return w.adapter.ReopenContainerLog(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return w.call(ctx, "UpdateContainerResources", func(ctx context.Context) error {
+		return w.adapter.UpdateContainerResources(ctx, cm)
+	})
+}
+
+func (w *wrappedAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	return w.call(ctx, "PullImage", func(ctx context.Context) error {
+		return w.adapter.PullImage(ctx, image)
+	})
+}
+
+func (w *wrappedAdapter) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	return w.call(ctx, "ListImages", func(ctx context.Context) error {
+		return w.adapter.ListImages(ctx, images)
+	})
+}
+
+func (w *wrappedAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return w.call(ctx, "ImageStatus", func(ctx context.Context) error {
+		return w.adapter.ImageStatus(ctx, image)
+	})
+}
+
+func (w *wrappedAdapter) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	var response *runtimeApi.ImageFsInfoResponse
+	err := w.call(ctx, "ImageFsInfo", func(ctx context.Context) (err error) {
+		response, err = w.adapter.ImageFsInfo(ctx)
+		return err
+	})
+	return response, err
+}
+
+func (w *wrappedAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	return w.call(ctx, "RemoveImage", func(ctx context.Context) error {
+		return w.adapter.RemoveImage(ctx, image)
+	})
+}
+
+func (w *wrappedAdapter) Capabilities() adapters.Capabilities {
+	return w.adapter.Capabilities()
+}
+
+func (w *wrappedAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	var response *runtimeApi.ExecSyncResponse
+	err := w.call(ctx, "ExecSync", func(ctx context.Context) (err error) {
+		response, err = w.adapter.ExecSync(ctx, cm, command)
+		return err
+	})
+	return response, err
+}
+
+func (w *wrappedAdapter) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
+	var response *runtimeApi.ExecResponse
+	err := w.call(ctx, "Exec", func(ctx context.Context) (err error) {
+		response, err = w.adapter.Exec(ctx, cm, req)
+		return err
+	})
+	return response, err
+}
+
+func (w *wrappedAdapter) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
+	var response *runtimeApi.AttachResponse
+	err := w.call(ctx, "Attach", func(ctx context.Context) (err error) {
+		response, err = w.adapter.Attach(ctx, cm, req)
+		return err
+	})
+	return response, err
+}
+
+func (w *wrappedAdapter) NewStreamRuntime(c store.ContainerStoreInterface) streaming.Runtime {
+	return w.adapter.NewStreamRuntime(c)
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+)
+
+// failingAdapter fails the first calls of ContainerStatus and StopContainer with its error
+type failingAdapter struct {
+	adapters.AdapterInterface
+	err   error
+	fails int
+	calls int
+}
+
+func (f *failingAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	f.calls++
+	if f.calls <= f.fails {
+		return f.err
+	}
+	return nil
+}
+
+func (f *failingAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	return f.ContainerStatus(ctx, cm)
+}
+
+//Test only the transient errors of the methods with a retry policy are retried
+func TestUnitRetry(t *testing.T) {
+	policies := map[string]RetryPolicy{"ContainerStatus": {Attempts: 3, Backoff: time.Millisecond}}
+	tests := []struct {
+		name      string
+		err       error
+		fails     int
+		stop      bool
+		wantCalls int
+		wantErr   bool
+	}{
+		{"transient error", adapters.UnavailableError("cluster down"), 2, false, 3, false},
+		{"attempts exhausted", adapters.UnavailableError("cluster down"), 5, false, 3, true},
+		{"permanent error", adapters.NotFoundError("job not found"), 5, false, 1, true},
+		{"unclassified error", fmt.Errorf("failure"), 5, false, 1, true},
+		{"method without policy", adapters.UnavailableError("cluster down"), 5, true, 1, true},
+	}
+	for _, test := range tests {
+		a := &failingAdapter{err: test.err, fails: test.fails}
+		wrapped := Wrap(a, Retry(policies))
+		var err error
+		if test.stop {
+			err = wrapped.StopContainer(context.Background(), &store.ContainerMetadata{})
+		} else {
+			err = wrapped.ContainerStatus(context.Background(), &store.ContainerMetadata{})
+		}
+		if a.calls != test.wantCalls || (err != nil) != test.wantErr {
+			t.Errorf("%s: %d calls and error %v", test.name, a.calls, err)
+		}
+	}
+}
+
+//Test retries stop when the context is done
+func TestUnitRetryContext(t *testing.T) {
+	a := &failingAdapter{err: adapters.UnavailableError("cluster down"), fails: 5}
+	wrapped := Wrap(a, Retry(map[string]RetryPolicy{"ContainerStatus": {Attempts: 5, Backoff: time.Hour}}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := wrapped.ContainerStatus(ctx, &store.ContainerMetadata{}); err == nil || a.calls != 1 {
+		t.Errorf("Retry must stop with the context: %d calls and error %v", a.calls, err)
+	}
+}
+
+//Test the interceptors run in order and the metrics count the calls and errors
+func TestUnitMetricsChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, method string, next Call) error {
+			order = append(order, name+":"+method)
+			return next(ctx)
+		}
+	}
+	metrics := NewMetrics()
+	a := &failingAdapter{err: adapters.UnavailableError("cluster down"), fails: 1}
+	wrapped := Wrap(a, trace("outer"), metrics.Interceptor(), trace("inner"))
+	wrapped.StopContainer(context.Background(), &store.ContainerMetadata{})
+	wrapped.StopContainer(context.Background(), &store.ContainerMetadata{})
+
+	if fmt.Sprint(order) != "[outer:StopContainer inner:StopContainer outer:StopContainer inner:StopContainer]" {
+		t.Errorf("Interceptors run in wrong order: %v", order)
+	}
+	stop := metrics.Snapshot()["StopContainer"]
+	if stop.Calls != 2 || stop.Errors[string(adapters.KindUnavailable)] != 1 {
+		t.Errorf("Metrics wrong: %+v", stop)
+	}
+}
+
+//Test retry policies parsing
+func TestUnitParseRetryPolicies(t *testing.T) {
+	policies, err := ParseRetryPolicies(DefaultRetries, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies["ContainerStatus"].Attempts != 3 || policies["ContainerStatus"].Backoff != time.Second {
+		t.Errorf("Default retries wrong: %v", policies)
+	}
+	if policies, err := ParseRetryPolicies("StopContainer=2, RemoveImage=2", time.Second); err != nil || len(policies) != 2 {
+		t.Errorf("Idempotent methods should be retried: %v %v", policies, err)
+	}
+	for _, retries := range []string{"ContainerStatus", "ContainerStatus=0", "=3", "ContainerStatus=three", "ContainerStatuses=3",
+		"Capabilities=3", "StartContainer=3", "CreateContainer=2", "ExecSync=2"} {
+		if _, err := ParseRetryPolicies(retries, time.Second); err == nil {
+			t.Errorf("Retries %q should be rejected", retries)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultRetries retries the idempotent calls, which only read the state of the backend
+	DefaultRetries = "ContainerStatus=3,PodSandboxStatus=3,ImageStatus=3,ListImages=3,Version=3,ImageFsInfo=3"
+	// DefaultRetryBackoff is the wait before the first retry. It doubles on every retry.
+	DefaultRetryBackoff = time.Second
+	// maxRetryBackoff limits the wait between retries
+	maxRetryBackoff = 30 * time.Second
+)
+
+// idempotentMethods are the methods which can be retried: the ones which only read the state of the backend,
+// and the ones which the CRI defines idempotent, whose second call does nothing
+var idempotentMethods = map[string]bool{
+	"Version":          true,
+	"PodSandboxStatus": true,
+	"ContainerStatus":  true,
+	"ListImages":       true,
+	"ImageStatus":      true,
+	"ImageFsInfo":      true,
+	"StopPodSandbox":   true,
+	"RemovePodSandbox": true,
+	"StopContainer":    true,
+	"RemoveImage":      true,
+}
+
+// RetryPolicy sets how many times a method is called before its error is returned
+type RetryPolicy struct {
+	// Attempts is the maximum number of calls, including the first one
+	Attempts int
+	// Backoff is the wait before the first retry. It doubles on every retry, up to 30 seconds.
+	Backoff time.Duration
+}
+
+// ParseRetryPolicies parses the retry policies in "Method=attempts,Method=attempts" format. The methods
+// are named after the AdapterInterface methods, and only the idempotent ones can be retried.
+func ParseRetryPolicies(retries string, backoff time.Duration) (map[string]RetryPolicy, error) {
+	if backoff < 0 {
+		return nil, fmt.Errorf("Retry backoff can not be negative")
+	}
+	out := make(map[string]RetryPolicy)
+	if strings.TrimSpace(retries) == "" {
+		return out, nil
+	}
+	for _, retry := range strings.Split(retries, ",") {
+		kv := strings.SplitN(retry, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Bad format for adapter retry %q. It must be method=attempts", retry)
+		}
+		method := strings.TrimSpace(kv[0])
+		if !adapters.IsOperation(method) {
+			return nil, fmt.Errorf("Unknown adapter operation %s in adapter retry %q", method, retry)
+		}
+		if !idempotentMethods[method] {
+			return nil, fmt.Errorf("Adapter operation %s in adapter retry %q is not idempotent and can not be retried", method, retry)
+		}
+		attempts, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("Bad number of attempts for adapter retry %q", retry)
+		}
+		out[method] = RetryPolicy{Attempts: attempts, Backoff: backoff}
+	}
+	return out, nil
+}
+
+// Retry calls again the methods with a policy while they fail with adapters.KindUnavailable errors.
+// It stops when the context is done, so the retries never exceed the deadline of the operation.
+func Retry(policies map[string]RetryPolicy) Interceptor {
+	return func(ctx context.Context, method string, next Call) error {
+		policy, ok := policies[method]
+		if !ok || policy.Attempts <= 1 {
+			return next(ctx)
+		}
+		backoff := policy.Backoff
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = next(ctx)
+			if err == nil || attempt >= policy.Attempts || !retryable(err) {
+				return err
+			}
+			klog.V(4).Infof("Retrying adapter call method=%s attempt=%d backoff=%s error=%q", method, attempt, backoff, err)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+		}
+	}
+}
+
+// retryable returns whether the error is transient
+func retryable(err error) bool {
+	return adapters.ErrorKindOf(err) == adapters.KindUnavailable
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pbs implements the adapter of PBS Pro and Torque clusters. Containers are submitted as
+// batch jobs with qsub through SSH, like in the Slurm adapter.
+package pbs
+
+import (
+	"fmt"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	PBSADAPTERVERSION = "0.1.0"
+	PBSNAME           = "Adapter PBS"
+	MOUNTHPATH        = "multi-cri"
+	// FlavorPro and FlavorTorque select the resource request syntax
+	FlavorPro    = "pro"
+	FlavorTorque = "torque"
+)
+
+type PBSAdapter struct {
+	batch.Base
+	ImageRemoteMount string
+	// Flavor is the PBS implementation of the cluster, FlavorPro or FlavorTorque
+	Flavor string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "pbs",
+		Description: "Submits containers as batch jobs to PBS Pro and Torque clusters",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the PBS cluster, relative to $HOME (CRI_PBS_MOUNT_PATH)",
+			"image-remote-mount": "Path in which the images are built (CRI_PBS_IMAGE_REMOTE_MOUNT)",
+			"build-in-cluster":   "Build images directly in the PBS cluster (CRI_PBS_BUILD_IN_CLUSTER)",
+			"flavor":             "PBS implementation, pro or torque (CRI_PBS_FLAVOR)",
+		},
+		Validate: validateConfig,
+		New:      NewPBSAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if _, err := config.GetBool("build-in-cluster", false); err != nil {
+		return err
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	if flavor := config.Get("flavor", FlavorPro); flavor != FlavorPro && flavor != FlavorTorque {
+		return fmt.Errorf("flavor must be %s or %s", FlavorPro, FlavorTorque)
+	}
+	return nil
+}
+
+// NewPBSAdapter creates the PBS adapter configured with the CRI_PBS_* environment variables
+func NewPBSAdapter() (adapters.AdapterInterface, error) {
+	return NewPBSAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewPBSAdapterWithConfig creates the PBS adapter. Options not set in the config
+// are read from the CRI_PBS_* environment variables
+func NewPBSAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	q := MOUNTHPATH
+	b := false
+	remoteDefault := ""
+	flavorDefault := FlavorPro
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_PBS_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_PBS_MOUNT_PATH", &q))
+	flavor := config.Get("flavor", common.GetEnv("CRI_PBS_FLAVOR", &flavorDefault))
+	if flavor != FlavorPro && flavor != FlavorTorque {
+		return nil, fmt.Errorf("PBS flavor must be %s or %s", FlavorPro, FlavorTorque)
+	}
+	buildInCluster, err := config.GetBool("build-in-cluster", common.GetBoolEnv("CRI_PBS_BUILD_IN_CLUSTER", &b))
+	if err != nil {
+		return nil, err
+	}
+	build, err := builder.NewImageBuilder(buildInCluster, mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return PBSAdapter{Base: batch.NewBase("PBS", mountP, build),
+		ImageRemoteMount: imageRemoteMountPath, Flavor: flavor}, nil
+}
+
+func (p PBSAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           PBSADAPTERVERSION,
+		RuntimeName:       PBSNAME,
+		RuntimeVersion:    PBSADAPTERVERSION,
+		RuntimeApiVersion: PBSADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted
+func (p PBSAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ImageFsInfo: true}
+}
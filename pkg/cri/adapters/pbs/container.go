@@ -0,0 +1,133 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pbs
+
+import (
+	"fmt"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// jobSettingRules are the job settings which PBS supports
+var jobSettingRules = []batch.JobSettingRule{batch.QueueRule, batch.TimeRule, batch.GresRule, batch.NodesRule,
+	batch.CoresRule, batch.CoresPerNodeRule, batch.TasksPerNodeRule, batch.CustomConfigRule}
+
+func (p PBSAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	klog.Infof("Creating container path in server")
+	if err := batch.ValidateJobSettings(cm, p.Name, jobSettingRules); err != nil {
+		return err
+	}
+	batch.SetupContainerPaths(cm, p.MountPath)
+	client, err := batch.NewClient(cm, pbsErrors)
+	if err != nil {
+		return err
+	}
+	//Ensure container path exists in PBS cluster
+	if err := client.MakeDir(ctx, cm.Extra["RMPath"]); err != nil {
+		return err
+	}
+	//Pull image in PBS cluster
+	if err := p.Builder.PullImageInCluster(ctx, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container path in server with id %s", cm.ID)
+	return nil
+}
+
+func (p PBSAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, pbsErrors)
+	if err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	command := settings.Command(cm, builder.GetRMImagePath(cm, p.MountPath, p.ImageRemoteMount))
+	response, err := client.Submit(ctx, cm, settings, batchScript(settings, p.Flavor, command),
+		fmt.Sprintf("qsub %s", batch.BatchScript))
+	if err != nil {
+		return err
+	}
+	cm.Pid, err = parseJobId(response)
+	return err
+}
+
+func (p PBSAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, pbsErrors)
+	if err != nil {
+		return err
+	}
+	_, err = client.Run(ctx, fmt.Sprintf("qdel %d", cm.Pid))
+	if adapters.IsNotFound(err) {
+		// The job already finished
+		return nil
+	}
+	return err
+}
+
+func (p PBSAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Pid == 0 {
+		return nil
+	}
+	client, err := batch.NewClient(cm, pbsErrors)
+	if err != nil {
+		return err
+	}
+	status, err := jobStatus(ctx, client, cm.Pid)
+	if err != nil {
+		return err
+	}
+	status.Apply(cm)
+	if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+		client.CopyOutput(ctx, cm)
+	}
+	return nil
+}
+
+// jobStatus queries the job with qstat, and with tracejob when qstat does not know the job anymore
+// or does not support JSON output, as in Torque
+func jobStatus(ctx context.Context, client *batch.Client, pid int) (*batch.JobStatus, error) {
+	out, err := client.Output(ctx, fmt.Sprintf("qstat -f -F json -x %d", pid))
+	if err == nil {
+		var status *batch.JobStatus
+		if status, err = parseQstat(out); err == nil {
+			return status, nil
+		}
+	}
+	klog.V(5).Infof("qstat command fails. %s", err)
+	qstatErr := err
+	out, err = client.Output(ctx, fmt.Sprintf("tracejob -n 7 %d 2>&1", pid))
+	if err != nil {
+		if adapters.IsNotFound(qstatErr) {
+			// tracejob is not installed in every server, so the job unknown to qstat does not exist
+			return nil, qstatErr
+		}
+		return nil, batch.WrapError(err, "Retrieve job info fails %s ", err)
+	}
+	return parseTracejob(out, pid)
+}
+
+func (p PBSAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("PBS: ReopenContainerLog not implemented")
+}
+
+func (p PBSAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("PBS: UpdateContainerResources not implemented")
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pbs
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// pbsErrors classifies the PBS command errors by their message
+var pbsErrors = []batch.ErrorPattern{
+	{"unknown queue", adapters.KindInvalidArgument},
+	{"unknown resource", adapters.KindInvalidArgument},
+	{"illegal attribute or resource value", adapters.KindInvalidArgument},
+	{"job exceeds queue resource limits", adapters.KindInvalidArgument},
+	{"job violates queue and/or server resource limits", adapters.KindInvalidArgument},
+	{"unknown job id", adapters.KindNotFound},
+	{"job has finished", adapters.KindNotFound},
+	{"unauthorized request", adapters.KindPermissionDenied},
+	{"access from host not allowed", adapters.KindPermissionDenied},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"cannot connect to server", adapters.KindUnavailable},
+	{"communication failure", adapters.KindUnavailable},
+	{"server is not available", adapters.KindUnavailable},
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pbs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// batchScript returns the job script with the #PBS directives of the job settings. The environment
+// exported by the run script is passed to the job with -V.
+func batchScript(settings batch.JobSettings, flavor, command string) []string {
+	lines := []string{
+		"#!/bin/bash",
+		fmt.Sprintf("#PBS -N %s", settings.Name),
+		fmt.Sprintf("#PBS -o %s", batch.StdoutFile),
+		fmt.Sprintf("#PBS -e %s", batch.SterrFile),
+		"#PBS -V",
+	}
+	if settings.Queue != "" {
+		lines = append(lines, fmt.Sprintf("#PBS -q %s", settings.Queue))
+	}
+	if r := resources(settings, flavor); r != "" {
+		lines = append(lines, fmt.Sprintf("#PBS -l %s", r))
+	}
+	// PBS has no unlimited walltime, the jobs without walltime get the default of the queue
+	if seconds := settings.TimeLimitSeconds(); seconds > 0 {
+		lines = append(lines, fmt.Sprintf("#PBS -l walltime=%s", batch.Walltime(seconds)))
+	}
+	if settings.CustomConfig != "" {
+		lines = append(lines, settings.CustomConfig)
+	}
+	// PBS runs the jobs in $HOME, while the output files are relative to the submission directory
+	return append(lines, "cd $PBS_O_WORKDIR", command)
+}
+
+// resources returns the resource request of the job: a select statement in PBS Pro,
+// or nodes and processors per node in Torque. It is empty when no resources are requested.
+func resources(settings batch.JobSettings, flavor string) string {
+	if settings.Nodes == "" && settings.CoresPerNode == "" && settings.Cores == "" &&
+		settings.TasksPerNode == "" && settings.GPU == "" {
+		return ""
+	}
+	nodes := settings.NodeCount()
+	cores := settings.CoresPerNodeCount()
+	gpus := settings.GPUCount()
+	if flavor == FlavorTorque {
+		r := fmt.Sprintf("nodes=%d", nodes)
+		if cores == 0 && settings.TasksPerNode != "" {
+			r = fmt.Sprintf("%s:ppn=%s", r, settings.TasksPerNode)
+		} else if cores > 0 {
+			r = fmt.Sprintf("%s:ppn=%d", r, cores)
+		}
+		if gpus > 0 {
+			r = fmt.Sprintf("%s:gpus=%d", r, gpus)
+		}
+		return r
+	}
+	r := fmt.Sprintf("select=%d", nodes)
+	if cores > 0 {
+		r = fmt.Sprintf("%s:ncpus=%d", r, cores)
+	}
+	if settings.TasksPerNode != "" {
+		r = fmt.Sprintf("%s:mpiprocs=%s", r, settings.TasksPerNode)
+	}
+	if gpus > 0 {
+		r = fmt.Sprintf("%s:ngpus=%d", r, gpus)
+	}
+	return r
+}
+
+// parseJobId returns the number of the job id printed by qsub, e.g. 1234 for 1234.server
+func parseJobId(response string) (int, error) {
+	id := strings.TrimSpace(response)
+	if lines := strings.Split(id, "\n"); len(lines) > 1 {
+		id = strings.TrimSpace(lines[len(lines)-1])
+	}
+	pid, err := strconv.Atoi(strings.SplitN(id, ".", 2)[0])
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("Not submitted batch job id found: %s ", response)
+	}
+	return pid, nil
+}
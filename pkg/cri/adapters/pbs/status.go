@@ -0,0 +1,154 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pbs
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// qstatTimeLayout is the time format of qstat, e.g. "Mon Oct 14 10:00:00 2019"
+	qstatTimeLayout = time.ANSIC
+	// tracejobTimeLayout is the time format of the tracejob lines, e.g. "10/14/2019 10:00:00"
+	tracejobTimeLayout = "01/02/2006 15:04:05"
+	// signalExitStatus is added to the signal number in the exit status of the killed jobs
+	signalExitStatus = 256
+)
+
+// qstatJob contains the fields of the qstat -f -F json output used by the adapter
+type qstatJob struct {
+	JobState   string `json:"job_state"`
+	ExitStatus *int   `json:"Exit_status"`
+	StartTime  string `json:"stime"`
+	ObitTime   string `json:"obittime"`
+	Comment    string `json:"comment"`
+}
+
+type qstatOutput struct {
+	Jobs map[string]qstatJob `json:"Jobs"`
+}
+
+// parseQstat parses the output of qstat -f -F json for a single job
+func parseQstat(output string) (*batch.JobStatus, error) {
+	var out qstatOutput
+	if err := json.Unmarshal([]byte(output), &out); err != nil {
+		return nil, fmt.Errorf("qstat output cannot be parsed: %v", err)
+	}
+	for _, job := range out.Jobs {
+		status := &batch.JobStatus{
+			State:      jobState(job.JobState),
+			StartedAt:  parseTime(qstatTimeLayout, job.StartTime),
+			FinishedAt: parseTime(qstatTimeLayout, job.ObitTime),
+		}
+		if status.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+			exitStatus := 0
+			if job.ExitStatus != nil {
+				exitStatus = *job.ExitStatus
+			}
+			setExitStatus(status, exitStatus)
+		}
+		return status, nil
+	}
+	return nil, adapters.NotFoundError("Job not found in qstat output")
+}
+
+// jobState maps the PBS job states to the container states. Queued, held, waiting and moving jobs
+// are created; running, exiting, suspended and begun array jobs are running; finished jobs are exited.
+func jobState(state string) runtimeApi.ContainerState {
+	switch state {
+	case "R", "E", "S", "U", "B":
+		return runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "F", "X", "C":
+		return runtimeApi.ContainerState_CONTAINER_EXITED
+	default:
+		return runtimeApi.ContainerState_CONTAINER_CREATED
+	}
+}
+
+// setExitStatus translates the PBS exit status. Negative values mean PBS could not run the job,
+// and values over 256 that the job was killed by the signal exitStatus - 256.
+func setExitStatus(status *batch.JobStatus, exitStatus int) {
+	switch {
+	case exitStatus < 0:
+		status.ExitCode = 1
+		status.Reason = batch.ReasonCannotRun
+	case exitStatus > signalExitStatus:
+		status.ExitCode = 128 + exitStatus - signalExitStatus
+		status.Reason = batch.ReasonKilled
+	default:
+		status.ExitCode = exitStatus
+	}
+}
+
+var (
+	tracejobLine = regexp.MustCompile(`^(\d\d/\d\d/\d{4} \d\d:\d\d:\d\d)(\.\d+)?\s+\w\s+(.*)$`)
+	exitStatusRe = regexp.MustCompile(`Exit_status=(-?\d+)`)
+)
+
+// parseTracejob parses the job events of the tracejob output, which is read from the
+// server logs once qstat does not know the job anymore
+func parseTracejob(output string, pid int) (*batch.JobStatus, error) {
+	var status *batch.JobStatus
+	for _, line := range strings.Split(output, "\n") {
+		m := tracejobLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		at := parseTime(tracejobTimeLayout, m[1])
+		event := m[3]
+		if status == nil {
+			status = &batch.JobStatus{State: runtimeApi.ContainerState_CONTAINER_CREATED}
+		}
+		switch {
+		case strings.HasPrefix(event, "Job Run at"):
+			status.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+			status.StartedAt = at
+		case exitStatusRe.MatchString(event):
+			exitStatus, _ := strconv.Atoi(exitStatusRe.FindStringSubmatch(event)[1])
+			status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+			status.FinishedAt = at
+			setExitStatus(status, exitStatus)
+		case strings.HasPrefix(event, "Job deleted at request of") &&
+			status.State != runtimeApi.ContainerState_CONTAINER_EXITED:
+			status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+			status.FinishedAt = at
+			status.ExitCode = 1
+			status.Reason = batch.ReasonKilled
+		}
+	}
+	if status == nil {
+		return nil, adapters.NotFoundError("Job %d not found", pid)
+	}
+	return status, nil
+}
+
+// parseTime returns the time in nanoseconds, or 0 when it can not be parsed
+func parseTime(layout, value string) int64 {
+	t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local)
+	if err != nil {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pbs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the job settings are translated to PBS Pro and Torque resources
+func TestUnitResources(t *testing.T) {
+	for _, c := range []struct {
+		settings batch.JobSettings
+		flavor   string
+		expected string
+	}{
+		{batch.JobSettings{}, FlavorPro, ""},
+		{batch.JobSettings{Nodes: "2", CoresPerNode: "4", TasksPerNode: "4"}, FlavorPro, "select=2:ncpus=4:mpiprocs=4"},
+		{batch.JobSettings{Nodes: "2", Cores: "6", GPU: "gpu:2"}, FlavorPro, "select=2:ncpus=3:ngpus=2"},
+		{batch.JobSettings{Nodes: "2", CoresPerNode: "4", GPU: "gpu"}, FlavorTorque, "nodes=2:ppn=4:gpus=1"},
+		{batch.JobSettings{TasksPerNode: "8"}, FlavorTorque, "nodes=1:ppn=8"},
+	} {
+		if r := resources(c.settings, c.flavor); r != c.expected {
+			t.Errorf("Resources of %+v in %s should be %q instead of %q", c.settings, c.flavor, c.expected, r)
+		}
+	}
+	script := strings.Join(batchScript(batch.JobSettings{Name: "test", Queue: "workq", Cores: "2", TimeLimit: "90"}, FlavorPro,
+		"singularity exec image hostname"), "\n")
+	for _, line := range []string{"#PBS -N test", "#PBS -q workq", "#PBS -l select=1:ncpus=2", "#PBS -l walltime=01:30:00",
+		"#PBS -V", "cd $PBS_O_WORKDIR\nsingularity exec image hostname"} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Batch script should contain %q:\n%s", line, script)
+		}
+	}
+	if script := strings.Join(batchScript(batch.JobSettings{Name: "test", TimeLimit: "UNLIMITED"}, FlavorPro, "hostname"), "\n"); strings.Contains(script, "walltime") {
+		t.Errorf("Unlimited jobs should have the walltime of the queue:\n%s", script)
+	}
+}
+
+//Test the containers with job settings which PBS does not support are not created
+func TestUnitUnsupportedJobSettings(t *testing.T) {
+	cm := &store.ContainerMetadata{Name: "compute", Config: runtimeApi.ContainerConfig{Annotations: map[string]string{
+		batch.JobAnnotationPrefix + "qos": "high"}}}
+	err := PBSAdapter{Base: batch.NewBase("PBS", "", nil)}.CreateContainer(context.Background(), cm)
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument || !strings.Contains(err.Error(), "PBS does not support") {
+		t.Errorf("Unsupported job settings should be invalid arguments: %v", err)
+	}
+}
+
+//Test qsub job ids are parsed
+func TestUnitParseJobId(t *testing.T) {
+	if pid, err := parseJobId("1234.pbs-server\n"); err != nil || pid != 1234 {
+		t.Errorf("Job id should be 1234: %d %v", pid, err)
+	}
+	if _, err := parseJobId("qsub: Unknown queue"); err == nil {
+		t.Errorf("Wrong job ids should fail")
+	}
+}
+
+//Test qstat JSON output is translated to container states
+func TestUnitParseQstat(t *testing.T) {
+	qstat := `{"pbs_version":"19.1.1","Jobs":{"1234.server":{"job_state":"%s",%s"stime":"Mon Oct 14 10:00:00 2019","obittime":"Mon Oct 14 10:05:00 2019"}}}`
+	for _, c := range []struct {
+		state      string
+		exitStatus string
+		expected   runtimeApi.ContainerState
+		exitCode   int
+		reason     string
+	}{
+		{"Q", "", runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{"R", "", runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{"F", `"Exit_status":0,`, runtimeApi.ContainerState_CONTAINER_EXITED, 0, ""},
+		{"F", `"Exit_status":3,`, runtimeApi.ContainerState_CONTAINER_EXITED, 3, ""},
+		{"F", `"Exit_status":271,`, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonKilled},
+		{"F", `"Exit_status":-1,`, runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonCannotRun},
+	} {
+		status, err := parseQstat(fmt.Sprintf(qstat, c.state, c.exitStatus))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != c.expected || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("State %s %s wrong: %+v", c.state, c.exitStatus, status)
+		}
+		if status.StartedAt == 0 {
+			t.Errorf("Start time should be parsed: %+v", status)
+		}
+	}
+	if _, err := parseQstat(`{"Jobs":{}}`); !adapters.IsNotFound(err) {
+		t.Errorf("Missing jobs should not be found: %v", err)
+	}
+}
+
+//Test tracejob events are translated to container states
+func TestUnitParseTracejob(t *testing.T) {
+	tracejob := `
+Job: 1234.server
+
+10/14/2019 10:00:00  S    Job Queued at request of user@host, owner = user@host, job name = test, queue = workq
+10/14/2019 10:00:05  S    Job Run at request of Scheduler@server on exec_vnode (node1:ncpus=1)
+10/14/2019 10:05:00  S    Exit_status=2 resources_used.cpupercent=0 resources_used.cput=00:00:00
+10/14/2019 10:05:01  S    dequeuing from workq, state 5
+`
+	status, err := parseTracejob(tracejob, 1234)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != runtimeApi.ContainerState_CONTAINER_EXITED || status.ExitCode != 2 ||
+		status.StartedAt == 0 || status.FinishedAt <= status.StartedAt {
+		t.Errorf("Tracejob status wrong: %+v", status)
+	}
+	if _, err := parseTracejob("tracejob: Couldn't find Job Id 1234.server in logs of past 7 days", 1234); !adapters.IsNotFound(err) {
+		t.Errorf("Jobs without events should not be found: %v", err)
+	}
+}
+
+//Test PBS errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		kind     adapters.ErrorKind
+	}{
+		{"qsub: Unknown queue", adapters.KindInvalidArgument},
+		{"qdel: Unknown Job Id 1234.server", adapters.KindNotFound},
+		{"qsub: cannot connect to server pbs-server (errno=111)", adapters.KindUnavailable},
+		{"qsub: Unauthorized Request", adapters.KindPermissionDenied},
+	} {
+		err := batch.ClassifyError(pbsErrors, c.response, fmt.Errorf("exit status 1"))
+		if kind := adapters.ErrorKindOf(err); kind != c.kind {
+			t.Errorf("Error %q should be %q instead of %q", c.response, c.kind, kind)
+		}
+	}
+}
+
+// startCluster starts a fake cluster with the PBS commands of the adapter: qsub submits the script, qdel
+// cancels the job and qstat -f -F json shows it
+func startCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := fakecluster.Scheduler{Directives: "#PBS", Name: "-N", Stdout: "-o", Stderr: "-e",
+		Submitted: "%d.fakecluster\n", WorkDirEnv: "PBS_O_WORKDIR", Unknown: "qdel: Unknown Job Id %s\n", UnknownStatus: 153}
+	cluster.AddBuiltin("qsub", cluster.SubmitCommand(scheduler))
+	cluster.AddBuiltin("qdel", cluster.CancelCommand(scheduler))
+	cluster.AddBuiltin("qstat", func(s fakecluster.Session, args []string) int {
+		id, _ := strconv.Atoi(args[len(args)-1])
+		job, ok := cluster.Job(id)
+		if !ok {
+			fmt.Fprintf(s.Stderr, "qstat: Unknown Job Id %s\n", args[len(args)-1])
+			return 153
+		}
+		attributes := map[string]interface{}{"job_state": "Q"}
+		if !job.StartTime.IsZero() {
+			attributes["job_state"] = "R"
+			attributes["stime"] = job.StartTime.Format(qstatTimeLayout)
+		}
+		if !job.EndTime.IsZero() {
+			exitStatus := job.ExitCode
+			if job.State == fakecluster.StateCancelled {
+				exitStatus = signalExitStatus + 15
+			}
+			attributes["job_state"] = "F"
+			attributes["Exit_status"] = exitStatus
+			attributes["obittime"] = job.EndTime.Format(qstatTimeLayout)
+		}
+		jobs := map[string]interface{}{fmt.Sprintf("%d.fakecluster", id): attributes}
+		json.NewEncoder(s.Stdout).Encode(map[string]interface{}{"Jobs": jobs})
+		return 0
+	})
+	return cluster
+}
+
+//Test the adapter passes the conformance suite against a fake PBS cluster
+func TestUnitConformance(t *testing.T) {
+	cluster := startCluster(t)
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewPBSAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+// capabilitiesTimeout is the deadline to get the capabilities of the external adapter
+const capabilitiesTimeout = 10 * time.Second
+
+// Client is an adapter which forwards the calls to an external adapter
+type Client struct {
+	conn *grpc.ClientConn
+	// capabilities of the external adapter, once they are known
+	capabilities     *adapters.Capabilities
+	capabilitiesLock sync.Mutex
+}
+
+// Dial connects to the adapter served on the unix socket. The connection is established in the
+// background, so the external adapter may not be running yet. Calls fail while it is down.
+func Dial(socket string) (*Client, error) {
+	conn, err := grpc.Dial(socket, grpc.WithInsecure(),
+		grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", addr, timeout)
+		}),
+		grpc.WithBackoffMaxDelay(5*time.Second),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to adapter socket %s: %v", socket, err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection with the external adapter
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) invoke(ctx context.Context, method string, req *Request) (*Response, error) {
+	out := &Response{}
+	if err := c.conn.Invoke(ctx, fullMethod(method), req, out); err != nil {
+		if s, ok := status.FromError(err); ok {
+			return nil, adapters.NewError(codeKinds[s.Code()], "Adapter %s failed: %s", method, s.Message())
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// codeKinds classifies the errors of the calls which do not reach the external adapter
+var codeKinds = map[codes.Code]adapters.ErrorKind{
+	codes.Unavailable:     adapters.KindUnavailable,
+	codes.Unimplemented:   adapters.KindUnimplemented,
+	codes.InvalidArgument: adapters.KindInvalidArgument,
+}
+
+func responseError(out *Response) error {
+	if out.Error != "" {
+		return adapters.NewError(out.ErrorKind, "%s", out.Error)
+	}
+	return nil
+}
+
+func (c *Client) sandboxCall(ctx context.Context, method string, sandbox *store.SandboxMetadata) error {
+	out, err := c.invoke(ctx, method, &Request{Sandbox: sandbox})
+	if err != nil {
+		return err
+	}
+	if out.Sandbox != nil {
+		*sandbox = *out.Sandbox
+	}
+	return responseError(out)
+}
+
+func (c *Client) containerCall(ctx context.Context, method string, cm *store.ContainerMetadata, req *Request) (*Response, error) {
+	req.Container = cm
+	out, err := c.invoke(ctx, method, req)
+	if err != nil {
+		return nil, err
+	}
+	if out.Container != nil {
+		// The image is shared with the image store, so the copy of the plugin does not replace it
+		image := cm.Image
+		*cm = *out.Container
+		cm.Image = image
+	}
+	return out, responseError(out)
+}
+
+func (c *Client) imageCall(ctx context.Context, method string, image *store.ImageMetadata) error {
+	out, err := c.invoke(ctx, method, &Request{Image: image})
+	if err != nil {
+		return err
+	}
+	if out.Image != nil {
+		*image = *out.Image
+	}
+	return responseError(out)
+}
+
+func (c *Client) RunPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return c.sandboxCall(ctx, MethodRunPodSandbox, sandbox)
+}
+
+func (c *Client) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return c.sandboxCall(ctx, MethodStopPodSandbox, sandbox)
+}
+
+func (c *Client) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return c.sandboxCall(ctx, MethodRemovePodSandbox, sandbox)
+}
+
+func (c *Client) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return c.sandboxCall(ctx, MethodPodSandboxStatus, sandbox)
+}
+
+func (c *Client) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	out, err := c.invoke(ctx, MethodVersion, &Request{})
+	if err != nil {
+		return nil, err
+	}
+	return out.Version, responseError(out)
+}
+
+func (c *Client) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodCreateContainer, cm, &Request{})
+	return err
+}
+
+func (c *Client) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodStartContainer, cm, &Request{})
+	return err
+}
+
+func (c *Client) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodStopContainer, cm, &Request{})
+	return err
+}
+
+func (c *Client) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodContainerStatus, cm, &Request{})
+	return err
+}
+
+func (c *Client) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodReopenContainerLog, cm, &Request{})
+	return err
+}
+
+func (c *Client) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	_, err := c.containerCall(ctx, MethodUpdateContainerResources, cm, &Request{})
+	return err
+}
+
+func (c *Client) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	return c.imageCall(ctx, MethodPullImage, image)
+}
+
+func (c *Client) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	out, err := c.invoke(ctx, MethodListImages, &Request{Images: images})
+	if err != nil {
+		return err
+	}
+	// The slice can not grow, but the adapter may update its images
+	for i := range images {
+		if i < len(out.Images) && out.Images[i] != nil {
+			*images[i] = *out.Images[i]
+		}
+	}
+	return responseError(out)
+}
+
+func (c *Client) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return c.imageCall(ctx, MethodImageStatus, image)
+}
+
+func (c *Client) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	out, err := c.invoke(ctx, MethodImageFsInfo, &Request{})
+	if err != nil {
+		return nil, err
+	}
+	return out.ImageFsInfo, responseError(out)
+}
+
+func (c *Client) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	return c.imageCall(ctx, MethodRemoveImage, image)
+}
+
+func (c *Client) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	out, err := c.containerCall(ctx, MethodExecSync, cm, &Request{Command: command})
+	if out == nil {
+		return nil, err
+	}
+	return out.ExecSync, err
+}
+
+func (c *Client) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
+	out, err := c.containerCall(ctx, MethodExec, cm, &Request{Exec: req})
+	if out == nil {
+		return nil, err
+	}
+	return out.Exec, err
+}
+
+func (c *Client) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
+	out, err := c.containerCall(ctx, MethodAttach, cm, &Request{Attach: req})
+	if out == nil {
+		return nil, err
+	}
+	return out.Attach, err
+}
+
+// Capabilities returns the capabilities of the external adapter. They are asked once the adapter is up. Until then,
+// every call is forwarded and fails with the adapter. Streaming is never supported because it is not forwarded.
+func (c *Client) Capabilities() adapters.Capabilities {
+	c.capabilitiesLock.Lock()
+	defer c.capabilitiesLock.Unlock()
+	if c.capabilities == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), capabilitiesTimeout)
+		defer cancel()
+		out, err := c.invoke(ctx, MethodCapabilities, &Request{})
+		if err != nil || out.Capabilities == nil {
+			klog.V(4).Infof("Capabilities of the external adapter not available: %v", err)
+			return withoutStreaming(adapters.AllCapabilities())
+		}
+		capabilities := withoutStreaming(*out.Capabilities)
+		c.capabilities = &capabilities
+	}
+	return *c.capabilities
+}
+
+func withoutStreaming(capabilities adapters.Capabilities) adapters.Capabilities {
+	capabilities.Exec, capabilities.Attach, capabilities.PortForward = false, false, false
+	return capabilities
+}
+
+// streamRuntime rejects the streaming requests, which are not forwarded to external adapters
+type streamRuntime struct{}
+
+func (c *Client) NewStreamRuntime(cs store.ContainerStoreInterface) streaming.Runtime {
+	return &streamRuntime{}
+}
+
+func (r *streamRuntime) Attach(containerID string, in io.Reader, out, err io.WriteCloser, tty bool,
+	resize <-chan remotecommand.TerminalSize) error {
+	return fmt.Errorf("Attach streaming is not supported by external adapters")
+}
+
+func (r *streamRuntime) Exec(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.WriteCloser,
+	tty bool, resize <-chan remotecommand.TerminalSize) error {
+	return fmt.Errorf("Exec streaming is not supported by external adapters")
+}
+
+func (r *streamRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	return fmt.Errorf("PortForward streaming is not supported by external adapters")
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// testAdapter implements the calls used by the tests
+type testAdapter struct {
+	adapters.AdapterInterface
+}
+
+func (a *testAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{RuntimeName: "test"}, nil
+}
+
+func (a *testAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	cm.Pid = 42
+	cm.Extra = map[string]string{"job": cm.Name}
+	return nil
+}
+
+func (a *testAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	cm.Reason = "scancel failed"
+	return adapters.NotFoundError("Job %d can not be cancelled", cm.Pid)
+}
+
+func (a *testAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ExecSync: true, Exec: true}
+}
+
+func (a *testAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	return &runtimeApi.ExecSyncResponse{Stdout: []byte(command[0])}, nil
+}
+
+func servePlugin(t *testing.T) (*Client, func()) {
+	dir, err := ioutil.TempDir("", "multicri-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "adapter.sock")
+	go Serve(&testAdapter{}, socket)
+	client, err := Dial(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() {
+		client.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+//Test adapter calls through the plugin protocol
+func TestUnitPluginCalls(t *testing.T) {
+	client, cleanup := servePlugin(t)
+	defer cleanup()
+
+	var version *runtimeApi.VersionResponse
+	var err error
+	// Wait until the server listens
+	for i := 0; i < 50; i++ {
+		if version, err = client.Version(context.Background()); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil || version.RuntimeName != "test" {
+		t.Fatalf("Version fails: %v %v", version, err)
+	}
+
+	image := &store.ImageMetadata{ID: "image1"}
+	cm := &store.ContainerMetadata{ID: "c1", Name: "test1", PodSandbox: store.SandboxMetadata{ID: "pod1"}, Image: image}
+	if err := client.StartContainer(context.Background(), cm); err != nil {
+		t.Fatalf("Start container fails: %v", err)
+	}
+	if cm.Pid != 42 || cm.Extra["job"] != "test1" || cm.PodSandbox.ID != "pod1" {
+		t.Errorf("Container metadata not updated: %+v", cm)
+	}
+	if cm.Image != image {
+		t.Errorf("Container image of the store must be kept: %+v", cm.Image)
+	}
+
+	err = client.StopContainer(context.Background(), cm)
+	if err == nil || err.Error() != "Job 42 can not be cancelled" || !adapters.IsNotFound(err) {
+		t.Errorf("Adapter error not forwarded: %v", err)
+	}
+	if cm.Reason != "scancel failed" {
+		t.Errorf("Container metadata must be updated on errors: %+v", cm)
+	}
+
+	capabilities := client.Capabilities()
+	if !capabilities.ExecSync || capabilities.Exec || capabilities.ReopenContainerLog {
+		t.Errorf("Capabilities wrong: %+v", capabilities)
+	}
+
+	out, err := client.ExecSync(context.Background(), cm, []string{"hostname"})
+	if err != nil || string(out.Stdout) != "hostname" {
+		t.Errorf("ExecSync fails: %v %v", out, err)
+	}
+}
+
+//Test proxy adapter configuration
+func TestUnitProxyConfig(t *testing.T) {
+	for _, config := range []adapters.AdapterConfig{{}, {"socket": "/tmp/a.sock", "args": "--serve-adapter=slurm"}, {"socket": "/tmp/a.sock", "other": "1"}} {
+		if _, err := adapters.NewAdapter("proxy", config); err == nil {
+			t.Errorf("Proxy configuration %v should fail", config)
+		}
+	}
+	a, err := adapters.NewAdapter("proxy", adapters.AdapterConfig{"socket": "/tmp/a.sock"})
+	if err != nil || a == nil {
+		t.Errorf("Proxy adapter should be created: %v", err)
+	}
+}
+
+//Test the proxy adapter stops the external adapter it supervises when it is closed
+func TestUnitProxySupervisor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multicri-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	a, err := adapters.NewAdapter("proxy", adapters.AdapterConfig{"socket": filepath.Join(dir, "adapter.sock"),
+		"command": "sh", "args": "-c 'sleep 60'"})
+	if err != nil {
+		t.Fatalf("Proxy adapter should be created: %v", err)
+	}
+	proxy := a.(*ProxyAdapter)
+	if len(proxy.supervisor.Args) != 2 || proxy.supervisor.Args[1] != "sleep 60" {
+		t.Errorf("Arguments wrong: %q", proxy.supervisor.Args)
+	}
+	process := proxy.supervisor.cmd.Process
+	proxy.Close()
+	for i := 0; i < 50 && process.Signal(syscall.Signal(0)) == nil; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if process.Signal(syscall.Signal(0)) == nil {
+		t.Errorf("External adapter should be stopped")
+	}
+}
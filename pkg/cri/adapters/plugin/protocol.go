@@ -0,0 +1,161 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin runs adapters out of the multi-cri process. The adapter is served by an external binary
+// over a unix socket, and multi-cri reaches it through the proxy adapter. The gRPC service mirrors
+// adapters.AdapterInterface and its messages carry the store metadata encoded as JSON.
+package plugin
+
+import (
+	"encoding/json"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// ServiceName is the gRPC service implemented by external adapters
+	ServiceName = "multicri.adapter.v1.Adapter"
+	// SocketEnv is the environment variable the proxy uses to tell the socket path to the adapter it starts
+	SocketEnv = "MULTICRI_ADAPTER_SOCKET"
+
+	codecName = "json"
+)
+
+// Methods of the adapter service
+const (
+	MethodRunPodSandbox            = "RunPodSandbox"
+	MethodStopPodSandbox           = "StopPodSandbox"
+	MethodRemovePodSandbox         = "RemovePodSandbox"
+	MethodPodSandboxStatus         = "PodSandboxStatus"
+	MethodVersion                  = "Version"
+	MethodCreateContainer          = "CreateContainer"
+	MethodStartContainer           = "StartContainer"
+	MethodStopContainer            = "StopContainer"
+	MethodContainerStatus          = "ContainerStatus"
+	MethodReopenContainerLog       = "ReopenContainerLog"
+	MethodUpdateContainerResources = "UpdateContainerResources"
+	MethodPullImage                = "PullImage"
+	MethodListImages               = "ListImages"
+	MethodImageStatus              = "ImageStatus"
+	MethodImageFsInfo              = "ImageFsInfo"
+	MethodRemoveImage              = "RemoveImage"
+	MethodExecSync                 = "ExecSync"
+	MethodExec                     = "Exec"
+	MethodAttach                   = "Attach"
+	MethodCapabilities             = "Capabilities"
+)
+
+var methods = []string{
+	MethodRunPodSandbox, MethodStopPodSandbox, MethodRemovePodSandbox, MethodPodSandboxStatus,
+	MethodVersion,
+	MethodCreateContainer, MethodStartContainer, MethodStopContainer, MethodContainerStatus,
+	MethodReopenContainerLog, MethodUpdateContainerResources,
+	MethodPullImage, MethodListImages, MethodImageStatus, MethodImageFsInfo, MethodRemoveImage,
+	MethodExecSync, MethodExec, MethodAttach,
+	MethodCapabilities,
+}
+
+// Request is the message sent to the external adapter. Only the fields used by the method are set.
+type Request struct {
+	Sandbox   *store.SandboxMetadata    `json:"sandbox,omitempty"`
+	Container *store.ContainerMetadata  `json:"container,omitempty"`
+	Image     *store.ImageMetadata      `json:"image,omitempty"`
+	Images    []*runtimeApi.Image       `json:"images,omitempty"`
+	Command   []string                  `json:"command,omitempty"`
+	Exec      *runtimeApi.ExecRequest   `json:"exec,omitempty"`
+	Attach    *runtimeApi.AttachRequest `json:"attach,omitempty"`
+}
+
+// Response is the message returned by the external adapter. The metadata is sent back
+// because the adapters update it in place.
+type Response struct {
+	Sandbox      *store.SandboxMetadata          `json:"sandbox,omitempty"`
+	Container    *store.ContainerMetadata        `json:"container,omitempty"`
+	Image        *store.ImageMetadata            `json:"image,omitempty"`
+	Images       []*runtimeApi.Image             `json:"images,omitempty"`
+	Version      *runtimeApi.VersionResponse     `json:"version,omitempty"`
+	ImageFsInfo  *runtimeApi.ImageFsInfoResponse `json:"imageFsInfo,omitempty"`
+	ExecSync     *runtimeApi.ExecSyncResponse    `json:"execSync,omitempty"`
+	Exec         *runtimeApi.ExecResponse        `json:"exec,omitempty"`
+	Attach       *runtimeApi.AttachResponse      `json:"attach,omitempty"`
+	Capabilities *adapters.Capabilities          `json:"capabilities,omitempty"`
+	// Error is the error returned by the adapter
+	Error string `json:"error,omitempty"`
+	// ErrorKind classifies the error returned by the adapter
+	ErrorKind adapters.ErrorKind `json:"errorKind,omitempty"`
+}
+
+// jsonCodec encodes the adapter messages as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// adapterServer is implemented by the gRPC server of the adapter service
+type adapterServer interface {
+	call(ctx context.Context, method string, req *Request) (*Response, error)
+}
+
+func methodHandler(method string) func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := new(Request)
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return srv.(adapterServer).call(ctx, method, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod(method)}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return srv.(adapterServer).call(ctx, method, req.(*Request))
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+func serviceDesc() *grpc.ServiceDesc {
+	desc := &grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*adapterServer)(nil),
+		Streams:     []grpc.StreamDesc{},
+		Metadata:    "multi-cri/pkg/cri/adapters/plugin",
+	}
+	for _, method := range methods {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{MethodName: method, Handler: methodHandler(method)})
+	}
+	return desc
+}
+
+func fullMethod(method string) string {
+	return "/" + ServiceName + "/" + method
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"k8s.io/klog"
+)
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = 30 * time.Second
+	// stableRunTime is how long the adapter must run before the restart delay is reset
+	stableRunTime = time.Minute
+)
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "proxy",
+		Description: "Forwards the calls to an adapter running in a separate process",
+		Options: map[string]string{
+			"socket":  "Unix socket the external adapter serves on (required)",
+			"command": "Binary of the external adapter. If set, multi-cri starts it and restarts it when it fails",
+			"args":    "Arguments for the command, quoted like in a shell",
+		},
+		Validate: func(config adapters.AdapterConfig) error {
+			if config.Get("socket", "") == "" {
+				return fmt.Errorf("socket is required")
+			}
+			if config.Get("command", "") == "" && config.Get("args", "") != "" {
+				return fmt.Errorf("args requires command")
+			}
+			if _, err := shellquote.Split(config.Get("args", "")); err != nil {
+				return fmt.Errorf("bad args: %v", err)
+			}
+			return nil
+		},
+		New: func(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+			args, err := shellquote.Split(config.Get("args", ""))
+			if err != nil {
+				return nil, err
+			}
+			return NewProxyAdapter(config.Get("socket", ""), config.Get("command", ""), args)
+		},
+	})
+}
+
+var errStopped = fmt.Errorf("adapter supervisor stopped")
+
+// ProxyAdapter forwards the calls to an external adapter, which it may supervise
+type ProxyAdapter struct {
+	*Client
+	supervisor *Supervisor
+}
+
+// NewProxyAdapter connects to the external adapter served on the socket. When command is set,
+// the external adapter is started and restarted every time it exits, until the adapter is closed.
+func NewProxyAdapter(socket, command string, args []string) (*ProxyAdapter, error) {
+	var s *Supervisor
+	if command != "" {
+		s = &Supervisor{Command: command, Args: args, Socket: socket}
+		if err := s.Start(); err != nil {
+			return nil, err
+		}
+	}
+	client, err := Dial(socket)
+	if err != nil {
+		if s != nil {
+			s.Stop()
+		}
+		return nil, err
+	}
+	return &ProxyAdapter{Client: client, supervisor: s}, nil
+}
+
+// Close stops the supervised external adapter and closes the connection with it
+func (p *ProxyAdapter) Close() error {
+	if p.supervisor != nil {
+		p.supervisor.Stop()
+	}
+	return p.Client.Close()
+}
+
+// Supervisor keeps an external adapter running
+type Supervisor struct {
+	Command string
+	Args    []string
+	Socket  string
+
+	lock    sync.Mutex
+	cmd     *exec.Cmd
+	stopped bool
+}
+
+// Start starts the external adapter and watches it in the background
+func (s *Supervisor) Start() error {
+	if err := s.run(); err != nil {
+		return err
+	}
+	go s.watch()
+	return nil
+}
+
+// Stop kills the external adapter and stops restarting it
+func (s *Supervisor) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopped = true
+	if s.cmd != nil && s.cmd.Process != nil {
+		s.cmd.Process.Kill() // nolint: errcheck
+	}
+}
+
+func (s *Supervisor) run() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return errStopped
+	}
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Env = append(os.Environ(), SocketEnv+"="+s.Socket)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start adapter %s: %v", s.Command, err)
+	}
+	klog.V(2).Infof("Started adapter %s with pid %d", s.Command, cmd.Process.Pid)
+	s.cmd = cmd
+	return nil
+}
+
+func (s *Supervisor) watch() {
+	delay := minRestartDelay
+	for {
+		s.lock.Lock()
+		cmd := s.cmd
+		s.lock.Unlock()
+		startedAt := time.Now()
+		err := cmd.Wait()
+
+		s.lock.Lock()
+		stopped := s.stopped
+		s.lock.Unlock()
+		if stopped {
+			return
+		}
+		if time.Since(startedAt) > stableRunTime {
+			delay = minRestartDelay
+		}
+		klog.Errorf("Adapter %s exited: %v. Restarting it in %s", s.Command, err, delay)
+		time.Sleep(delay)
+		for {
+			err := s.run()
+			if err == nil {
+				break
+			}
+			if err == errStopped {
+				return
+			}
+			klog.Errorf("%v", err)
+			delay = nextDelay(delay)
+			time.Sleep(delay)
+		}
+		delay = nextDelay(delay)
+	}
+}
+
+func nextDelay(delay time.Duration) time.Duration {
+	delay *= 2
+	if delay > maxRestartDelay {
+		return maxRestartDelay
+	}
+	return delay
+}
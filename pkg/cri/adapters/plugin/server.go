@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog"
+)
+
+// server exposes an adapter through the adapter service
+type server struct {
+	adapter adapters.AdapterInterface
+}
+
+// NewServer creates a gRPC server which serves the adapter
+func NewServer(adapter adapters.AdapterInterface) *grpc.Server {
+	s := grpc.NewServer()
+	s.RegisterService(serviceDesc(), &server{adapter: adapter})
+	return s
+}
+
+// Serve serves the adapter on the unix socket until the server fails
+func Serve(adapter adapters.AdapterInterface, socket string) error {
+	if err := syscall.Unlink(socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to unlink socket file %q: %v", socket, err)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %v", socket, err)
+	}
+	klog.V(2).Infof("Serving adapter on %s", socket)
+	return NewServer(adapter).Serve(l)
+}
+
+func (s *server) call(ctx context.Context, method string, req *Request) (*Response, error) {
+	klog.V(4).Infof("Adapter call %s", method)
+	out := &Response{}
+	var err error
+	switch method {
+	case MethodRunPodSandbox, MethodStopPodSandbox, MethodRemovePodSandbox, MethodPodSandboxStatus:
+		if req.Sandbox == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s requires a sandbox", method)
+		}
+		switch method {
+		case MethodRunPodSandbox:
+			err = s.adapter.RunPodSandbox(ctx, req.Sandbox)
+		case MethodStopPodSandbox:
+			err = s.adapter.StopPodSandbox(ctx, req.Sandbox)
+		case MethodRemovePodSandbox:
+			err = s.adapter.RemovePodSandbox(ctx, req.Sandbox)
+		default:
+			err = s.adapter.PodSandboxStatus(ctx, req.Sandbox)
+		}
+		out.Sandbox = req.Sandbox
+	case MethodCreateContainer, MethodStartContainer, MethodStopContainer, MethodContainerStatus,
+		MethodReopenContainerLog, MethodUpdateContainerResources, MethodExecSync, MethodExec, MethodAttach:
+		if req.Container == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s requires a container", method)
+		}
+		switch method {
+		case MethodCreateContainer:
+			err = s.adapter.CreateContainer(ctx, req.Container)
+		case MethodStartContainer:
+			err = s.adapter.StartContainer(ctx, req.Container)
+		case MethodStopContainer:
+			err = s.adapter.StopContainer(ctx, req.Container)
+		case MethodContainerStatus:
+			err = s.adapter.ContainerStatus(ctx, req.Container)
+		case MethodReopenContainerLog:
+			err = s.adapter.ReopenContainerLog(ctx, req.Container)
+		case MethodUpdateContainerResources:
+			err = s.adapter.UpdateContainerResources(ctx, req.Container)
+		case MethodExecSync:
+			out.ExecSync, err = s.adapter.ExecSync(ctx, req.Container, req.Command)
+		case MethodExec:
+			out.Exec, err = s.adapter.Exec(ctx, req.Container, req.Exec)
+		default:
+			out.Attach, err = s.adapter.Attach(ctx, req.Container, req.Attach)
+		}
+		out.Container = req.Container
+	case MethodPullImage, MethodImageStatus, MethodRemoveImage:
+		if req.Image == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s requires an image", method)
+		}
+		switch method {
+		case MethodPullImage:
+			err = s.adapter.PullImage(ctx, req.Image)
+		case MethodImageStatus:
+			err = s.adapter.ImageStatus(ctx, req.Image)
+		default:
+			err = s.adapter.RemoveImage(ctx, req.Image)
+		}
+		out.Image = req.Image
+	case MethodListImages:
+		err = s.adapter.ListImages(ctx, req.Images)
+		out.Images = req.Images
+	case MethodVersion:
+		out.Version, err = s.adapter.Version(ctx)
+	case MethodImageFsInfo:
+		out.ImageFsInfo, err = s.adapter.ImageFsInfo(ctx)
+	case MethodCapabilities:
+		capabilities := s.adapter.Capabilities()
+		out.Capabilities = &capabilities
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", method)
+	}
+	if err != nil {
+		// Adapter errors travel in the response, so the metadata updated before failing is not lost
+		out.Error = err.Error()
+		out.ErrorKind = adapters.ErrorKindOf(err)
+	}
+	return out, nil
+}
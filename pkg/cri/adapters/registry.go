@@ -0,0 +1,162 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapters
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AdapterConfig contains the adapter options. They are set with --adapter-config
+// in the "key=value,key=value" format.
+type AdapterConfig map[string]string
+
+// AdapterFactory describes an adapter that can be selected with --adapter-name.
+// Adapters register their factory from the init function of their package.
+type AdapterFactory struct {
+	// Name identifies the adapter
+	Name string
+	// Description is shown when listing the adapters
+	Description string
+	// Options maps the configuration keys accepted by the adapter to their description
+	Options map[string]string
+	// Validate checks the configuration before building the adapter. Optional
+	Validate func(config AdapterConfig) error
+	// New builds the adapter
+	New func(config AdapterConfig) (AdapterInterface, error)
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[string]AdapterFactory)
+)
+
+// Register adds an adapter factory to the registry
+func Register(factory AdapterFactory) error {
+	if factory.Name == "" {
+		return fmt.Errorf("Adapter name can not be empty")
+	}
+	if factory.New == nil {
+		return fmt.Errorf("Adapter %s does not provide a constructor", factory.Name)
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, exists := registry[factory.Name]; exists {
+		return fmt.Errorf("Adapter %s already registered", factory.Name)
+	}
+	registry[factory.Name] = factory
+	return nil
+}
+
+// MustRegister registers the adapter factory and panics if it fails. It is
+// meant to be called from init functions.
+func MustRegister(factory AdapterFactory) {
+	if err := Register(factory); err != nil {
+		panic(err)
+	}
+}
+
+// LookupAdapter returns the adapter factory registered with that name
+func LookupAdapter(name string) (AdapterFactory, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	factory, ok := registry[name]
+	return factory, ok
+}
+
+// NewAdapter validates the configuration and builds the adapter registered with that name
+func NewAdapter(name string, config AdapterConfig) (AdapterInterface, error) {
+	factory, ok := LookupAdapter(name)
+	if !ok {
+		return nil, fmt.Errorf("Adapter %q not found. Available adapters: %s", name, strings.Join(AdapterNames(), ", "))
+	}
+	if config == nil {
+		config = AdapterConfig{}
+	}
+	if factory.Options != nil {
+		for key := range config {
+			if _, ok := factory.Options[key]; !ok {
+				return nil, fmt.Errorf("Unknown option %q for adapter %s", key, name)
+			}
+		}
+	}
+	if factory.Validate != nil {
+		if err := factory.Validate(config); err != nil {
+			return nil, fmt.Errorf("Invalid configuration for adapter %s: %v", name, err)
+		}
+	}
+	return factory.New(config)
+}
+
+// ListAdapters returns the registered adapter factories sorted by name
+func ListAdapters() []AdapterFactory {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+	factories := make([]AdapterFactory, 0, len(registry))
+	for _, f := range registry {
+		factories = append(factories, f)
+	}
+	sort.Slice(factories, func(i, j int) bool { return factories[i].Name < factories[j].Name })
+	return factories
+}
+
+// AdapterNames returns the names of the registered adapters sorted alphabetically
+func AdapterNames() []string {
+	var names []string
+	for _, f := range ListAdapters() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+// ParseAdapterConfig parses the adapter options from the "key=value,key=value" format
+func ParseAdapterConfig(config string) (AdapterConfig, error) {
+	out := AdapterConfig{}
+	if strings.TrimSpace(config) == "" {
+		return out, nil
+	}
+	for _, option := range strings.Split(config, ",") {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("Bad format for adapter option %q. It must be key=value", option)
+		}
+		out[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return out, nil
+}
+
+// Get returns the option value, or the default value when it is not set
+func (c AdapterConfig) Get(key, defaultValue string) string {
+	if v, ok := c[key]; ok && v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+// GetBool returns the option as boolean, or the default value when it is not set
+func (c AdapterConfig) GetBool(key string, defaultValue bool) (bool, error) {
+	v, ok := c[key]
+	if !ok || v == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("Option %s must be a boolean: %v", key, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapters
+
+import (
+	"strings"
+	"testing"
+)
+
+func registerTestAdapter(t *testing.T, name string) {
+	err := Register(AdapterFactory{
+		Name:    name,
+		Options: map[string]string{"path": "", "enabled": ""},
+		Validate: func(config AdapterConfig) error {
+			_, err := config.GetBool("enabled", false)
+			return err
+		},
+		New: func(config AdapterConfig) (AdapterInterface, error) {
+			return nil, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+//Test adapters are found by name once registered
+func TestUnitRegistryNewAdapter(t *testing.T) {
+	registerTestAdapter(t, "test-new")
+	if _, err := NewAdapter("test-new", AdapterConfig{"path": "/tmp"}); err != nil {
+		t.Fatal("Registered adapter should be created: ", err)
+	}
+	found := false
+	for _, name := range AdapterNames() {
+		if name == "test-new" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Registered adapter should be listed")
+	}
+}
+
+//Test registry errors
+func TestUnitRegistryErrors(t *testing.T) {
+	registerTestAdapter(t, "test-errors")
+	if err := Register(AdapterFactory{Name: "test-errors", New: func(AdapterConfig) (AdapterInterface, error) { return nil, nil }}); err == nil {
+		t.Fatal("Adapters can not be registered twice")
+	}
+	if _, err := NewAdapter("notfound", nil); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatal("Unknown adapter should fail: ", err)
+	}
+	if _, err := NewAdapter("test-errors", AdapterConfig{"unknown": "value"}); err == nil || !strings.Contains(err.Error(), "Unknown option") {
+		t.Fatal("Unknown options should fail: ", err)
+	}
+	if _, err := NewAdapter("test-errors", AdapterConfig{"enabled": "maybe"}); err == nil || !strings.Contains(err.Error(), "Invalid configuration") {
+		t.Fatal("Validation should fail: ", err)
+	}
+}
+
+//Test adapter options parsing
+func TestUnitParseAdapterConfig(t *testing.T) {
+	config, err := ParseAdapterConfig("mount-path=multi-cri, build-in-cluster=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Get("mount-path", "") != "multi-cri" {
+		t.Fatal("Wrong mount-path option: ", config["mount-path"])
+	}
+	if b, err := config.GetBool("build-in-cluster", false); err != nil || !b {
+		t.Fatal("Wrong build-in-cluster option: ", err)
+	}
+	if config.Get("notset", "default") != "default" {
+		t.Fatal("Default value should be returned")
+	}
+	if _, err := ParseAdapterConfig("wrong"); err == nil {
+		t.Fatal("Options without value should fail")
+	}
+}
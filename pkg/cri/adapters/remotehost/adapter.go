@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remotehost implements the adapter which runs the containers in a remote Linux host through
+// SSH, with podman, docker or Singularity instances, for the hosts without workload manager. The
+// containers are created, inspected and stopped with the commands of the engine, and their output is
+// followed while they run.
+package remotehost
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	REMOTEHOSTADAPTERVERSION = "0.1.0"
+	REMOTEHOSTNAME           = "Adapter Remote Host"
+	MOUNTHPATH               = "multi-cri"
+	// containerPrefix is prepended to the container ids in the names of the remote containers
+	containerPrefix = "multicri-"
+)
+
+type RemoteHostAdapter struct {
+	batch.NoStreaming
+	batch.NoSandbox
+	engine engine
+	// followers follow the output of the running containers, by container id
+	followers map[string]*follower
+	lock      sync.Mutex
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "remotehost",
+		Description: "Runs containers in a remote Linux host through SSH with podman, docker or Singularity",
+		Options: map[string]string{
+			"engine":     "Container engine of the host: podman, docker or singularity (CRI_REMOTEHOST_ENGINE)",
+			"mount-path": "Working directory of the Singularity containers, relative to $HOME (CRI_REMOTEHOST_MOUNT_PATH)",
+		},
+		Validate: validateConfig,
+		New:      NewRemoteHostAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	switch config.Get("engine", enginePodman) {
+	case enginePodman, engineDocker, engineSingularity:
+	default:
+		return fmt.Errorf("engine must be %s, %s or %s", enginePodman, engineDocker, engineSingularity)
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	return nil
+}
+
+// NewRemoteHostAdapter creates the adapter configured with the CRI_REMOTEHOST_* environment variables
+func NewRemoteHostAdapter() (adapters.AdapterInterface, error) {
+	return NewRemoteHostAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewRemoteHostAdapterWithConfig creates the adapter. Options not set in the config are read from the
+// CRI_REMOTEHOST_* environment variables. The host and its credentials are set by every container.
+func NewRemoteHostAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	engineDefault := enginePodman
+	mountDefault := MOUNTHPATH
+	config = adapters.AdapterConfig{
+		"engine":     config.Get("engine", common.GetEnv("CRI_REMOTEHOST_ENGINE", &engineDefault)),
+		"mount-path": config.Get("mount-path", common.GetEnv("CRI_REMOTEHOST_MOUNT_PATH", &mountDefault)),
+	}
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	return &RemoteHostAdapter{
+		NoStreaming: batch.NoStreaming{Name: "REMOTEHOST"},
+		engine:      newEngine(config["engine"], config["mount-path"]),
+		followers:   make(map[string]*follower),
+	}, nil
+}
+
+func (r *RemoteHostAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           REMOTEHOSTADAPTERVERSION,
+		RuntimeName:       REMOTEHOSTNAME,
+		RuntimeVersion:    REMOTEHOSTADAPTERVERSION,
+		RuntimeApiVersion: REMOTEHOSTADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that only ExecSync is supported, besides the container lifecycle
+func (r *RemoteHostAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ExecSync: true}
+}
+
+// containerName returns the name of the remote container, or Singularity instance, of the container
+func containerName(cm *store.ContainerMetadata) string {
+	return containerPrefix + cm.ID
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/common/ssh"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+var hostErrors = []batch.ErrorPattern{
+	{"no such container", adapters.KindNotFound},
+	{"no such object", adapters.KindNotFound},
+	{"no container with name or id", adapters.KindNotFound},
+	{"no instance found", adapters.KindNotFound},
+	{"container not found", adapters.KindNotFound},
+	{"manifest unknown", adapters.KindNotFound},
+	{"pull access denied", adapters.KindNotFound},
+	{"invalid reference format", adapters.KindInvalidArgument},
+	{"unknown flag", adapters.KindInvalidArgument},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"cannot connect to the docker daemon", adapters.KindUnavailable},
+	{"command not found", adapters.KindUnavailable},
+	{"connection refused", adapters.KindUnavailable},
+}
+
+// client runs the commands of the engine in the host set in the CLUSTER_* variables of the container
+type client struct {
+	ssh *ssh.SSH
+}
+
+func newClient(cm *store.ContainerMetadata) (*client, error) {
+	sshClient, err := batch.NewSSH(cm)
+	if err != nil {
+		return nil, err
+	}
+	return &client{ssh: sshClient}, nil
+}
+
+// run runs the command and returns its output. It fails with the stderr of the command when it exits
+// with an error.
+func (c *client) run(ctx context.Context, command string) (string, error) {
+	klog.V(4).Infof("Execute command %s", command)
+	stdout, stderr, exitCode, err := c.exec(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		message := strings.TrimSpace(stderr)
+		return stdout, batch.ClassifyError(hostErrors, stdout, fmt.Errorf("%s exits with code %d: %s", command, exitCode, message))
+	}
+	return stdout, nil
+}
+
+// exec runs the command and returns its output and exit code. It only fails when the command can not run.
+func (c *client) exec(ctx context.Context, command string) (string, string, int, error) {
+	stdout, stderr, exitCode, err := c.ssh.RunStatus(ctx, command)
+	if err != nil {
+		return "", "", 0, batch.ClassifyError(hostErrors, stdout+" "+stderr, err)
+	}
+	return stdout, stderr, exitCode, nil
+}
+
+// stream runs the command, writing its output while it runs
+func (c *client) stream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	klog.V(4).Infof("Stream command %s", command)
+	return batch.ClassifyError(hostErrors, "", c.ssh.Stream(ctx, command, stdout, stderr))
+}
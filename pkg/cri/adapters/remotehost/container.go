@@ -0,0 +1,99 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// CreateContainer pulls the image in the host when it is not there, and creates the container
+func (r *RemoteHostAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	c, err := newClient(cm)
+	if err != nil {
+		return err
+	}
+	if err := r.engine.create(ctx, c, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container %s in host %s", cm.ID, cm.Environment["CLUSTER_HOST"])
+	return nil
+}
+
+func (r *RemoteHostAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	c, err := newClient(cm)
+	if err != nil {
+		return err
+	}
+	if err := r.engine.start(ctx, c, cm); err != nil {
+		return err
+	}
+	r.follow(c, cm, true)
+	return nil
+}
+
+func (r *RemoteHostAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	c, err := newClient(cm)
+	if err != nil {
+		return err
+	}
+	err = r.engine.stop(ctx, c, cm)
+	if adapters.IsNotFound(err) {
+		// The container was already removed
+		return nil
+	}
+	return err
+}
+
+// ContainerStatus inspects the container. The output of the running containers is followed, and the
+// exited containers are removed from the host once their output is in the log.
+func (r *RemoteHostAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.State == runtimeApi.ContainerState_CONTAINER_CREATED {
+		return nil
+	}
+	c, err := newClient(cm)
+	if err != nil {
+		return err
+	}
+	status, err := r.engine.status(ctx, c, cm)
+	if err != nil {
+		return err
+	}
+	status.Apply(cm)
+	switch cm.State {
+	case runtimeApi.ContainerState_CONTAINER_RUNNING:
+		// The output is followed again when multi-cri restarts or the connection is lost, without the
+		// output written meanwhile
+		r.follow(c, cm, false)
+	case runtimeApi.ContainerState_CONTAINER_EXITED:
+		r.unfollow(cm.ID)
+		if err := r.engine.remove(ctx, c, cm); err != nil {
+			klog.V(4).Infof("Container %s can not be removed from the host: %v", cm.ID, err)
+		}
+	}
+	return nil
+}
+
+func (r *RemoteHostAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("REMOTEHOST: ReopenContainerLog not implemented")
+}
+
+func (r *RemoteHostAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("REMOTEHOST: UpdateContainerResources not implemented")
+}
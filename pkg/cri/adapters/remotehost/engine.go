@@ -0,0 +1,213 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	enginePodman      = "podman"
+	engineDocker      = "docker"
+	engineSingularity = "singularity"
+	// stopTimeout is the time the containers have to exit after SIGTERM, before they are killed
+	stopTimeout = 10
+	// signalExitCode is the lowest exit code of the containers killed by a signal
+	signalExitCode = 128
+)
+
+// engine runs the containers in the host with the commands of a container engine
+type engine interface {
+	// create pulls the image of the container when it is not in the host, and creates the container
+	create(ctx context.Context, c *client, cm *store.ContainerMetadata) error
+	start(ctx context.Context, c *client, cm *store.ContainerMetadata) error
+	// stop stops the container, killing it when it does not exit in stopTimeout seconds
+	stop(ctx context.Context, c *client, cm *store.ContainerMetadata) error
+	status(ctx context.Context, c *client, cm *store.ContainerMetadata) (*batch.JobStatus, error)
+	// remove removes the container and its files from the host
+	remove(ctx context.Context, c *client, cm *store.ContainerMetadata) error
+	// logs returns the command which follows the output of the container until it exits, from the
+	// start or only the new output
+	logs(cm *store.ContainerMetadata, fromStart bool) string
+	// exec returns the command which runs the command in the container
+	exec(cm *store.ContainerMetadata, command []string) string
+}
+
+func newEngine(name, mountPath string) engine {
+	if name == engineSingularity {
+		return &singularityEngine{mountPath: mountPath}
+	}
+	return &dockerEngine{binary: name}
+}
+
+// dockerEngine runs the containers with docker or podman, whose commands are the same
+type dockerEngine struct {
+	binary string
+}
+
+// dockerState is the state of docker inspect and podman inspect
+type dockerState struct {
+	Status     string `json:"Status"`
+	ExitCode   int    `json:"ExitCode"`
+	OOMKilled  bool   `json:"OOMKilled"`
+	StartedAt  string `json:"StartedAt"`
+	FinishedAt string `json:"FinishedAt"`
+}
+
+func (d *dockerEngine) command(args ...string) string {
+	return d.binary + " " + shellquote.Join(args...)
+}
+
+func (d *dockerEngine) create(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	if cm.Image == nil || cm.Image.RepoType != store.DockerRepositoryImageRepo {
+		return adapters.InvalidArgumentError("%s only runs images of the docker repository", d.binary)
+	}
+	image := strings.TrimPrefix(cm.Image.RemotePath, "docker://")
+	if _, err := c.run(ctx, fmt.Sprintf("%s >/dev/null 2>&1 || %s", d.command("image", "inspect", image), d.command("pull", "-q", image))); err != nil {
+		return batch.WrapError(err, "Image %s can not be pulled. %s", image, err)
+	}
+	if _, err := c.run(ctx, d.command(d.createArgs(cm, image)...)); err != nil {
+		return batch.WrapError(err, "Container %s can not be created. %s", cm.Name, err)
+	}
+	return nil
+}
+
+// createArgs returns the arguments of create. The container command replaces the entrypoint of the image
+// and the arguments its command, as in Kubernetes.
+func (d *dockerEngine) createArgs(cm *store.ContainerMetadata, image string) []string {
+	args := []string{"create", "--name", containerName(cm),
+		"--label", "io.multicri.container=" + cm.ID, "--label", "io.multicri.sandbox=" + cm.PodSandbox.ID}
+	env := batch.FilterEnvironment(cm)
+	var keys []string
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k+"="+env[k])
+	}
+	if cm.Config.WorkingDir != "" {
+		args = append(args, "--workdir", cm.Config.WorkingDir)
+	}
+	if cm.Config.Tty {
+		args = append(args, "--tty")
+	}
+	if linux := cm.Config.Linux; linux != nil && linux.Resources != nil {
+		r := linux.Resources
+		if r.CpuQuota > 0 && r.CpuPeriod > 0 {
+			args = append(args, "--cpus", strconv.FormatFloat(float64(r.CpuQuota)/float64(r.CpuPeriod), 'f', -1, 64))
+		}
+		if r.MemoryLimitInBytes > 0 {
+			args = append(args, "--memory", strconv.FormatInt(r.MemoryLimitInBytes, 10))
+		}
+	}
+	var command []string
+	if len(cm.Command) > 0 {
+		args = append(args, "--entrypoint", cm.Command[0])
+		command = cm.Command[1:]
+	}
+	args = append(args, image)
+	args = append(args, command...)
+	return append(args, cm.Args...)
+}
+
+func (d *dockerEngine) start(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	_, err := c.run(ctx, d.command("start", containerName(cm)))
+	return err
+}
+
+func (d *dockerEngine) stop(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	_, err := c.run(ctx, d.command("stop", "-t", strconv.Itoa(stopTimeout), containerName(cm)))
+	return err
+}
+
+func (d *dockerEngine) status(ctx context.Context, c *client, cm *store.ContainerMetadata) (*batch.JobStatus, error) {
+	out, err := c.run(ctx, d.command("inspect", "--format", "{{json .State}}", containerName(cm)))
+	if err != nil {
+		return nil, err
+	}
+	return parseDockerState(out)
+}
+
+func parseDockerState(out string) (*batch.JobStatus, error) {
+	var state dockerState
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &state); err != nil {
+		return nil, fmt.Errorf("Container state %q can not be parsed: %v", out, err)
+	}
+	status := &batch.JobStatus{StartedAt: parseTime(state.StartedAt)}
+	switch strings.ToLower(state.Status) {
+	case "created", "configured", "initialized":
+		status.State = runtimeApi.ContainerState_CONTAINER_CREATED
+	case "running", "paused", "restarting", "stopping":
+		status.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "exited", "stopped", "dead":
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = state.ExitCode
+		status.FinishedAt = parseTime(state.FinishedAt)
+		status.Reason = exitReason(state.ExitCode, state.OOMKilled)
+	default:
+		status.State = runtimeApi.ContainerState_CONTAINER_UNKNOWN
+	}
+	return status, nil
+}
+
+func (d *dockerEngine) remove(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	_, err := c.run(ctx, d.command("rm", "-f", containerName(cm)))
+	return err
+}
+
+func (d *dockerEngine) logs(cm *store.ContainerMetadata, fromStart bool) string {
+	if fromStart {
+		return d.command("logs", "-f", containerName(cm))
+	}
+	return d.command("logs", "-f", "--tail", "0", containerName(cm))
+}
+
+func (d *dockerEngine) exec(cm *store.ContainerMetadata, command []string) string {
+	return d.command(append([]string{"exec", containerName(cm)}, command...)...)
+}
+
+// exitReason returns the reason of the exited containers, empty when it is the one of the exit code
+func exitReason(exitCode int, oomKilled bool) string {
+	if oomKilled {
+		return batch.ReasonOOMKilled
+	}
+	if exitCode > signalExitCode {
+		return batch.ReasonKilled
+	}
+	return ""
+}
+
+// parseTime returns the RFC 3339 time in nanoseconds, 0 when it is unknown
+func parseTime(value string) int64 {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.Year() <= 1 {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// ExecSync runs the command in the remote container and returns its output and exit code
+func (r *RemoteHostAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	c, err := newClient(cm)
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr, exitCode, err := c.exec(ctx, r.engine.exec(cm, command))
+	if err != nil {
+		return nil, err
+	}
+	return &runtimeApi.ExecSyncResponse{Stdout: []byte(stdout), Stderr: []byte(stderr), ExitCode: int32(exitCode)}, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// PullImage only checks the image. The host and its credentials are set by the containers, so images are
+// pulled in the host when the containers are created.
+func (r *RemoteHostAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	switch image.RepoType {
+	case store.DockerRepositoryImageRepo:
+	case store.SingularityRepositoryImageRepo, store.LocalImageRepo:
+		if _, ok := r.engine.(*singularityEngine); !ok {
+			return adapters.InvalidArgumentError("Image repository type only supported by singularity %s ", image.RemotePath)
+		}
+	default:
+		return adapters.InvalidArgumentError("Image repository type not supported by the remote host adapter %s ", image.RemotePath)
+	}
+	image.Size = 1 //It must to be set, otherwise k8s fails
+	return nil
+}
+
+func (r *RemoteHostAdapter) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	return nil
+}
+
+func (r *RemoteHostAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
+
+func (r *RemoteHostAdapter) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	return nil, adapters.UnimplementedError("REMOTEHOST: ImageFsInfo not implemented")
+}
+
+func (r *RemoteHostAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
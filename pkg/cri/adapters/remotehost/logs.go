@@ -0,0 +1,93 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"time"
+
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+// followerTimeout is the time the output of an exited container has to be written in its log
+const followerTimeout = 5 * time.Second
+
+// follower writes the output of a running container in its log
+type follower struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// running returns whether the follower is still writing the output
+func (f *follower) running() bool {
+	select {
+	case <-f.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// follow starts following the output of the container, unless it is already followed. The output is
+// followed from the start of the container, or only the new output when multi-cri lost the follower.
+func (r *RemoteHostAdapter) follow(c *client, cm *store.ContainerMetadata, fromStart bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if f, ok := r.followers[cm.ID]; ok && f.running() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &follower{cancel: cancel, done: make(chan struct{})}
+	r.followers[cm.ID] = f
+	command := r.engine.logs(cm, fromStart)
+	id, logFile := cm.ID, cm.LogFile
+	go func() {
+		defer close(f.done)
+		stdout, stderr, err := common.CreateContainerLoggers(logFile, false, 0)
+		if err != nil {
+			klog.Errorf("failed to start container logger: %s", err)
+			return
+		}
+		defer func() {
+			stderr.Close()
+			stdout.Close()
+		}()
+		if err := c.stream(ctx, command, stdout, stderr); err != nil {
+			klog.V(4).Infof("Output of container %s is not followed anymore: %v", id, err)
+		}
+	}()
+}
+
+// unfollow waits for the follower of the exited container to write its last output, and stops it when
+// it does not finish in followerTimeout
+func (r *RemoteHostAdapter) unfollow(containerID string) {
+	r.lock.Lock()
+	f, ok := r.followers[containerID]
+	delete(r.followers, containerID)
+	r.lock.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case <-f.done:
+	case <-time.After(followerTimeout):
+		f.cancel()
+		<-f.done
+	}
+	f.cancel()
+}
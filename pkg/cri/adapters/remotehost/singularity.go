@@ -0,0 +1,215 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// runscript is run when the container has no command, as singularity run does
+	runscript = "/.singularity.d/runscript"
+	// envPrefix passes the container environment to singularity and apptainer
+	envPrefix = "SINGULARITYENV_"
+	// lostExitCode is the exit code of the containers whose command was lost, e.g. when the host restarted
+	lostExitCode = 255
+)
+
+var imageFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// singularityEngine runs every container in a Singularity instance. The container command runs in the
+// instance in the background, in its own session, and it writes its output, pid and exit code in the
+// container directory, <mount path>/containers/<container id>.
+type singularityEngine struct {
+	mountPath string
+}
+
+func (s *singularityEngine) dir(cm *store.ContainerMetadata) string {
+	return path.Join(s.mountPath, "containers", cm.ID)
+}
+
+// image returns the path of the image in the host, relative to $HOME unless it is a local image
+func (s *singularityEngine) image(cm *store.ContainerMetadata) (string, error) {
+	switch cm.Image.RepoType {
+	case store.DockerRepositoryImageRepo, store.SingularityRepositoryImageRepo:
+		name := cm.Image.RemotePath
+		if i := strings.Index(name, "://"); i >= 0 {
+			name = name[i+len("://"):]
+		}
+		return path.Join(s.mountPath, "images", imageFileChars.ReplaceAllString(name, "_")+".sif"), nil
+	case store.LocalImageRepo:
+		return cm.Image.RemotePath, nil
+	}
+	return "", adapters.InvalidArgumentError("Image repository type not supported by singularity %s ", cm.Image.RemotePath)
+}
+
+// homePath returns the path for the commands which do not run in $HOME
+func homePath(p string) string {
+	if path.IsAbs(p) {
+		return shellquote.Join(p)
+	}
+	return `"$HOME"/` + shellquote.Join(p)
+}
+
+func (s *singularityEngine) create(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	if cm.Image == nil {
+		return adapters.InvalidArgumentError("Container %s has no image", cm.Name)
+	}
+	image, err := s.image(cm)
+	if err != nil {
+		return err
+	}
+	command := fmt.Sprintf("mkdir -p %s && test -f %s", shellquote.Join(s.dir(cm)), shellquote.Join(image))
+	if cm.Image.RepoType != store.LocalImageRepo {
+		command = fmt.Sprintf("mkdir -p %s %s && (test -f %s || singularity pull %s %s)", shellquote.Join(path.Dir(image)),
+			shellquote.Join(s.dir(cm)), shellquote.Join(image), shellquote.Join(image), shellquote.Join(cm.Image.RemotePath))
+	}
+	if _, err := c.run(ctx, command); err != nil {
+		return batch.WrapError(err, "Image %s can not be pulled. %s", cm.Image.RemotePath, err)
+	}
+	return nil
+}
+
+// execArgs returns the singularity exec command of the command in the instance of the container, with
+// the container environment and working directory
+func execArgs(cm *store.ContainerMetadata, command []string) []string {
+	args := []string{"env"}
+	env := batch.FilterEnvironment(cm)
+	var keys []string
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, envPrefix+k+"="+env[k])
+	}
+	args = append(args, "singularity", "exec")
+	if cm.Config.WorkingDir != "" {
+		args = append(args, "--pwd", cm.Config.WorkingDir)
+	}
+	args = append(args, "instance://"+containerName(cm))
+	return append(args, command...)
+}
+
+func (s *singularityEngine) start(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	image, err := s.image(cm)
+	if err != nil {
+		return err
+	}
+	command := append(append([]string{}, cm.Command...), cm.Args...)
+	if len(command) == 0 {
+		command = []string{runscript}
+	}
+	run := shellquote.Join(execArgs(cm, command)...) + " >stdout 2>stderr; echo $? >exitcode"
+	script := fmt.Sprintf("cd %s && singularity instance start %s %s >/dev/null && : >stdout && : >stderr && "+
+		"{ setsid sh -c %s >/dev/null 2>&1 </dev/null & echo $! >pid; }",
+		shellquote.Join(s.dir(cm)), homePath(image), containerName(cm), shellquote.Join(run))
+	_, err = c.run(ctx, script)
+	return err
+}
+
+func (s *singularityEngine) stop(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	script := fmt.Sprintf("cd %s || exit 0; "+
+		"if [ -f pid ] && [ ! -f exitcode ]; then pid=$(cat pid); kill -TERM -- -$pid 2>/dev/null; i=0; "+
+		"while kill -0 $pid 2>/dev/null && [ $i -lt %d ]; do sleep 1; i=$((i+1)); done; "+
+		"if kill -0 $pid 2>/dev/null; then kill -KILL -- -$pid 2>/dev/null; sleep 1; [ -f exitcode ] || echo 137 >exitcode; fi; "+
+		"[ -f exitcode ] || echo 143 >exitcode; fi; singularity instance stop %s >/dev/null 2>&1; true",
+		shellquote.Join(s.dir(cm)), stopTimeout, containerName(cm))
+	_, err := c.run(ctx, script)
+	return err
+}
+
+func (s *singularityEngine) status(ctx context.Context, c *client, cm *store.ContainerMetadata) (*batch.JobStatus, error) {
+	script := fmt.Sprintf("cd %s 2>/dev/null || { echo missing; exit 0; }; "+
+		"if [ -f exitcode ]; then echo exited $(cat exitcode) $(stat -c %%Y pid) $(stat -c %%Y exitcode); "+
+		"elif [ -f pid ] && kill -0 $(cat pid) 2>/dev/null; then echo running 0 $(stat -c %%Y pid) 0; "+
+		"elif [ -f pid ]; then echo lost 0 $(stat -c %%Y pid) 0; else echo created 0 0 0; fi",
+		shellquote.Join(s.dir(cm)))
+	out, err := c.run(ctx, script)
+	if err != nil {
+		return nil, err
+	}
+	return parseSingularityState(out, cm)
+}
+
+// parseSingularityState parses the state, exit code, start time and finish time of the status script
+func parseSingularityState(out string, cm *store.ContainerMetadata) (*batch.JobStatus, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 1 && fields[0] == "missing" {
+		return nil, adapters.NotFoundError("Container %s not found in the host", cm.ID)
+	}
+	if len(fields) != 4 {
+		// The exit code is being written
+		return &batch.JobStatus{State: runtimeApi.ContainerState_CONTAINER_RUNNING}, nil
+	}
+	var times [3]int64
+	for i, field := range fields[1:] {
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Container state %q can not be parsed: %v", out, err)
+		}
+		times[i] = n
+	}
+	status := &batch.JobStatus{StartedAt: times[1] * 1e9}
+	switch fields[0] {
+	case "created":
+		status.State = runtimeApi.ContainerState_CONTAINER_CREATED
+	case "running":
+		status.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "exited":
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = int(times[0])
+		status.FinishedAt = times[2] * 1e9
+		status.Reason = exitReason(status.ExitCode, false)
+	case "lost":
+		status.State = runtimeApi.ContainerState_CONTAINER_EXITED
+		status.ExitCode = lostExitCode
+		status.Reason = batch.ReasonError
+	default:
+		return nil, fmt.Errorf("Container state %q can not be parsed", out)
+	}
+	return status, nil
+}
+
+func (s *singularityEngine) remove(ctx context.Context, c *client, cm *store.ContainerMetadata) error {
+	_, err := c.run(ctx, fmt.Sprintf("singularity instance stop %s >/dev/null 2>&1; rm -rf %s", containerName(cm), shellquote.Join(s.dir(cm))))
+	return err
+}
+
+func (s *singularityEngine) logs(cm *store.ContainerMetadata, fromStart bool) string {
+	lines := "0"
+	if fromStart {
+		lines = "+1"
+	}
+	return fmt.Sprintf("cd %s && pid=$(cat pid) && { tail -n %s --pid=$pid -F stdout & tail -n %s --pid=$pid -F stderr >&2; wait; }",
+		shellquote.Join(s.dir(cm)), lines, lines)
+}
+
+func (s *singularityEngine) exec(cm *store.ContainerMetadata, command []string) string {
+	return shellquote.Join(execArgs(cm, command)...)
+}
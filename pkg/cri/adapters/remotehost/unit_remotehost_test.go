@@ -0,0 +1,244 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remotehost
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// singularityScript replaces singularity in the fake host: instances do nothing, and exec runs the command
+// in the working directory
+const singularityScript = `#!/bin/sh
+case "$1" in
+instance) exit 0 ;;
+pull) touch "$2" ;;
+exec)
+	shift
+	if [ "$1" = "--pwd" ]; then cd "$2"; shift 2; fi
+	shift
+	exec "$@" ;;
+*) exit 1 ;;
+esac
+`
+
+func newContainer(t *testing.T, id string, cluster *fakecluster.Cluster) *store.ContainerMetadata {
+	dir, err := ioutil.TempDir("", "multicri-remotehost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &store.ContainerMetadata{
+		ID:         id,
+		Name:       "remotehost-test",
+		PodSandbox: store.SandboxMetadata{ID: "pod1"},
+		Image:      &store.ImageMetadata{RemotePath: "docker://alpine:latest", RepoType: store.DockerRepositoryImageRepo},
+		LogFile:    filepath.Join(dir, id+".log"),
+		Environment: map[string]string{
+			"GREETING": "hello",
+		},
+		Extra: map[string]string{},
+	}
+	if cluster != nil {
+		cm.Environment["CLUSTER_USERNAME"] = cluster.User
+		cm.Environment["CLUSTER_PASSWORD"] = cluster.Password
+		cm.Environment["CLUSTER_HOST"] = cluster.Host
+		cm.Environment["CLUSTER_PORT"] = cluster.Port
+	}
+	return cm
+}
+
+func waitExited(t *testing.T, adapter *RemoteHostAdapter, cm *store.ContainerMetadata) {
+	deadline := time.Now().Add(20 * time.Second)
+	for cm.State != runtimeApi.ContainerState_CONTAINER_EXITED {
+		if time.Now().After(deadline) {
+			t.Fatalf("Container %s should exit: %s", cm.ID, cm.State)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if err := adapter.ContainerStatus(context.Background(), cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+//Test the docker and podman commands of the containers
+func TestUnitDockerCommands(t *testing.T) {
+	cm := newContainer(t, "container1", nil)
+	cm.Command = []string{"/bin/sh", "-c"}
+	cm.Args = []string{"echo $GREETING"}
+	cm.Config.WorkingDir = "/data"
+	cm.Config.Linux = &runtimeApi.LinuxContainerConfig{Resources: &runtimeApi.LinuxContainerResources{
+		CpuQuota: 150000, CpuPeriod: 100000, MemoryLimitInBytes: 1 << 30}}
+	cm.Environment["JOB_QUEUE"] = "batch"
+	d := &dockerEngine{binary: enginePodman}
+	expected := []string{"create", "--name", "multicri-container1", "--label", "io.multicri.container=container1",
+		"--label", "io.multicri.sandbox=pod1", "--env", "GREETING=hello", "--workdir", "/data", "--cpus", "1.5",
+		"--memory", "1073741824", "--entrypoint", "/bin/sh", "alpine:latest", "-c", "echo $GREETING"}
+	if args := d.createArgs(cm, "alpine:latest"); !reflect.DeepEqual(args, expected) {
+		t.Errorf("Wrong create arguments: %q", args)
+	}
+	if command := d.exec(cm, []string{"cat", "/etc/os release"}); command != `podman exec multicri-container1 cat '/etc/os release'` {
+		t.Errorf("Wrong exec command: %s", command)
+	}
+	if command := d.logs(cm, false); command != "podman logs -f --tail 0 multicri-container1" {
+		t.Errorf("Wrong logs command: %s", command)
+	}
+}
+
+//Test the container states of docker, podman and the Singularity containers are parsed
+func TestUnitParseState(t *testing.T) {
+	for _, c := range []struct {
+		state    string
+		expected runtimeApi.ContainerState
+		exitCode int
+		reason   string
+	}{
+		{`{"Status":"created","ExitCode":0,"StartedAt":"0001-01-01T00:00:00Z"}`, runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{`{"Status":"running","Running":true,"ExitCode":0,"StartedAt":"2019-10-14T10:00:00.5Z"}`, runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{`{"Status":"exited","ExitCode":3,"StartedAt":"2019-10-14T10:00:00.5Z","FinishedAt":"2019-10-14T10:01:00Z"}`, runtimeApi.ContainerState_CONTAINER_EXITED, 3, ""},
+		{`{"Status":"exited","ExitCode":137,"OOMKilled":true}`, runtimeApi.ContainerState_CONTAINER_EXITED, 137, batch.ReasonOOMKilled},
+		{`{"Status":"stopped","ExitCode":143}`, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonKilled},
+	} {
+		status, err := parseDockerState(c.state + "\n")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != c.expected || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("Status of %s wrong: %+v", c.state, status)
+		}
+	}
+	if status, _ := parseDockerState(`{"Status":"exited","ExitCode":0,"StartedAt":"2019-10-14T10:00:00.5Z","FinishedAt":"2019-10-14T10:01:00Z"}`); status.StartedAt != 1571047200500000000 || status.FinishedAt != 1571047260000000000 {
+		t.Errorf("Times should be exact: %+v", status)
+	}
+
+	cm := newContainer(t, "container1", nil)
+	if status, err := parseSingularityState("exited 2 1571047200 1571047260\n", cm); err != nil || status.State != runtimeApi.ContainerState_CONTAINER_EXITED ||
+		status.ExitCode != 2 || status.StartedAt != 1571047200000000000 || status.FinishedAt != 1571047260000000000 {
+		t.Errorf("Exited Singularity containers should be parsed: %+v %v", status, err)
+	}
+	if status, err := parseSingularityState("lost 0 1571047200 0\n", cm); err != nil || status.ExitCode != lostExitCode {
+		t.Errorf("Lost Singularity containers should exit: %+v %v", status, err)
+	}
+	if _, err := parseSingularityState("missing\n", cm); !adapters.IsNotFound(err) {
+		t.Errorf("Missing Singularity containers should not be found: %v", err)
+	}
+}
+
+//Test the Singularity containers run in a fake host, with their output in the log, exec and stop
+func TestUnitSingularityContainer(t *testing.T) {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	if err := cluster.AddCommand("singularity", singularityScript); err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewRemoteHostAdapterWithConfig(adapters.AdapterConfig{"engine": engineSingularity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := a.(*RemoteHostAdapter)
+	ctx := context.Background()
+
+	cm := newContainer(t, "container1", cluster)
+	defer os.RemoveAll(filepath.Dir(cm.LogFile))
+	cm.Command = []string{"sh", "-c", "echo hello; echo world >&2; exit 3"}
+	if err := adapter.CreateContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(cluster.Home, "multi-cri/images/alpine_latest.sif")); err != nil {
+		t.Errorf("Image should be pulled: %v", err)
+	}
+	if err := adapter.StartContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	waitExited(t, adapter, cm)
+	if cm.ExitCode != 3 || cm.Reason != batch.ReasonError {
+		t.Errorf("Container should exit with code 3: %d %s", cm.ExitCode, cm.Reason)
+	}
+	log, err := ioutil.ReadFile(cm.LogFile)
+	if err != nil || !strings.Contains(string(log), "hello") || !strings.Contains(string(log), "world") {
+		t.Errorf("Container output should be logged: %q %v", log, err)
+	}
+	if _, err := os.Stat(filepath.Join(cluster.Home, "multi-cri/containers/container1")); !os.IsNotExist(err) {
+		t.Errorf("Exited containers should be removed: %v", err)
+	}
+
+	cm = newContainer(t, "container2", cluster)
+	defer os.RemoveAll(filepath.Dir(cm.LogFile))
+	cm.Command = []string{"sleep", "30"}
+	if err := adapter.CreateContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.StartContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	if err := adapter.ContainerStatus(ctx, cm); err != nil || cm.State != runtimeApi.ContainerState_CONTAINER_RUNNING {
+		t.Fatalf("Container should run: %s %v", cm.State, err)
+	}
+	response, err := adapter.ExecSync(ctx, cm, []string{"sh", "-c", "echo out; echo err >&2; exit 4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(response.Stdout) != "out\n" || string(response.Stderr) != "err\n" || response.ExitCode != 4 {
+		t.Errorf("Wrong exec response: %q %q %d", response.Stdout, response.Stderr, response.ExitCode)
+	}
+	if err := adapter.StopContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	waitExited(t, adapter, cm)
+	if cm.ExitCode != 143 || cm.Reason != batch.ReasonKilled {
+		t.Errorf("Stopped container should be killed: %d %s", cm.ExitCode, cm.Reason)
+	}
+	if err := adapter.StopContainer(ctx, cm); err != nil {
+		t.Errorf("Removed containers should be stopped: %v", err)
+	}
+}
+
+//Test the adapter passes the conformance suite with Singularity containers in a fake host
+func TestUnitConformance(t *testing.T) {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	if err := cluster.AddCommand("singularity", singularityScript); err != nil {
+		t.Fatal(err)
+	}
+	conformance.Run(t, conformance.Config{
+		New: func() (adapters.AdapterInterface, error) {
+			return NewRemoteHostAdapterWithConfig(adapters.AdapterConfig{"engine": engineSingularity})
+		},
+		Fixtures:     conformance.EnvironmentFixtures(cluster.Credentials()),
+		Timeout:      20 * time.Second,
+		PollInterval: 100 * time.Millisecond,
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sge implements the adapter of the Grid Engine family of schedulers: Sun/Son of Grid Engine,
+// Univa Grid Engine and Open Grid Scheduler. Containers are submitted as batch jobs with qsub
+// through SSH, like in the Slurm adapter.
+package sge
+
+import (
+	"fmt"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	SGEADAPTERVERSION = "0.1.0"
+	SGENAME           = "Adapter Grid Engine"
+	MOUNTHPATH        = "multi-cri"
+	// DefaultMPIPE and DefaultSMPPE are the usual names of the parallel environments
+	// which spread the slots among hosts and allocate them in a single host
+	DefaultMPIPE = "mpi"
+	DefaultSMPPE = "smp"
+)
+
+type SGEAdapter struct {
+	batch.Base
+	ImageRemoteMount string
+	// MPIPE is the parallel environment of MPI and multi-node jobs, SMPPE the one of multi-core jobs
+	MPIPE string
+	SMPPE string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "sge",
+		Description: "Submits containers as batch jobs to Grid Engine clusters (SGE, UGE, OGS)",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the Grid Engine cluster, relative to $HOME (CRI_SGE_MOUNT_PATH)",
+			"image-remote-mount": "Path in which the images are built (CRI_SGE_IMAGE_REMOTE_MOUNT)",
+			"build-in-cluster":   "Build images directly in the Grid Engine cluster (CRI_SGE_BUILD_IN_CLUSTER)",
+			"mpi-pe":             "Parallel environment of the MPI and multi-node jobs (CRI_SGE_MPI_PE)",
+			"smp-pe":             "Parallel environment of the multi-core jobs (CRI_SGE_SMP_PE)",
+		},
+		Validate: validateConfig,
+		New:      NewSGEAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if _, err := config.GetBool("build-in-cluster", false); err != nil {
+		return err
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	return nil
+}
+
+// NewSGEAdapter creates the Grid Engine adapter configured with the CRI_SGE_* environment variables
+func NewSGEAdapter() (adapters.AdapterInterface, error) {
+	return NewSGEAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewSGEAdapterWithConfig creates the Grid Engine adapter. Options not set in the config
+// are read from the CRI_SGE_* environment variables
+func NewSGEAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	q := MOUNTHPATH
+	b := false
+	remoteDefault := ""
+	mpiDefault := DefaultMPIPE
+	smpDefault := DefaultSMPPE
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_SGE_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_SGE_MOUNT_PATH", &q))
+	mpiPE := config.Get("mpi-pe", common.GetEnv("CRI_SGE_MPI_PE", &mpiDefault))
+	smpPE := config.Get("smp-pe", common.GetEnv("CRI_SGE_SMP_PE", &smpDefault))
+	buildInCluster, err := config.GetBool("build-in-cluster", common.GetBoolEnv("CRI_SGE_BUILD_IN_CLUSTER", &b))
+	if err != nil {
+		return nil, err
+	}
+	build, err := builder.NewImageBuilder(buildInCluster, mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
+	}
+	return SGEAdapter{Base: batch.NewBase("SGE", mountP, build),
+		ImageRemoteMount: imageRemoteMountPath, MPIPE: mpiPE, SMPPE: smpPE}, nil
+}
+
+func (s SGEAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           SGEADAPTERVERSION,
+		RuntimeName:       SGENAME,
+		RuntimeVersion:    SGEADAPTERVERSION,
+		RuntimeApiVersion: SGEADAPTERVERSION,
+	}, nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted
+func (s SGEAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{ImageFsInfo: true}
+}
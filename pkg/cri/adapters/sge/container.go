@@ -0,0 +1,135 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sge
+
+import (
+	"fmt"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// jobSettingRules are the job settings which SGE supports
+var jobSettingRules = []batch.JobSettingRule{batch.QueueRule, batch.TimeRule, batch.GresRule, batch.NodesRule,
+	batch.CoresRule, batch.CoresPerNodeRule, batch.TasksPerNodeRule, batch.CustomConfigRule}
+
+func (s SGEAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	klog.Infof("Creating container path in server")
+	if err := batch.ValidateJobSettings(cm, s.Name, jobSettingRules); err != nil {
+		return err
+	}
+	batch.SetupContainerPaths(cm, s.MountPath)
+	client, err := batch.NewClient(cm, sgeErrors)
+	if err != nil {
+		return err
+	}
+	//Ensure container path exists in Grid Engine cluster
+	if err := client.MakeDir(ctx, cm.Extra["RMPath"]); err != nil {
+		return err
+	}
+	//Pull image in Grid Engine cluster
+	if err := s.Builder.PullImageInCluster(ctx, cm); err != nil {
+		return err
+	}
+	klog.Infof("Created container path in server with id %s", cm.ID)
+	return nil
+}
+
+func (s SGEAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, sgeErrors)
+	if err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	command := settings.Command(cm, builder.GetRMImagePath(cm, s.MountPath, s.ImageRemoteMount))
+	response, err := client.Submit(ctx, cm, settings, batchScript(settings, s.MPIPE, s.SMPPE, command),
+		fmt.Sprintf("qsub -terse %s", batch.BatchScript))
+	if err != nil {
+		return err
+	}
+	cm.Pid, err = parseJobId(response)
+	return err
+}
+
+func (s SGEAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := batch.NewClient(cm, sgeErrors)
+	if err != nil {
+		return err
+	}
+	_, err = client.Run(ctx, fmt.Sprintf("qdel %d", cm.Pid))
+	if adapters.IsNotFound(err) {
+		// The job already finished
+		return nil
+	}
+	return err
+}
+
+func (s SGEAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Pid == 0 {
+		return nil
+	}
+	client, err := batch.NewClient(cm, sgeErrors)
+	if err != nil {
+		return err
+	}
+	status, err := jobStatus(ctx, client, cm.Pid)
+	if err != nil {
+		return err
+	}
+	status.Apply(cm)
+	if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+		client.CopyOutput(ctx, cm)
+	}
+	return nil
+}
+
+// jobStatus queries the job with qstat, and with qacct once it left the queue. The accounting record
+// is written some seconds after the job finishes, so the job is still running until it is found.
+func jobStatus(ctx context.Context, client *batch.Client, pid int) (*batch.JobStatus, error) {
+	out, err := client.Output(ctx, "qstat -xml")
+	if err != nil {
+		return nil, batch.WrapError(err, "Retrieve job info fails %s ", err)
+	}
+	status, err := parseQstat(out, pid)
+	if !adapters.IsNotFound(err) {
+		return status, err
+	}
+	out, err = client.Output(ctx, fmt.Sprintf("qacct -j %d", pid))
+	if err == nil {
+		status, err = parseQacct(out, pid)
+	}
+	if adapters.IsNotFound(err) {
+		klog.V(4).Infof("Accounting of job %d not found yet", pid)
+		return &batch.JobStatus{State: runtimeApi.ContainerState_CONTAINER_RUNNING}, nil
+	}
+	if err != nil {
+		return nil, batch.WrapError(err, "Retrieve job accounting fails %s ", err)
+	}
+	return status, nil
+}
+
+func (s SGEAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("SGE: ReopenContainerLog not implemented")
+}
+
+func (s SGEAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("SGE: UpdateContainerResources not implemented")
+}
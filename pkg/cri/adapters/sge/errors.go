@@ -0,0 +1,36 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sge
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// sgeErrors classifies the Grid Engine command errors by their message
+var sgeErrors = []batch.ErrorPattern{
+	{"parallel environment", adapters.KindInvalidArgument},
+	{"unknown queue", adapters.KindInvalidArgument},
+	{"unknown resource", adapters.KindInvalidArgument},
+	{"job rejected", adapters.KindInvalidArgument},
+	{"invalid option argument", adapters.KindInvalidArgument},
+	{"does not exist", adapters.KindNotFound},
+	{"not found", adapters.KindNotFound},
+	{"has no permission", adapters.KindPermissionDenied},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"unable to contact qmaster", adapters.KindUnavailable},
+	{"unable to send message to qmaster", adapters.KindUnavailable},
+	{"commlib error", adapters.KindUnavailable},
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// batchScript returns the job script with the #$ directives of the job settings. The job runs in the
+// container path with the environment exported by the run script.
+func batchScript(settings batch.JobSettings, mpiPE, smpPE, command string) []string {
+	lines := []string{
+		"#!/bin/bash",
+		fmt.Sprintf("#$ -N %s", settings.Name),
+		"#$ -S /bin/bash",
+		"#$ -cwd",
+		"#$ -V",
+		fmt.Sprintf("#$ -o %s", batch.StdoutFile),
+		fmt.Sprintf("#$ -e %s", batch.SterrFile),
+	}
+	if settings.Queue != "" {
+		lines = append(lines, fmt.Sprintf("#$ -q %s", settings.Queue))
+	}
+	if pe := parallelEnvironment(settings, mpiPE, smpPE); pe != "" {
+		lines = append(lines, fmt.Sprintf("#$ -pe %s", pe))
+	}
+	if gpus := settings.GPUCount(); gpus > 0 {
+		lines = append(lines, fmt.Sprintf("#$ -l gpu=%d", gpus))
+	}
+	if seconds := settings.TimeLimitSeconds(); seconds == batch.TimeLimitUnlimited {
+		lines = append(lines, "#$ -l h_rt=INFINITY")
+	} else if seconds > 0 {
+		lines = append(lines, fmt.Sprintf("#$ -l h_rt=%s", batch.Walltime(seconds)))
+	}
+	if settings.CustomConfig != "" {
+		lines = append(lines, settings.CustomConfig)
+	}
+	return append(lines, command)
+}
+
+// parallelEnvironment returns the parallel environment and the slots of the job. MPI and multi-node
+// jobs use the MPI parallel environment, and multi-core jobs the SMP one. Single slot jobs do not
+// need a parallel environment, unless they run mpirun.
+func parallelEnvironment(settings batch.JobSettings, mpiPE, smpPE string) string {
+	slots := settings.TaskCount()
+	switch {
+	case settings.IsMPI() && slots > 0, settings.NodeCount() > 1:
+		return fmt.Sprintf("%s %d", mpiPE, slots)
+	case slots > 1:
+		return fmt.Sprintf("%s %d", smpPE, slots)
+	default:
+		return ""
+	}
+}
+
+// parseJobId returns the job id printed by qsub -terse, e.g. 1234 for "1234" or "1234.1-10:1" in array jobs
+func parseJobId(response string) (int, error) {
+	id := strings.TrimSpace(response)
+	if lines := strings.Split(id, "\n"); len(lines) > 1 {
+		id = strings.TrimSpace(lines[len(lines)-1])
+	}
+	pid, err := strconv.Atoi(strings.SplitN(id, ".", 2)[0])
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("Not submitted batch job id found: %s ", response)
+	}
+	return pid, nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// failedLimit is the qacct failed code of the jobs killed by qmaster for exceeding their limits
+	failedLimit = 37
+	// failedAfterJob is the qacct failed code of the errors after the job ran, such as a kill signal
+	failedAfterJob = 100
+	// signalExitStatus is the first exit status of the jobs killed by a signal
+	signalExitStatus = 128
+)
+
+// timeLayouts are the time formats of qstat -xml, and of qacct in SGE and in UGE
+var timeLayouts = []string{"2006-01-02T15:04:05", time.ANSIC, "01/02/2006 15:04:05"}
+
+// qstatJob contains the fields of the qstat -xml job list used by the adapter
+type qstatJob struct {
+	Number    int    `xml:"JB_job_number"`
+	State     string `xml:"state"`
+	StartTime string `xml:"JAT_start_time"`
+}
+
+// qstatOutput contains the running jobs, listed by queue, and the pending jobs
+type qstatOutput struct {
+	Running []qstatJob `xml:"queue_info>job_list"`
+	Pending []qstatJob `xml:"job_info>job_list"`
+}
+
+// parseQstat returns the status of the job in the qstat -xml output. Jobs which are not in the
+// output have left the queue.
+func parseQstat(output string, pid int) (*batch.JobStatus, error) {
+	var out qstatOutput
+	if err := xml.Unmarshal([]byte(output), &out); err != nil {
+		return nil, fmt.Errorf("qstat output cannot be parsed: %v", err)
+	}
+	for _, job := range append(out.Running, out.Pending...) {
+		if job.Number != pid {
+			continue
+		}
+		status := &batch.JobStatus{State: jobState(job.State), StartedAt: parseTime(job.StartTime)}
+		if status.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+			// Jobs in error state stay in the queue until they are deleted
+			status.ExitCode = 1
+			status.Reason = batch.ReasonCannotRun
+		}
+		return status, nil
+	}
+	return nil, adapters.NotFoundError("Job %d not found in qstat output", pid)
+}
+
+// jobState maps the Grid Engine job states, which combine several letters such as "hqw" or "dr",
+// to the container states. Jobs in error state (E) are exited; running, transferring, restarted and
+// suspended jobs are running; queued, waiting and held jobs are created.
+func jobState(state string) runtimeApi.ContainerState {
+	switch {
+	case strings.Contains(state, "E"):
+		return runtimeApi.ContainerState_CONTAINER_EXITED
+	case strings.ContainsAny(state, "rtRsST"):
+		return runtimeApi.ContainerState_CONTAINER_RUNNING
+	default:
+		return runtimeApi.ContainerState_CONTAINER_CREATED
+	}
+}
+
+// parseQacct returns the status of the finished job from its qacct -j accounting record.
+// The last record is used when the job id was reused.
+func parseQacct(output string, pid int) (*batch.JobStatus, error) {
+	var record map[string]string
+	current := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "====") {
+			current = map[string]string{}
+			continue
+		}
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		current[fields[0]] = strings.TrimSpace(fields[1])
+		if fields[0] == "jobnumber" && current["jobnumber"] == strconv.Itoa(pid) {
+			record = current
+		}
+	}
+	if record == nil {
+		return nil, adapters.NotFoundError("Job %d not found in qacct output", pid)
+	}
+	status := &batch.JobStatus{
+		State:      runtimeApi.ContainerState_CONTAINER_EXITED,
+		StartedAt:  parseTime(record["start_time"]),
+		FinishedAt: parseTime(record["end_time"]),
+	}
+	failed := leadingInt(record["failed"])
+	exitStatus := leadingInt(record["exit_status"])
+	switch {
+	case failed == failedLimit:
+		status.ExitCode = exitStatus
+		status.Reason = batch.ReasonDeadlineExceeded
+	case failed != 0 && failed != failedAfterJob:
+		// The job could not be started
+		status.ExitCode = 1
+		status.Reason = batch.ReasonCannotRun
+	case exitStatus > signalExitStatus:
+		status.ExitCode = exitStatus
+		status.Reason = batch.ReasonKilled
+	default:
+		status.ExitCode = exitStatus
+	}
+	if status.ExitCode == 0 && status.Reason != "" {
+		status.ExitCode = 1
+	}
+	return status, nil
+}
+
+// leadingInt returns the number at the beginning of a qacct value, e.g. 37 for
+// "37  : qmaster enforced h_rt, h_cpu, or h_vmem limit"
+func leadingInt(value string) int {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(fields[0])
+	return n
+}
+
+// parseTime returns the time in nanoseconds, or 0 when it can not be parsed, as the "-/-" times of
+// the jobs which did not start
+func parseTime(value string) int64 {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), time.Local); err == nil {
+			return t.UnixNano()
+		}
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sge
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the MPI and core settings are translated to parallel environments
+func TestUnitParallelEnvironment(t *testing.T) {
+	for _, c := range []struct {
+		settings batch.JobSettings
+		expected string
+	}{
+		{batch.JobSettings{}, ""},
+		{batch.JobSettings{Cores: "1"}, ""},
+		{batch.JobSettings{Cores: "4"}, "smp 4"},
+		{batch.JobSettings{Nodes: "2", CoresPerNode: "4"}, "mpi 8"},
+		{batch.JobSettings{Nodes: "3"}, "mpi 3"},
+		{batch.JobSettings{Nodes: "2", TasksPerNode: "8"}, "mpi 16"},
+		{batch.JobSettings{MPIVersion: "1.10.2", MPIFlags: "-np 2"}, "mpi 2"},
+		{batch.JobSettings{MPIVersion: "1.10.2", MPIFlags: "--mca btl self -np 2", Cores: "6"}, "mpi 6"},
+		{batch.JobSettings{MPIVersion: "1.10.2"}, ""},
+	} {
+		if pe := parallelEnvironment(c.settings, DefaultMPIPE, DefaultSMPPE); pe != c.expected {
+			t.Errorf("Parallel environment of %+v should be %q instead of %q", c.settings, c.expected, pe)
+		}
+	}
+	script := strings.Join(batchScript(batch.JobSettings{Name: "test", Queue: "all.q", Cores: "2", GPU: "gpu:1", TimeLimit: "45:30"},
+		DefaultMPIPE, DefaultSMPPE, "singularity exec image hostname"), "\n")
+	for _, line := range []string{"#$ -N test", "#$ -q all.q", "#$ -pe smp 2", "#$ -l gpu=1", "#$ -l h_rt=00:45:30", "#$ -cwd", "#$ -V"} {
+		if !strings.Contains(script, line) {
+			t.Errorf("Batch script should contain %q:\n%s", line, script)
+		}
+	}
+	script = strings.Join(batchScript(batch.JobSettings{Name: "test", TimeLimit: "UNLIMITED"}, DefaultMPIPE, DefaultSMPPE, "hostname"), "\n")
+	if !strings.Contains(script, "#$ -l h_rt=INFINITY") {
+		t.Errorf("Unlimited jobs should have an infinite run time:\n%s", script)
+	}
+}
+
+//Test qsub -terse job ids are parsed
+func TestUnitParseJobId(t *testing.T) {
+	for response, expected := range map[string]int{"1234\n": 1234, "1235.1-10:1\n": 1235} {
+		if pid, err := parseJobId(response); err != nil || pid != expected {
+			t.Errorf("Job id should be %d: %d %v", expected, pid, err)
+		}
+	}
+	if _, err := parseJobId("Unable to run job: job rejected"); err == nil {
+		t.Errorf("Wrong job ids should fail")
+	}
+}
+
+//Test qstat XML output is translated to container states
+func TestUnitParseQstat(t *testing.T) {
+	qstat := `<?xml version='1.0'?>
+<job_info  xmlns:xsd="http://arc.liv.ac.uk/repos/darcs/sge/source/dist/util/resources/schemas/qstat/qstat.xsd">
+  <queue_info>
+    <job_list state="running">
+      <JB_job_number>1234</JB_job_number>
+      <JB_name>test</JB_name>
+      <state>r</state>
+      <JAT_start_time>2019-10-14T10:00:00</JAT_start_time>
+      <queue_name>all.q@node1</queue_name>
+      <slots>1</slots>
+    </job_list>
+  </queue_info>
+  <job_info>
+    <job_list state="pending">
+      <JB_job_number>1235</JB_job_number>
+      <state>hqw</state>
+      <JB_submission_time>2019-10-14T10:00:00</JB_submission_time>
+    </job_list>
+    <job_list state="pending">
+      <JB_job_number>1236</JB_job_number>
+      <state>Eqw</state>
+    </job_list>
+  </job_info>
+</job_info>`
+	for pid, expected := range map[int]runtimeApi.ContainerState{
+		1234: runtimeApi.ContainerState_CONTAINER_RUNNING,
+		1235: runtimeApi.ContainerState_CONTAINER_CREATED,
+		1236: runtimeApi.ContainerState_CONTAINER_EXITED,
+	} {
+		status, err := parseQstat(qstat, pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != expected {
+			t.Errorf("State of job %d should be %s: %+v", pid, expected, status)
+		}
+	}
+	if status, _ := parseQstat(qstat, 1234); status.StartedAt == 0 {
+		t.Errorf("Start time should be parsed: %+v", status)
+	}
+	if _, err := parseQstat(qstat, 1); !adapters.IsNotFound(err) {
+		t.Errorf("Jobs out of the queue should not be found: %v", err)
+	}
+}
+
+//Test qacct records are translated to container exit statuses
+func TestUnitParseQacct(t *testing.T) {
+	qacct := `==============================================================
+qname        all.q
+hostname     node1
+jobname      test
+jobnumber    1234
+qsub_time    Mon Oct 14 09:59:00 2019
+start_time   Mon Oct 14 10:00:00 2019
+end_time     Mon Oct 14 10:05:00 2019
+failed       %s
+exit_status  %d
+`
+	for _, c := range []struct {
+		failed     string
+		exitStatus int
+		exitCode   int
+		reason     string
+	}{
+		{"0", 0, 0, ""},
+		{"0", 2, 2, ""},
+		{"100 : assumedly after job", 137, 137, batch.ReasonKilled},
+		{"37  : qmaster enforced h_rt, h_cpu, or h_vmem limit", 137, 137, batch.ReasonDeadlineExceeded},
+		{"1       : assumedly before job", 0, 1, batch.ReasonCannotRun},
+	} {
+		status, err := parseQacct(fmt.Sprintf(qacct, c.failed, c.exitStatus), 1234)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.State != runtimeApi.ContainerState_CONTAINER_EXITED || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("Failed %q and exit status %d wrong: %+v", c.failed, c.exitStatus, status)
+		}
+		if status.StartedAt == 0 || status.FinishedAt <= status.StartedAt {
+			t.Errorf("Times should be parsed: %+v", status)
+		}
+	}
+	if _, err := parseQacct(fmt.Sprintf(qacct, "0", 0), 1); !adapters.IsNotFound(err) {
+		t.Errorf("Other jobs should not be found: %v", err)
+	}
+}
+
+//Test Grid Engine errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		kind     adapters.ErrorKind
+	}{
+		{`Unable to run job: job rejected: the requested parallel environment "mpi" does not exist.`, adapters.KindInvalidArgument},
+		{`denied: job "1234" does not exist`, adapters.KindNotFound},
+		{"error: job id 1234 not found", adapters.KindNotFound},
+		{"error: commlib error: got select error (Connection refused)\nunable to contact qmaster", adapters.KindUnavailable},
+		{"user has no permission to modify job", adapters.KindPermissionDenied},
+	} {
+		err := batch.ClassifyError(sgeErrors, c.response, fmt.Errorf("exit status 1"))
+		if kind := adapters.ErrorKindOf(err); kind != c.kind {
+			t.Errorf("Error %q should be %q instead of %q", c.response, c.kind, kind)
+		}
+	}
+}
+
+// startCluster starts a fake cluster with the Grid Engine commands of the adapter: qsub -terse submits the
+// script, qdel deletes the job, qstat -xml shows the jobs in the queue and qacct -j the finished ones
+func startCluster(t *testing.T) *fakecluster.Cluster {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler := fakecluster.Scheduler{Directives: "#$", Name: "-N", Stdout: "-o", Stderr: "-e", Submitted: "%d\n",
+		Unknown: "denied: job \"%s\" does not exist\n", UnknownStatus: 1}
+	cluster.AddBuiltin("qsub", cluster.SubmitCommand(scheduler))
+	cluster.AddBuiltin("qdel", cluster.CancelCommand(scheduler))
+	cluster.AddBuiltin("qstat", func(s fakecluster.Session, args []string) int {
+		var out qstatOutput
+		for _, job := range cluster.Jobs() {
+			switch {
+			case !job.EndTime.IsZero():
+			case !job.StartTime.IsZero():
+				out.Running = append(out.Running, qstatJob{Number: job.ID, State: "r",
+					StartTime: job.StartTime.Format(timeLayouts[0])})
+			default:
+				out.Pending = append(out.Pending, qstatJob{Number: job.ID, State: "qw"})
+			}
+		}
+		xml.NewEncoder(s.Stdout).Encode(out)
+		return 0
+	})
+	cluster.AddBuiltin("qacct", func(s fakecluster.Session, args []string) int {
+		id, _ := strconv.Atoi(args[len(args)-1])
+		job, ok := cluster.Job(id)
+		if !ok || job.EndTime.IsZero() {
+			fmt.Fprintf(s.Stderr, "error: job id %d not found\n", id)
+			return 1
+		}
+		failed, exitStatus := 0, job.ExitCode
+		if job.State == fakecluster.StateCancelled {
+			failed, exitStatus = failedAfterJob, signalExitStatus+9
+		}
+		fmt.Fprintf(s.Stdout, "==============================================================\n")
+		fmt.Fprintf(s.Stdout, "jobnumber    %d\nfailed       %d\nexit_status  %d\n", id, failed, exitStatus)
+		fmt.Fprintf(s.Stdout, "start_time   %s\nend_time     %s\n", job.StartTime.Format(time.ANSIC), job.EndTime.Format(time.ANSIC))
+		return 0
+	})
+	return cluster
+}
+
+//Test the adapter passes the conformance suite against a fake Grid Engine cluster
+func TestUnitConformance(t *testing.T) {
+	cluster := startCluster(t)
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewSGEAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
@@ -17,9 +17,15 @@ package slurm
 import (
 	"multi-cri/pkg/cri/adapters"
 	"multi-cri/pkg/cri/adapters/slurm/builder"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
 	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"golang.org/x/net/context"
+	"k8s.io/klog"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
@@ -30,38 +36,109 @@ const (
 	StdoutFile          = "stdout.out"
 	SterrFile           = "sterr.out"
 	RunScript           = "run.sh"
+	// ArrayStdoutFile and ArraySterrFile are the output files of the array tasks, %a is the task id
+	ArrayStdoutFile = "stdout_%a.out"
+	ArraySterrFile  = "sterr_%a.out"
+	// TransportSSH runs the Slurm commands through SSH, TransportRest calls the slurmrestd REST API
+	TransportSSH  = "ssh"
+	TransportRest = "rest"
 )
 
 type SlurmAdapter struct {
 	MountPath        string
 	Builder          builder.ImageBuilder
 	ImageRemoteMount string
+	// Transport submits, queries and cancels the jobs
+	Transport      string
+	RestAPIVersion string
+	// Allocation is AllocationJob, or AllocationPod to run the containers as steps of one allocation per pod
+	Allocation string
+	pods       *podAllocations
+	jobs       *podJobs
+	hetJobs    *podHetJobs
 }
 
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "slurm",
+		Description: "Submits containers as batch jobs to Slurm clusters",
+		Options: map[string]string{
+			"mount-path":         "Working directory in the Slurm cluster, relative to $HOME (CRI_SLURM_MOUNT_PATH)",
+			"image-remote-mount": "Path in which the images are built (CRI_SLURM_IMAGE_REMOTE_MOUNT)",
+			"build-in-cluster":   "Build images directly in the Slurm cluster (CRI_SLURM_BUILD_IN_CLUSTER)",
+			"transport":          "Transport of the job operations, ssh or rest (CRI_SLURM_TRANSPORT)",
+			"rest-api-version":   "Version of the slurmrestd API of the rest transport (CRI_SLURM_REST_API_VERSION)",
+			"allocation":         "Allocation of the containers, job per container or pod with containers as steps (CRI_SLURM_ALLOCATION)",
+		},
+		Validate: validateConfig,
+		New:      NewSlurmAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if _, err := config.GetBool("build-in-cluster", false); err != nil {
+		return err
+	}
+	if strings.HasPrefix(config.Get("mount-path", MOUNTHPATH), "/") {
+		return fmt.Errorf("mount-path must be relative to the $HOME directory")
+	}
+	if transport := config.Get("transport", TransportSSH); transport != TransportSSH && transport != TransportRest {
+		return fmt.Errorf("transport must be %s or %s: %s", TransportSSH, TransportRest, transport)
+	}
+	return validateAllocation(config.Get("allocation", AllocationJob), config.Get("transport", TransportSSH))
+}
+
+func validateAllocation(allocation, transport string) error {
+	if allocation != AllocationJob && allocation != AllocationPod {
+		return fmt.Errorf("allocation must be %s or %s: %s", AllocationJob, AllocationPod, allocation)
+	}
+	if allocation == AllocationPod && transport != TransportSSH {
+		return fmt.Errorf("allocation %s requires the %s transport", AllocationPod, TransportSSH)
+	}
+	return nil
+}
+
+// NewSlurmAdapter creates the Slurm adapter configured with the CRI_SLURM_* environment variables
 func NewSlurmAdapter() (adapters.AdapterInterface, error) {
+	return NewSlurmAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+// NewSlurmAdapterWithConfig creates the Slurm adapter. Options not set in the config
+// are read from the CRI_SLURM_* environment variables
+func NewSlurmAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
 	q := MOUNTHPATH
 	b := false
 	remoteDefault := ""
-	imageRemoteMountPath := common.GetEnv("CRI_SLURM_IMAGE_REMOTE_MOUNT", &remoteDefault)
-	mountP := common.GetEnv("CRI_SLURM_MOUNT_PATH", &q)
-
-	var build builder.ImageBuilder
-	var err error
-	if common.GetBoolEnv("CRI_SLURM_BUILD_IN_CLUSTER", &b) {
+	imageRemoteMountPath := config.Get("image-remote-mount", common.GetEnv("CRI_SLURM_IMAGE_REMOTE_MOUNT", &remoteDefault))
+	mountP := config.Get("mount-path", common.GetEnv("CRI_SLURM_MOUNT_PATH", &q))
+	buildInCluster, err := config.GetBool("build-in-cluster", common.GetBoolEnv("CRI_SLURM_BUILD_IN_CLUSTER", &b))
+	if err != nil {
+		return nil, err
+	}
+	t := TransportSSH
+	v := cmd.RestAPIVersion
+	transport := config.Get("transport", common.GetEnv("CRI_SLURM_TRANSPORT", &t))
+	restAPIVersion := config.Get("rest-api-version", common.GetEnv("CRI_SLURM_REST_API_VERSION", &v))
+	if transport != TransportSSH && transport != TransportRest {
+		return nil, fmt.Errorf("transport must be %s or %s: %s", TransportSSH, TransportRest, transport)
+	}
+	a := AllocationJob
+	allocation := config.Get("allocation", common.GetEnv("CRI_SLURM_ALLOCATION", &a))
+	if err := validateAllocation(allocation, transport); err != nil {
+		return nil, err
+	}
 
-		if build, err = builder.NewImageBuilderInCluster(mountP, imageRemoteMountPath); err != nil {
-			return nil, err
-		}
-	} else {
-		if build, err = builder.NewImageBuilderInCRI(imageRemoteMountPath); err != nil {
-			return nil, err
-		}
+	build, err := builder.NewImageBuilder(buildInCluster, mountP, imageRemoteMountPath)
+	if err != nil {
+		return nil, err
 	}
 
-	return SlurmAdapter{MountPath: mountP, Builder: build, ImageRemoteMount: imageRemoteMountPath}, nil
+	return SlurmAdapter{MountPath: mountP, Builder: build, ImageRemoteMount: imageRemoteMountPath,
+		Transport: transport, RestAPIVersion: restAPIVersion, Allocation: allocation, pods: newPodAllocations(), jobs: newPodJobs(),
+		hetJobs: newPodHetJobs()}, nil
 }
 
-func (s SlurmAdapter) Version() (*runtimeApi.VersionResponse, error) {
+func (s SlurmAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
 	return &runtimeApi.VersionResponse{
 		Version:           SLURMADAPTERVERSION,
 		RuntimeName:       SLURMNAME,
@@ -70,6 +147,35 @@ func (s SlurmAdapter) Version() (*runtimeApi.VersionResponse, error) {
 	}, nil
 }
 
+// Restore rebuilds the allocations of the pods, the jobs of the containers and the heterogeneous jobs from
+// the stored containers, so the allocations are used and released, the dependencies are resolved and the
+// components find their job after restarts
+func (s SlurmAdapter) Restore(ctx context.Context, containers []*store.ContainerMetadata) error {
+	s.jobs.restore(containers)
+	s.hetJobs.restore(containers)
+	for _, cm := range containers {
+		if err := s.restoreAllocation(cm); err != nil {
+			klog.Warningf("Allocation of container %s can not be restored: %v", cm.ID, err)
+		}
+	}
+	return nil
+}
+
+// Capabilities reports that jobs can not be accessed once they are submitted, but their resources can be
+// updated
+func (s SlurmAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{UpdateContainerResources: true, ImageFsInfo: true}
+}
+
+// jobClient returns the client of the transport of the job operations. Files and images are always
+// handled through SSH.
+func (s SlurmAdapter) jobClient(cm *store.ContainerMetadata) (cmd.JobClient, error) {
+	if s.Transport == TransportRest {
+		return cmd.CreateRestClient(cm, s.RestAPIVersion)
+	}
+	return cmd.CreateCMD(cm)
+}
+
 func getRMScriptPath(RMContainerPath string) string {
 	return fmt.Sprintf("%s/%s", RMContainerPath, RunScript)
 }
@@ -81,3 +187,12 @@ func getRMStderrPath(RMContainerPath string) string {
 func getRMStdoutPath(RMContainerPath string) string {
 	return fmt.Sprintf("%s/%s", RMContainerPath, StdoutFile)
 }
+
+// getRMArrayPaths returns the paths of the file of every task, from the file pattern of the array
+func getRMArrayPaths(RMContainerPath, pattern string, tasks []int) []string {
+	var paths []string
+	for _, task := range tasks {
+		paths = append(paths, fmt.Sprintf("%s/%s", RMContainerPath, strings.Replace(pattern, "%a", strconv.Itoa(task), -1)))
+	}
+	return paths
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"fmt"
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// AllocationJob submits every container as a batch job, AllocationPod runs the containers of a pod as
+	// steps of one allocation
+	AllocationJob = "job"
+	AllocationPod = "pod"
+	// AllocationCommand launches the steps of the pod in its allocation until it is cancelled
+	AllocationCommand = cmd.StepLauncher
+)
+
+// allocation is the holder batch job of a pod. Its client keeps the cluster credentials of the container
+// which requested it, since the pod sandbox does not have them. The lock serializes the requests of the
+// containers of the pod, and jobId is 0 until the holder job is submitted.
+type allocation struct {
+	lock   sync.Mutex
+	jobId  int32
+	client *cmd.SlurmCmd
+}
+
+// podAllocations are the allocations of the running pods, by pod sandbox id. The lock only guards the map,
+// so the pods request their allocations concurrently.
+type podAllocations struct {
+	lock        sync.Mutex
+	allocations map[string]*allocation
+}
+
+func newPodAllocations() *podAllocations {
+	return &podAllocations{allocations: make(map[string]*allocation)}
+}
+
+// pod returns the allocation of the pod, which is created without holder job when the pod has none
+func (p *podAllocations) pod(sandboxID string) *allocation {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	a, ok := p.allocations[sandboxID]
+	if !ok {
+		a = &allocation{}
+		p.allocations[sandboxID] = a
+	}
+	return a
+}
+
+func getRMPodPath(cm *store.ContainerMetadata) string {
+	return fmt.Sprintf("%s/%s", cm.Extra["RMVolumePath"], cm.PodSandbox.ID)
+}
+
+// validateStep returns an error when the job settings of the container can not run as a job step
+func validateStep(settings batch.JobSettings) error {
+	if settings.Array != "" {
+		return adapters.InvalidArgumentError("Job arrays are not supported with the pod allocation")
+	}
+	if settings.IsMPI() {
+		return adapters.InvalidArgumentError("MPI jobs are not supported with the pod allocation")
+	}
+	return nil
+}
+
+// requestAllocation returns the job id of the allocation of the pod of the container. The first container of
+// the pod submits the holder job, sized with its job settings, so the credentials of the cluster are known.
+func (s SlurmAdapter) requestAllocation(ctx context.Context, cm *store.ContainerMetadata) (int32, error) {
+	if s.pods == nil {
+		return 0, fmt.Errorf("pod allocations are not set up")
+	}
+	a := s.pods.pod(cm.PodSandbox.ID)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.jobId != 0 {
+		return a.jobId, nil
+	}
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return 0, err
+	}
+	podPath := getRMPodPath(cm)
+	if _, err := slurmClient.ExecCmd(ctx, fmt.Sprintf("mkdir -p %s", podPath)); err != nil {
+		return 0, err
+	}
+	settings := batch.ParseJobSettings(cm)
+	settings.Name = cm.PodSandbox.ID
+	if cm.PodSandbox.Config.Metadata != nil {
+		settings.Name = cm.PodSandbox.Config.Metadata.Name
+	}
+	jobConf := &cmd.JobConfig{
+		Command: AllocationCommand,
+		Path:    podPath,
+		Script:  getRMScriptPath(podPath),
+	}
+	if settings.ClusterConfig != "" {
+		jobConf.Prerun = settings.ClusterConfig
+	}
+	setupBatchHeaders(settings, linuxResources(cm), jobConf)
+	jobId, err := slurmClient.Sbatch(ctx, jobConf)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(jobId)
+	if err != nil {
+		return 0, fmt.Errorf("Job id of the pod allocation cannot be parsed %s ", jobId)
+	}
+	klog.Infof("Requested allocation %d for pod %s", id, cm.PodSandbox.ID)
+	a.jobId, a.client = int32(id), slurmClient
+	return a.jobId, nil
+}
+
+// restoreAllocation keeps the allocation recorded in the container as the allocation of its pod, unless
+// the pod has one already
+func (s SlurmAdapter) restoreAllocation(cm *store.ContainerMetadata) error {
+	if s.pods == nil || cm.Extra["AllocationJobId"] == "" {
+		return nil
+	}
+	jobId, err := strconv.Atoi(cm.Extra["AllocationJobId"])
+	if err != nil {
+		return fmt.Errorf("Job id of the pod allocation cannot be parsed %s ", cm.Extra["AllocationJobId"])
+	}
+	a := s.pods.pod(cm.PodSandbox.ID)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.jobId != 0 {
+		return nil
+	}
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return err
+	}
+	klog.Infof("Restored allocation %d for pod %s", jobId, cm.PodSandbox.ID)
+	a.jobId, a.client = int32(jobId), slurmClient
+	return nil
+}
+
+// releaseAllocation cancels the allocation of the pod, if it has one. It waits for the request in progress
+// of the pod, so its holder job is cancelled too.
+func (s SlurmAdapter) releaseAllocation(ctx context.Context, sandboxID string) error {
+	if s.pods == nil {
+		return nil
+	}
+	s.pods.lock.Lock()
+	a, ok := s.pods.allocations[sandboxID]
+	s.pods.lock.Unlock()
+	if !ok {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.jobId != 0 {
+		klog.Infof("Releasing allocation %d of pod %s", a.jobId, sandboxID)
+		// The allocation is released when the holder job is already gone
+		if err := a.client.Scancel(ctx, cmd.JobReference{JobId: a.jobId}); err != nil && !adapters.IsNotFound(err) {
+			return err
+		}
+		a.jobId, a.client = 0, nil
+	}
+	s.pods.lock.Lock()
+	if s.pods.allocations[sandboxID] == a {
+		delete(s.pods.allocations, sandboxID)
+	}
+	s.pods.lock.Unlock()
+	return nil
+}
+
+// stepOptions returns the srun options of the job settings and of the resources of the container. The
+// step takes the resources from the allocation of the pod.
+func stepOptions(settings batch.JobSettings, resources *runtimeApi.LinuxContainerResources) []string {
+	var options []string
+	if settings.Nodes != "" {
+		options = append(options, fmt.Sprintf("--nodes=%s", settings.Nodes))
+	}
+	if settings.Cores != "" {
+		options = append(options, fmt.Sprintf("--ntasks=%s", settings.Cores))
+	}
+	if settings.CoresPerNode != "" {
+		options = append(options, fmt.Sprintf("--cpus-per-task=%s", settings.CoresPerNode))
+	}
+	if settings.TasksPerNode != "" {
+		options = append(options, fmt.Sprintf("--ntasks-per-node=%s", settings.TasksPerNode))
+	}
+	if settings.GPU != "" {
+		options = append(options, fmt.Sprintf("--gres=%s", settings.GPU))
+	}
+	return append(options, resourceOptions(settings, resources)...)
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+)
+
+// Rules which aggregate the array tasks in the container state (JOB_ARRAY_POLICY)
+const (
+	// ArrayPolicyAll exits the container when every task finishes. It fails when any of them fails.
+	ArrayPolicyAll = "all"
+	// ArrayPolicyAny fails the container as soon as any task fails, and the rest of the tasks are cancelled
+	ArrayPolicyAny = "any"
+)
+
+// arrayRange matches a range of array task ids, e.g. "5", "0-99" or "0-99:2"
+var arrayRange = regexp.MustCompile(`^(\d+)(?:-(\d+)(?::(\d+))?)?$`)
+
+// maxArraySize is the largest MaxArraySize of Slurm. The task ids of the arrays are lower, and the arrays
+// have fewer tasks.
+const maxArraySize = 4000001
+
+// taskRange is a range of array task ids, from first to last every step
+type taskRange struct {
+	first, last, step int
+}
+
+// size returns the number of tasks of the range
+func (r taskRange) size() int {
+	return (r.last-r.first)/r.step + 1
+}
+
+// jobArray is an array specification, parsed without expanding its tasks
+type jobArray []taskRange
+
+// parseArray parses the array specification, comma separated ranges with an optional limit of running
+// tasks, e.g. "0-99%10" or "1,3,5-9:2"
+func parseArray(spec string) (jobArray, error) {
+	ranges := spec
+	if i := strings.Index(spec, "%"); i >= 0 {
+		if limit, err := strconv.Atoi(spec[i+1:]); err != nil || limit <= 0 {
+			return nil, adapters.InvalidArgumentError("Wrong limit of running tasks in JOB_ARRAY %s", spec)
+		}
+		ranges = spec[:i]
+	}
+	var array jobArray
+	tasks := 0
+	for _, r := range strings.Split(ranges, ",") {
+		m := arrayRange.FindStringSubmatch(strings.TrimSpace(r))
+		if m == nil {
+			return nil, adapters.InvalidArgumentError("Wrong JOB_ARRAY %s", spec)
+		}
+		first, err := strconv.Atoi(m[1])
+		if err != nil || first >= maxArraySize {
+			return nil, adapters.InvalidArgumentError("Task ids of JOB_ARRAY %s must be lower than %d", spec, maxArraySize)
+		}
+		last, step := first, 1
+		if m[2] != "" {
+			if last, err = strconv.Atoi(m[2]); err != nil || last >= maxArraySize {
+				return nil, adapters.InvalidArgumentError("Task ids of JOB_ARRAY %s must be lower than %d", spec, maxArraySize)
+			}
+		}
+		if m[3] != "" {
+			step, _ = strconv.Atoi(m[3])
+		}
+		if last < first || step <= 0 {
+			return nil, adapters.InvalidArgumentError("Wrong range %s of JOB_ARRAY %s", r, spec)
+		}
+		array = append(array, taskRange{first, last, step})
+		if tasks += array[len(array)-1].size(); tasks > maxArraySize {
+			return nil, adapters.InvalidArgumentError("JOB_ARRAY %s has more than %d tasks", spec, maxArraySize)
+		}
+	}
+	return array, nil
+}
+
+// contains returns whether the task is in the array
+func (a jobArray) contains(task int) bool {
+	for _, r := range a {
+		if task >= r.first && task <= r.last && (task-r.first)%r.step == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// size returns the number of distinct tasks of the array. Disjoint ranges are counted arithmetically, and
+// the tasks of overlapping ones are marked in a bitmap, which the task ids bound.
+func (a jobArray) size() int {
+	sorted := append(jobArray(nil), a...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].first < sorted[j].first })
+	size, last, overlap := 0, -1, false
+	for _, r := range sorted {
+		size += r.size()
+		overlap = overlap || r.first <= last
+		if r.last > last {
+			last = r.last
+		}
+	}
+	if !overlap {
+		return size
+	}
+	marked := make([]uint64, last/64+1)
+	size = 0
+	for _, r := range a {
+		for task := r.first; task <= r.last; task += r.step {
+			if marked[task/64]&(1<<uint(task%64)) == 0 {
+				marked[task/64] |= 1 << uint(task%64)
+				size++
+			}
+		}
+	}
+	return size
+}
+
+// validArray returns whether the array specification has tasks
+func validArray(spec string) bool {
+	_, err := parseArray(spec)
+	return err == nil
+}
+
+// validArrayPolicy returns whether the policy aggregates the array tasks
+func validArrayPolicy(policy string) bool {
+	return policy == ArrayPolicyAll || policy == ArrayPolicyAny
+}
+
+// taskFinished returns whether the task is in a final state
+func taskFinished(state string) bool {
+	switch state {
+	case "COMPLETED", "COMPLETING", "FAILED", "CANCELLED", "TIMEOUT", "NODE_FAIL", "OUT_OF_MEMORY",
+		"BOOT_FAIL", "DEADLINE", "PREEMPTED":
+		return true
+	}
+	return false
+}
+
+// taskFailed returns whether the finished task failed
+func taskFailed(state string, exitCode int) bool {
+	return (state != "COMPLETED" && state != "COMPLETING") || exitCode != 0
+}
+
+// aggregateArray aggregates the status of the array tasks in the status of the container, with the
+// states handled by ContainerStatus. It is PENDING until any task starts and RUNNING until every
+// task finishes. Then it is COMPLETED when every task succeeds, or the status of the first failed
+// task otherwise. With ArrayPolicyAny, it is the status of the first failed task as soon as any
+// task fails. It also returns the tasks which started, to collect their output. Only the tasks of
+// the statuses are read, the array is not expanded.
+func aggregateArray(array jobArray, statuses []*cmd.JobStatus, policy string) (*cmd.JobStatus, []int) {
+	byTask := make(map[int]*cmd.JobStatus)
+	var tasks []int
+	for _, status := range statuses {
+		if task, err := strconv.Atoi(status.ArrayTaskId); err == nil && array.contains(task) {
+			if _, ok := byTask[task]; !ok {
+				tasks = append(tasks, task)
+			}
+			byTask[task] = status
+		}
+	}
+	sort.Ints(tasks)
+	aggregated := &cmd.JobStatus{JobState: "PENDING"}
+	var failed *cmd.JobStatus
+	var started []int
+	finished := 0
+	for _, task := range tasks {
+		status := byTask[task]
+		// sacct shows the user of the cancellations, e.g. "CANCELLED by 1000"
+		state := strings.Fields(status.JobState + " ")[0]
+		if state != "PENDING" {
+			started = append(started, task)
+			aggregated.JobState = "RUNNING"
+		}
+		if status.StarTime > 0 && (aggregated.StarTime == 0 || status.StarTime < aggregated.StarTime) {
+			aggregated.StarTime = status.StarTime
+		}
+		if status.EndTime > aggregated.EndTime {
+			aggregated.EndTime = status.EndTime
+		}
+		if !taskFinished(state) {
+			continue
+		}
+		finished++
+		if failed == nil && taskFailed(state, status.ExitCode) {
+			failed = &cmd.JobStatus{JobState: "FAILED", ExitCode: status.ExitCode,
+				Reason: "Array task " + strconv.Itoa(task) + " " + state}
+			if state == "CANCELLED" || state == "TIMEOUT" {
+				failed.JobState = state
+			}
+		}
+	}
+	size := array.size()
+	if failed != nil && (finished == size || policy == ArrayPolicyAny) {
+		failed.StarTime, failed.EndTime = aggregated.StarTime, aggregated.EndTime
+		return failed, started
+	}
+	if finished == size {
+		aggregated.JobState = "COMPLETED"
+	}
+	return aggregated, started
+}
@@ -19,6 +19,8 @@ import (
 	"multi-cri/pkg/cri/store"
 	"fmt"
 	"strings"
+
+	"golang.org/x/net/context"
 )
 
 const (
@@ -26,11 +28,20 @@ const (
 )
 
 type ImageBuilder interface {
-	PullImage(cm *store.ContainerMetadata) error
-	PullImageInCluster(cm *store.ContainerMetadata) error
+	PullImage(ctx context.Context, cm *store.ContainerMetadata) error
+	PullImageInCluster(ctx context.Context, cm *store.ContainerMetadata) error
 	GetImagePath(cm *store.ContainerMetadata) string
 }
 
+// NewImageBuilder returns the builder which pulls the images in the cluster when buildInCluster is set,
+// or in the CRI node otherwise
+func NewImageBuilder(buildInCluster bool, mountPoint, remoteMount string) (ImageBuilder, error) {
+	if buildInCluster {
+		return NewImageBuilderInCluster(mountPoint, remoteMount)
+	}
+	return NewImageBuilderInCRI(remoteMount)
+}
+
 func parseImageFileName(imageName string) string {
 	chars := []string{":", "/"}
 	for _, c := range chars {
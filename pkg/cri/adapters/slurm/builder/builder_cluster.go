@@ -20,6 +20,8 @@ import (
 	"multi-cri/pkg/cri/store"
 	"fmt"
 	"path/filepath"
+
+	"golang.org/x/net/context"
 )
 
 type ImageBuilderInCluster struct {
@@ -32,7 +34,7 @@ func NewImageBuilderInCluster(mountPoint string, remoteMount string) (ImageBuild
 	return builder, nil
 }
 
-func (builder ImageBuilderInCluster) PullImage(cm *store.ContainerMetadata) error {
+func (builder ImageBuilderInCluster) PullImage(ctx context.Context, cm *store.ContainerMetadata) error {
 	cm.Image.Size = 1 //It must to be set, otherwise k8s fails
 	if builder.RemoteMount != "" {
 		cm.Image.LocalPath = builder.RemoteMount
@@ -40,7 +42,7 @@ func (builder ImageBuilderInCluster) PullImage(cm *store.ContainerMetadata) erro
 	return nil
 }
 
-func (builder ImageBuilderInCluster) PullImageInCluster(cm *store.ContainerMetadata) error {
+func (builder ImageBuilderInCluster) PullImageInCluster(ctx context.Context, cm *store.ContainerMetadata) error {
 	cm.Image.LocalPath = getMountImagePath(cm, builder.MountPoint)
 
 	if cm.Image.RepoType == store.LocalImageRepo {
@@ -56,7 +58,7 @@ func (builder ImageBuilderInCluster) PullImageInCluster(cm *store.ContainerMetad
 	}
 	scriptPath := getRMImageScript(cm)
 
-	if cm.Image.Size, err = client.PullImageScript(command, imagePath, scriptPath, cm.Environment); err != nil {
+	if cm.Image.Size, err = client.PullImageScript(ctx, command, imagePath, scriptPath, cm.Environment); err != nil {
 		return err
 	}
 
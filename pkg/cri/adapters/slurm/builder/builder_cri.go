@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"path"
 	"strings"
+
+	"golang.org/x/net/context"
 )
 
 type ImageBuilderInCRI struct {
@@ -42,7 +44,7 @@ func NewImageBuilderInCRI(remoteMount string) (ImageBuilder, error) {
 	return builder, nil
 }
 
-func (builder ImageBuilderInCRI) PullImage(cm *store.ContainerMetadata) error {
+func (builder ImageBuilderInCRI) PullImage(ctx context.Context, cm *store.ContainerMetadata) error {
 	if cm.Image.RepoType == store.LocalImageRepo {
 		cm.Image.LocalPath = getMountImagePath(cm, builder.RemoteMount)
 		return nil
@@ -55,7 +57,7 @@ func (builder ImageBuilderInCRI) PullImage(cm *store.ContainerMetadata) error {
 	if err := file.EnsurePathExist(path.Dir(cm.Image.LocalPath)); err != nil {
 		return err
 	}
-	if err := builder.client.SingularityPullImage(cm.Image.LocalPath, cm.Image.RemotePath, authString); err != nil {
+	if err := builder.client.SingularityPullImage(ctx, cm.Image.LocalPath, cm.Image.RemotePath, authString); err != nil {
 		return err
 	}
 	if size, err = file.FileSize(cm.Image.LocalPath); err != nil {
@@ -65,7 +67,7 @@ func (builder ImageBuilderInCRI) PullImage(cm *store.ContainerMetadata) error {
 	return nil
 }
 
-func (builder ImageBuilderInCRI) PullImageInCluster(cm *store.ContainerMetadata) error {
+func (builder ImageBuilderInCRI) PullImageInCluster(ctx context.Context, cm *store.ContainerMetadata) error {
 	if cm.Image.RepoType == store.LocalImageRepo {
 		return PullLocalImage(cm)
 	}
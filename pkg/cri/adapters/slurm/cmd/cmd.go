@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"multi-cri/pkg/cri/adapters"
 	"multi-cri/pkg/cri/common/file"
 	"multi-cri/pkg/cri/common/ssh"
 	"path"
@@ -32,6 +33,7 @@ import (
 
 	"multi-cri/pkg/cri/store"
 
+	"golang.org/x/net/context"
 	"k8s.io/klog"
 )
 
@@ -52,6 +54,9 @@ type JobConfig struct {
 	Script        string
 	Prerun        string
 	ENV           map[string]string
+	// HetJobHeaders are the headers of the other components of a heterogeneous job, whose first component
+	// has the Headers
+	HetJobHeaders [][]JobConfigField
 }
 
 type JobStatus struct {
@@ -60,10 +65,34 @@ type JobStatus struct {
 	Reason   string
 	StarTime int64
 	EndTime  int64
+	// ArrayTaskId is the task id of the array tasks. Pending tasks are shown together, with their range
+	// as task id, e.g. "4-99%10".
+	ArrayTaskId string
 }
 
 type JobReference struct {
 	JobId int32
+	// HetJobOffset is the offset of a component of the heterogeneous job JobId, when HetJob is set
+	HetJob       bool
+	HetJobOffset int
+}
+
+// String returns the job id of the reference, or <job id>+<offset> for a component of a heterogeneous job
+func (r JobReference) String() string {
+	if r.HetJob {
+		return fmt.Sprintf("%d+%d", r.JobId, r.HetJobOffset)
+	}
+	return strconv.Itoa(int(r.JobId))
+}
+
+// JobClient submits, queries and cancels the Slurm jobs. SlurmCmd runs the Slurm commands through SSH,
+// and RestClient calls the slurmrestd REST API.
+type JobClient interface {
+	Sbatch(ctx context.Context, config *JobConfig) (string, error)
+	Scancel(ctx context.Context, reference JobReference) error
+	Supdate(ctx context.Context, reference JobReference, update JobUpdate) error
+	Sstatus(ctx context.Context, reference *JobReference) (*JobStatus, error)
+	SstatusArray(ctx context.Context, reference *JobReference) ([]*JobStatus, error)
 }
 
 type SlurmCmd struct {
@@ -94,7 +123,7 @@ func NewSlurmCMD(user, host, port, logPath, key, password string) (*SlurmCmd, er
 	} else if password != "" {
 		sshClient = ssh.NewSSH(user, host, port, nil, &password, nil)
 	} else {
-		return nil, fmt.Errorf("KeyPath or password must be setup")
+		return nil, adapters.InvalidArgumentError("KeyPath or password must be setup")
 	}
 
 	sl := &SlurmCmd{
@@ -109,7 +138,7 @@ func NewSlurmCMD(user, host, port, logPath, key, password string) (*SlurmCmd, er
 Execute command in remote via ssh
 String: command
 */
-func (s SlurmCmd) ExecCmd(cmd string) (string, error) {
+func (s SlurmCmd) ExecCmd(ctx context.Context, cmd string) (string, error) {
 	klog.V(4).Infof("Execute command %s", cmd)
 	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
 	if err != nil {
@@ -121,9 +150,9 @@ func (s SlurmCmd) ExecCmd(cmd string) (string, error) {
 		stdoutWC.Close()
 	}()
 
-	response, err := s.run(cmd, stdoutWC, stderrWC)
+	response, err := s.run(ctx, cmd, stdoutWC, stderrWC)
 	if err != nil {
-		return "", fmt.Errorf("%s. %s", response, err)
+		return "", wrapError(err, "%s. %s", response, err)
 	}
 	return response, nil
 }
@@ -132,7 +161,7 @@ func (s SlurmCmd) ExecCmd(cmd string) (string, error) {
 Get Stderr via ssh
 String: stderrpath
 */
-func (s SlurmCmd) GetStderr(stdoerrPath string) (string, error) {
+func (s SlurmCmd) GetStderr(ctx context.Context, stdoerrPath string) (string, error) {
 	klog.V(4).Infof("Get Job Error%s", stdoerrPath)
 	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
 	if err != nil {
@@ -144,9 +173,9 @@ func (s SlurmCmd) GetStderr(stdoerrPath string) (string, error) {
 		stdoutWC.Close()
 	}()
 	cmd := fmt.Sprintf("cat %s", stdoerrPath)
-	response, err := s.run(cmd, stderrWC, stderrWC)
+	response, err := s.run(ctx, cmd, stderrWC, stderrWC)
 	if err != nil {
-		return "", fmt.Errorf("%s. %s", response, err)
+		return "", wrapError(err, "%s. %s", response, err)
 	}
 	return response, nil
 }
@@ -155,15 +184,15 @@ func (s SlurmCmd) GetStderr(stdoerrPath string) (string, error) {
 Get Stout via ssh
 String: stdoutpath
 */
-func (s SlurmCmd) GetStdout(stdoerrPath string) (string, error) {
+func (s SlurmCmd) GetStdout(ctx context.Context, stdoerrPath string) (string, error) {
 	klog.V(4).Infof("Get Job output %s", stdoerrPath)
 	cmd := fmt.Sprintf("cat %s", stdoerrPath)
-	return s.ExecCmd(cmd)
+	return s.ExecCmd(ctx, cmd)
 }
 
-func (s SlurmCmd) run(cmd string, stdout, stderr io.WriteCloser) (string, error) {
-	response, _, err := s.sshClient.Run(cmd, stdout, stderr, true)
-	return response, err
+func (s SlurmCmd) run(ctx context.Context, cmd string, stdout, stderr io.WriteCloser) (string, error) {
+	response, _, err := s.sshClient.Run(ctx, cmd, stdout, stderr, true)
+	return response, classifyError(response, err)
 }
 
 /*
@@ -171,9 +200,9 @@ Execute sbatch command in a slurm cluster.
 Returns JobID
 Returns error
 */
-func (s SlurmCmd) Sbatch(config *JobConfig) (string, error) {
+func (s SlurmCmd) Sbatch(ctx context.Context, config *JobConfig) (string, error) {
 	klog.V(4).Infof("Execute batch %s", config.Script)
-	err := s.batchScript(config)
+	err := s.batchScript(ctx, config)
 	if err != nil {
 		return "", err
 	}
@@ -187,7 +216,7 @@ func (s SlurmCmd) Sbatch(config *JobConfig) (string, error) {
 		stdoutWC.Close()
 	}()
 	//run command
-	response, err := s.run(config.Script, stdoutWC, stderrWC)
+	response, err := s.run(ctx, config.Script, stdoutWC, stderrWC)
 	if err != nil {
 		return "", err
 	}
@@ -201,10 +230,10 @@ func (s SlurmCmd) Sbatch(config *JobConfig) (string, error) {
 /*
 Copy local path to remote path
 */
-func (s SlurmCmd) CopyTo(resourcePath, destinyPath string) error {
-	err := s.sshClient.CopyTo(resourcePath, destinyPath)
+func (s SlurmCmd) CopyTo(ctx context.Context, resourcePath, destinyPath string) error {
+	err := s.sshClient.CopyTo(ctx, resourcePath, destinyPath)
 	if err != nil {
-		return fmt.Errorf("Error copying file to ssh server. %s ", err)
+		return wrapError(classifyError("", err), "Error copying file to ssh server. %s ", err)
 	}
 	return nil
 }
@@ -212,11 +241,11 @@ func (s SlurmCmd) CopyTo(resourcePath, destinyPath string) error {
 /*
 Copy local path to local path
 */
-func (s SlurmCmd) CopyInternal(resourcePath, destinyPath string) error {
+func (s SlurmCmd) CopyInternal(ctx context.Context, resourcePath, destinyPath string) error {
 	cmd := fmt.Sprintf("cp -r %s %s", resourcePath, destinyPath)
-	_, err := s.ExecCmd(cmd)
+	_, err := s.ExecCmd(ctx, cmd)
 	if err != nil {
-		return fmt.Errorf("Error copying folder/file inside the server cluster. %s ", err)
+		return wrapError(err, "Error copying folder/file inside the server cluster. %s ", err)
 	}
 	return nil
 }
@@ -224,8 +253,8 @@ func (s SlurmCmd) CopyInternal(resourcePath, destinyPath string) error {
 /*
 Cancel a specific job
 */
-func (s SlurmCmd) Scancel(reference JobReference) error {
-	klog.V(4).Infof("Canceling job %d", reference.JobId)
+func (s SlurmCmd) Scancel(ctx context.Context, reference JobReference) error {
+	klog.V(4).Infof("Canceling job %s", reference)
 	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
 	if err != nil {
 		return fmt.Errorf("failed to start container logger: %s", err)
@@ -235,20 +264,42 @@ func (s SlurmCmd) Scancel(reference JobReference) error {
 		stdoutWC.Close()
 	}()
 	//build command
-	cmd := fmt.Sprintf("scancel %d", reference.JobId)
+	cmd := fmt.Sprintf("scancel %s", reference)
 	//run command
-	_, err = s.run(cmd, stdoutWC, stderrWC)
+	_, err = s.run(ctx, cmd, stdoutWC, stderrWC)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+/*
+Update the options of a job with scontrol update
+*/
+func (s SlurmCmd) Supdate(ctx context.Context, reference JobReference, update JobUpdate) error {
+	klog.V(4).Infof("Updating job %s", reference)
+	fields := update.fields()
+	if len(fields) == 0 {
+		return nil
+	}
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
+	if err != nil {
+		return fmt.Errorf("failed to start container logger: %s", err)
+	}
+	defer func() {
+		stderrWC.Close()
+		stdoutWC.Close()
+	}()
+	cmd := fmt.Sprintf("scontrol update JobId=%s %s", reference, strings.Join(fields, " "))
+	_, err = s.run(ctx, cmd, stdoutWC, stderrWC)
+	return err
+}
+
 /*
 Get job status
 */
-func (s SlurmCmd) Sstatus(reference *JobReference) (*JobStatus, error) {
-	klog.V(4).Infof("Check status for job %d", reference.JobId)
+func (s SlurmCmd) Sstatus(ctx context.Context, reference *JobReference) (*JobStatus, error) {
+	klog.V(4).Infof("Check status for job %s", reference)
 	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start container logger: %s", err)
@@ -257,35 +308,77 @@ func (s SlurmCmd) Sstatus(reference *JobReference) (*JobStatus, error) {
 		stderrWC.Close()
 		stdoutWC.Close()
 	}()
-	out, err := s.scontrol(reference, stdoutWC, stderrWC)
+	out, err := s.scontrol(ctx, reference, stdoutWC, stderrWC)
 	if err != nil {
 		klog.V(5).Infof("scontrol command fails. %s", err)
-		return s.sacct(reference, stdoutWC, stderrWC)
+		return s.sacct(ctx, reference, stdoutWC, stderrWC)
 	}
 	return out, err
 }
 
-func (s SlurmCmd) sacct(jobRef *JobReference, stdoutWC, stderrWC io.WriteCloser) (*JobStatus, error) {
-	cmd := fmt.Sprintf("sacct -p -n -j %d -o start,end,exitcode,start,state,comment", jobRef.JobId)
-	response, err := s.run(cmd, stdoutWC, stderrWC)
+/*
+Get the status of every task of a job array, from the accounting, or from slurmctld when the tasks are not
+in the accounting yet
+*/
+func (s SlurmCmd) SstatusArray(ctx context.Context, reference *JobReference) ([]*JobStatus, error) {
+	klog.V(4).Infof("Check status for job array %d", reference.JobId)
+	stdoutWC, stderrWC, err := common.CreateContainerLoggers(s.logPath, false, 100)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start container logger: %s", err)
+	}
+	defer func() {
+		stderrWC.Close()
+		stdoutWC.Close()
+	}()
+	cmd := fmt.Sprintf("sacct -p -n -X -j %d -o jobid,start,end,exitcode,state,comment", reference.JobId)
+	response, err := s.run(ctx, cmd, stdoutWC, stderrWC)
+	if err == nil && strings.TrimSpace(response) != "" {
+		return parseAcctArrayStatus(response)
+	}
+	klog.V(5).Infof("sacct command fails. %v", err)
+	cmd = fmt.Sprintf("scontrol show jobid -dd  %d", reference.JobId)
+	response, err = s.run(ctx, cmd, stdoutWC, stderrWC)
+	if err != nil {
+		return nil, wrapError(err, "Retrieve job info fails %s ", err)
+	}
+	var statuses []*JobStatus
+	for _, record := range strings.Split(response, "\n\n") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		status, err := parseControlStatus(record)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (s SlurmCmd) sacct(ctx context.Context, jobRef *JobReference, stdoutWC, stderrWC io.WriteCloser) (*JobStatus, error) {
+	cmd := fmt.Sprintf("sacct -p -n -j %s -o start,end,exitcode,state,comment", jobRef)
+	response, err := s.run(ctx, cmd, stdoutWC, stderrWC)
 	if err != nil {
-		return nil, fmt.Errorf("Retrieve job info fails %s ", err)
+		return nil, wrapError(err, "Retrieve job info fails %s ", err)
+	}
+	if strings.TrimSpace(response) == "" {
+		return nil, adapters.NotFoundError("Job %s not found", jobRef)
 	}
 	return parseAcctStatus(response)
 }
 
-func (s SlurmCmd) scontrol(jobRef *JobReference, stdoutWC, stderrWC io.WriteCloser) (*JobStatus, error) {
+func (s SlurmCmd) scontrol(ctx context.Context, jobRef *JobReference, stdoutWC, stderrWC io.WriteCloser) (*JobStatus, error) {
 	//build command
-	cmd := fmt.Sprintf("scontrol show jobid -dd  %d", jobRef.JobId)
+	cmd := fmt.Sprintf("scontrol show jobid -dd  %s", jobRef)
 	//run command
-	response, err := s.run(cmd, stdoutWC, stderrWC)
+	response, err := s.run(ctx, cmd, stdoutWC, stderrWC)
 	if err != nil {
-		return nil, fmt.Errorf("Retrieve job info fails %s ", err)
+		return nil, wrapError(err, "Retrieve job info fails %s ", err)
 	}
 	return parseControlStatus(response)
 }
 
-func (s SlurmCmd) batchScript(config *JobConfig) error {
+func (s SlurmCmd) batchScript(ctx context.Context, config *JobConfig) error {
 	//Pre run
 	if config.Prerun != "" {
 		prerun := fmt.Sprintf("%s/%s", config.Path, PreRunScript)
@@ -293,9 +386,9 @@ func (s SlurmCmd) batchScript(config *JobConfig) error {
 		if err != nil {
 			return fmt.Errorf("Error generating batch script %s ", err)
 		}
-		err = s.CopyTo(prerunScript, prerun)
+		err = s.CopyTo(ctx, prerunScript, prerun)
 		if err != nil {
-			return fmt.Errorf("Error copying prerun file to Slurm cluster. %s ", err)
+			return wrapError(err, "Error copying prerun file to Slurm cluster. %s ", err)
 		}
 	}
 	//Batch
@@ -304,9 +397,9 @@ func (s SlurmCmd) batchScript(config *JobConfig) error {
 	if err != nil {
 		return fmt.Errorf("Error generating batch script %s ", err)
 	}
-	err = s.CopyTo(batchLocalPath, batchScript)
+	err = s.CopyTo(ctx, batchLocalPath, batchScript)
 	if err != nil {
-		return fmt.Errorf("Error copying batch file to Slurm cluster. %s ", err)
+		return wrapError(err, "Error copying batch file to Slurm cluster. %s ", err)
 	}
 
 	//Run script
@@ -314,9 +407,9 @@ func (s SlurmCmd) batchScript(config *JobConfig) error {
 	if err != nil {
 		return fmt.Errorf("Error generating run script %s ", err)
 	}
-	err = s.CopyTo(runLocalScript, config.Script)
+	err = s.CopyTo(ctx, runLocalScript, config.Script)
 	if err != nil {
-		return fmt.Errorf("Error copying run file to Slurm cluster. %s ", err)
+		return wrapError(err, "Error copying run file to Slurm cluster. %s ", err)
 	}
 	return nil
 }
@@ -406,6 +499,13 @@ func buildSbatch(config *JobConfig) (string, error) {
 		content := fmt.Sprintf("#SBATCH %s %s", c.Flag, c.Value)
 		commands = append(commands, content)
 	}
+	//components of heterogeneous jobs
+	for _, headers := range config.HetJobHeaders {
+		commands = append(commands, "#SBATCH hetjob")
+		for _, c := range headers {
+			commands = append(commands, fmt.Sprintf("#SBATCH %s %s", c.Flag, c.Value))
+		}
+	}
 	commands = append(commands, config.Command)
 
 	if err := writeLines(&b, commands); err != nil {
@@ -455,12 +555,13 @@ func parseControlStatus(stdout string) (*JobStatus, error) {
 	}
 	exitCode := 0
 	if val, ok := jobInfo["ExitCode"]; ok {
-		exitCode, _ = strconv.Atoi(val)
+		// The exit code is shown with the signal, e.g. 3:0
+		exitCode, _ = strconv.Atoi(strings.Split(val, ":")[0])
 	}
 	start := common.ParseDate(jobInfo["StartTime"])
 	end := common.ParseDate(jobInfo["EndTime"])
 	return &JobStatus{ExitCode: exitCode, JobState: jobInfo["JobState"],
-		Reason: jobInfo["Reason"], EndTime: end, StarTime: start,
+		Reason: jobInfo["Reason"], EndTime: end, StarTime: start, ArrayTaskId: jobInfo["ArrayTaskId"],
 	}, nil
 }
 
@@ -470,6 +571,9 @@ func parseAcctStatus(stdout string) (*JobStatus, error) {
 		return nil, fmt.Errorf("Accounting data cannot be parsed %s ", stdout)
 	}
 	output := strings.Split(lines[0], "|")
+	if len(output) < 5 {
+		return nil, fmt.Errorf("Accounting data cannot be parsed %s ", stdout)
+	}
 	start := common.ParseDate(output[0])
 	end := common.ParseDate(output[1])
 	exitCode, err := strconv.Atoi(strings.Split(output[2], ":")[0])
@@ -484,6 +588,27 @@ func parseAcctStatus(stdout string) (*JobStatus, error) {
 	return &JobStatus{ExitCode: exitCode, JobState: state, Reason: reason, EndTime: end, StarTime: start}, nil
 }
 
+// parseAcctArrayStatus parses the accounting of the array tasks, whose first field is the job id of the
+// task, e.g. 42_3, or 42_[4-99%10] for the pending tasks
+func parseAcctArrayStatus(stdout string) ([]*JobStatus, error) {
+	var statuses []*JobStatus
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Accounting data cannot be parsed %s ", stdout)
+		}
+		status, err := parseAcctStatus(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		if i := strings.Index(fields[0], "_"); i >= 0 {
+			status.ArrayTaskId = strings.Trim(fields[0][i+1:], "[]")
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
 func parseJobId(response string) (string, error) {
 	value := "Submitted batch job "
 	splitResponse := strings.Split(response, value)
@@ -493,7 +618,7 @@ func parseJobId(response string) (string, error) {
 	return "", fmt.Errorf("Not submitted batch job id found: %s ", response)
 }
 
-func (s SlurmCmd) PullImageScript(command, imagePath, scriptPath string, env map[string]string) (uint64, error) {
+func (s SlurmCmd) PullImageScript(ctx context.Context, command, imagePath, scriptPath string, env map[string]string) (uint64, error) {
 	var size uint64
 	filePath := file.GenerateTmpFile("/tmp", "pullimage", "sh")
 	// open output file
@@ -526,11 +651,11 @@ func (s SlurmCmd) PullImageScript(command, imagePath, scriptPath string, env map
 	if _, err := fo.Write(b.Bytes()); err != nil {
 		return size, err
 	}
-	err = s.CopyTo(filePath, scriptPath)
+	err = s.CopyTo(ctx, filePath, scriptPath)
 	if err != nil {
-		return size, fmt.Errorf("Error copying run file to Slurm cluster. %s ", err)
+		return size, wrapError(err, "Error copying run file to Slurm cluster. %s ", err)
 	}
-	out, err := s.ExecCmd(scriptPath)
+	out, err := s.ExecCmd(ctx, scriptPath)
 	if err != nil {
 		return size, err
 	}
@@ -0,0 +1,49 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// slurmErrors classifies the Slurm command errors by their message
+var slurmErrors = []batch.ErrorPattern{
+	{"invalid partition", adapters.KindInvalidArgument},
+	{"invalid account", adapters.KindInvalidArgument},
+	{"invalid qos", adapters.KindInvalidArgument},
+	{"invalid generic resource", adapters.KindInvalidArgument},
+	{"node count specification invalid", adapters.KindInvalidArgument},
+	{"requested node configuration is not available", adapters.KindInvalidArgument},
+	{"more processors requested than permitted", adapters.KindInvalidArgument},
+	{"job is no longer pending execution", adapters.KindInvalidArgument},
+	{"already completing or completed", adapters.KindInvalidArgument},
+	{"invalid job id specified", adapters.KindNotFound},
+	{"not permitted to use this partition", adapters.KindPermissionDenied},
+	{"access denied", adapters.KindPermissionDenied},
+	{"permission denied", adapters.KindPermissionDenied},
+	{"unable to contact slurm controller", adapters.KindUnavailable},
+	{"socket timed out", adapters.KindUnavailable},
+}
+
+// classifyError sets the kind of the error of a remote command from its output
+func classifyError(response string, err error) error {
+	return batch.ClassifyError(slurmErrors, response, err)
+}
+
+// wrapError adds context to the error message, keeping its kind
+func wrapError(err error, format string, a ...interface{}) error {
+	return batch.WrapError(err, format, a...)
+}
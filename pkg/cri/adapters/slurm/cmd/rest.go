@@ -0,0 +1,412 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+const (
+	// RestAPIVersion is the default version of the slurmrestd API
+	RestAPIVersion = "v0.0.39"
+	// defaultPath is the PATH of the jobs whose container does not set it. slurmrestd jobs
+	// do not inherit the environment of a login shell.
+	defaultPath = "/usr/local/bin:/usr/bin:/bin"
+)
+
+// RestClient calls the slurmrestd REST API with JWT authentication. The times of its job status
+// are in nanoseconds.
+type RestClient struct {
+	url     string
+	version string
+	user    string
+	token   string
+	// home is the home directory of the user, the container paths are relative to it
+	home       string
+	httpClient *http.Client
+}
+
+// CreateRestClient returns the client of the slurmrestd set in the CLUSTER_REST_* variables of the container
+func CreateRestClient(metadata *store.ContainerMetadata, version string) (*RestClient, error) {
+	user := metadata.Environment["CLUSTER_USERNAME"]
+	home := metadata.Environment["CLUSTER_REST_HOME"]
+	if home == "" {
+		home = path.Join("/home", user)
+	}
+	return NewRestClient(metadata.Environment["CLUSTER_REST_URL"], version, user,
+		metadata.Environment["CLUSTER_REST_TOKEN"], home)
+}
+
+func NewRestClient(url, version, user, token, home string) (*RestClient, error) {
+	if url == "" {
+		return nil, adapters.InvalidArgumentError("CLUSTER_REST_URL must be setup")
+	}
+	if token == "" {
+		return nil, adapters.InvalidArgumentError("CLUSTER_REST_TOKEN must be setup")
+	}
+	if version == "" {
+		version = RestAPIVersion
+	}
+	return &RestClient{
+		url:        strings.TrimSuffix(url, "/"),
+		version:    version,
+		user:       user,
+		token:      token,
+		home:       home,
+		httpClient: &http.Client{},
+	}, nil
+}
+
+/*
+Submit the job with the job options of the config.
+Returns JobID
+*/
+func (r RestClient) Sbatch(ctx context.Context, config *JobConfig) (string, error) {
+	request, err := r.submitRequest(config)
+	if err != nil {
+		return "", err
+	}
+	klog.V(4).Infof("Submit job %s to %s", request.Job.Name, r.url)
+	var response RestJobSubmitResponse
+	if err := r.do(ctx, http.MethodPost, fmt.Sprintf("/slurm/%s/job/submit", r.version), request, &response); err != nil {
+		return "", wrapError(err, "Job submission fails. %s ", err)
+	}
+	if response.JobId == 0 {
+		return "", fmt.Errorf("Not submitted batch job id found in slurmrestd response")
+	}
+	return strconv.Itoa(response.JobId), nil
+}
+
+/*
+Cancel a specific job
+*/
+func (r RestClient) Scancel(ctx context.Context, reference JobReference) error {
+	klog.V(4).Infof("Canceling job %d", reference.JobId)
+	return r.do(ctx, http.MethodDelete, fmt.Sprintf("/slurm/%s/job/%d", r.version, reference.JobId), nil, nil)
+}
+
+/*
+Update the options of a job
+*/
+func (r RestClient) Supdate(ctx context.Context, reference JobReference, update JobUpdate) error {
+	klog.V(4).Infof("Updating job %d", reference.JobId)
+	if update == (JobUpdate{}) {
+		return nil
+	}
+	if reference.HetJob {
+		return adapters.InvalidArgumentError("Heterogeneous jobs are not supported by slurmrestd")
+	}
+	job := RestJobUpdate{MinimumCpusPerNode: update.CPUsPerNode}
+	if update.MemoryPerNode > 0 {
+		job.MemoryPerNode = &RestNumber{Set: true, Number: update.MemoryPerNode}
+	}
+	return r.do(ctx, http.MethodPost, fmt.Sprintf("/slurm/%s/job/%d", r.version, reference.JobId), job, nil)
+}
+
+/*
+Get job status from slurmctld, or from the accounting when slurmctld does not know the job anymore
+*/
+func (r RestClient) Sstatus(ctx context.Context, reference *JobReference) (*JobStatus, error) {
+	klog.V(4).Infof("Check status for job %d", reference.JobId)
+	var response RestJobsResponse
+	err := r.do(ctx, http.MethodGet, fmt.Sprintf("/slurm/%s/job/%d", r.version, reference.JobId), nil, &response)
+	if err == nil && len(response.Jobs) > 0 {
+		return response.Jobs[0].status(), nil
+	}
+	klog.V(5).Infof("slurmctld job query fails. %v", err)
+	var accounting RestAccountingResponse
+	err = r.do(ctx, http.MethodGet, fmt.Sprintf("/slurmdb/%s/job/%d", r.version, reference.JobId), nil, &accounting)
+	if err != nil {
+		return nil, wrapError(err, "Retrieve job info fails %s ", err)
+	}
+	if len(accounting.Jobs) == 0 {
+		return nil, adapters.NotFoundError("Job %d not found", reference.JobId)
+	}
+	return accounting.Jobs[0].status(), nil
+}
+
+/*
+Get the status of every task of a job array from slurmctld, or from the accounting when slurmctld does not
+know the array anymore
+*/
+func (r RestClient) SstatusArray(ctx context.Context, reference *JobReference) ([]*JobStatus, error) {
+	klog.V(4).Infof("Check status for job array %d", reference.JobId)
+	var statuses []*JobStatus
+	var response RestJobsResponse
+	err := r.do(ctx, http.MethodGet, fmt.Sprintf("/slurm/%s/job/%d", r.version, reference.JobId), nil, &response)
+	if err == nil && len(response.Jobs) > 0 {
+		for _, job := range response.Jobs {
+			statuses = append(statuses, job.status())
+		}
+		return statuses, nil
+	}
+	klog.V(5).Infof("slurmctld job query fails. %v", err)
+	var accounting RestAccountingResponse
+	err = r.do(ctx, http.MethodGet, fmt.Sprintf("/slurmdb/%s/job/%d", r.version, reference.JobId), nil, &accounting)
+	if err != nil {
+		return nil, wrapError(err, "Retrieve job info fails %s ", err)
+	}
+	if len(accounting.Jobs) == 0 {
+		return nil, adapters.NotFoundError("Job %d not found", reference.JobId)
+	}
+	for _, job := range accounting.Jobs {
+		statuses = append(statuses, job.status())
+	}
+	return statuses, nil
+}
+
+// status returns the status of the job, in nanoseconds
+func (job RestJob) status() *JobStatus {
+	status := &JobStatus{
+		JobState: job.JobState.Base(),
+		ExitCode: waitStatusCode(job.ExitCode.Value()),
+		Reason:   job.StateReason,
+		StarTime: job.StartTime.Value() * 1e9,
+		EndTime:  job.EndTime.Value() * 1e9,
+	}
+	if job.ArrayTaskId.Set && !job.ArrayTaskId.Infinite {
+		status.ArrayTaskId = strconv.FormatInt(job.ArrayTaskId.Number, 10)
+	} else if job.ArrayTaskString != "" {
+		status.ArrayTaskId = job.ArrayTaskString
+	}
+	return status
+}
+
+// status returns the status of the job, in nanoseconds
+func (job RestAccountingJob) status() *JobStatus {
+	reason := job.State.Current.Base()
+	if job.State.Reason != "" && job.State.Reason != "None" {
+		reason = job.State.Reason
+	}
+	status := &JobStatus{
+		JobState: job.State.Current.Base(),
+		ExitCode: int(job.ExitCode.ReturnCode.Value()),
+		Reason:   reason,
+		StarTime: job.Time.Start.Value() * 1e9,
+		EndTime:  job.Time.End.Value() * 1e9,
+	}
+	if job.Array.JobId != 0 && job.Array.TaskId.Set && !job.Array.TaskId.Infinite {
+		status.ArrayTaskId = strconv.FormatInt(job.Array.TaskId.Number, 10)
+	}
+	return status
+}
+
+// do sends the request with the JWT token of the user and decodes the response in out. The errors
+// of the response are classified like the errors of the Slurm commands.
+func (r RestClient) do(ctx context.Context, method, endpoint string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	request, err := http.NewRequest(method, r.url+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return adapters.InvalidArgumentError("Wrong slurmrestd request %s %s: %v", method, endpoint, err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("X-SLURM-USER-TOKEN", r.token)
+	if r.user != "" {
+		request.Header.Set("X-SLURM-USER-NAME", r.user)
+	}
+	response, err := r.httpClient.Do(request)
+	if err != nil {
+		return adapters.WrapError(adapters.KindUnavailable, fmt.Errorf("slurmrestd %s can not be reached: %w", r.url, err))
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return adapters.UnavailableError("slurmrestd response can not be read: %v", err)
+	}
+	var errors RestResponse
+	json.Unmarshal(data, &errors)
+	if response.StatusCode >= 300 || len(errors.Errors) > 0 {
+		return restError(method, endpoint, response.StatusCode, errors.Errors, data)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("slurmrestd response of %s %s can not be parsed: %v", method, endpoint, err)
+	}
+	return nil
+}
+
+// restError classifies the error by its message, or by the status code when the message is not known
+func restError(method, endpoint string, status int, errors []RestError, data []byte) error {
+	var messages []string
+	for _, e := range errors {
+		message := e.Description
+		if e.Error != "" {
+			message = strings.TrimSpace(e.Error + " " + e.Description)
+		}
+		messages = append(messages, message)
+	}
+	if len(messages) == 0 {
+		messages = append(messages, strings.TrimSpace(string(data)))
+	}
+	message := strings.Join(messages, ". ")
+	err := classifyError(message, fmt.Errorf("slurmrestd %s %s fails with status %d: %s", method, endpoint, status, message))
+	if adapters.ErrorKindOf(err) != adapters.KindUnknown {
+		return err
+	}
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return adapters.WrapError(adapters.KindPermissionDenied, err)
+	case http.StatusNotFound:
+		return adapters.WrapError(adapters.KindNotFound, err)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return adapters.WrapError(adapters.KindUnavailable, err)
+	}
+	return err
+}
+
+// submitRequest translates the job config to the job options of slurmrestd. The container path,
+// the working directory of the job, is relative to the home directory.
+func (r RestClient) submitRequest(config *JobConfig) (*RestJobSubmitRequest, error) {
+	if config.CustomHeaders != "" {
+		return nil, adapters.InvalidArgumentError("JOB_CUSTOM_CONFIG is not supported by slurmrestd")
+	}
+	if len(config.HetJobHeaders) > 0 {
+		return nil, adapters.InvalidArgumentError("Heterogeneous jobs are not supported by slurmrestd")
+	}
+	workDir := config.Path
+	if !path.IsAbs(workDir) {
+		workDir = path.Join(r.home, workDir)
+	}
+	job := RestJobDescription{CurrentWorkingDirectory: workDir, Environment: r.environment(config.ENV)}
+	for _, h := range config.Headers {
+		var err error
+		switch {
+		case h.Flag == "-J":
+			job.Name = h.Value
+		case h.Flag == "-o":
+			job.StandardOutput = path.Join(workDir, h.Value)
+		case h.Flag == "-e":
+			job.StandardError = path.Join(workDir, h.Value)
+		case h.Flag == "-p":
+			job.Partition = h.Value
+		case h.Flag == "-N":
+			job.MinimumNodes, job.MaximumNodes, err = parseNodes(h.Value)
+		case h.Flag == "-c":
+			job.CpusPerTask, err = strconv.Atoi(h.Value)
+		case h.Flag == "-n":
+			job.Tasks, err = strconv.Atoi(h.Value)
+		case strings.HasPrefix(h.Flag, "--cpus-per-task="):
+			job.CpusPerTask, err = strconv.Atoi(strings.TrimPrefix(h.Flag, "--cpus-per-task="))
+		case strings.HasPrefix(h.Flag, "--mem="):
+			job.MemoryPerNode, err = parseMemory(strings.TrimPrefix(h.Flag, "--mem="))
+		case h.Flag == "-t":
+			job.TimeLimit, err = parseTime(h.Value)
+		case strings.HasPrefix(h.Flag, "--ntasks-per-node="):
+			job.TasksPerNode, err = strconv.Atoi(strings.TrimPrefix(h.Flag, "--ntasks-per-node="))
+		case strings.HasPrefix(h.Flag, "--gres="):
+			job.TresPerNode = "gres/" + strings.TrimPrefix(h.Flag, "--gres=")
+		case strings.HasPrefix(h.Flag, "--array="):
+			job.Array = strings.TrimPrefix(h.Flag, "--array=")
+		case strings.HasPrefix(h.Flag, "--dependency="):
+			job.Dependency = strings.TrimPrefix(h.Flag, "--dependency=")
+		case strings.HasPrefix(h.Flag, "--account="):
+			job.Account = strings.TrimPrefix(h.Flag, "--account=")
+		case strings.HasPrefix(h.Flag, "--qos="):
+			job.Qos = strings.TrimPrefix(h.Flag, "--qos=")
+		case strings.HasPrefix(h.Flag, "--constraint="):
+			job.Constraints = strings.TrimPrefix(h.Flag, "--constraint=")
+		default:
+			return nil, adapters.InvalidArgumentError("Slurm option %s %s is not supported by slurmrestd", h.Flag, h.Value)
+		}
+		if err != nil {
+			return nil, adapters.InvalidArgumentError("Wrong value of Slurm option %s %s: %v", h.Flag, h.Value, err)
+		}
+	}
+	lines := []string{"#!/bin/bash"}
+	if config.Prerun != "" {
+		lines = append(lines, config.Prerun)
+	}
+	lines = append(lines, config.Command)
+	return &RestJobSubmitRequest{Script: strings.Join(lines, "\n") + "\n", Job: job}, nil
+}
+
+// environment returns the job environment, with the PATH and HOME of the user when the container does not set them
+func (r RestClient) environment(env map[string]string) []string {
+	variables := map[string]string{"PATH": defaultPath, "HOME": r.home}
+	for key, value := range env {
+		variables[key] = value
+	}
+	var environment []string
+	for key, value := range variables {
+		environment = append(environment, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(environment)
+	return environment
+}
+
+// parseMemory parses a memory size in MiB, with the optional M suffix
+func parseMemory(memory string) (*RestNumber, error) {
+	n, err := strconv.ParseInt(strings.TrimSuffix(memory, "M"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &RestNumber{Set: true, Number: n}, nil
+}
+
+// parseTime parses a time limit in minutes, infinite when it is unlimited
+func parseTime(limit string) (*RestNumber, error) {
+	minutes, err := ParseTimeLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	if minutes == TimeLimitUnlimited {
+		return &RestNumber{Set: true, Infinite: true}, nil
+	}
+	return &RestNumber{Set: true, Number: int64(minutes)}, nil
+}
+
+// parseNodes parses the node count, which can be a min-max range
+func parseNodes(nodes string) (int, int, error) {
+	parts := strings.SplitN(nodes, "-", 2)
+	min, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) == 1 {
+		return min, min, err
+	}
+	max, err := strconv.Atoi(parts[1])
+	return min, max, err
+}
+
+// waitStatusCode returns the exit code of a wait status, which is 128 plus the signal number
+// when the batch script was killed by a signal
+func waitStatusCode(status int64) int {
+	if signal := status & 0x7f; signal != 0 {
+		return 128 + int(signal)
+	}
+	return int(status >> 8)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RestJobSubmitRequest is the body of the slurmrestd job submit request
+type RestJobSubmitRequest struct {
+	Script string             `json:"script"`
+	Job    RestJobDescription `json:"job"`
+}
+
+// RestJobDescription has the job options. slurmrestd does not read the #SBATCH directives of the script.
+type RestJobDescription struct {
+	Name                    string   `json:"name,omitempty"`
+	CurrentWorkingDirectory string   `json:"current_working_directory"`
+	Environment             []string `json:"environment"`
+	StandardOutput          string   `json:"standard_output,omitempty"`
+	StandardError           string   `json:"standard_error,omitempty"`
+	Partition               string   `json:"partition,omitempty"`
+	MinimumNodes            int      `json:"minimum_nodes,omitempty"`
+	MaximumNodes            int      `json:"maximum_nodes,omitempty"`
+	Tasks                   int      `json:"tasks,omitempty"`
+	TasksPerNode            int      `json:"tasks_per_node,omitempty"`
+	CpusPerTask             int      `json:"cpus_per_task,omitempty"`
+	TresPerNode             string   `json:"tres_per_node,omitempty"`
+	Array                   string   `json:"array,omitempty"`
+	Dependency              string   `json:"dependency,omitempty"`
+	Account                 string   `json:"account,omitempty"`
+	Qos                     string   `json:"qos,omitempty"`
+	Constraints             string   `json:"constraints,omitempty"`
+	// MemoryPerNode is in MiB, and TimeLimit in minutes, with the number format of the recent API versions
+	MemoryPerNode *RestNumber `json:"memory_per_node,omitempty"`
+	TimeLimit     *RestNumber `json:"time_limit,omitempty"`
+}
+
+// RestJobUpdate has the job options which are changed in a submitted job
+type RestJobUpdate struct {
+	MinimumCpusPerNode int         `json:"minimum_cpus_per_node,omitempty"`
+	MemoryPerNode      *RestNumber `json:"memory_per_node,omitempty"`
+}
+
+// RestError is an error of a slurmrestd response. Older API versions set Errno instead of ErrorNumber.
+type RestError struct {
+	Error       string `json:"error"`
+	ErrorNumber int    `json:"error_number"`
+	Errno       int    `json:"errno"`
+	Description string `json:"description"`
+}
+
+// RestResponse has the errors every slurmrestd response may have
+type RestResponse struct {
+	Errors []RestError `json:"errors"`
+}
+
+// RestJobSubmitResponse is the response of the job submit request
+type RestJobSubmitResponse struct {
+	JobId int `json:"job_id"`
+}
+
+// RestJobsResponse is the response of the job query of slurmctld
+type RestJobsResponse struct {
+	Jobs []RestJob `json:"jobs"`
+}
+
+// RestJob is a job known by slurmctld. ExitCode is the wait status of the batch script. The pending
+// tasks of an array are a single job, with their range in ArrayTaskString.
+type RestJob struct {
+	JobId           int        `json:"job_id"`
+	JobState        RestStates `json:"job_state"`
+	StateReason     string     `json:"state_reason"`
+	ExitCode        RestNumber `json:"exit_code"`
+	StartTime       RestNumber `json:"start_time"`
+	EndTime         RestNumber `json:"end_time"`
+	ArrayJobId      RestNumber `json:"array_job_id"`
+	ArrayTaskId     RestNumber `json:"array_task_id"`
+	ArrayTaskString string     `json:"array_task_string"`
+}
+
+// RestAccountingResponse is the response of the job query of slurmdbd
+type RestAccountingResponse struct {
+	Jobs []RestAccountingJob `json:"jobs"`
+}
+
+// RestAccountingJob is a job of the Slurm accounting, which keeps the finished jobs
+type RestAccountingJob struct {
+	JobId int `json:"job_id"`
+	Array struct {
+		JobId  int        `json:"job_id"`
+		TaskId RestNumber `json:"task_id"`
+	} `json:"array"`
+	State struct {
+		Current RestStates `json:"current"`
+		Reason  string     `json:"reason"`
+	} `json:"state"`
+	ExitCode struct {
+		ReturnCode RestNumber `json:"return_code"`
+	} `json:"exit_code"`
+	Time struct {
+		Start RestNumber `json:"start"`
+		End   RestNumber `json:"end"`
+	} `json:"time"`
+}
+
+// RestNumber is a number of slurmrestd. Recent API versions send it as an object
+// with the set and infinite flags, and the older ones as a plain number.
+type RestNumber struct {
+	Set      bool  `json:"set"`
+	Infinite bool  `json:"infinite"`
+	Number   int64 `json:"number"`
+}
+
+func (n *RestNumber) UnmarshalJSON(data []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		type number RestNumber
+		return json.Unmarshal(data, (*number)(n))
+	}
+	if string(data) == "null" {
+		*n = RestNumber{}
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.Number); err != nil {
+		return fmt.Errorf("wrong slurmrestd number %s: %v", data, err)
+	}
+	n.Set = true
+	return nil
+}
+
+// Value returns the number, or 0 when it is not set or infinite
+func (n RestNumber) Value() int64 {
+	if !n.Set || n.Infinite {
+		return 0
+	}
+	return n.Number
+}
+
+// RestStates are the states of a job. Recent API versions send a list of flags, where the first one is
+// the base state, and the older ones a single state.
+type RestStates []string
+
+func (s *RestStates) UnmarshalJSON(data []byte) error {
+	var state string
+	if err := json.Unmarshal(data, &state); err == nil {
+		*s = RestStates{state}
+		return nil
+	}
+	var states []string
+	if err := json.Unmarshal(data, &states); err != nil {
+		return fmt.Errorf("wrong slurmrestd job state %s: %v", data, err)
+	}
+	*s = states
+	return nil
+}
+
+// Base returns the base state of the job
+func (s RestStates) Base() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common/file"
+	"os"
+	"strconv"
+	"strings"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+const (
+	StepScript = "step.sh"
+	// StepRequest is created in the path of a step to request its launch to the launcher of the allocation
+	StepRequest = "requested"
+	// StepStopTimeout is the time in seconds that a step has to exit after SIGTERM, before it is killed
+	StepStopTimeout = 10
+)
+
+// StepLauncher is the command of the holder job of an allocation. It runs in the allocation, in the path of
+// the job, and launches the steps requested in its subdirectories. The steps report their state in files of
+// their path, on the shared file system, since they do not run on the login node.
+const StepLauncher = `while true; do
+	for request in */` + StepRequest + `; do
+		step=${request%/*}
+		mv "$request" "$step/launched" 2>/dev/null && (cd "$step" && exec bash ` + StepScript + ` >/dev/null 2>&1 </dev/null) &
+	done
+	sleep 1
+done`
+
+// StepConfig is a job step, which runs in the allocation of a job. The step is launched in the allocation by
+// the StepLauncher of its holder job, and step.sh runs srun and writes the exit code of the step.
+type StepConfig struct {
+	JobId   int32
+	Options []string
+	Command string
+	Path    string
+	Prerun  string
+	ENV     map[string]string
+	// Stdout and Stderr are the output files of the step, in its path
+	Stdout string
+	Stderr string
+}
+
+/*
+Request the launch of a step in the allocation of a job, whose holder job runs the StepLauncher in the
+parent path of the step. The step runs in the background, so it is not started when Srun returns
+*/
+func (s SlurmCmd) Srun(ctx context.Context, config *StepConfig) error {
+	klog.V(4).Infof("Execute step in job %d", config.JobId)
+	if config.Prerun != "" {
+		prerunScript, err := buildPreRunScript(config.Prerun)
+		if err != nil {
+			return fmt.Errorf("Error generating step script %s ", err)
+		}
+		if err := s.CopyTo(ctx, prerunScript, fmt.Sprintf("%s/%s", config.Path, PreRunScript)); err != nil {
+			return wrapError(err, "Error copying prerun file to Slurm cluster. %s ", err)
+		}
+	}
+	stepLocalPath, err := buildStepScript(config)
+	if err != nil {
+		return fmt.Errorf("Error generating step script %s ", err)
+	}
+	if err := s.CopyTo(ctx, stepLocalPath, fmt.Sprintf("%s/%s", config.Path, StepScript)); err != nil {
+		return wrapError(err, "Error copying step file to Slurm cluster. %s ", err)
+	}
+	// The files of a previous run of the step are removed before the launch is requested
+	cmd := fmt.Sprintf("cd %s && rm -f launched started stop exitcode && touch %s", config.Path, StepRequest)
+	_, err = s.ExecCmd(ctx, cmd)
+	return err
+}
+
+/*
+Get the status of the step requested in the path of the allocation of the job. Steps which are not started
+are PENDING, and steps without exit code are NODE_FAIL once the allocation is gone
+*/
+func (s SlurmCmd) StepStatus(ctx context.Context, jobId int32, stepPath string) (*JobStatus, error) {
+	klog.V(4).Infof("Check status for step in %s", stepPath)
+	cmd := fmt.Sprintf("cd %s 2>/dev/null || { echo missing; exit 0; }; "+
+		"if [ -f exitcode ]; then echo exited $(cat exitcode) $(cat started 2>/dev/null || echo 0) $(stat -c %%Y exitcode); "+
+		"elif [ ! -f %s ] && [ ! -f launched ]; then echo missing; "+
+		"else case $(squeue -h -j %d -o %%T 2>/dev/null) in "+
+		"RUNNING) if [ -f started ]; then echo running 0 $(cat started) 0; else echo pending 0 0 0; fi ;; "+
+		"PENDING|CONFIGURING) echo pending 0 0 0 ;; "+
+		"*) echo lost 0 $(cat started 2>/dev/null || echo 0) 0 ;; esac; fi", stepPath, StepRequest, jobId)
+	response, err := s.ExecCmd(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return parseStepStatus(response)
+}
+
+/*
+Stop the step requested in the path. Steps which are not launched yet are not run, and running steps are
+killed by step.sh when they do not exit in StepStopTimeout seconds
+*/
+func (s SlurmCmd) StepCancel(ctx context.Context, stepPath string) error {
+	klog.V(4).Infof("Canceling step in %s", stepPath)
+	cmd := fmt.Sprintf("cd %s 2>/dev/null || exit 0; [ -f exitcode ] && exit 0; "+
+		"if rm %s 2>/dev/null; then echo 143 >exitcode; exit 0; fi; touch stop; i=0; "+
+		"while [ ! -f exitcode ] && [ $i -lt %d ]; do sleep 1; i=$((i+1)); done; true",
+		stepPath, StepRequest, StepStopTimeout+2)
+	_, err := s.ExecCmd(ctx, cmd)
+	return err
+}
+
+// buildStepScript builds step.sh, which the launcher runs in the path of the step. It forwards the stop
+// requests of StepCancel to srun.
+func buildStepScript(config *StepConfig) (string, error) {
+	var commands []string
+	commands = append(commands, "#!/bin/bash")
+	for key, value := range config.ENV {
+		commands = append(commands, fmt.Sprintf("export %s=\"%s\"", key, value))
+	}
+	if config.Prerun != "" {
+		commands = append(commands, fmt.Sprintf("source %s", PreRunScript))
+	}
+	commands = append(commands, "if [ -f stop ]; then echo 143 > exitcode; exit 0; fi", "date +%s > started")
+	srun := append([]string{"srun", fmt.Sprintf("--jobid=%d", config.JobId), "--overlap"}, config.Options...)
+	srun = append(srun, "bash", "-c", config.Command)
+	commands = append(commands, fmt.Sprintf("%s > %s 2> %s &", shellquote.Join(srun...), config.Stdout, config.Stderr),
+		"step=$!",
+		"while kill -0 $step 2>/dev/null; do",
+		"if [ -f stop ]; then",
+		"kill -TERM $step",
+		fmt.Sprintf("for i in $(seq %d); do kill -0 $step 2>/dev/null || break; sleep 1; done", StepStopTimeout),
+		"kill -KILL $step 2>/dev/null",
+		"break",
+		"fi",
+		"sleep 1",
+		"done",
+		"wait $step",
+		// The exit code is renamed, so it is never read half written
+		"echo $? > exitcode.tmp && mv exitcode.tmp exitcode")
+
+	var b bytes.Buffer
+	if err := writeLines(&b, commands); err != nil {
+		return "", err
+	}
+	filePath := file.GenerateTmpFile("/tmp", "step", "sh")
+	// open output file
+	fo, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0777)
+	if err != nil {
+		return "", err
+	}
+	defer fo.Close()
+	if _, err := fo.Write(b.Bytes()); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// parseStepStatus parses the output of the status command of the steps: the state of the step launcher,
+// its exit code, and the start and end times in seconds
+func parseStepStatus(stdout string) (*JobStatus, error) {
+	fields := strings.Fields(stdout)
+	if len(fields) > 0 && fields[0] == "missing" {
+		return nil, adapters.NotFoundError("Step not found")
+	}
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("Step status cannot be parsed %s ", stdout)
+	}
+	var values [3]int64
+	for i, f := range fields[1:4] {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Step status cannot be parsed %s ", stdout)
+		}
+		values[i] = v
+	}
+	status := &JobStatus{ExitCode: int(values[0]), StarTime: values[1], EndTime: values[2]}
+	switch fields[0] {
+	case "exited":
+		status.JobState = "COMPLETED"
+		if status.ExitCode != 0 {
+			status.JobState = "FAILED"
+		}
+	case "running":
+		status.JobState = "RUNNING"
+	case "pending":
+		status.JobState = "PENDING"
+	case "lost":
+		status.JobState = "NODE_FAIL"
+	default:
+		return nil, fmt.Errorf("Step status cannot be parsed %s ", stdout)
+	}
+	status.Reason = status.JobState
+	return status, nil
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common/ssh"
+)
+
+//Test Slurm errors are classified
+func TestUnitClassifyError(t *testing.T) {
+	for _, c := range []struct {
+		response string
+		err      error
+		kind     adapters.ErrorKind
+	}{
+		{"sbatch: error: Batch job submission failed: Invalid partition name specified", fmt.Errorf("exit status 1"), adapters.KindInvalidArgument},
+		{"slurm_load_jobs error: Invalid job id specified", fmt.Errorf("exit status 1"), adapters.KindNotFound},
+		{"slurm_update error: Job is no longer pending execution", fmt.Errorf("exit status 1"), adapters.KindInvalidArgument},
+		{"", fmt.Errorf("sbatch: error: Batch job submission failed: Unable to contact slurm controller"), adapters.KindUnavailable},
+		{"", &ssh.ConnectionError{Err: fmt.Errorf("dial tcp: connection refused")}, adapters.KindUnavailable},
+		{"", &ssh.ConnectionError{Err: fmt.Errorf("ssh: handshake failed: ssh: unable to authenticate")}, adapters.KindPermissionDenied},
+		{"", fmt.Errorf("exit status 2"), adapters.KindUnknown},
+	} {
+		if kind := adapters.ErrorKindOf(classifyError(c.response, c.err)); kind != c.kind {
+			t.Errorf("Error %q %q should be %q instead of %q", c.response, c.err, c.kind, kind)
+		}
+	}
+	err := wrapError(adapters.UnavailableError("down"), "Retrieve job info fails %s ", "down")
+	if adapters.ErrorKindOf(err) != adapters.KindUnavailable {
+		t.Errorf("Wrapped errors must keep their kind: %v", err)
+	}
+	if classifyError("", nil) != nil {
+		t.Errorf("Nil errors should not be classified")
+	}
+}
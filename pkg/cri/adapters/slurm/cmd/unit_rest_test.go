@@ -0,0 +1,264 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+)
+
+// Recorded slurmrestd v0.0.39 responses
+const (
+	restSubmitResponse = `{"meta": {"plugin": {"type": "openapi\/v0.0.39"}}, "errors": [], "warnings": [],
+		"job_id": 42, "step_id": "batch", "job_submit_user_msg": ""}`
+	restJobResponse = `{"errors": [], "warnings": [], "jobs": [{"job_id": 42, "job_state": "RUNNING", "state_reason": "None",
+		"exit_code": {"set": true, "infinite": false, "number": 0},
+		"start_time": {"set": true, "infinite": false, "number": 1700000000},
+		"end_time": {"set": true, "infinite": false, "number": 1700003600}}]}`
+	restInvalidJobResponse = `{"errors": [{"description": "Failed to load job", "error_number": 2017,
+		"error": "Invalid job id specified", "source": "_handle_job_get"}], "warnings": [], "jobs": []}`
+	restAccountingResponse = `{"errors": [], "warnings": [], "jobs": [{"job_id": 41, "name": "test",
+		"state": {"current": "FAILED", "reason": "None"}, "exit_code": {"status": "FAILED", "return_code": 3},
+		"time": {"start": 1700000000, "end": 1700000060}}]}`
+	restArrayResponse = `{"errors": [], "warnings": [], "jobs": [
+		{"job_id": 51, "job_state": "PENDING", "array_job_id": {"set": true, "number": 50},
+		"array_task_id": {"set": false, "number": 0}, "array_task_string": "2-9%2"},
+		{"job_id": 50, "job_state": "COMPLETED", "exit_code": {"set": true, "number": 0},
+		"array_job_id": {"set": true, "number": 50}, "array_task_id": {"set": true, "number": 0}},
+		{"job_id": 52, "job_state": "FAILED", "exit_code": {"set": true, "number": 768},
+		"array_job_id": {"set": true, "number": 50}, "array_task_id": {"set": true, "number": 1}}]}`
+	restPartitionResponse = `{"errors": [{"description": "Batch job submission failed", "error_number": 2009,
+		"error": "Invalid partition name specified", "source": "slurm_submit_batch_job()"}], "warnings": []}`
+)
+
+func newRestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *RestClient) {
+	server := httptest.NewServer(handler)
+	client, err := NewRestClient(server.URL, "", "user", "jwt", "/home/user")
+	if err != nil {
+		t.Fatalf("Rest client can not be created: %v", err)
+	}
+	return server, client
+}
+
+//Test jobs are submitted with the job options and the JWT token of the user
+func TestUnitRestSbatch(t *testing.T) {
+	var request RestJobSubmitRequest
+	server, client := newRestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/slurm/v0.0.39/job/submit" {
+			t.Errorf("Wrong submit request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("X-SLURM-USER-TOKEN") != "jwt" || r.Header.Get("X-SLURM-USER-NAME") != "user" {
+			t.Errorf("Request should be authenticated: %v", r.Header)
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(restSubmitResponse))
+	})
+	defer server.Close()
+	config := &JobConfig{
+		Headers: []JobConfigField{{"-J", "test"}, {"-o", "stdout.out"}, {"-p", "debug"}, {"-N", "2"},
+			{"-n", "8"}, {"--gres=gpu:1", ""}, {"--ntasks-per-node=4", ""}, {"--dependency=afterok:41", ""},
+			{"--cpus-per-task=2", ""}, {"--mem=512M", ""}, {"-t", "1-00:30"}, {"--account=physics", ""},
+			{"--qos=high", ""}, {"--constraint=intel&ib", ""}},
+		Command: "singularity exec image hostname",
+		Path:    "multi-cri/sandbox/container",
+		Prerun:  "module load singularity",
+		ENV:     map[string]string{"A": "1"},
+	}
+	jobId, err := client.Sbatch(context.Background(), config)
+	if err != nil || jobId != "42" {
+		t.Fatalf("Job should be submitted: %s %v", jobId, err)
+	}
+	job := request.Job
+	if job.Name != "test" || job.Partition != "debug" || job.MinimumNodes != 2 || job.MaximumNodes != 2 ||
+		job.Tasks != 8 || job.TasksPerNode != 4 || job.TresPerNode != "gres/gpu:1" || job.Dependency != "afterok:41" {
+		t.Errorf("Wrong job options: %+v", job)
+	}
+	if job.CpusPerTask != 2 || job.MemoryPerNode == nil || job.MemoryPerNode.Value() != 512 ||
+		job.TimeLimit == nil || job.TimeLimit.Value() != 1470 || job.Account != "physics" || job.Qos != "high" ||
+		job.Constraints != "intel&ib" {
+		t.Errorf("Wrong job resources: %+v", job)
+	}
+	if job.CurrentWorkingDirectory != "/home/user/multi-cri/sandbox/container" ||
+		job.StandardOutput != "/home/user/multi-cri/sandbox/container/stdout.out" {
+		t.Errorf("Job paths should be relative to the home directory: %+v", job)
+	}
+	if env := strings.Join(job.Environment, " "); env != "A=1 HOME=/home/user PATH="+defaultPath {
+		t.Errorf("Wrong job environment: %s", env)
+	}
+	if request.Script != "#!/bin/bash\nmodule load singularity\nsingularity exec image hostname\n" {
+		t.Errorf("Wrong job script: %q", request.Script)
+	}
+
+	config.CustomHeaders = "#SBATCH --exclusive"
+	if _, err := client.Sbatch(context.Background(), config); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Custom headers should not be supported: %v", err)
+	}
+}
+
+//Test the status of the jobs is read from slurmctld, and from the accounting for finished jobs
+func TestUnitRestSstatus(t *testing.T) {
+	server, client := newRestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slurm/v0.0.39/job/42":
+			w.Write([]byte(restJobResponse))
+		case "/slurm/v0.0.39/job/41":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(restInvalidJobResponse))
+		case "/slurmdb/v0.0.39/job/41":
+			w.Write([]byte(restAccountingResponse))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(restInvalidJobResponse))
+		}
+	})
+	defer server.Close()
+	status, err := client.Sstatus(context.Background(), &JobReference{JobId: 42})
+	if err != nil || status.JobState != "RUNNING" || status.StarTime != 1700000000*1e9 {
+		t.Errorf("Wrong status of running job: %+v %v", status, err)
+	}
+	status, err = client.Sstatus(context.Background(), &JobReference{JobId: 41})
+	if err != nil || status.JobState != "FAILED" || status.ExitCode != 3 || status.EndTime != 1700000060*1e9 {
+		t.Errorf("Wrong status of finished job: %+v %v", status, err)
+	}
+	if _, err := client.Sstatus(context.Background(), &JobReference{JobId: 40}); adapters.ErrorKindOf(err) != adapters.KindNotFound {
+		t.Errorf("Unknown jobs should not be found: %v", err)
+	}
+}
+
+//Test the status of every task of the job arrays is read, with the pending tasks together
+func TestUnitRestSstatusArray(t *testing.T) {
+	server, client := newRestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slurm/v0.0.39/job/50":
+			w.Write([]byte(restArrayResponse))
+		case "/slurm/v0.0.39/job/41":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(restInvalidJobResponse))
+		case "/slurmdb/v0.0.39/job/41":
+			w.Write([]byte(restAccountingResponse))
+		}
+	})
+	defer server.Close()
+	statuses, err := client.SstatusArray(context.Background(), &JobReference{JobId: 50})
+	if err != nil || len(statuses) != 3 {
+		t.Fatalf("Every task should be read: %v", err)
+	}
+	if statuses[0].ArrayTaskId != "2-9%2" || statuses[0].JobState != "PENDING" {
+		t.Errorf("Pending tasks should have their range: %+v", statuses[0])
+	}
+	if statuses[2].ArrayTaskId != "1" || statuses[2].JobState != "FAILED" || statuses[2].ExitCode != 3 {
+		t.Errorf("Wrong status of failed task: %+v", statuses[2])
+	}
+	statuses, err = client.SstatusArray(context.Background(), &JobReference{JobId: 41})
+	if err != nil || len(statuses) != 1 || statuses[0].JobState != "FAILED" || statuses[0].ArrayTaskId != "" {
+		t.Errorf("Jobs should be read from the accounting: %v", err)
+	}
+
+	var request RestJobSubmitRequest
+	submit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(restSubmitResponse))
+	}))
+	defer submit.Close()
+	client.url = submit.URL
+	config := &JobConfig{Headers: []JobConfigField{{"-o", "stdout_%a.out"}, {"--array=0-9%2", ""}}, Path: "job"}
+	if _, err := client.Sbatch(context.Background(), config); err != nil || request.Job.Array != "0-9%2" ||
+		request.Job.StandardOutput != "/home/user/job/stdout_%a.out" {
+		t.Errorf("Arrays should be submitted: %+v %v", request.Job, err)
+	}
+}
+
+//Test jobs are updated with the changed options only
+func TestUnitRestSupdate(t *testing.T) {
+	var request map[string]interface{}
+	server, client := newRestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/slurm/v0.0.39/job/42" {
+			t.Errorf("Wrong update request %s %s", r.Method, r.URL.Path)
+		}
+		request = nil
+		json.NewDecoder(r.Body).Decode(&request)
+		w.Write([]byte(`{"errors": [], "warnings": []}`))
+	})
+	defer server.Close()
+	update := JobUpdate{CPUsPerNode: 2, MemoryPerNode: 1024}
+	if err := client.Supdate(context.Background(), JobReference{JobId: 42}, update); err != nil {
+		t.Fatalf("Job should be updated: %v", err)
+	}
+	data, _ := json.Marshal(request)
+	expected := `{"memory_per_node":{"infinite":false,"number":1024,"set":true},"minimum_cpus_per_node":2}`
+	if string(data) != expected {
+		t.Errorf("Wrong update request: %s", data)
+	}
+	err := client.Supdate(context.Background(), JobReference{JobId: 42, HetJob: true, HetJobOffset: 1}, update)
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Components of heterogeneous jobs should be invalid arguments: %v", err)
+	}
+}
+
+//Test jobs are cancelled and the slurmrestd errors are classified
+func TestUnitRestErrors(t *testing.T) {
+	server, client := newRestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete && r.URL.Path == "/slurm/v0.0.39/job/42":
+			w.Write([]byte(`{"errors": [], "warnings": []}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Authentication failure"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(restPartitionResponse))
+		}
+	})
+	if err := client.Scancel(context.Background(), JobReference{JobId: 42}); err != nil {
+		t.Errorf("Job should be cancelled: %v", err)
+	}
+	if err := client.Scancel(context.Background(), JobReference{JobId: 43}); adapters.ErrorKindOf(err) != adapters.KindPermissionDenied {
+		t.Errorf("Unauthorized requests should be denied: %v", err)
+	}
+	_, err := client.Sbatch(context.Background(), &JobConfig{Headers: []JobConfigField{{"-p", "wrong"}}, Path: "job"})
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Invalid partitions should be invalid arguments: %v", err)
+	}
+	_, err = client.Sbatch(context.Background(), &JobConfig{Headers: []JobConfigField{{"-p", "gpu"}},
+		HetJobHeaders: [][]JobConfigField{{{"-p", "cpu"}}}, Path: "job"})
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Heterogeneous jobs should be invalid arguments: %v", err)
+	}
+	server.Close()
+	if err := client.Scancel(context.Background(), JobReference{JobId: 42}); adapters.ErrorKindOf(err) != adapters.KindUnavailable {
+		t.Errorf("Unreachable slurmrestd should be unavailable: %v", err)
+	}
+}
+
+//Test the numbers and states of the older and recent slurmrestd versions are parsed
+func TestUnitRestModels(t *testing.T) {
+	var job RestJob
+	if err := json.Unmarshal([]byte(`{"job_state": ["COMPLETED", "REQUEUED"], "exit_code": 256, "end_time": {"set": false, "infinite": false, "number": 0}}`), &job); err != nil {
+		t.Fatalf("Job should be parsed: %v", err)
+	}
+	if job.JobState.Base() != "COMPLETED" || waitStatusCode(job.ExitCode.Value()) != 1 || job.EndTime.Value() != 0 {
+		t.Errorf("Wrong job: %+v", job)
+	}
+	if code := waitStatusCode(9); code != 137 {
+		t.Errorf("Killed jobs should exit with 137: %d", code)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+//Test the Slurm time limits are parsed in minutes
+func TestUnitParseTimeLimit(t *testing.T) {
+	for limit, expected := range map[string]int{
+		"30":         30,
+		"30:30":      31,
+		"2:00:00":    120,
+		"1-2":        1560,
+		"1-2:30":     1590,
+		"1-00:00:01": 1441,
+		"UNLIMITED":  TimeLimitUnlimited,
+		"infinite":   TimeLimitUnlimited,
+	} {
+		if minutes, err := ParseTimeLimit(limit); err != nil || minutes != expected {
+			t.Errorf("Time limit %s should be %d minutes: %d %v", limit, expected, minutes, err)
+		}
+	}
+	for _, limit := range []string{"", "0", "1:2:3:4", "-1", "a-1", "1h", "NONE"} {
+		if _, err := ParseTimeLimit(limit); err == nil {
+			t.Errorf("Time limit %q should be wrong", limit)
+		}
+	}
+}
+
+//Test the resources of the updates are translated to scontrol update fields
+func TestUnitJobUpdateFields(t *testing.T) {
+	update := JobUpdate{CPUsPerNode: 4, MemoryPerNode: 1024}
+	expected := []string{"MinCPUsNode=4", "MinMemoryNode=1024"}
+	if fields := update.fields(); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Wrong update fields: %v", fields)
+	}
+	if fields := (JobUpdate{MemoryPerNode: 10}).fields(); !reflect.DeepEqual(fields, []string{"MinMemoryNode=10"}) {
+		t.Errorf("Only the resources which are set should be updated: %v", fields)
+	}
+}
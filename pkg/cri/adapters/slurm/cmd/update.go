@@ -0,0 +1,53 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"multi-cri/pkg/cri/adapters/batch"
+)
+
+// TimeLimitUnlimited is the time limit of the jobs which run without limit, UNLIMITED or INFINITE in Slurm
+const TimeLimitUnlimited = batch.TimeLimitUnlimited
+
+// JobUpdate has the resources of a submitted job which are changed. The resources which are not set are kept.
+type JobUpdate struct {
+	CPUsPerNode int
+	// MemoryPerNode is in MiB
+	MemoryPerNode int64
+}
+
+// fields returns the scontrol update fields of the resources which are set
+func (u JobUpdate) fields() []string {
+	var fields []string
+	if u.CPUsPerNode > 0 {
+		fields = append(fields, fmt.Sprintf("MinCPUsNode=%d", u.CPUsPerNode))
+	}
+	if u.MemoryPerNode > 0 {
+		fields = append(fields, fmt.Sprintf("MinMemoryNode=%d", u.MemoryPerNode))
+	}
+	return fields
+}
+
+// ParseTimeLimit parses a Slurm time limit in minutes, rounded up, with the formats of batch.ParseTimeLimit.
+// UNLIMITED and INFINITE, in any case, are TimeLimitUnlimited.
+func ParseTimeLimit(limit string) (int, error) {
+	seconds, err := batch.ParseTimeLimit(limit)
+	if err != nil || seconds == TimeLimitUnlimited {
+		return seconds, err
+	}
+	return (seconds + 59) / 60, nil
+}
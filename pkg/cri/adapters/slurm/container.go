@@ -22,67 +22,95 @@ import (
 	"multi-cri/pkg/cri/store"
 
 	"strconv"
-
-	"multi-cri/pkg/cri/common"
 	"strings"
-
-	"path/filepath"
+	"time"
 
 	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
 
+	"golang.org/x/net/context"
 	"k8s.io/klog"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
-func (s SlurmAdapter) CreateContainer(cm *store.ContainerMetadata) error {
+func (s SlurmAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
 	klog.Infof("Creating container path in server")
-	//mount parallel filesystem volume
-	mounts := make(map[string]string)
-	for _, m := range cm.Config.Mounts {
-		mounts[m.ContainerPath] = m.HostPath
-	}
-
 	var err error
-	mountPoint := s.MountPath
-	if val, ok := mounts[adapters.VolumeContainer]; ok {
-		cm.Extra["VolumePath"] = val
-		cm.Extra["LocalPath"] = fmt.Sprintf("%s/%s/%s", val, cm.PodSandbox.ID, cm.ID)
-		volName := filepath.Base(val)
-		mountPoint = fmt.Sprintf("%s/%s", mountPoint, volName)
-		logString(cm.LogFile, fmt.Sprintf("---\nContainer mounted in \"%s\" Volume.\nResults stored in directory:  \"%s/%s\" \n---",
-			volName, cm.PodSandbox.ID, cm.ID))
-	}
-	cm.Extra["RMVolumePath"] = mountPoint
-	cm.Extra["RMPath"] = fmt.Sprintf("%s/%s/%s", mountPoint, cm.PodSandbox.ID, cm.ID)
+	if err := validateJobSettings(cm); err != nil {
+		return err
+	}
+	settings := batch.ParseJobSettings(cm)
+	if err := validateDependencies(cm.PodSandbox.Config.Annotations, cm.Name); err != nil {
+		return err
+	}
+	if err := s.validateHetJob(cm); err != nil {
+		return err
+	}
+	if s.Allocation == AllocationPod {
+		if err := validateStep(settings); err != nil {
+			return err
+		}
+		if hasDependencies(cm.PodSandbox.Config.Annotations) {
+			return adapters.InvalidArgumentError("Container dependencies are not supported with the pod allocation")
+		}
+	}
+	batch.SetupContainerPaths(cm, s.MountPath)
 
 	//Ensure container path exists in Slurm cluster
-	ensureRMPathExists(cm)
+	ensureRMPathExists(ctx, cm)
 
 	//Pull image in Slurm cluster
-	if err := s.Builder.PullImageInCluster(cm); err != nil {
+	if err := s.Builder.PullImageInCluster(ctx, cm); err != nil {
 		return err
 	}
 
+	//The first container of the pod requests the allocation of the pod
+	if s.Allocation == AllocationPod {
+		jobId, err := s.requestAllocation(ctx, cm)
+		if err != nil {
+			return err
+		}
+		cm.Extra["AllocationJobId"] = strconv.Itoa(int(jobId))
+	}
+
 	klog.Infof("Created container path in server with id %s", cm.ID)
 	return err
 }
 
-func (s SlurmAdapter) StartContainer(cm *store.ContainerMetadata) error {
-	slurmClient, err := cmd.CreateCMD(cm)
+func (s SlurmAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	slurmClient, err := s.jobClient(cm)
 	if err != nil {
 		return err
 	}
 
+	settings := batch.ParseJobSettings(cm)
+	if cm.Extra["AllocationJobId"] != "" {
+		return s.startStep(ctx, cm, settings)
+	}
+	if offset := hetJobOffset(cm.PodSandbox.Config.Annotations, cm.Name); offset >= 0 {
+		return s.startComponent(ctx, cm, offset)
+	}
+	resources := requestedResources(settings, linuxResources(cm))
+
 	// Create run singularity command
-	jobConf := s.buildStartCommand(cm)
+	jobConf := s.buildStartCommand(cm, settings)
 
 	//Filter system environment varaiables, so only container variables are set
-	jobConf.ENV = filterEnvironmentVariables(cm)
+	jobConf.ENV = batch.FilterEnvironment(cm)
 
 	//Batch Job headers
-	setupBatchHeaders(cm, jobConf)
+	setupBatchHeaders(settings, linuxResources(cm), jobConf)
+
+	//The job waits for the jobs of the containers it depends on
+	dependency, err := s.jobDependency(cm)
+	if err != nil {
+		return err
+	}
+	if dependency != "" {
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{fmt.Sprintf("--dependency=%s", dependency), ""})
+	}
 
-	jobId, err := slurmClient.Sbatch(jobConf)
+	jobId, err := slurmClient.Sbatch(ctx, jobConf)
 	if err != nil {
 		return err
 	}
@@ -91,62 +119,177 @@ func (s SlurmAdapter) StartContainer(cm *store.ContainerMetadata) error {
 		pid, err = strconv.Atoi(jobId)
 	}
 	cm.Pid = pid
+	if err == nil {
+		s.jobs.add(cm.PodSandbox.ID, cm.Name, pid)
+		resources.keep(cm)
+	}
 	return err
 }
 
-func setupBatchHeaders(cm *store.ContainerMetadata, jobConf *cmd.JobConfig) {
-	jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-J", cm.Name})
-	jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-o", StdoutFile})
-	jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-e", SterrFile})
-	if c, ok := cm.Environment["JOB_QUEUE"]; ok {
-		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-p", c})
+// startStep launches the container as a step of the allocation of its pod
+func (s SlurmAdapter) startStep(ctx context.Context, cm *store.ContainerMetadata, settings batch.JobSettings) error {
+	jobId, err := strconv.Atoi(cm.Extra["AllocationJobId"])
+	if err != nil {
+		return fmt.Errorf("Job id of the pod allocation cannot be parsed %s ", cm.Extra["AllocationJobId"])
+	}
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return err
 	}
-	if c, ok := cm.Environment["JOB_GPU"]; ok {
+	jobConf := s.buildStartCommand(cm, settings)
+	stepConf := &cmd.StepConfig{
+		JobId:   int32(jobId),
+		Options: stepOptions(settings, linuxResources(cm)),
+		Command: jobConf.Command,
+		Path:    jobConf.Path,
+		Prerun:  jobConf.Prerun,
+		ENV:     batch.FilterEnvironment(cm),
+		Stdout:  StdoutFile,
+		Stderr:  SterrFile,
+	}
+	if err := slurmClient.Srun(ctx, stepConf); err != nil {
+		return err
+	}
+	cm.Pid = jobId
+	return nil
+}
+
+// setupBatchHeaders sets the headers of the job settings, and of the resources of the container which the
+// settings do not set
+func setupBatchHeaders(settings batch.JobSettings, resources *runtimeApi.LinuxContainerResources, jobConf *cmd.JobConfig) {
+	jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-J", settings.Name})
+	if settings.Array != "" {
+		// Every task writes its own output files
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-o", ArrayStdoutFile})
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-e", ArraySterrFile})
 		jobConf.Headers = append(jobConf.Headers,
-			cmd.JobConfigField{fmt.Sprintf("--gres=%s", c), ""})
+			cmd.JobConfigField{fmt.Sprintf("--array=%s", settings.Array), ""})
+	} else {
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-o", StdoutFile})
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-e", SterrFile})
 	}
-	if c, ok := cm.Environment["JOB_NUM_NODES"]; ok {
-		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-N", c})
+	if settings.Account != "" {
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{fmt.Sprintf("--account=%s", settings.Account), ""})
 	}
-	if c, ok := cm.Environment["JOB_NUM_CORES_NODE"]; ok {
-		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-c", c})
+	if settings.QOS != "" {
+		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{fmt.Sprintf("--qos=%s", settings.QOS), ""})
 	}
-	if c, ok := cm.Environment["JOB_NUM_CORES"]; ok {
-		jobConf.Headers = append(jobConf.Headers, cmd.JobConfigField{"-n", c})
+	jobConf.Headers = append(jobConf.Headers, resourceHeaders(settings, resources)...)
+	if settings.CustomConfig != "" {
+		jobConf.CustomHeaders = settings.CustomConfig
 	}
-	if c, ok := cm.Environment["JOB_NUM_TASKS_NODE"]; ok {
-		jobConf.Headers = append(jobConf.Headers,
-			cmd.JobConfigField{fmt.Sprintf("--ntasks-per-node=%s", c), ""})
+}
+
+// resourceHeaders returns the headers of the resources of the job settings and of the container, which
+// are set in every component of heterogeneous jobs
+func resourceHeaders(settings batch.JobSettings, resources *runtimeApi.LinuxContainerResources) []cmd.JobConfigField {
+	var headers []cmd.JobConfigField
+	if settings.Queue != "" {
+		headers = append(headers, cmd.JobConfigField{"-p", settings.Queue})
+	}
+	if settings.GPU != "" {
+		headers = append(headers, cmd.JobConfigField{fmt.Sprintf("--gres=%s", settings.GPU), ""})
+	}
+	if settings.Nodes != "" {
+		headers = append(headers, cmd.JobConfigField{"-N", settings.Nodes})
+	}
+	if settings.CoresPerNode != "" {
+		headers = append(headers, cmd.JobConfigField{"-c", settings.CoresPerNode})
+	}
+	if settings.Cores != "" {
+		headers = append(headers, cmd.JobConfigField{"-n", settings.Cores})
+	}
+	if settings.TasksPerNode != "" {
+		headers = append(headers, cmd.JobConfigField{fmt.Sprintf("--ntasks-per-node=%s", settings.TasksPerNode), ""})
 	}
-	if c, ok := cm.Environment["JOB_CUSTOM_CONFIG"]; ok {
-		jobConf.CustomHeaders = c
+	if settings.TimeLimit != "" {
+		headers = append(headers, cmd.JobConfigField{"-t", settings.TimeLimit})
 	}
+	if settings.Constraint != "" {
+		headers = append(headers, cmd.JobConfigField{fmt.Sprintf("--constraint=%s", settings.Constraint), ""})
+	}
+	for _, option := range resourceOptions(settings, resources) {
+		headers = append(headers, cmd.JobConfigField{option, ""})
+	}
+	return headers
 }
 
-func (s SlurmAdapter) StopContainer(cm *store.ContainerMetadata) error {
-	slurmClient, err := cmd.CreateCMD(cm)
+func (s SlurmAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	//Steps are stopped alone, the allocation is kept for the rest of the pod
+	if cm.Extra["AllocationJobId"] != "" {
+		slurmClient, err := cmd.CreateCMD(cm)
+		if err != nil {
+			return err
+		}
+		return slurmClient.StepCancel(ctx, cm.Extra["RMPath"])
+	}
+	slurmClient, err := s.jobClient(cm)
 	if err != nil {
 		return err
 	}
+	if cm.Extra["HetJobOffset"] != "" {
+		return s.stopComponent(ctx, slurmClient, cm)
+	}
 
 	jobRef := cmd.JobReference{JobId: int32(cm.Pid)}
-	return slurmClient.Scancel(jobRef)
+	return slurmClient.Scancel(ctx, jobRef)
 }
 
-func (s SlurmAdapter) ContainerStatus(cm *store.ContainerMetadata) error {
-	slurmClient, err := cmd.CreateCMD(cm)
+func (s SlurmAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	jobClient, err := s.jobClient(cm)
 	if err != nil {
 		return err
 	}
 
+	//The job was cancelled when its dependency could not be satisfied
+	if cm.Pid != 0 && cm.Reason == ReasonDependencyNeverSatisfied {
+		return nil
+	}
+
+	//The components wait until the last component of their heterogeneous job starts
+	var componentRef *cmd.JobReference
+	if cm.Extra["HetJobOffset"] != "" {
+		ref, ok, err := s.componentReference(cm)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			cm.State = runtimeApi.ContainerState_CONTAINER_CREATED
+			return nil
+		}
+		componentRef = ref
+	}
+
 	if cm.Pid != 0 {
 		jobRef := &cmd.JobReference{JobId: int32(cm.Pid)}
-		status, err := slurmClient.Sstatus(jobRef)
+		settings := batch.ParseJobSettings(cm)
+		var status *cmd.JobStatus
+		var tasks []int
+		if cm.Extra["AllocationJobId"] != "" {
+			status, err = stepStatus(ctx, cm)
+		} else if componentRef != nil {
+			jobRef = componentRef
+			status, err = componentStatus(ctx, jobClient, cm, jobRef)
+		} else if settings.Array != "" {
+			status, tasks, err = arrayStatus(ctx, jobClient, jobRef, settings)
+		} else {
+			status, err = jobClient.Sstatus(ctx, jobRef)
+		}
 		if err != nil {
 			return err
 		}
 
-		if status.JobState == "RUNNING" {
+		if status.Reason == ReasonDependencyNeverSatisfied {
+			// The job would be pending until it is cancelled, and it has no output
+			cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
+			cm.ExitCode = 1
+			cm.Reason = ReasonDependencyNeverSatisfied
+			cm.FinishedAt = time.Now().UnixNano()
+			if err := jobClient.Scancel(ctx, *jobRef); err != nil {
+				klog.Warningf("Job %d can not be cancelled: %v", jobRef.JobId, err)
+			}
+			return nil
+		} else if status.JobState == "RUNNING" {
 			cm.State = runtimeApi.ContainerState_CONTAINER_RUNNING
 		} else if status.JobState == "COMPLETED" || status.JobState == "COMPLETING" {
 			cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
@@ -158,12 +301,6 @@ func (s SlurmAdapter) ContainerStatus(cm *store.ContainerMetadata) error {
 			cm.ExitCode = 1
 			cm.Reason = "OOMKilled"
 			cm.FinishedAt = status.EndTime
-		} else if status.JobState == "FAILED" || status.JobState == "NODE_FAIL" {
-			cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
-			if status.ExitCode == 0 {
-				cm.ExitCode = 1
-			}
-			cm.Reason = status.JobState
 		} else if status.JobState == "FAILED" || status.JobState == "NODE_FAIL" {
 			cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
 			if status.ExitCode == 0 {
@@ -171,48 +308,77 @@ func (s SlurmAdapter) ContainerStatus(cm *store.ContainerMetadata) error {
 			} else {
 				cm.ExitCode = status.ExitCode
 			}
+			// The reason of job arrays tells which task failed
 			cm.Reason = status.JobState
-			cm.Reason = "ContainerCannotRun"
-			cm.FinishedAt = status.StarTime
+			if status.Reason != "" && status.Reason != "None" {
+				cm.Reason = status.Reason
+			}
+			cm.FinishedAt = status.EndTime
 		} else {
 			cm.State = runtimeApi.ContainerState_CONTAINER_CREATED
 		}
 		if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
-			RMStderrPath := getRMStderrPath(cm.Extra["RMPath"])
-			slurmClient.GetStderr(RMStderrPath)
-			RMStdoutPath := getRMStdoutPath(cm.Extra["RMPath"])
-			slurmClient.GetStdout(RMStdoutPath)
+			//Job output files are read through SSH with every transport
+			slurmClient, err := cmd.CreateCMD(cm)
+			if err != nil {
+				return err
+			}
+			if settings.Array != "" {
+				if len(tasks) == 0 {
+					return nil
+				}
+				// The output files of the tasks which started are read at once, in task order
+				slurmClient.GetStderr(ctx, strings.Join(getRMArrayPaths(cm.Extra["RMPath"], ArraySterrFile, tasks), " "))
+				slurmClient.GetStdout(ctx, strings.Join(getRMArrayPaths(cm.Extra["RMPath"], ArrayStdoutFile, tasks), " "))
+			} else {
+				RMStderrPath := getRMStderrPath(cm.Extra["RMPath"])
+				slurmClient.GetStderr(ctx, RMStderrPath)
+				RMStdoutPath := getRMStdoutPath(cm.Extra["RMPath"])
+				slurmClient.GetStdout(ctx, RMStdoutPath)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (s SlurmAdapter) ReopenContainerLog(cm *store.ContainerMetadata) error {
-	return fmt.Errorf("SLURMCRU: ReopenContainerLog not implemented")
+// stepStatus returns the status of the step of the container in the allocation of its pod
+func stepStatus(ctx context.Context, cm *store.ContainerMetadata) (*cmd.JobStatus, error) {
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return nil, err
+	}
+	return slurmClient.StepStatus(ctx, int32(cm.Pid), cm.Extra["RMPath"])
 }
 
-func (s SlurmAdapter) UpdateContainerResources(cm *store.ContainerMetadata) error {
-	return fmt.Errorf("SLURMCRU: UpdateContainerResources not implemented")
+// arrayStatus returns the status of the job array aggregated with the policy of the container, and the
+// tasks which started. With ArrayPolicyAny, the rest of the tasks are cancelled when any of them fails.
+func arrayStatus(ctx context.Context, jobClient cmd.JobClient, jobRef *cmd.JobReference, settings batch.JobSettings) (*cmd.JobStatus, []int, error) {
+	array, err := parseArray(settings.Array)
+	if err != nil {
+		return nil, nil, err
+	}
+	statuses, err := jobClient.SstatusArray(ctx, jobRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, started := aggregateArray(array, statuses, settings.ArrayPolicy)
+	if settings.ArrayPolicy == ArrayPolicyAny && status.JobState != "RUNNING" && status.JobState != "PENDING" &&
+		status.JobState != "COMPLETED" {
+		if err := jobClient.Scancel(ctx, *jobRef); err != nil {
+			klog.Warningf("Job array %d can not be cancelled: %v", jobRef.JobId, err)
+		}
+	}
+	return status, started, nil
 }
 
-func (s SlurmAdapter) buildStartCommand(c *store.ContainerMetadata) *cmd.JobConfig {
-	var command string
+func (s SlurmAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("SLURMCRU: ReopenContainerLog not implemented")
+}
 
+func (s SlurmAdapter) buildStartCommand(c *store.ContainerMetadata, settings batch.JobSettings) *cmd.JobConfig {
 	RMScriptPath := getRMScriptPath(c.Extra["RMPath"])
-	//MPI job
-	if _, ok := c.Environment["MPI_VERSION"]; ok {
-		command = "mpirun"
-		if flags, ok := c.Environment["MPI_FLAGS"]; ok {
-			command = fmt.Sprintf("%s %s ", command, flags)
-		}
-	}
-	command = fmt.Sprintf("%ssingularity exec %s", command, builder.GetRMImagePath(c, s.MountPath, s.ImageRemoteMount))
-
-	for _, s := range c.Command {
-		s = common.EscapeSpeciaCharacters(s)
-		command = fmt.Sprintf("%s %s", command, s)
-	}
+	command := settings.Command(c, builder.GetRMImagePath(c, s.MountPath, s.ImageRemoteMount))
 
 	jobConf := &cmd.JobConfig{
 		Command: command,
@@ -220,58 +386,20 @@ func (s SlurmAdapter) buildStartCommand(c *store.ContainerMetadata) *cmd.JobConf
 		Script:  RMScriptPath,
 	}
 
-	if c, ok := c.Environment["CLUSTER_CONFIG"]; ok {
-		jobConf.Prerun = c
+	if settings.ClusterConfig != "" {
+		jobConf.Prerun = settings.ClusterConfig
 	}
 
 	return jobConf
 }
 
-func filterEnvironmentVariables(c *store.ContainerMetadata) map[string]string {
-	jobEnv := make(map[string]string)
-	for k, v := range c.Environment {
-		if strings.HasPrefix(k, "KUBERNETES_") {
-			continue
-		}
-		if strings.HasPrefix(k, "CLUSTER_") {
-			continue
-		}
-		if strings.HasPrefix(k, "JOB_") {
-			continue
-		}
-		if strings.EqualFold(k, "MPI_FLAGS") {
-			continue
-		}
-		jobEnv[k] = v
-	}
-	return jobEnv
-}
-
-/*
-Execute command in remote via ssh
-String: command
-*/
-func logString(logPath, message string) {
-	stdoutWC, stderrWC, err := common.CreateContainerLoggers(logPath, false, 0)
-	if err != nil {
-		fmt.Printf("failed to start container logger: %s", err)
-	}
-
-	defer func() {
-		stderrWC.Close()
-		stdoutWC.Close()
-	}()
-	m := []byte(message)
-	stdoutWC.Write(m)
-}
-
-func ensureRMPathExists(cm *store.ContainerMetadata) error {
+func ensureRMPathExists(ctx context.Context, cm *store.ContainerMetadata) error {
 	cli, err := cmd.CreateCMD(cm)
 	if err != nil {
 		return err
 	}
 	cmd := fmt.Sprintf("mkdir -p %s", cm.Extra["RMPath"])
-	if _, err := cli.ExecCmd(cmd); err != nil {
+	if _, err := cli.ExecCmd(ctx, cmd); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,227 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"fmt"
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DependencyAnnotation is the prefix of the pod annotations which declare the containers that a container
+	// depends on, e.g. multicri.atrio.io/depends-on.compute: "preprocess" or "afterany:preprocess,afterok:setup"
+	DependencyAnnotation = "multicri.atrio.io/depends-on."
+	// SequentialAnnotation runs every container of the pod after the container started before it succeeds
+	SequentialAnnotation = "multicri.atrio.io/sequential"
+	// ReasonDependencyNeverSatisfied is the reason of the jobs whose dependency can never be satisfied
+	ReasonDependencyNeverSatisfied = "DependencyNeverSatisfied"
+	// DefaultDependencyType is the dependency type of the containers declared without type
+	DefaultDependencyType = "afterok"
+)
+
+// dependencyTypes are the Slurm dependency types supported between containers
+var dependencyTypes = map[string]bool{"after": true, "afterok": true, "afternotok": true, "afterany": true}
+
+// dependency is a container which a container depends on, with the Slurm dependency type
+type dependency struct {
+	kind      string
+	container string
+}
+
+// containerDependencies returns the dependencies of the container declared in the pod annotations
+func containerDependencies(annotations map[string]string, name string) ([]dependency, error) {
+	value, ok := annotations[DependencyAnnotation+name]
+	if !ok {
+		return nil, nil
+	}
+	var dependencies []dependency
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		d := dependency{kind: DefaultDependencyType, container: item}
+		if i := strings.Index(item, ":"); i >= 0 {
+			d.kind, d.container = item[:i], item[i+1:]
+		}
+		if !dependencyTypes[d.kind] {
+			return nil, adapters.InvalidArgumentError("Wrong dependency type %s of container %s in %s%s", d.kind, name,
+				DependencyAnnotation, name)
+		}
+		if d.container == "" || d.container == name {
+			return nil, adapters.InvalidArgumentError("Wrong dependency %q of container %s in %s%s", item, name,
+				DependencyAnnotation, name)
+		}
+		dependencies = append(dependencies, d)
+	}
+	return dependencies, nil
+}
+
+// validateDependencies returns an error when the dependencies of the container are wrong, or they lead
+// back to the container, so its job would never run
+func validateDependencies(annotations map[string]string, name string) error {
+	if value, ok := annotations[SequentialAnnotation]; ok {
+		if _, err := strconv.ParseBool(value); err != nil {
+			return adapters.InvalidArgumentError("Wrong value of %s: %s", SequentialAnnotation, value)
+		}
+	}
+	visited := map[string]bool{}
+	path := []string{name}
+	var visit func(container string) error
+	visit = func(container string) error {
+		dependencies, err := containerDependencies(annotations, container)
+		if err != nil {
+			return err
+		}
+		for _, d := range dependencies {
+			if d.container == name {
+				return adapters.InvalidArgumentError("Circular dependency of container %s: %s -> %s", name,
+					strings.Join(path, " -> "), name)
+			}
+			if visited[d.container] {
+				continue
+			}
+			visited[d.container] = true
+			path = append(path, d.container)
+			if err := visit(d.container); err != nil {
+				return err
+			}
+			path = path[:len(path)-1]
+		}
+		return nil
+	}
+	return visit(name)
+}
+
+// hasDependencies returns whether the pod annotations declare dependencies between its containers
+func hasDependencies(annotations map[string]string) bool {
+	for key := range annotations {
+		if strings.HasPrefix(key, DependencyAnnotation) {
+			return true
+		}
+	}
+	sequential, _ := strconv.ParseBool(annotations[SequentialAnnotation])
+	return sequential
+}
+
+// podJob is the job of a container of a pod
+type podJob struct {
+	container string
+	jobId     int
+}
+
+// podJobs are the jobs of the containers of the running pods, by pod sandbox id, in start order. The
+// dependencies between containers are resolved to their jobs. A nil podJobs has no jobs.
+type podJobs struct {
+	lock sync.Mutex
+	jobs map[string][]podJob
+}
+
+func newPodJobs() *podJobs {
+	return &podJobs{jobs: make(map[string][]podJob)}
+}
+
+// add sets the job of the container. A restarted container replaces its previous job.
+func (p *podJobs) add(sandboxID, container string, jobId int) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var jobs []podJob
+	for _, job := range p.jobs[sandboxID] {
+		if job.container != container {
+			jobs = append(jobs, job)
+		}
+	}
+	p.jobs[sandboxID] = append(jobs, podJob{container: container, jobId: jobId})
+}
+
+// restore adds the jobs of the stored containers, in start order. The steps of the pod allocation and the
+// components of heterogeneous jobs are not dependencies.
+func (p *podJobs) restore(containers []*store.ContainerMetadata) {
+	started := append([]*store.ContainerMetadata(nil), containers...)
+	sort.SliceStable(started, func(i, j int) bool { return started[i].StartedAt < started[j].StartedAt })
+	for _, cm := range started {
+		if cm.Pid != 0 && cm.Extra["AllocationJobId"] == "" && cm.Extra["HetJobOffset"] == "" {
+			p.add(cm.PodSandbox.ID, cm.Name, cm.Pid)
+		}
+	}
+}
+
+// lookup returns the job of the container
+func (p *podJobs) lookup(sandboxID, container string) (int, bool) {
+	if p == nil {
+		return 0, false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, job := range p.jobs[sandboxID] {
+		if job.container == container {
+			return job.jobId, true
+		}
+	}
+	return 0, false
+}
+
+// last returns the job of the last container started in the pod
+func (p *podJobs) last(sandboxID string) (podJob, bool) {
+	if p == nil {
+		return podJob{}, false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	jobs := p.jobs[sandboxID]
+	if len(jobs) == 0 {
+		return podJob{}, false
+	}
+	return jobs[len(jobs)-1], true
+}
+
+func (p *podJobs) remove(sandboxID string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.jobs, sandboxID)
+}
+
+// jobDependency returns the Slurm dependency specification of the container, from the jobs of the containers
+// which it depends on. Containers can not start before their dependencies.
+func (s SlurmAdapter) jobDependency(cm *store.ContainerMetadata) (string, error) {
+	annotations := cm.PodSandbox.Config.Annotations
+	dependencies, err := containerDependencies(annotations, cm.Name)
+	if err != nil {
+		return "", err
+	}
+	var conditions []string
+	if sequential, _ := strconv.ParseBool(annotations[SequentialAnnotation]); sequential && len(dependencies) == 0 {
+		if job, ok := s.jobs.last(cm.PodSandbox.ID); ok && job.container != cm.Name {
+			conditions = append(conditions, fmt.Sprintf("%s:%d", DefaultDependencyType, job.jobId))
+		}
+	}
+	for _, d := range dependencies {
+		jobId, ok := s.jobs.lookup(cm.PodSandbox.ID, d.container)
+		if !ok {
+			return "", adapters.UnavailableError("Container %s depends on container %s, which is not started",
+				cm.Name, d.container)
+		}
+		conditions = append(conditions, fmt.Sprintf("%s:%d", d.kind, jobId))
+	}
+	return strings.Join(conditions, ","), nil
+}
@@ -15,19 +15,20 @@
 package slurm
 
 import (
+	"multi-cri/pkg/cri/adapters"
 	"multi-cri/pkg/cri/store"
-	"fmt"
 
+	"golang.org/x/net/context"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
-func (s SlurmAdapter) ExecSync(cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
-	return nil, fmt.Errorf("ExecSync not implemented for Slurm Adapter")
+func (s SlurmAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeApi.ExecSyncResponse, error) {
+	return nil, adapters.UnimplementedError("ExecSync not implemented for Slurm Adapter")
 }
-func (s SlurmAdapter) Exec(cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
-	return nil, fmt.Errorf("Exec not implemented for Slurm Adapter")
+func (s SlurmAdapter) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
+	return nil, adapters.UnimplementedError("Exec not implemented for Slurm Adapter")
 }
 
-func (s SlurmAdapter) Attach(cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
-	return nil, fmt.Errorf("Attach not implemented for Slurm Adapter")
+func (s SlurmAdapter) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeApi.AttachRequest) (*runtimeApi.AttachResponse, error) {
+	return nil, adapters.UnimplementedError("Attach not implemented for Slurm Adapter")
 }
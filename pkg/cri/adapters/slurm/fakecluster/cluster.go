@@ -0,0 +1,426 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakecluster is an in-process Slurm cluster reachable through SSH, to test the Slurm adapter without
+// a real cluster. It emulates sbatch, with job arrays, dependencies and heterogeneous jobs, scontrol show
+// jobid and update, sacct, scancel, mkdir, cat, stat and scp in a temporary home directory, other commands run
+// with bash, and the jobs run as local processes. squeue and srun are scripts, which read the job states from
+// the state directory:
+//
+//	cluster, err := fakecluster.Start(fakecluster.Config{})
+//	defer cluster.Close()
+//	client, err := cmd.NewSlurmCMD(cluster.User, cluster.Host, cluster.Port, logPath, "", cluster.Password)
+//
+// The tests of other batch schedulers add their commands with AddBuiltin: the submit and cancel commands of
+// SubmitCommand and CancelCommand, and the commands which show the jobs from Job.
+package fakecluster
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Job states
+const (
+	StatePending   = "PENDING"
+	StateRunning   = "RUNNING"
+	StateCompleted = "COMPLETED"
+	StateFailed    = "FAILED"
+	StateCancelled = "CANCELLED"
+	StateTimeout   = "TIMEOUT"
+	StateNodeFail  = "NODE_FAIL"
+)
+
+// Reasons of the pending jobs which wait for their dependency
+const (
+	ReasonDependency               = "Dependency"
+	ReasonDependencyNeverSatisfied = "DependencyNeverSatisfied"
+)
+
+const (
+	defaultUser     = "slurm"
+	defaultPassword = "slurm"
+	// singularityScript replaces singularity in the cluster: exec runs the command without image,
+	// and pull creates an empty image
+	singularityScript = `#!/bin/sh
+case "$1" in
+exec) shift 2; exec "$@" ;;
+pull) touch "$2" ;;
+*) echo "singularity: $1 is not supported by the fake cluster" >&2; exit 1 ;;
+esac
+`
+	// squeueScript shows the state of a job, as squeue -h -j <job id> -o %T does
+	squeueScript = `#!/bin/sh
+job=
+while [ $# -gt 0 ]; do
+	case "$1" in
+	-j) job=$2; shift ;;
+	--jobs=*) job=${1#--jobs=} ;;
+	esac
+	shift
+done
+cat "$FAKECLUSTER_STATE/$job" 2>/dev/null || { echo "slurm_load_jobs error: Invalid job id specified" >&2; exit 1; }
+`
+	// srunScript runs a step in a running job, which is killed when the job ends. The step of --het-group
+	// runs in that component of a heterogeneous job, whose id follows the id of the job. Only the options
+	// with the --option=value format are supported.
+	srunScript = `#!/bin/bash
+job=$SLURM_JOB_ID
+group=0
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--jobid=*) job=${1#--jobid=} ;;
+	--het-group=*) group=${1#--het-group=} ;;
+	--output=*) output=${1#--output=} ;;
+	--error=*) error=${1#--error=} ;;
+	-*) ;;
+	*) break ;;
+	esac
+	shift
+done
+job=$((job + group))
+state=$(cat "$FAKECLUSTER_STATE/$job" 2>/dev/null)
+if [ "$state" != RUNNING ]; then
+	echo "srun: error: Unable to confirm allocation for job $job: ${state:-Invalid job id specified}" >&2
+	exit 1
+fi
+[ -z "$output" ] || exec >"$output"
+[ -z "$error" ] || exec 2>"$error"
+SLURM_JOB_ID=$job "$@" &
+step=$!
+trap 'kill -TERM $step 2>/dev/null' TERM INT
+while kill -0 $step 2>/dev/null; do
+	if [ "$(cat "$FAKECLUSTER_STATE/$job")" != RUNNING ]; then
+		kill -KILL $step
+		echo "srun: error: job $job ended" >&2
+	fi
+	sleep 0.1
+done
+wait $step
+`
+)
+
+// Plan sets the state transitions of a job. The job is PENDING for Pending, or until it is cancelled when
+// Hold is set, and while every slot of the cluster is busy. Then it runs its script, and it ends COMPLETED
+// or FAILED with the exit code of the script, TIMEOUT when it runs for longer than TimeLimit, or in State,
+// such as NODE_FAIL, after running for StateAfter.
+type Plan struct {
+	Pending    time.Duration
+	Hold       bool
+	TimeLimit  time.Duration
+	State      string
+	StateAfter time.Duration
+}
+
+// Config configures the cluster. The zero value is a cluster without limits.
+type Config struct {
+	// User and Password are the SSH credentials, slurm and slurm by default
+	User     string
+	Password string
+	// Slots is the number of jobs running at the same time, unlimited when it is 0
+	Slots int
+	// Partitions are the valid partitions. Any partition is valid when it is empty.
+	Partitions []string
+	// MinJobAge is the time finished jobs are shown by scontrol, and then they are only shown by sacct.
+	// They are always shown when it is 0.
+	MinJobAge time.Duration
+	// NoAccounting makes sacct fail, as in the clusters without accounting storage
+	NoAccounting bool
+	// Plans are the plans of the jobs by job name. The other jobs follow DefaultPlan.
+	Plans       map[string]Plan
+	DefaultPlan Plan
+}
+
+// Job is a job submitted to the cluster
+type Job struct {
+	ID         int
+	Name       string
+	Partition  string
+	WorkDir    string
+	Script     string
+	Stdout     string
+	Stderr     string
+	State      string
+	ExitCode   int
+	Signal     int
+	SubmitTime time.Time
+	StartTime  time.Time
+	EndTime    time.Time
+	// ArrayJobID and ArrayTaskID are set in the tasks of job arrays. The array job id is the id of its
+	// first task.
+	ArrayJobID  int
+	ArrayTaskID int
+	// Dependency is the dependency specification of the job, e.g. "afterok:1:2,afterany:3", and Reason
+	// is the reason of the pending jobs which wait for it
+	Dependency string
+	Reason     string
+	// HetJobID and HetJobOffset are set in the components of heterogeneous jobs. The heterogeneous job id
+	// is the id of its first component, which runs the script, and the other components follow its state
+	// until they are cancelled.
+	HetJobID     int
+	HetJobOffset int
+	// TimeLimit, NumNodes, MinCPUsNode and MinMemoryNode are the resources of the job, as set by sbatch and
+	// scontrol update. They do not limit the job, which follows its plan.
+	TimeLimit     string
+	NumNodes      int
+	MinCPUsNode   int
+	MinMemoryNode string
+
+	env []string
+	// limit is shared by the tasks of an array with a limit of running tasks
+	limit chan struct{}
+	// components are the other components of a heterogeneous job, in its first component
+	components []*Job
+	plan       Plan
+	cancel     chan struct{}
+	cancelled  bool
+}
+
+// finished returns whether the job is in a final state
+func (j *Job) finished() bool {
+	return j.State != StatePending && j.State != StateRunning
+}
+
+// Cluster is a running fake cluster
+type Cluster struct {
+	// Host, Port, User and Password are the SSH credentials of the cluster
+	Host     string
+	Port     string
+	User     string
+	Password string
+	// Home is the home directory of the user, where the commands start
+	Home string
+
+	config   Config
+	root     string
+	bin      string
+	listener net.Listener
+	ssh      *ssh.ServerConfig
+	slots    chan struct{}
+	running  sync.WaitGroup
+
+	lock     sync.Mutex
+	jobs     map[int]*Job
+	lastId   int
+	builtins map[string]Builtin
+}
+
+// Session is the shell session of a command added with AddBuiltin
+type Session struct {
+	// Dir is the current directory, and Env the variables in KEY=value format
+	Dir    string
+	Env    []string
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Builtin emulates a command in process, and returns its exit code
+type Builtin func(s Session, args []string) int
+
+// Start starts the cluster, listening in a random local port
+func Start(config Config) (*Cluster, error) {
+	if config.User == "" {
+		config.User = defaultUser
+	}
+	if config.Password == "" {
+		config.Password = defaultPassword
+	}
+	root, err := ioutil.TempDir("", "fakecluster")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{
+		User:     config.User,
+		Password: config.Password,
+		Home:     filepath.Join(root, "home", config.User),
+		config:   config,
+		root:     root,
+		bin:      filepath.Join(root, "bin"),
+		jobs:     make(map[int]*Job),
+		builtins: make(map[string]Builtin),
+	}
+	if config.Slots > 0 {
+		c.slots = make(chan struct{}, config.Slots)
+	}
+	if err := c.setup(); err != nil {
+		os.RemoveAll(root)
+		return nil, err
+	}
+	go c.serve()
+	return c, nil
+}
+
+func (c *Cluster) setup() error {
+	for _, dir := range []string{c.Home, c.bin, filepath.Join(c.root, "state")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for name, script := range map[string]string{"singularity": singularityScript, "squeue": squeueScript, "srun": srunScript} {
+		if err := c.AddCommand(name, script); err != nil {
+			return err
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return err
+	}
+	c.ssh = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() != c.User || string(password) != c.Password {
+				return nil, fmt.Errorf("wrong credentials of user %s", conn.User())
+			}
+			return nil, nil
+		},
+	}
+	c.ssh.AddHostKey(signer)
+	if c.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		return err
+	}
+	c.Host, c.Port, err = net.SplitHostPort(c.listener.Addr().String())
+	return err
+}
+
+// Close stops the cluster, cancelling its jobs, and removes its files
+func (c *Cluster) Close() error {
+	err := c.listener.Close()
+	c.lock.Lock()
+	for _, job := range c.jobs {
+		c.cancelJob(job)
+	}
+	c.lock.Unlock()
+	c.running.Wait()
+	os.RemoveAll(c.root)
+	return err
+}
+
+// Credentials returns the CLUSTER_* variables with which the containers connect to the cluster
+func (c *Cluster) Credentials() map[string]string {
+	return map[string]string{"CLUSTER_USERNAME": c.User, "CLUSTER_PASSWORD": c.Password, "CLUSTER_HOST": c.Host,
+		"CLUSTER_PORT": c.Port}
+}
+
+// AddCommand adds an executable script to the PATH of the commands and jobs of the cluster
+func (c *Cluster) AddCommand(name, script string) error {
+	return ioutil.WriteFile(filepath.Join(c.bin, name), []byte(script), 0755)
+}
+
+// AddBuiltin adds a command emulated in process. Like the other emulated commands, it runs in the command
+// lines without pipes or lists, whose only redirection is the standard input, e.g. bsub < batch.sh.
+func (c *Cluster) AddBuiltin(name string, command Builtin) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.builtins[name] = command
+}
+
+// Submit queues a job which runs the script of the job in its WorkDir with the variables of env, and returns
+// its id. Only the Name, Script, WorkDir, Stdout and Stderr of the job are used, and %j is replaced by the id
+// in the output paths, which are relative to WorkDir. Stdout is %j.out when it is not set. The job follows the
+// plan of its name.
+func (c *Cluster) Submit(job Job, env []string) int {
+	if job.Stdout == "" {
+		job.Stdout = "%j.out"
+	}
+	return c.submit(&Job{Name: job.Name, Script: job.Script, WorkDir: job.WorkDir, Stdout: job.Stdout,
+		Stderr: job.Stderr, State: StatePending, NumNodes: 1, SubmitTime: time.Now(),
+		env: append([]string{}, env...), cancel: make(chan struct{})})
+}
+
+// Cancel cancels the job, and returns false when it does not exist. Finished jobs are not changed.
+func (c *Cluster) Cancel(id int) bool {
+	return c.cancel(strconv.Itoa(id))
+}
+
+// Directives returns the options of the directives of the script with the prefix, e.g. "#PBS", before its
+// first command
+func Directives(script, prefix string) ([]string, error) {
+	f, err := os.Open(script)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var options []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			break
+		}
+		if strings.HasPrefix(line, prefix+" ") {
+			options = append(options, splitWords(strings.TrimPrefix(line, prefix))...)
+		}
+	}
+	return options, nil
+}
+
+// Job returns a copy of the job
+func (c *Cluster) Job(id int) (Job, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	job, ok := c.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// Jobs returns a copy of the jobs, sorted by id
+func (c *Cluster) Jobs() []Job {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var jobs []Job
+	for id := 1; id <= c.lastId; id++ {
+		if job, ok := c.jobs[id]; ok {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// WaitJob waits until the job is finished, and returns it
+func (c *Cluster) WaitJob(id int, timeout time.Duration) (Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, ok := c.Job(id)
+		if !ok {
+			return job, fmt.Errorf("job %d not found", id)
+		}
+		if job.finished() {
+			return job, nil
+		}
+		if time.Now().After(deadline) {
+			return job, fmt.Errorf("job %d is %s after %s", id, job.State, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
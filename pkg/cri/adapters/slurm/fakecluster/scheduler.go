@@ -0,0 +1,118 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Scheduler describes the submit and cancel commands of a batch scheduler, which SubmitCommand and
+// CancelCommand emulate, so the tests of its adapter only add the commands which show the jobs
+type Scheduler struct {
+	// Directives is the prefix of the directives of the job scripts, e.g. "#PBS"
+	Directives string
+	// Name, Stdout and Stderr are the options of the job name and output files, e.g. "-N", "-o" and "-e",
+	// followed by their values or joined to them by "=", e.g. "--job-name=test"
+	Name, Stdout, Stderr string
+	// Submitted is the output of the submit command with the job id, e.g. "%d.server\n"
+	Submitted string
+	// WorkDirEnv is the variable with the submission directory of the jobs, e.g. PBS_O_WORKDIR
+	WorkDirEnv string
+	// Unknown is the error of the cancel command with the unknown job ids, e.g. "qdel: Unknown Job Id %s\n",
+	// which exits with UnknownStatus
+	Unknown       string
+	UnknownStatus int
+}
+
+// ScriptJob returns the job of the script in dir, named after the script, with the name and output files
+// of the options of args, the command line ones, and of the directives of the script
+func (s Scheduler) ScriptJob(dir, script string, args []string) (Job, error) {
+	path := filepath.Join(dir, script)
+	directives, err := Directives(path, s.Directives)
+	if err != nil {
+		return Job{}, err
+	}
+	job := Job{Name: filepath.Base(path), Script: path, WorkDir: dir}
+	options := append(append([]string{}, args...), directives...)
+	for i, option := range options {
+		value := ""
+		if parts := strings.SplitN(option, "=", 2); len(parts) == 2 {
+			option, value = parts[0], parts[1]
+		} else if i+1 < len(options) {
+			value = options[i+1]
+		}
+		switch option {
+		case s.Name:
+			job.Name = value
+		case s.Stdout:
+			job.Stdout = value
+		case s.Stderr:
+			job.Stderr = value
+		}
+	}
+	return job, nil
+}
+
+// SubmitCommand returns the submit command of the scheduler, which submits the job script of its last
+// argument, or of its standard input when it has no arguments, e.g. bsub < batch.sh
+func (c *Cluster) SubmitCommand(scheduler Scheduler) Builtin {
+	return func(s Session, args []string) int {
+		var script string
+		if len(args) > 0 {
+			script, args = args[len(args)-1], args[:len(args)-1]
+		} else {
+			f, err := ioutil.TempFile(s.Dir, "stdin")
+			if err == nil {
+				_, err = f.ReadFrom(s.Stdin)
+				f.Close()
+			}
+			if err != nil {
+				fmt.Fprintf(s.Stderr, "%v\n", err)
+				return 1
+			}
+			script = filepath.Base(f.Name())
+		}
+		job, err := scheduler.ScriptJob(s.Dir, script, args)
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "%s: No such file or directory\n", script)
+			return 1
+		}
+		env := s.Env
+		if scheduler.WorkDirEnv != "" {
+			env = append(append([]string{}, env...), scheduler.WorkDirEnv+"="+s.Dir)
+		}
+		fmt.Fprintf(s.Stdout, scheduler.Submitted, c.Submit(job, env))
+		return 0
+	}
+}
+
+// CancelCommand returns the cancel command of the scheduler, which cancels the job of its first argument
+func (c *Cluster) CancelCommand(scheduler Scheduler) Builtin {
+	return func(s Session, args []string) int {
+		if len(args) == 0 {
+			fmt.Fprintf(s.Stderr, scheduler.Unknown, "")
+			return scheduler.UnknownStatus
+		}
+		if id, _ := strconv.Atoi(args[0]); !c.Cancel(id) {
+			fmt.Fprintf(s.Stderr, scheduler.Unknown, args[0])
+			return scheduler.UnknownStatus
+		}
+		return 0
+	}
+}
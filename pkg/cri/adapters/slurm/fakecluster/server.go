@@ -0,0 +1,87 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecluster
+
+import (
+	"io"
+	"net"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// serve accepts the SSH connections until the listener is closed
+func (c *Cluster) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			return
+		}
+		go c.handleConn(conn)
+	}
+}
+
+func (c *Cluster) handleConn(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, c.ssh)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go c.handleSession(channel, requests)
+	}
+}
+
+// handleSession runs the command of an exec request. The output of the command is merged, like in sshd,
+// when the session has a pseudo terminal.
+func (c *Cluster) handleSession(channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	tty := false
+	for request := range requests {
+		switch request.Type {
+		case "pty-req":
+			tty = true
+			request.Reply(true, nil)
+		case "env":
+			request.Reply(true, nil)
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+				request.Reply(false, nil)
+				return
+			}
+			request.Reply(true, nil)
+			// Signals are not supported, the client closes the session to abort the command
+			go ssh.DiscardRequests(requests)
+			var stderr io.Writer = channel.Stderr()
+			if tty {
+				stderr = channel
+			}
+			code := c.newShell(channel, channel, stderr).run(payload.Command)
+			channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(code)}))
+			return
+		default:
+			request.Reply(false, nil)
+		}
+	}
+}
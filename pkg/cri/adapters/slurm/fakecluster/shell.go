@@ -0,0 +1,409 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecluster
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// builtins are the commands emulated by the cluster
+var builtins map[string]func(s *shell, args []string) int
+
+func init() {
+	builtins = map[string]func(s *shell, args []string) int{
+		"cd":       (*shell).cd,
+		"export":   (*shell).export,
+		"source":   (*shell).source,
+		".":        (*shell).source,
+		"mkdir":    (*shell).mkdir,
+		"cat":      (*shell).cat,
+		"stat":     (*shell).stat,
+		"scp":      (*shell).scp,
+		"sbatch":   (*shell).sbatch,
+		"scontrol": (*shell).scontrol,
+		"sacct":    (*shell).sacct,
+		"scancel":  (*shell).scancel,
+	}
+}
+
+// shell runs the commands of a session. Scripts are interpreted line by line: the emulated commands run
+// in process and the other lines run with bash. Pipes, lists and redirections other than the standard input
+// of an emulated command always run with bash.
+type shell struct {
+	cluster *Cluster
+	dir     string
+	env     map[string]string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func (c *Cluster) newShell(stdin io.Reader, stdout, stderr io.Writer) *shell {
+	env := map[string]string{"HOME": c.Home, "USER": c.User, "PATH": c.bin + ":" + os.Getenv("PATH"),
+		"FAKECLUSTER_STATE": filepath.Join(c.root, "state")}
+	return &shell{
+		cluster: c,
+		dir:     c.Home,
+		env:     env,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+	}
+}
+
+// run runs the command line and returns its exit code
+func (s *shell) run(line string) int {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return 0
+	}
+	if strings.ContainsAny(line, "|;&>`") || strings.Contains(line, "$(") || strings.Count(line, "<") > 1 {
+		return s.bash(line)
+	}
+	if i := strings.Index(line, "<"); i >= 0 {
+		return s.redirect(line[:i], line[i+1:], line)
+	}
+	words := splitWords(os.Expand(line, s.getenv))
+	if len(words) == 0 {
+		return 0
+	}
+	if command, ok := s.command(words[0]); ok {
+		return command(s, words[1:])
+	}
+	if len(words) == 1 && strings.Contains(words[0], "=") {
+		s.export(words)
+		return 0
+	}
+	if info, err := os.Stat(s.path(words[0])); err == nil && info.Mode().IsRegular() {
+		return s.subshell().script(s.path(words[0]))
+	}
+	return s.bash(line)
+}
+
+// command returns the emulated command of the name
+func (s *shell) command(name string) (func(s *shell, args []string) int, bool) {
+	if command, ok := builtins[name]; ok {
+		return command, true
+	}
+	s.cluster.lock.Lock()
+	builtin, ok := s.cluster.builtins[name]
+	s.cluster.lock.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return func(s *shell, args []string) int {
+		return builtin(Session{Dir: s.dir, Env: s.environ(), Stdin: s.stdin, Stdout: s.stdout, Stderr: s.stderr}, args)
+	}, true
+}
+
+// redirect runs the emulated command with the standard input read from the file, or the line with bash
+// when it is not an emulated command
+func (s *shell) redirect(command, input, line string) int {
+	words := splitWords(os.Expand(command, s.getenv))
+	files := splitWords(os.Expand(input, s.getenv))
+	if len(words) == 0 || len(files) != 1 {
+		return s.bash(line)
+	}
+	run, ok := s.command(words[0])
+	if !ok {
+		return s.bash(line)
+	}
+	f, err := os.Open(s.path(files[0]))
+	if err != nil {
+		return s.fail(1, "bash: %s: No such file or directory", files[0])
+	}
+	defer f.Close()
+	stdin := s.stdin
+	s.stdin = f
+	defer func() { s.stdin = stdin }()
+	return run(s, words[1:])
+}
+
+// script runs the lines of the script, and returns the exit code of the last one
+func (s *shell) script(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return s.fail(127, "bash: %s: No such file or directory", path)
+	}
+	defer f.Close()
+	code := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		code = s.run(scanner.Text())
+	}
+	return code
+}
+
+// subshell returns a shell whose directory and variables do not change this one
+func (s *shell) subshell() *shell {
+	sub := *s
+	sub.env = make(map[string]string)
+	for key, value := range s.env {
+		sub.env[key] = value
+	}
+	return &sub
+}
+
+func (s *shell) bash(line string) int {
+	cmd := osexec.Command("bash", "-c", line)
+	cmd.Dir = s.dir
+	cmd.Env = s.environ()
+	cmd.Stdout = s.stdout
+	cmd.Stderr = s.stderr
+	return exitCode(cmd.Run())
+}
+
+func (s *shell) getenv(key string) string {
+	return s.env[key]
+}
+
+// environ returns the variables in KEY=value format
+func (s *shell) environ() []string {
+	var env []string
+	for key, value := range s.env {
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// path returns the absolute path of a path relative to the current directory
+func (s *shell) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(s.dir, path)
+}
+
+// fail writes the error message and returns the exit code
+func (s *shell) fail(code int, format string, a ...interface{}) int {
+	fmt.Fprintf(s.stderr, format+"\n", a...)
+	return code
+}
+
+func (s *shell) cd(args []string) int {
+	dir := s.env["HOME"]
+	if len(args) > 0 {
+		dir = s.path(args[0])
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return s.fail(1, "bash: cd: %s: No such file or directory", args[0])
+	}
+	s.dir = dir
+	return 0
+}
+
+func (s *shell) export(args []string) int {
+	for _, arg := range args {
+		if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 {
+			s.env[parts[0]] = parts[1]
+		}
+	}
+	return 0
+}
+
+func (s *shell) source(args []string) int {
+	if len(args) == 0 {
+		return s.fail(2, "bash: source: filename argument required")
+	}
+	return s.script(s.path(args[0]))
+}
+
+func (s *shell) mkdir(args []string) int {
+	parents := false
+	code := 0
+	for _, arg := range args {
+		if arg == "-p" {
+			parents = true
+			continue
+		}
+		var err error
+		if parents {
+			err = os.MkdirAll(s.path(arg), 0755)
+		} else {
+			err = os.Mkdir(s.path(arg), 0755)
+		}
+		if err != nil {
+			code = s.fail(1, "mkdir: cannot create directory '%s': %v", arg, err)
+		}
+	}
+	return code
+}
+
+func (s *shell) cat(args []string) int {
+	code := 0
+	for _, arg := range args {
+		data, err := ioutil.ReadFile(s.path(arg))
+		if err != nil {
+			code = s.fail(1, "cat: %s: No such file or directory", arg)
+			continue
+		}
+		s.stdout.Write(data)
+	}
+	return code
+}
+
+// stat supports the %s (size) and %a (permissions) formats of --print and -c
+func (s *shell) stat(args []string) int {
+	format, newLine := "%s", true
+	var files []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case strings.HasPrefix(args[i], "--print="):
+			format, newLine = strings.TrimPrefix(args[i], "--print="), false
+		case args[i] == "-c" && i+1 < len(args):
+			format = args[i+1]
+			i++
+		default:
+			files = append(files, args[i])
+		}
+	}
+	code := 0
+	for _, file := range files {
+		info, err := os.Stat(s.path(file))
+		if err != nil {
+			code = s.fail(1, "stat: cannot stat '%s': No such file or directory", file)
+			continue
+		}
+		output := strings.Replace(format, "%s", strconv.FormatInt(info.Size(), 10), -1)
+		output = strings.Replace(output, "%a", strconv.FormatUint(uint64(info.Mode().Perm()), 8), -1)
+		if newLine {
+			output += "\n"
+		}
+		fmt.Fprint(s.stdout, output)
+	}
+	return code
+}
+
+// scp is the sink of the scp protocol, scp -t. It receives regular files, which are written in the
+// destination directory or in the destination path.
+func (s *shell) scp(args []string) int {
+	destination := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			destination = s.path(arg)
+		}
+	}
+	if destination == "" {
+		return s.fail(1, "scp: destination is required")
+	}
+	reader := bufio.NewReader(s.stdin)
+	s.stdout.Write([]byte{0})
+	for {
+		header, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return 0
+		}
+		if err != nil {
+			return s.fail(1, "scp: %v", err)
+		}
+		var mode os.FileMode
+		var size int64
+		var name string
+		if _, err := fmt.Sscanf(header, "C%o %d %s\n", &mode, &size, &name); err != nil {
+			return s.fail(1, "scp: protocol error: %q is not supported", header)
+		}
+		path := destination
+		if info, err := os.Stat(destination); err == nil && info.IsDir() {
+			path = filepath.Join(destination, name)
+		}
+		s.stdout.Write([]byte{0})
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return s.fail(1, "scp: %v", err)
+		}
+		reader.ReadByte()
+		if err := ioutil.WriteFile(path, data, mode); err != nil {
+			return s.fail(1, "scp: %s: %v", path, err)
+		}
+		s.stdout.Write([]byte{0})
+	}
+}
+
+// splitWords splits the command line in words, removing the quotes
+func splitWords(line string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else if r == '\\' && quote == '"' {
+				escaped = true
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// exitCode returns the exit code of a command, which is 128 plus the signal number when it was killed
+func exitCode(err error) int {
+	code, signal := waitStatus(err)
+	if signal != 0 {
+		return 128 + signal
+	}
+	return code
+}
+
+// waitStatus returns the exit code and the signal which killed the command
+func waitStatus(err error) (int, int) {
+	if err == nil {
+		return 0, 0
+	}
+	if exitErr, ok := err.(*osexec.ExitError); ok {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			if status.Signaled() {
+				return 0, int(status.Signal())
+			}
+			return status.ExitStatus(), 0
+		}
+	}
+	return 127, 0
+}
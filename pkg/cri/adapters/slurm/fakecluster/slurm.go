@@ -0,0 +1,808 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// timeLayout is the format of the times shown by scontrol and sacct
+const timeLayout = "2006-01-02T15:04:05"
+
+// sacctFields are the fields shown by sacct without --format
+var sacctFields = []string{"jobid", "jobname", "partition", "state", "exitcode"}
+
+// sbatch submits the job script. The options are read from the arguments and from the #SBATCH directives
+// before the first command of the script, where hetjob separates the options of the components of a
+// heterogeneous job.
+func (s *shell) sbatch(args []string) int {
+	if len(args) == 0 {
+		return s.fail(1, "sbatch: error: Batch script is empty!")
+	}
+	script := s.path(args[len(args)-1])
+	options, err := Directives(script, "#SBATCH")
+	if err != nil {
+		return s.fail(1, "sbatch: error: Unable to open file %s", args[len(args)-1])
+	}
+	options = append(append([]string{}, args[:len(args)-1]...), options...)
+	job := &Job{Script: script, WorkDir: s.dir, Name: filepath.Base(script), Stdout: "slurm-%j.out", State: StatePending,
+		NumNodes: 1, SubmitTime: time.Now(), env: s.environ(), cancel: make(chan struct{})}
+	// The components of heterogeneous jobs are copies of the job with their own options
+	groups := [][]string{{}}
+	for _, option := range options {
+		if option == "hetjob" {
+			groups = append(groups, []string{})
+			continue
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], option)
+	}
+	array := parseOptions(job, groups[0])
+	var components []*Job
+	for _, options := range groups[1:] {
+		component := *job
+		component.Partition = ""
+		component.cancel = make(chan struct{})
+		if spec := parseOptions(&component, options); spec != "" {
+			array = spec
+		}
+		components = append(components, &component)
+	}
+	for _, j := range append([]*Job{job}, components...) {
+		if !s.cluster.validPartition(j.Partition) {
+			return s.fail(1, "sbatch: error: Batch job submission failed: Invalid partition name specified")
+		}
+	}
+	if job.Dependency != "" && !s.cluster.validDependency(job.Dependency) {
+		return s.fail(1, "sbatch: error: Batch job submission failed: Job dependency problem")
+	}
+	if array != "" && len(components) > 0 {
+		return s.fail(1, "sbatch: error: Job arrays of heterogeneous jobs are not supported")
+	}
+	if len(components) > 0 {
+		fmt.Fprintf(s.stdout, "Submitted batch job %d\n", s.cluster.submitHetJob(job, components))
+		return 0
+	}
+	if array != "" {
+		tasks, limit, err := parseArray(array)
+		if err != nil {
+			return s.fail(1, "sbatch: error: Invalid job array specification")
+		}
+		fmt.Fprintf(s.stdout, "Submitted batch job %d\n", s.cluster.submitArray(job, tasks, limit))
+		return 0
+	}
+	id := s.cluster.submit(job)
+	fmt.Fprintf(s.stdout, "Submitted batch job %d\n", id)
+	return 0
+}
+
+// parseOptions sets the options in the job, and returns its array specification
+func parseOptions(job *Job, options []string) string {
+	array := ""
+	for i := 0; i < len(options); i++ {
+		option, value := options[i], ""
+		if parts := strings.SplitN(option, "=", 2); len(parts) == 2 && strings.HasPrefix(option, "--") {
+			option, value = parts[0], parts[1]
+		} else if i+1 < len(options) {
+			value = options[i+1]
+		}
+		switch option {
+		case "-J", "--job-name":
+			job.Name = value
+		case "-o", "--output":
+			job.Stdout = value
+		case "-e", "--error":
+			job.Stderr = value
+		case "-p", "--partition":
+			job.Partition = value
+		case "-a", "--array":
+			array = value
+		case "-d", "--dependency":
+			job.Dependency = value
+			job.Reason = ReasonDependency
+		case "-t", "--time":
+			job.TimeLimit = value
+		case "-N", "--nodes":
+			// The node count is the minimum of the node range
+			if n, err := strconv.Atoi(strings.SplitN(value, "-", 2)[0]); err == nil {
+				job.NumNodes = n
+			}
+		case "-c", "--cpus-per-task":
+			job.MinCPUsNode, _ = strconv.Atoi(value)
+		case "--mem":
+			job.MinMemoryNode = value
+		default:
+			continue
+		}
+		if !strings.Contains(options[i], "=") {
+			i++
+		}
+	}
+	return array
+}
+
+// arrayRange matches a range of array task ids, e.g. "5", "0-99" or "0-99:2"
+var arrayRange = regexp.MustCompile(`^(\d+)(?:-(\d+)(?::(\d+))?)?$`)
+
+// parseArray parses the task ids and the limit of running tasks of an array specification, e.g. "0-99%10"
+func parseArray(spec string) ([]int, int, error) {
+	limit := 0
+	if i := strings.Index(spec, "%"); i >= 0 {
+		var err error
+		if limit, err = strconv.Atoi(spec[i+1:]); err != nil || limit <= 0 {
+			return nil, 0, fmt.Errorf("wrong limit of %s", spec)
+		}
+		spec = spec[:i]
+	}
+	var tasks []int
+	for _, r := range strings.Split(spec, ",") {
+		m := arrayRange.FindStringSubmatch(r)
+		if m == nil {
+			return nil, 0, fmt.Errorf("wrong range %s", r)
+		}
+		first, _ := strconv.Atoi(m[1])
+		last, step := first, 1
+		if m[2] != "" {
+			last, _ = strconv.Atoi(m[2])
+		}
+		if m[3] != "" {
+			step, _ = strconv.Atoi(m[3])
+		}
+		if last < first || step <= 0 {
+			return nil, 0, fmt.Errorf("wrong range %s", r)
+		}
+		for task := first; task <= last; task += step {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, limit, nil
+}
+
+// dependencyTypes are the supported dependency types. The conditions must be all satisfied, "?" is not
+// supported.
+var dependencyTypes = map[string]bool{"after": true, "afterok": true, "afternotok": true, "afterany": true}
+
+// validDependency returns whether the types of the dependency specification are supported, and its jobs exist
+func (c *Cluster) validDependency(spec string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, condition := range strings.Split(spec, ",") {
+		parts := strings.Split(condition, ":")
+		if !dependencyTypes[parts[0]] || len(parts) < 2 {
+			return false
+		}
+		for _, id := range parts[1:] {
+			if len(c.lookup(id)) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// dependencyState returns whether the dependency of the job is satisfied, or it can never be satisfied.
+// The cluster lock must be held.
+func (c *Cluster) dependencyState(job *Job) (satisfied, never bool) {
+	satisfied = true
+	for _, condition := range strings.Split(job.Dependency, ",") {
+		parts := strings.Split(condition, ":")
+		for _, id := range parts[1:] {
+			for _, dependency := range c.lookup(id) {
+				switch {
+				case parts[0] == "after":
+					if dependency.State == StatePending {
+						satisfied = false
+					}
+				case !dependency.finished():
+					satisfied = false
+				case parts[0] == "afterok" && dependency.State != StateCompleted:
+					return false, true
+				case parts[0] == "afternotok" && dependency.State == StateCompleted:
+					return false, true
+				}
+			}
+		}
+	}
+	return satisfied, false
+}
+
+// waitDependency waits until the dependency of the job is satisfied, and returns false when the job is
+// cancelled before. The jobs whose dependency can never be satisfied stay pending, as Slurm does by default.
+func (c *Cluster) waitDependency(job *Job) bool {
+	for {
+		c.lock.Lock()
+		satisfied, never := c.dependencyState(job)
+		if satisfied {
+			job.Reason = ""
+		} else if never {
+			job.Reason = ReasonDependencyNeverSatisfied
+		}
+		c.lock.Unlock()
+		if satisfied || !job.wait(50*time.Millisecond) {
+			return satisfied
+		}
+	}
+}
+
+// scontrol shows the jobs known by the controller, with scontrol show job and scontrol show jobid, and
+// updates them with scontrol update
+func (s *shell) scontrol(args []string) int {
+	var words []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			words = append(words, arg)
+		}
+	}
+	if len(words) > 0 && words[0] == "update" {
+		return s.scontrolUpdate(words[1:])
+	}
+	if len(words) != 3 || words[0] != "show" || (words[1] != "job" && words[1] != "jobid") {
+		return s.fail(1, "scontrol: error: %s is not supported by the fake cluster", strings.Join(args, " "))
+	}
+	jobs := s.cluster.controllerJobs(words[2])
+	if len(jobs) == 0 {
+		return s.fail(1, "slurm_load_jobs error: Invalid job id specified")
+	}
+	// The jobs of an array are shown as separate records
+	for i, job := range jobs {
+		if i > 0 {
+			fmt.Fprintln(s.stdout)
+		}
+		reason, dependency := "None", "(null)"
+		if job.State == StatePending {
+			reason = "Priority"
+			if job.Reason != "" {
+				reason = job.Reason
+			}
+		}
+		if job.Dependency != "" {
+			dependency = job.Dependency
+		}
+		fmt.Fprintf(s.stdout, "JobId=%d JobName=%s\n", job.ID, job.Name)
+		if job.ArrayJobID != 0 {
+			fmt.Fprintf(s.stdout, "   ArrayJobId=%d ArrayTaskId=%d\n", job.ArrayJobID, job.ArrayTaskID)
+		}
+		if job.HetJobID != 0 {
+			fmt.Fprintf(s.stdout, "   HetJobId=%d HetJobOffset=%d\n", job.HetJobID, job.HetJobOffset)
+		}
+		fmt.Fprintf(s.stdout, "   UserId=%s Partition=%s TimeLimit=%s\n", s.cluster.User, job.Partition, job.TimeLimit)
+		fmt.Fprintf(s.stdout, "   NumNodes=%d MinCPUsNode=%d MinMemoryNode=%s\n", job.NumNodes, job.MinCPUsNode,
+			job.MinMemoryNode)
+		fmt.Fprintf(s.stdout, "   JobState=%s Reason=%s Dependency=%s\n", job.State, reason, dependency)
+		fmt.Fprintf(s.stdout, "   ExitCode=%d:%d\n", job.ExitCode, job.Signal)
+		fmt.Fprintf(s.stdout, "   SubmitTime=%s StartTime=%s EndTime=%s\n",
+			formatTime(job.SubmitTime), formatTime(job.StartTime), formatTime(job.EndTime))
+		fmt.Fprintf(s.stdout, "   WorkDir=%s\n   StdErr=%s\n   StdOut=%s\n", job.WorkDir, job.Stderr, job.Stdout)
+	}
+	return 0
+}
+
+// scontrolUpdate updates the job of the JobId field with the rest of the fields, e.g. scontrol update
+// JobId=1 MinCPUsNode=2. The pending jobs can update the minimum CPUs and memory per node, and the running
+// jobs none of them.
+func (s *shell) scontrolUpdate(args []string) int {
+	fields := make(map[string]string)
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 {
+			return s.fail(1, "scontrol: error: Invalid input: %s", arg)
+		}
+		fields[parts[0]] = parts[1]
+	}
+	id, ok := fields["JobId"]
+	if !ok {
+		return s.fail(1, "scontrol: error: No job id specified")
+	}
+	delete(fields, "JobId")
+	if err := s.cluster.update(id, fields); err != nil {
+		return s.fail(1, "%v", err)
+	}
+	return 0
+}
+
+// sacct shows the jobs of the accounting, with the fields of --format or -o
+func (s *shell) sacct(args []string) int {
+	if s.cluster.config.NoAccounting {
+		return s.fail(1, "sacct: error: Slurm accounting storage is disabled")
+	}
+	fields := sacctFields
+	parsable, header := false, true
+	var ids []string
+	for i := 0; i < len(args); i++ {
+		value := ""
+		if i+1 < len(args) {
+			value = args[i+1]
+		}
+		switch {
+		case args[i] == "-p" || args[i] == "--parsable":
+			parsable = true
+		case args[i] == "-n" || args[i] == "--noheader":
+			header = false
+		case args[i] == "-j" || args[i] == "--jobs":
+			ids = strings.Split(value, ",")
+			i++
+		case args[i] == "-o" || args[i] == "--format":
+			fields = strings.Split(strings.ToLower(value), ",")
+			i++
+		case strings.HasPrefix(args[i], "--format="):
+			fields = strings.Split(strings.ToLower(strings.TrimPrefix(args[i], "--format=")), ",")
+		}
+	}
+	var lines [][]string
+	if header {
+		lines = append(lines, fields)
+	}
+	for _, job := range s.cluster.accountingJobs(ids) {
+		var values []string
+		for _, field := range fields {
+			values = append(values, accountingField(job, field))
+		}
+		lines = append(lines, values)
+	}
+	for _, values := range lines {
+		if parsable {
+			fmt.Fprintln(s.stdout, strings.Join(values, "|")+"|")
+		} else {
+			fmt.Fprintln(s.stdout, strings.Join(values, " "))
+		}
+	}
+	return 0
+}
+
+func accountingField(job Job, field string) string {
+	switch field {
+	case "jobid":
+		if job.ArrayJobID != 0 {
+			return fmt.Sprintf("%d_%d", job.ArrayJobID, job.ArrayTaskID)
+		}
+		if job.HetJobID != 0 {
+			return fmt.Sprintf("%d+%d", job.HetJobID, job.HetJobOffset)
+		}
+		return strconv.Itoa(job.ID)
+	case "jobname":
+		return job.Name
+	case "partition":
+		return job.Partition
+	case "state":
+		return job.State
+	case "exitcode":
+		return fmt.Sprintf("%d:%d", job.ExitCode, job.Signal)
+	case "submit":
+		return formatTime(job.SubmitTime)
+	case "start":
+		return formatTime(job.StartTime)
+	case "end":
+		return formatTime(job.EndTime)
+	case "workdir":
+		return job.WorkDir
+	}
+	return ""
+}
+
+// scancel cancels the jobs
+func (s *shell) scancel(args []string) int {
+	code := 0
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if !s.cluster.cancel(arg) {
+			code = s.fail(1, "scancel: error: Kill job error on job id %s: Invalid job id specified", arg)
+		}
+	}
+	return code
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "Unknown"
+	}
+	return t.Format(timeLayout)
+}
+
+func (c *Cluster) validPartition(partition string) bool {
+	if partition == "" || len(c.config.Partitions) == 0 {
+		return true
+	}
+	for _, p := range c.config.Partitions {
+		if p == partition {
+			return true
+		}
+	}
+	return false
+}
+
+// update changes the fields of the jobs of the id, when every job can change all of them
+func (c *Cluster) update(id string, fields map[string]string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	jobs := c.lookup(id)
+	if len(jobs) == 0 {
+		return fmt.Errorf("slurm_update error: Invalid job id specified")
+	}
+	for _, job := range jobs {
+		if job.finished() {
+			return fmt.Errorf("slurm_update error: Job/step already completing or completed")
+		}
+		for field := range fields {
+			switch field {
+			case "MinCPUsNode", "MinMemoryNode":
+				if job.State != StatePending {
+					return fmt.Errorf("slurm_update error: Job is no longer pending execution")
+				}
+			default:
+				return fmt.Errorf("scontrol: error: Update of %s is not supported by the fake cluster", field)
+			}
+		}
+	}
+	for _, job := range jobs {
+		for field, value := range fields {
+			switch field {
+			case "MinCPUsNode":
+				job.MinCPUsNode, _ = strconv.Atoi(value)
+			case "MinMemoryNode":
+				job.MinMemoryNode = value
+			}
+		}
+	}
+	return nil
+}
+
+// submit queues the job and returns its id
+func (c *Cluster) submit(job *Job) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.queue(job)
+	return job.ID
+}
+
+// submitArray queues a copy of the job for every task of the array, and returns the array job id.
+// The limit of running tasks is not set when it is 0.
+func (c *Cluster) submitArray(job *Job, tasks []int, limit int) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var semaphore chan struct{}
+	if limit > 0 {
+		semaphore = make(chan struct{}, limit)
+	}
+	arrayId := c.lastId + 1
+	for _, taskId := range tasks {
+		task := *job
+		task.ArrayJobID = arrayId
+		task.ArrayTaskID = taskId
+		task.Stdout = strings.Replace(strings.Replace(job.Stdout, "%A", strconv.Itoa(arrayId), -1), "%a", strconv.Itoa(taskId), -1)
+		task.Stderr = strings.Replace(strings.Replace(job.Stderr, "%A", strconv.Itoa(arrayId), -1), "%a", strconv.Itoa(taskId), -1)
+		task.env = append(append([]string{}, job.env...),
+			"SLURM_ARRAY_JOB_ID="+strconv.Itoa(arrayId), "SLURM_ARRAY_TASK_ID="+strconv.Itoa(taskId))
+		task.limit = semaphore
+		task.cancel = make(chan struct{})
+		c.queue(&task)
+	}
+	return arrayId
+}
+
+// submitHetJob queues the heterogeneous job, whose components follow its first component, and returns the
+// heterogeneous job id
+func (c *Cluster) submitHetJob(job *Job, components []*Job) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	job.HetJobID = c.lastId + 1
+	job.components = components
+	c.queue(job)
+	for offset, component := range components {
+		c.lastId++
+		component.ID = c.lastId
+		component.HetJobID = job.ID
+		component.HetJobOffset = offset + 1
+		c.jobs[component.ID] = component
+		c.setState(component, job.State)
+	}
+	return job.ID
+}
+
+// syncComponents sets the state of the heterogeneous job in its components which are not finished. The
+// cluster lock must be held.
+func (c *Cluster) syncComponents(job *Job) {
+	for _, component := range job.components {
+		if component.finished() {
+			continue
+		}
+		c.setState(component, job.State)
+		component.Reason = job.Reason
+		component.ExitCode, component.Signal = job.ExitCode, job.Signal
+		component.StartTime, component.EndTime = job.StartTime, job.EndTime
+	}
+}
+
+// queue sets the id of the job and runs it. The cluster lock must be held.
+func (c *Cluster) queue(job *Job) {
+	c.lastId++
+	job.ID = c.lastId
+	id := strconv.Itoa(job.ID)
+	job.Stdout = strings.Replace(job.Stdout, "%j", id, -1)
+	job.Stderr = strings.Replace(job.Stderr, "%j", id, -1)
+	job.env = append(job.env, "SLURM_JOB_ID="+id, "SLURM_JOB_NAME="+job.Name)
+	job.plan = c.config.DefaultPlan
+	if plan, ok := c.config.Plans[job.Name]; ok {
+		job.plan = plan
+	}
+	c.jobs[job.ID] = job
+	c.setState(job, job.State)
+	c.running.Add(1)
+	go c.run(job)
+}
+
+// setState sets the state of the job, and writes it in the state directory, where squeue and srun read it.
+// The cluster lock must be held.
+func (c *Cluster) setState(job *Job, state string) {
+	job.State = state
+	ioutil.WriteFile(filepath.Join(c.root, "state", strconv.Itoa(job.ID)), []byte(state+"\n"), 0644)
+}
+
+// controllerJobs returns the jobs of the id which the controller knows, until MinJobAge after they finish
+func (c *Cluster) controllerJobs(id string) []Job {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var jobs []Job
+	for _, job := range c.lookup(id) {
+		if job.finished() && c.config.MinJobAge > 0 && time.Since(job.EndTime) > c.config.MinJobAge {
+			continue
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs
+}
+
+// accountingJobs returns the jobs with those ids, or every job when there are no ids
+func (c *Cluster) accountingJobs(ids []string) []Job {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var jobs []Job
+	if len(ids) == 0 {
+		for _, job := range c.jobs {
+			jobs = append(jobs, *job)
+		}
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+		return jobs
+	}
+	for _, id := range ids {
+		for _, job := range c.lookup(id) {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs
+}
+
+// lookup returns the jobs of the id: the job, every task of the array when it is an array job id, a
+// single task with the <array job id>_<task id> format, every component of a heterogeneous job id, or a
+// single component with the <heterogeneous job id>+<offset> format
+func (c *Cluster) lookup(id string) []*Job {
+	if parts := strings.SplitN(id, "+", 2); len(parts) == 2 {
+		hetJobId, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil
+		}
+		job, ok := c.jobs[hetJobId]
+		if !ok || job.HetJobID != hetJobId {
+			return nil
+		}
+		for _, component := range append([]*Job{job}, job.components...) {
+			if strconv.Itoa(component.HetJobOffset) == parts[1] {
+				return []*Job{component}
+			}
+		}
+		return nil
+	}
+	parts := strings.SplitN(id, "_", 2)
+	jobId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil
+	}
+	job, ok := c.jobs[jobId]
+	if !ok {
+		return nil
+	}
+	if job.ArrayJobID != jobId {
+		if len(parts) > 1 {
+			return nil
+		}
+		return append([]*Job{job}, job.components...)
+	}
+	var tasks []*Job
+	for taskJobId := jobId; taskJobId <= c.lastId; taskJobId++ {
+		task, ok := c.jobs[taskJobId]
+		if !ok || task.ArrayJobID != jobId {
+			break
+		}
+		if len(parts) == 1 || parts[1] == strconv.Itoa(task.ArrayTaskID) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+// cancel cancels the jobs of the id. Finished jobs are not changed.
+func (c *Cluster) cancel(id string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	jobs := c.lookup(id)
+	for _, job := range jobs {
+		if !job.finished() {
+			c.setState(job, StateCancelled)
+			job.EndTime = time.Now()
+			c.cancelJob(job)
+			c.syncComponents(job)
+		}
+	}
+	return len(jobs) > 0
+}
+
+// cancelJob stops the runner of the job. The cluster lock must be held.
+func (c *Cluster) cancelJob(job *Job) {
+	if !job.cancelled {
+		job.cancelled = true
+		close(job.cancel)
+	}
+}
+
+// run moves the job through the states of its plan
+func (c *Cluster) run(job *Job) {
+	defer c.running.Done()
+	if !job.wait(job.plan.Pending) {
+		return
+	}
+	if job.plan.Hold {
+		<-job.cancel
+		return
+	}
+	if job.Dependency != "" && !c.waitDependency(job) {
+		return
+	}
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+			defer func() { <-c.slots }()
+		case <-job.cancel:
+			return
+		}
+	}
+	if job.limit != nil {
+		select {
+		case job.limit <- struct{}{}:
+			defer func() { <-job.limit }()
+		case <-job.cancel:
+			return
+		}
+	}
+	cmd, err := c.start(job)
+	if err != nil {
+		c.finish(job, StateFailed, 1, 0)
+		return
+	}
+	if cmd == nil {
+		return
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var timeLimit, stateAfter <-chan time.Time
+	if job.plan.TimeLimit > 0 {
+		timeLimit = time.After(job.plan.TimeLimit)
+	}
+	if job.plan.State != "" {
+		stateAfter = time.After(job.plan.StateAfter)
+	}
+	select {
+	case err := <-done:
+		code, signal := waitStatus(err)
+		state := StateCompleted
+		if code != 0 || signal != 0 {
+			state = StateFailed
+		}
+		c.finish(job, state, code, signal)
+	case <-timeLimit:
+		kill(cmd)
+		<-done
+		c.finish(job, StateTimeout, 0, 15)
+	case <-stateAfter:
+		kill(cmd)
+		<-done
+		c.finish(job, job.plan.State, 0, 0)
+	case <-job.cancel:
+		kill(cmd)
+		<-done
+	}
+}
+
+// kill kills every process of the job script, like Slurm kills every process of the ended jobs
+func kill(cmd *osexec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// wait waits for the duration, and returns false when the job is cancelled before
+func (j *Job) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-j.cancel:
+		return false
+	}
+}
+
+// start runs the job script. It returns a nil command when the job was cancelled.
+func (c *Cluster) start(job *Job) (*osexec.Cmd, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if job.cancelled {
+		return nil, nil
+	}
+	cmd := osexec.Command("bash", job.Script)
+	cmd.Dir = job.WorkDir
+	cmd.Env = job.env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := os.OpenFile(c.jobPath(job, job.Stdout), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer stdout.Close()
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+	if job.Stderr != "" {
+		stderr, err := os.OpenFile(c.jobPath(job, job.Stderr), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer stderr.Close()
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c.setState(job, StateRunning)
+	job.StartTime = time.Now()
+	c.syncComponents(job)
+	return cmd, nil
+}
+
+func (c *Cluster) jobPath(job *Job, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(job.WorkDir, path)
+}
+
+// finish sets the final state of the job, unless it was cancelled
+func (c *Cluster) finish(job *Job, state string, code, signal int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if job.State == StateCancelled {
+		return
+	}
+	c.setState(job, state)
+	job.ExitCode = code
+	job.Signal = signal
+	job.EndTime = time.Now()
+	c.syncComponents(job)
+}
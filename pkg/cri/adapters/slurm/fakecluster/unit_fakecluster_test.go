@@ -0,0 +1,598 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakecluster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+
+	"golang.org/x/net/context"
+)
+
+const waitTimeout = 10 * time.Second
+
+func startCluster(t *testing.T, config Config) (*Cluster, *cmd.SlurmCmd) {
+	cluster, err := Start(config)
+	if err != nil {
+		t.Fatalf("Cluster can not be started: %v", err)
+	}
+	logFile, err := ioutil.TempFile("", "fakecluster-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Close()
+	client, err := cmd.NewSlurmCMD(cluster.User, cluster.Host, cluster.Port, logFile.Name(), "", cluster.Password)
+	if err != nil {
+		t.Fatalf("Client can not be created: %v", err)
+	}
+	return cluster, client
+}
+
+func submit(t *testing.T, client *cmd.SlurmCmd, name, command string) int {
+	ctx := context.Background()
+	path := "multi-cri/" + name
+	if _, err := client.ExecCmd(ctx, "mkdir -p "+path); err != nil {
+		t.Fatalf("Job path can not be created: %v", err)
+	}
+	jobId, err := client.Sbatch(ctx, &cmd.JobConfig{
+		Headers: []cmd.JobConfigField{{"-J", name}, {"-o", "stdout.out"}, {"-e", "sterr.out"}},
+		Command: command,
+		Path:    path,
+		Script:  path + "/run.sh",
+		ENV:     map[string]string{"GREETING": "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Job %s can not be submitted: %v", name, err)
+	}
+	id, err := strconv.Atoi(jobId)
+	if err != nil {
+		t.Fatalf("Wrong job id %q: %v", jobId, err)
+	}
+	return id
+}
+
+//Test jobs are submitted, run with the container environment and finish with the exit code of their script
+func TestUnitSubmitJob(t *testing.T) {
+	cluster, client := startCluster(t, Config{})
+	defer cluster.Close()
+	id := submit(t, client, "hello", "singularity exec image.sif sh -c 'echo $GREETING from $SLURM_JOB_ID'")
+	job, err := cluster.WaitJob(id, waitTimeout)
+	if err != nil || job.State != StateCompleted || job.Name != "hello" {
+		t.Fatalf("Job should be completed: %+v %v", job, err)
+	}
+	output, err := client.GetStdout(context.Background(), "multi-cri/hello/stdout.out")
+	if err != nil || strings.TrimSpace(output) != "hello from "+strconv.Itoa(id) {
+		t.Errorf("Wrong job output %q: %v", output, err)
+	}
+	status, err := client.Sstatus(context.Background(), &cmd.JobReference{JobId: int32(id)})
+	if err != nil || status.JobState != StateCompleted {
+		t.Errorf("Wrong job status %+v: %v", status, err)
+	}
+
+	id = submit(t, client, "fail", "exit 3")
+	if job, err := cluster.WaitJob(id, waitTimeout); err != nil || job.State != StateFailed || job.ExitCode != 3 {
+		t.Errorf("Job should fail with exit code 3: %+v %v", job, err)
+	}
+}
+
+//Test the jobs follow their plans and are cancelled
+func TestUnitJobPlans(t *testing.T) {
+	cluster, client := startCluster(t, Config{
+		Slots:     1,
+		MinJobAge: time.Millisecond,
+		Plans: map[string]Plan{
+			"held":    {Hold: true},
+			"timeout": {TimeLimit: 50 * time.Millisecond},
+			"node":    {State: StateNodeFail, StateAfter: 10 * time.Millisecond},
+		},
+	})
+	defer cluster.Close()
+	ctx := context.Background()
+	held := submit(t, client, "held", "true")
+	status, err := client.Sstatus(ctx, &cmd.JobReference{JobId: int32(held)})
+	if err != nil || status.JobState != StatePending {
+		t.Errorf("Held jobs should be pending: %+v %v", status, err)
+	}
+	if err := client.Scancel(ctx, cmd.JobReference{JobId: int32(held)}); err != nil {
+		t.Errorf("Job should be cancelled: %v", err)
+	}
+	if job, err := cluster.WaitJob(held, waitTimeout); err != nil || job.State != StateCancelled {
+		t.Errorf("Job should be cancelled: %+v %v", job, err)
+	}
+
+	timeout := submit(t, client, "timeout", "sleep 10")
+	node := submit(t, client, "node", "sleep 10")
+	if job, err := cluster.WaitJob(timeout, waitTimeout); err != nil || job.State != StateTimeout {
+		t.Errorf("Job should time out: %+v %v", job, err)
+	}
+	first, _ := cluster.Job(timeout)
+	if job, err := cluster.WaitJob(node, waitTimeout); err != nil || job.State != StateNodeFail || job.StartTime.Before(first.EndTime) {
+		t.Errorf("Job should fail after the first one with a single slot: %+v %v", job, err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// Old jobs are only in the accounting
+	status, err = client.Sstatus(ctx, &cmd.JobReference{JobId: int32(timeout)})
+	if err != nil || status.JobState != StateTimeout {
+		t.Errorf("Finished jobs should be read from the accounting: %+v %v", status, err)
+	}
+	if _, err := client.Sstatus(ctx, &cmd.JobReference{JobId: 100}); adapters.ErrorKindOf(err) != adapters.KindNotFound {
+		t.Errorf("Unknown jobs should not be found: %v", err)
+	}
+}
+
+//Test the submission errors are classified by the client
+func TestUnitSubmitErrors(t *testing.T) {
+	cluster, client := startCluster(t, Config{Partitions: []string{"debug"}})
+	defer cluster.Close()
+	ctx := context.Background()
+	if _, err := client.ExecCmd(ctx, "mkdir -p job"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Sbatch(ctx, &cmd.JobConfig{
+		Headers: []cmd.JobConfigField{{"-J", "job"}, {"-p", "gpu"}},
+		Command: "true",
+		Path:    "job",
+		Script:  "job/run.sh",
+	})
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Wrong partitions should be invalid arguments: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cluster.Home, "job", "batch.sh")); err != nil {
+		t.Errorf("Batch script should be copied: %v", err)
+	}
+	wrong, err := cmd.NewSlurmCMD(cluster.User, cluster.Host, cluster.Port, "", "", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.ExecCmd(ctx, "true"); adapters.ErrorKindOf(err) != adapters.KindPermissionDenied {
+		t.Errorf("Wrong passwords should be denied: %v", err)
+	}
+}
+
+//Test the tasks of job arrays run with their task id and limit, and their status is read by task
+func TestUnitJobArray(t *testing.T) {
+	cluster, client := startCluster(t, Config{Plans: map[string]Plan{"held": {Hold: true}}})
+	defer cluster.Close()
+	ctx := context.Background()
+	if _, err := client.ExecCmd(ctx, "mkdir -p array"); err != nil {
+		t.Fatal(err)
+	}
+	jobId, err := client.Sbatch(ctx, &cmd.JobConfig{
+		Headers: []cmd.JobConfigField{{"-J", "array"}, {"-o", "stdout_%a.out"}, {"--array=0-3%2", ""}},
+		Command: "sleep 0.2; echo task $SLURM_ARRAY_TASK_ID of $SLURM_ARRAY_JOB_ID; test $SLURM_ARRAY_TASK_ID != 3",
+		Path:    "array",
+		Script:  "array/run.sh",
+	})
+	if err != nil {
+		t.Fatalf("Array should be submitted: %v", err)
+	}
+	id, _ := strconv.Atoi(jobId)
+	jobs := cluster.Jobs()
+	if len(jobs) != 4 || jobs[0].ID != id || jobs[3].ArrayJobID != id || jobs[3].ArrayTaskID != 3 {
+		t.Fatalf("A job should be submitted per task: %+v", jobs)
+	}
+	var finished []Job
+	for _, job := range jobs {
+		job, err := cluster.WaitJob(job.ID, waitTimeout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		finished = append(finished, job)
+		output, _ := ioutil.ReadFile(filepath.Join(cluster.Home, "array", "stdout_"+strconv.Itoa(job.ArrayTaskID)+".out"))
+		if strings.TrimSpace(string(output)) != "task "+strconv.Itoa(job.ArrayTaskID)+" of "+jobId {
+			t.Errorf("Wrong output of task %d: %q", job.ArrayTaskID, output)
+		}
+	}
+	for _, job := range finished {
+		running := 0
+		for _, other := range finished {
+			if !job.StartTime.Before(other.StartTime) && job.StartTime.Before(other.EndTime) {
+				running++
+			}
+		}
+		if running > 2 {
+			t.Errorf("Only two tasks should run at the same time, %d run when task %d starts", running, job.ArrayTaskID)
+		}
+	}
+	statuses, err := client.SstatusArray(ctx, &cmd.JobReference{JobId: int32(id)})
+	if err != nil || len(statuses) != 4 {
+		t.Fatalf("Every task should be in the accounting: %v", err)
+	}
+	if statuses[1].ArrayTaskId != "1" || statuses[1].JobState != StateCompleted ||
+		statuses[3].ArrayTaskId != "3" || statuses[3].JobState != StateFailed || statuses[3].ExitCode != 1 {
+		t.Errorf("Wrong status of the tasks: %+v %+v", statuses[1], statuses[3])
+	}
+
+	// Without accounting, the status is read from the controller
+	cluster, client = startCluster(t, Config{NoAccounting: true, Plans: map[string]Plan{"held": {Hold: true}}})
+	defer cluster.Close()
+	if _, err := client.ExecCmd(ctx, "mkdir -p array"); err != nil {
+		t.Fatal(err)
+	}
+	jobId, err = client.Sbatch(ctx, &cmd.JobConfig{
+		Headers: []cmd.JobConfigField{{"-J", "held"}, {"--array=1,3", ""}},
+		Command: "true",
+		Path:    "array",
+		Script:  "array/run.sh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ = strconv.Atoi(jobId)
+	statuses, err = client.SstatusArray(ctx, &cmd.JobReference{JobId: int32(id)})
+	if err != nil || len(statuses) != 2 || statuses[1].ArrayTaskId != "3" || statuses[1].JobState != StatePending {
+		t.Errorf("Pending tasks should be read from the controller: %+v %v", statuses, err)
+	}
+	if err := client.Scancel(ctx, cmd.JobReference{JobId: int32(id)}); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := cluster.WaitJob(id+1, waitTimeout); err != nil || job.State != StateCancelled {
+		t.Errorf("Every task should be cancelled: %+v %v", job, err)
+	}
+}
+
+//Test the steps are launched in the allocation of a holder job, and are stopped with it
+func TestUnitJobSteps(t *testing.T) {
+	cluster, client := startCluster(t, Config{})
+	defer cluster.Close()
+	ctx := context.Background()
+	id := submit(t, client, "pod", cmd.StepLauncher)
+	for _, step := range []string{"main", "sidecar", "stopped"} {
+		if _, err := client.ExecCmd(ctx, "mkdir -p multi-cri/pod/"+step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	step := func(name, command string) {
+		if err := client.Srun(ctx, &cmd.StepConfig{JobId: int32(id), Options: []string{"--ntasks=1"}, Command: command,
+			Path: "multi-cri/pod/" + name, ENV: map[string]string{"GREETING": "hello"}, Stdout: "stdout.out", Stderr: "sterr.out"}); err != nil {
+			t.Fatalf("Step %s should be launched: %v", name, err)
+		}
+	}
+	waitStep := func(name, state string) *cmd.JobStatus {
+		var status *cmd.JobStatus
+		var err error
+		for deadline := time.Now().Add(waitTimeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+			status, err = client.StepStatus(ctx, int32(id), "multi-cri/pod/"+name)
+			if err == nil && status.JobState == state {
+				return status
+			}
+		}
+		t.Fatalf("Step %s should be %s: %+v %v", name, state, status, err)
+		return nil
+	}
+	step("main", "echo $GREETING from $SLURM_JOB_ID; exit 3")
+	step("sidecar", "exec sleep 60")
+	step("stopped", "exec sleep 60")
+	if status := waitStep("main", "FAILED"); status.ExitCode != 3 || status.StarTime == 0 || status.EndTime == 0 {
+		t.Errorf("Step should fail with exit code 3: %+v", status)
+	}
+	if output, err := client.GetStdout(ctx, "multi-cri/pod/main/stdout.out"); err != nil ||
+		strings.TrimSpace(output) != "hello from "+strconv.Itoa(id) {
+		t.Errorf("Wrong step output %q: %v", output, err)
+	}
+	waitStep("sidecar", "RUNNING")
+	waitStep("stopped", "RUNNING")
+	if err := client.StepCancel(ctx, "multi-cri/pod/stopped"); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitStep("stopped", "FAILED"); status.ExitCode != 143 {
+		t.Errorf("Stopped step should exit with SIGTERM: %+v", status)
+	}
+	if job, _ := cluster.Job(id); job.State != StateRunning {
+		t.Errorf("Allocation should run after its steps: %+v", job)
+	}
+
+	// The steps which run when the allocation ends are lost, and no step can start
+	if err := client.Scancel(ctx, cmd.JobReference{JobId: int32(id)}); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitStep("sidecar", "NODE_FAIL"); status.StarTime == 0 {
+		t.Errorf("Lost step should keep its start time: %+v", status)
+	}
+	if _, err := client.ExecCmd(ctx, "mkdir -p multi-cri/pod/late"); err != nil {
+		t.Fatal(err)
+	}
+	step("late", "true")
+	if status := waitStep("late", "NODE_FAIL"); status.StarTime != 0 {
+		t.Errorf("Step should not run without allocation: %+v", status)
+	}
+	if _, err := client.StepStatus(ctx, int32(id), "multi-cri/pod/missing"); !adapters.IsNotFound(err) {
+		t.Errorf("Missing step should not be found: %v", err)
+	}
+}
+
+//Test the jobs wait for their dependencies, and stay pending when they can never be satisfied
+func TestUnitJobDependency(t *testing.T) {
+	cluster, client := startCluster(t, Config{})
+	defer cluster.Close()
+	ctx := context.Background()
+	if _, err := client.ExecCmd(ctx, "mkdir -p deps"); err != nil {
+		t.Fatal(err)
+	}
+	submitAfter := func(name, command, dependency string) (int, error) {
+		jobId, err := client.Sbatch(ctx, &cmd.JobConfig{
+			Headers: []cmd.JobConfigField{{"-J", name}, {"--dependency=" + dependency, ""}},
+			Command: command,
+			Path:    "deps",
+			Script:  "deps/run.sh",
+		})
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(jobId)
+	}
+	pre := submit(t, client, "pre", "sleep 0.3")
+	fail := submit(t, client, "fail", "exit 1")
+	compute, err := submitAfter("compute", "true", "afterok:"+strconv.Itoa(pre))
+	if err != nil {
+		t.Fatal(err)
+	}
+	never, err := submitAfter("never", "true", "afterok:"+strconv.Itoa(pre)+":"+strconv.Itoa(fail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup, err := submitAfter("cleanup", "true", "afterany:"+strconv.Itoa(fail)+",after:"+strconv.Itoa(pre))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := client.Sstatus(ctx, &cmd.JobReference{JobId: int32(compute)}); err != nil ||
+		status.JobState != StatePending || status.Reason != ReasonDependency {
+		t.Errorf("Job should wait for its dependency: %+v %v", status, err)
+	}
+	first, _ := cluster.WaitJob(pre, waitTimeout)
+	job, err := cluster.WaitJob(compute, waitTimeout)
+	if err != nil || job.State != StateCompleted || job.StartTime.Before(first.EndTime) {
+		t.Errorf("Job should run after its dependency: %+v %v", job, err)
+	}
+	if job, err := cluster.WaitJob(cleanup, waitTimeout); err != nil || job.State != StateCompleted {
+		t.Errorf("Job should run after any end of its dependency: %+v %v", job, err)
+	}
+	var status *cmd.JobStatus
+	for deadline := time.Now().Add(waitTimeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if status, err = client.Sstatus(ctx, &cmd.JobReference{JobId: int32(never)}); err == nil &&
+			status.Reason == ReasonDependencyNeverSatisfied {
+			break
+		}
+	}
+	if status == nil || status.JobState != StatePending || status.Reason != ReasonDependencyNeverSatisfied {
+		t.Errorf("Job should never run: %+v %v", status, err)
+	}
+	if _, err := submitAfter("unknown", "true", "afterok:100"); err == nil {
+		t.Errorf("Dependency on unknown jobs should not be submitted")
+	}
+}
+
+//Test the components of heterogeneous jobs follow the state of the job, and they are cancelled alone
+func TestUnitHetJob(t *testing.T) {
+	cluster, client := startCluster(t, Config{Partitions: []string{"gpu", "cpu"}})
+	defer cluster.Close()
+	ctx := context.Background()
+	if _, err := client.ExecCmd(ctx, "mkdir -p multi-cri/het/sim multi-cri/het/analysis"); err != nil {
+		t.Fatal(err)
+	}
+	submitHet := func(headers [][]cmd.JobConfigField, command string) (int, error) {
+		jobId, err := client.Sbatch(ctx, &cmd.JobConfig{
+			Headers:       []cmd.JobConfigField{{"-J", "het"}, {"-p", "gpu"}},
+			HetJobHeaders: headers,
+			Command:       command,
+			Path:          "multi-cri/het",
+			Script:        "multi-cri/het/run.sh",
+		})
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(jobId)
+	}
+	id, err := submitHet([][]cmd.JobConfigField{{{"-p", "cpu"}}},
+		"(cd analysis && srun --het-group=1 --output=stdout.out sleep 60) & "+
+			"(cd sim && srun --het-group=0 --output=stdout.out bash -c 'echo $SLURM_JOB_ID'); wait")
+	if err != nil {
+		t.Fatalf("Heterogeneous job should be submitted: %v", err)
+	}
+	component := cmd.JobReference{JobId: int32(id), HetJob: true, HetJobOffset: 1}
+	var status *cmd.JobStatus
+	for deadline := time.Now().Add(waitTimeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if status, err = client.Sstatus(ctx, &component); err == nil && status.JobState == StateRunning {
+			break
+		}
+	}
+	if status == nil || status.JobState != StateRunning {
+		t.Fatalf("Component should run with the job: %+v %v", status, err)
+	}
+	if job, _ := cluster.Job(id + 1); job.HetJobID != id || job.HetJobOffset != 1 || job.Partition != "cpu" {
+		t.Errorf("Component should have its own options: %+v", job)
+	}
+	if output, err := client.ExecCmd(ctx, "sacct -p -n -j "+strconv.Itoa(id)+" -o jobid"); err != nil ||
+		output != strconv.Itoa(id)+"+0|\n"+strconv.Itoa(id)+"+1|\n" {
+		t.Errorf("Accounting should show every component: %q %v", output, err)
+	}
+	if err := client.Scancel(ctx, component); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := cluster.WaitJob(id, waitTimeout); err != nil || job.State != StateCompleted {
+		t.Errorf("Job should complete without its cancelled component: %+v %v", job, err)
+	}
+	if status, err := client.Sstatus(ctx, &component); err != nil || status.JobState != StateCancelled {
+		t.Errorf("Component should be cancelled: %+v %v", status, err)
+	}
+	if output, err := client.GetStdout(ctx, "multi-cri/het/sim/stdout.out"); err != nil || strings.TrimSpace(output) != strconv.Itoa(id) {
+		t.Errorf("Wrong output of the first component %q: %v", output, err)
+	}
+
+	if _, err := submitHet([][]cmd.JobConfigField{{{"-p", "debug"}}}, "true"); err == nil {
+		t.Errorf("Component with a wrong partition should not be submitted")
+	}
+	if _, err := submitHet([][]cmd.JobConfigField{{{"--array=0-1", ""}}}, "true"); err == nil {
+		t.Errorf("Heterogeneous job arrays should not be submitted")
+	}
+}
+
+//Test pending jobs update their CPUs and memory, and running jobs none of them
+func TestUnitUpdateJob(t *testing.T) {
+	cluster, client := startCluster(t, Config{Plans: map[string]Plan{"held": {Hold: true}}})
+	defer cluster.Close()
+	ctx := context.Background()
+	update := func(id int, update cmd.JobUpdate) error {
+		return client.Supdate(ctx, cmd.JobReference{JobId: int32(id)}, update)
+	}
+	id := submit(t, client, "held", "true")
+	if err := update(id, cmd.JobUpdate{CPUsPerNode: 4, MemoryPerNode: 1024}); err != nil {
+		t.Fatalf("Pending job should be updated: %v", err)
+	}
+	job, _ := cluster.Job(id)
+	if job.MinCPUsNode != 4 || job.MinMemoryNode != "1024" {
+		t.Errorf("Wrong updated job: %+v", job)
+	}
+	if err := update(id+100, cmd.JobUpdate{CPUsPerNode: 2}); !adapters.IsNotFound(err) {
+		t.Errorf("Unknown jobs should not be found: %v", err)
+	}
+
+	id = submit(t, client, "running", "sleep 60")
+	for deadline := time.Now().Add(waitTimeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if job, _ = cluster.Job(id); job.State == StateRunning {
+			break
+		}
+	}
+	if job.State != StateRunning {
+		t.Fatalf("Job should run: %+v", job)
+	}
+	for name, u := range map[string]cmd.JobUpdate{"CPUs": {CPUsPerNode: 2}, "memory": {MemoryPerNode: 1024}} {
+		if err := update(id, u); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Running job should not update its %s: %v", name, err)
+		}
+	}
+	if err := client.Scancel(ctx, cmd.JobReference{JobId: int32(id)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cluster.WaitJob(id, waitTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := update(id, cmd.JobUpdate{CPUsPerNode: 2}); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Finished job should not be updated: %v", err)
+	}
+	if job, _ := cluster.Job(id); job.MinCPUsNode != 0 {
+		t.Errorf("Only the allowed updates should be kept: %+v", job)
+	}
+}
+
+//Test the added commands submit and cancel jobs, and read their standard input from files
+func TestUnitBuiltin(t *testing.T) {
+	cluster, client := startCluster(t, Config{Plans: map[string]Plan{"held": {Hold: true}}})
+	defer cluster.Close()
+	cluster.AddBuiltin("qsub", func(s Session, args []string) int {
+		script, err := ioutil.ReadAll(s.Stdin)
+		if err != nil {
+			return 1
+		}
+		path := filepath.Join(s.Dir, args[0]+".sh")
+		if err := ioutil.WriteFile(path, script, 0755); err != nil {
+			return 1
+		}
+		id := cluster.Submit(Job{Name: args[0], Script: path, WorkDir: s.Dir, Stdout: "%j.out"}, s.Env)
+		fmt.Fprintf(s.Stdout, "%d.fakecluster\n", id)
+		return 0
+	})
+	cluster.AddBuiltin("qdel", func(s Session, args []string) int {
+		id, _ := strconv.Atoi(args[0])
+		if !cluster.Cancel(id) {
+			fmt.Fprintf(s.Stderr, "qdel: Unknown Job Id %s\n", args[0])
+			return 1
+		}
+		return 0
+	})
+	ctx := context.Background()
+	if _, err := client.ExecCmd(ctx, "echo 'echo $USER' > job.sh"); err != nil {
+		t.Fatal(err)
+	}
+	output, err := client.ExecCmd(ctx, "qsub hello < job.sh")
+	if err != nil {
+		t.Fatalf("Job should be submitted: %v", err)
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(output), ".fakecluster"))
+	if err != nil {
+		t.Fatalf("Wrong job id %q: %v", output, err)
+	}
+	if job, err := cluster.WaitJob(id, waitTimeout); err != nil || job.State != StateCompleted {
+		t.Errorf("Job should be completed: %+v %v", job, err)
+	}
+
+	output, err = client.ExecCmd(ctx, "qsub held < job.sh")
+	if err != nil {
+		t.Fatalf("Job should be submitted: %v", err)
+	}
+	id, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(output), ".fakecluster"))
+	if _, err := client.ExecCmd(ctx, "qdel "+strconv.Itoa(id)); err != nil {
+		t.Errorf("Job should be cancelled: %v", err)
+	}
+	if job, err := cluster.WaitJob(id, waitTimeout); err != nil || job.State != StateCancelled {
+		t.Errorf("Job should be cancelled: %+v %v", job, err)
+	}
+	if _, err := client.ExecCmd(ctx, "qdel 100"); err == nil {
+		t.Error("Unknown jobs should not be cancelled")
+	}
+}
+
+//Test the scheduler commands submit the scripts with the job name and output files of their options and directives
+func TestUnitSchedulerCommands(t *testing.T) {
+	cluster, client := startCluster(t, Config{Plans: map[string]Plan{"held": {Hold: true}}})
+	defer cluster.Close()
+	scheduler := Scheduler{Directives: "#SUB", Name: "--name", Stdout: "-o", Stderr: "-e", Submitted: "Job %d\n",
+		WorkDirEnv: "SUB_WORKDIR", Unknown: "unknown job %s\n", UnknownStatus: 3}
+	cluster.AddBuiltin("submit", cluster.SubmitCommand(scheduler))
+	cluster.AddBuiltin("cancel", cluster.CancelCommand(scheduler))
+	ctx := context.Background()
+	script := `printf '#!/bin/bash\n#SUB -o hello.out\n#SUB -e hello.err\necho $SUB_WORKDIR\n' > job.sh`
+	if _, err := client.ExecCmd(ctx, script); err != nil {
+		t.Fatal(err)
+	}
+	for command, name := range map[string]string{"submit --name=hello job.sh": "hello", "submit < job.sh": ""} {
+		output, err := client.ExecCmd(ctx, command)
+		if err != nil {
+			t.Fatalf("Job should be submitted: %v", err)
+		}
+		id, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(output), "Job "))
+		job, err := cluster.WaitJob(id, waitTimeout)
+		if err != nil || job.State != StateCompleted || job.Stdout != "hello.out" || job.Stderr != "hello.err" ||
+			(name != "" && job.Name != name) {
+			t.Errorf("Job of %s should be completed with the options of its directives: %+v %v", command, job, err)
+		}
+		if out, _ := ioutil.ReadFile(filepath.Join(job.WorkDir, job.Stdout)); !strings.Contains(string(out), job.WorkDir) {
+			t.Errorf("Job should run with its submission directory: %q", out)
+		}
+	}
+	if _, err := client.ExecCmd(ctx, "submit missing.sh"); err == nil {
+		t.Error("Missing scripts should not be submitted")
+	}
+	output, err := client.ExecCmd(ctx, "submit --name held job.sh")
+	if err != nil {
+		t.Fatalf("Job should be submitted: %v", err)
+	}
+	id := strings.TrimPrefix(strings.TrimSpace(output), "Job ")
+	if _, err := client.ExecCmd(ctx, "cancel "+id); err != nil {
+		t.Errorf("Job should be cancelled: %v", err)
+	}
+	if _, err := client.ExecCmd(ctx, "cancel 100"); err == nil || !strings.Contains(err.Error(), "unknown job 100") {
+		t.Errorf("Unknown jobs should not be cancelled: %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"fmt"
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	shellquote "github.com/kballard/go-shellquote"
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// HetJobAnnotation is the pod annotation with the containers which start together as the components of
+	// one heterogeneous job, in component order, e.g. multicri.atrio.io/hetjob: "simulation,analysis"
+	HetJobAnnotation = "multicri.atrio.io/hetjob"
+	// HetJobExitCodeFile is written in the container path with the exit code and the end time of the
+	// container of a component
+	HetJobExitCodeFile = "exitcode"
+)
+
+// hetJobComponents returns the containers of the heterogeneous job declared in the pod annotations
+func hetJobComponents(annotations map[string]string) ([]string, error) {
+	value, ok := annotations[HetJobAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var components []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			return nil, adapters.InvalidArgumentError("Wrong container %q in %s: %s", name, HetJobAnnotation, value)
+		}
+		seen[name] = true
+		components = append(components, name)
+	}
+	if len(components) < 2 {
+		return nil, adapters.InvalidArgumentError("A heterogeneous job needs at least two containers in %s: %s",
+			HetJobAnnotation, value)
+	}
+	return components, nil
+}
+
+// hetJobOffset returns the offset of the container in the heterogeneous job of its pod, or -1 when it is
+// not one of its components
+func hetJobOffset(annotations map[string]string, name string) int {
+	components, _ := hetJobComponents(annotations)
+	for offset, component := range components {
+		if component == name {
+			return offset
+		}
+	}
+	return -1
+}
+
+// validateHetJob returns an error when the heterogeneous job of the pod is wrong, or the container can not
+// run as one of its components
+func (s SlurmAdapter) validateHetJob(cm *store.ContainerMetadata) error {
+	annotations := cm.PodSandbox.Config.Annotations
+	components, err := hetJobComponents(annotations)
+	if err != nil || len(components) == 0 {
+		return err
+	}
+	if s.Allocation == AllocationPod {
+		return adapters.InvalidArgumentError("Heterogeneous jobs are not supported with the pod allocation")
+	}
+	if s.Transport != TransportSSH {
+		return adapters.InvalidArgumentError("Heterogeneous jobs require the %s transport", TransportSSH)
+	}
+	if hasDependencies(annotations) {
+		return adapters.InvalidArgumentError("Container dependencies are not supported with heterogeneous jobs")
+	}
+	if hetJobOffset(annotations, cm.Name) < 0 {
+		return nil
+	}
+	settings := batch.ParseJobSettings(cm)
+	if settings.Array != "" {
+		return adapters.InvalidArgumentError("Job arrays are not supported in heterogeneous jobs")
+	}
+	if settings.IsMPI() {
+		return adapters.InvalidArgumentError("MPI jobs are not supported in heterogeneous jobs")
+	}
+	return nil
+}
+
+// hetJob is the heterogeneous job of a pod. The started components wait until every component is started,
+// then they are submitted together, and the next start of a component begins a new job. The lock is held
+// while the job is submitted, so the rest of the pods are not blocked.
+type hetJob struct {
+	lock    sync.Mutex
+	started map[int]*store.ContainerMetadata
+	jobId   int
+}
+
+// podHetJobs are the heterogeneous jobs of the running pods, by pod sandbox id. A nil podHetJobs has
+// no jobs.
+type podHetJobs struct {
+	lock sync.Mutex
+	jobs map[string]*hetJob
+}
+
+func newPodHetJobs() *podHetJobs {
+	return &podHetJobs{jobs: make(map[string]*hetJob)}
+}
+
+// job returns the heterogeneous job of the pod, which is created when the pod has none
+func (p *podHetJobs) job(sandboxID string) *hetJob {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	h, ok := p.jobs[sandboxID]
+	if !ok {
+		h = &hetJob{started: make(map[int]*store.ContainerMetadata)}
+		p.jobs[sandboxID] = h
+	}
+	return h
+}
+
+// lookup returns the id of the last heterogeneous job submitted for the pod, unless the container waits
+// for the next one
+func (p *podHetJobs) lookup(sandboxID, containerID string) (int, bool) {
+	if p == nil {
+		return 0, false
+	}
+	p.lock.Lock()
+	h, ok := p.jobs[sandboxID]
+	p.lock.Unlock()
+	if !ok {
+		return 0, false
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.jobId == 0 {
+		return 0, false
+	}
+	for _, cm := range h.started {
+		if cm.ID == containerID {
+			return 0, false
+		}
+	}
+	return h.jobId, true
+}
+
+// stop removes the component from the next heterogeneous job of the pod, when it was not submitted yet
+func (p *podHetJobs) stop(sandboxID string, offset int) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	h, ok := p.jobs[sandboxID]
+	p.lock.Unlock()
+	if ok {
+		h.lock.Lock()
+		delete(h.started, offset)
+		h.lock.Unlock()
+	}
+}
+
+func (p *podHetJobs) remove(sandboxID string) {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.jobs, sandboxID)
+}
+
+// restore rebuilds the heterogeneous jobs of the pods from their stored components: the last submitted job,
+// whose components are recorded by the component which submitted it, and the components which wait for
+// the next one
+func (p *podHetJobs) restore(containers []*store.ContainerMetadata) {
+	if p == nil {
+		return
+	}
+	submitted := make(map[string]map[string]bool)
+	for _, cm := range containers {
+		if cm.Extra["HetJobContainers"] == "" {
+			continue
+		}
+		h := p.job(cm.PodSandbox.ID)
+		if cm.Pid > h.jobId {
+			h.jobId = cm.Pid
+			submitted[cm.PodSandbox.ID] = make(map[string]bool)
+			for _, id := range strings.Split(cm.Extra["HetJobContainers"], ",") {
+				submitted[cm.PodSandbox.ID][id] = true
+			}
+		}
+	}
+	for _, cm := range containers {
+		if cm.Extra["HetJobOffset"] == "" || cm.Pid != 0 || cm.State == runtimeApi.ContainerState_CONTAINER_EXITED ||
+			submitted[cm.PodSandbox.ID][cm.ID] {
+			continue
+		}
+		offset, err := strconv.Atoi(cm.Extra["HetJobOffset"])
+		if err != nil {
+			klog.Warningf("Offset of the heterogeneous job component %s cannot be parsed %s", cm.ID, cm.Extra["HetJobOffset"])
+			continue
+		}
+		p.job(cm.PodSandbox.ID).started[offset] = cm
+	}
+}
+
+// startComponent starts the container as the component of the offset in the heterogeneous job of its pod.
+// The job is submitted by the last component which starts, the rest stay created until then. The
+// components of the job are recorded in the container which submits it, so the job is restored after
+// restarts, and every component keeps the resources it requests from the job.
+func (s SlurmAdapter) startComponent(ctx context.Context, cm *store.ContainerMetadata, offset int) error {
+	if s.hetJobs == nil {
+		return fmt.Errorf("heterogeneous jobs are not set up")
+	}
+	components, err := hetJobComponents(cm.PodSandbox.Config.Annotations)
+	if err != nil {
+		return err
+	}
+	resources := requestedResources(batch.ParseJobSettings(cm), linuxResources(cm))
+	h := s.hetJobs.job(cm.PodSandbox.ID)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.started[offset] = cm
+	cm.Pid = 0
+	cm.Extra["HetJobOffset"] = strconv.Itoa(offset)
+	delete(cm.Extra, "HetJobContainers")
+	resources.keep(cm)
+	if len(h.started) < len(components) {
+		klog.Infof("Container %s waits for %d containers of the heterogeneous job of pod %s", cm.Name,
+			len(components)-len(h.started), cm.PodSandbox.ID)
+		return nil
+	}
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return err
+	}
+	podPath := getRMPodPath(cm)
+	if _, err := slurmClient.ExecCmd(ctx, fmt.Sprintf("mkdir -p %s", podPath)); err != nil {
+		return err
+	}
+	jobId, err := slurmClient.Sbatch(ctx, s.buildHetJob(cm, podPath, h.started))
+	if err != nil {
+		delete(h.started, offset)
+		return err
+	}
+	id, err := strconv.Atoi(jobId)
+	if err != nil {
+		delete(h.started, offset)
+		return fmt.Errorf("Job id of the heterogeneous job cannot be parsed %s ", jobId)
+	}
+	klog.Infof("Submitted heterogeneous job %d for pod %s", id, cm.PodSandbox.ID)
+	var ids []string
+	for offset := 0; offset < len(h.started); offset++ {
+		ids = append(ids, h.started[offset].ID)
+	}
+	h.jobId = id
+	h.started = make(map[int]*store.ContainerMetadata)
+	cm.Pid = id
+	cm.Extra["HetJobContainers"] = strings.Join(ids, ",")
+	return nil
+}
+
+// buildHetJob returns the heterogeneous job of the components, by offset. Every component has the
+// resources of its container, and the job runs the container of each component as a step in it.
+func (s SlurmAdapter) buildHetJob(cm *store.ContainerMetadata, podPath string, components map[int]*store.ContainerMetadata) *cmd.JobConfig {
+	jobConf := &cmd.JobConfig{
+		Path:   podPath,
+		Script: getRMScriptPath(podPath),
+	}
+	var commands []string
+	for offset := 0; offset < len(components); offset++ {
+		component := components[offset]
+		settings := batch.ParseJobSettings(component)
+		if offset == 0 {
+			settings.Name = cm.PodSandbox.ID
+			if cm.PodSandbox.Config.Metadata != nil {
+				settings.Name = cm.PodSandbox.Config.Metadata.Name
+			}
+			setupBatchHeaders(settings, linuxResources(component), jobConf)
+			if settings.ClusterConfig != "" {
+				jobConf.Prerun = settings.ClusterConfig
+			}
+		} else {
+			jobConf.HetJobHeaders = append(jobConf.HetJobHeaders, resourceHeaders(settings, linuxResources(component)))
+		}
+		commands = append(commands, s.componentCommand(component, settings, offset))
+	}
+	jobConf.Command = strings.Join(append(commands, "wait"), "\n")
+	return jobConf
+}
+
+// componentCommand returns the launcher of the container of the component, which runs it in the container
+// path, relative to the pod path, and writes its exit code and end time
+func (s SlurmAdapter) componentCommand(cm *store.ContainerMetadata, settings batch.JobSettings, offset int) string {
+	commands := []string{fmt.Sprintf("cd %s", cm.ID)}
+	commands = append(commands, batch.ExportEnvironment(batch.FilterEnvironment(cm))...)
+	srun := shellquote.Join("srun", fmt.Sprintf("--het-group=%d", offset), "--output="+StdoutFile,
+		"--error="+SterrFile, "bash", "-c", s.buildStartCommand(cm, settings).Command)
+	return fmt.Sprintf("(%s && { %s; echo $? $(date +%%s) > %s; }) &", strings.Join(commands, " && "), srun,
+		HetJobExitCodeFile)
+}
+
+// componentReference returns the reference of the component of the container, or false when its
+// heterogeneous job is not submitted yet
+func (s SlurmAdapter) componentReference(cm *store.ContainerMetadata) (*cmd.JobReference, bool, error) {
+	offset, err := strconv.Atoi(cm.Extra["HetJobOffset"])
+	if err != nil {
+		return nil, false, fmt.Errorf("Offset of the heterogeneous job component cannot be parsed %s ", cm.Extra["HetJobOffset"])
+	}
+	if cm.Pid == 0 {
+		jobId, ok := s.hetJobs.lookup(cm.PodSandbox.ID, cm.ID)
+		if !ok {
+			return nil, false, nil
+		}
+		cm.Pid = jobId
+	}
+	return &cmd.JobReference{JobId: int32(cm.Pid), HetJob: true, HetJobOffset: offset}, true, nil
+}
+
+// stopComponent cancels the component of the container, and the rest of the heterogeneous job keeps
+// running. A component which waits for the job is removed from it.
+func (s SlurmAdapter) stopComponent(ctx context.Context, jobClient cmd.JobClient, cm *store.ContainerMetadata) error {
+	jobRef, ok, err := s.componentReference(cm)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.hetJobs.stop(cm.PodSandbox.ID, hetJobOffset(cm.PodSandbox.Config.Annotations, cm.Name))
+		return nil
+	}
+	return jobClient.Scancel(ctx, *jobRef)
+}
+
+// componentStatus returns the status of the container of a component. The components end with the job, so
+// the container which exited before is known from its exit code file.
+func componentStatus(ctx context.Context, jobClient cmd.JobClient, cm *store.ContainerMetadata, jobRef *cmd.JobReference) (*cmd.JobStatus, error) {
+	status, err := jobClient.Sstatus(ctx, jobRef)
+	if err != nil {
+		return nil, err
+	}
+	if status.JobState != "RUNNING" && status.JobState != "COMPLETED" && status.JobState != "FAILED" {
+		return status, nil
+	}
+	slurmClient, err := cmd.CreateCMD(cm)
+	if err != nil {
+		return nil, err
+	}
+	out, err := slurmClient.ExecCmd(ctx, fmt.Sprintf("cat %s 2>/dev/null || true", batch.RMFile(cm, HetJobExitCodeFile)))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) < 2 {
+		return status, nil
+	}
+	exitCode, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("Exit code of heterogeneous job component cannot be parsed %s ", out)
+	}
+	end, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		end = time.Now().Unix()
+	}
+	status.ExitCode = exitCode
+	status.EndTime = end
+	status.JobState = "COMPLETED"
+	if exitCode != 0 {
+		status.JobState = "FAILED"
+	}
+	return status, nil
+}
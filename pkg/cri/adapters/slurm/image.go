@@ -15,31 +15,32 @@
 package slurm
 
 import (
+	"multi-cri/pkg/cri/adapters"
 	"multi-cri/pkg/cri/store"
-	"fmt"
 
 	"time"
 
+	"golang.org/x/net/context"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
-func (s SlurmAdapter) PullImage(image *store.ImageMetadata) error {
+func (s SlurmAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
 	if image.RepoType == store.UnknownImageRepo {
-		return fmt.Errorf("Image repository type not supported by multi-cri %s ", image.RemotePath)
+		return adapters.InvalidArgumentError("Image repository type not supported by multi-cri %s ", image.RemotePath)
 	}
 	container := &store.ContainerMetadata{Image: image}
-	return s.Builder.PullImage(container)
+	return s.Builder.PullImage(ctx, container)
 }
 
-func (s SlurmAdapter) ListImages(images []*runtimeApi.Image) error {
+func (s SlurmAdapter) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
 	return nil
 }
 
-func (s SlurmAdapter) ImageStatus(image *store.ImageMetadata) error {
+func (s SlurmAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
 	return nil
 }
 
-func (s SlurmAdapter) ImageFsInfo() (*runtimeApi.ImageFsInfoResponse, error) {
+func (s SlurmAdapter) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
 	//todo control it properly
 	filesystems := []*runtimeApi.FilesystemUsage{
 		{
@@ -53,6 +54,6 @@ func (s SlurmAdapter) ImageFsInfo() (*runtimeApi.ImageFsInfoResponse, error) {
 	return &runtimeApi.ImageFsInfoResponse{ImageFilesystems: filesystems}, nil
 }
 
-func (s SlurmAdapter) RemoveImage(image *store.ImageMetadata) error {
+func (s SlurmAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
 	return nil
 }
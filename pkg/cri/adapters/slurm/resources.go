@@ -0,0 +1,120 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"fmt"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+	"strconv"
+	"strings"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// linuxResources returns the resources which the kubelet sets from the requests and limits of the
+// container, or nil
+func linuxResources(cm *store.ContainerMetadata) *runtimeApi.LinuxContainerResources {
+	if cm.Config.Linux == nil {
+		return nil
+	}
+	return cm.Config.Linux.Resources
+}
+
+// resourceOptions returns the Slurm options of the resources of the container: --cpus-per-task with the
+// CPU quota, or the cpuset, and --mem with the memory limit. The job settings override them, so the CPUs
+// are not requested when the cores, the tasks or the nodes are set, nor any option set in JOB_CUSTOM_CONFIG.
+func resourceOptions(settings batch.JobSettings, r *runtimeApi.LinuxContainerResources) []string {
+	var options []string
+	if cpus := requestedCPUs(settings, r); cpus > 0 {
+		options = append(options, fmt.Sprintf("--cpus-per-task=%d", cpus))
+	}
+	if memory := requestedMemory(settings, r); memory > 0 {
+		options = append(options, fmt.Sprintf("--mem=%dM", memory))
+	}
+	return options
+}
+
+// requestedCPUs returns the CPUs of the resources which the job requests, or 0 when the job settings
+// override them
+func requestedCPUs(settings batch.JobSettings, r *runtimeApi.LinuxContainerResources) int {
+	if r == nil || settings.Cores != "" || settings.CoresPerNode != "" || settings.TasksPerNode != "" ||
+		settings.Nodes != "" || customOption(settings, "-c", "--cpus-per-task") {
+		return 0
+	}
+	return resourceCPUs(r)
+}
+
+// requestedMemory returns the memory in MiB of the resources which the job requests, or 0 when the job
+// settings override it
+func requestedMemory(settings batch.JobSettings, r *runtimeApi.LinuxContainerResources) int64 {
+	if r == nil || customOption(settings, "--mem", "--mem-per-cpu", "--mem-per-gpu") {
+		return 0
+	}
+	return resourceMemory(r)
+}
+
+// resourceCPUs returns the CPUs of the CPU quota, rounded up, or the CPUs of the cpuset. It is 0 when
+// neither is set.
+func resourceCPUs(r *runtimeApi.LinuxContainerResources) int {
+	if r.CpuQuota > 0 && r.CpuPeriod > 0 {
+		return int((r.CpuQuota + r.CpuPeriod - 1) / r.CpuPeriod)
+	}
+	return cpusetCount(r.CpusetCpus)
+}
+
+// resourceMemory returns the memory limit in MiB, rounded up, or 0 without limit
+func resourceMemory(r *runtimeApi.LinuxContainerResources) int64 {
+	if r.MemoryLimitInBytes <= 0 {
+		return 0
+	}
+	return (r.MemoryLimitInBytes + 1<<20 - 1) >> 20
+}
+
+// cpusetCount returns the number of CPUs of a cpuset, e.g. 5 for "0-3,7", or 0 when it can not be parsed
+func cpusetCount(cpuset string) int {
+	count := 0
+	for _, item := range strings.Split(cpuset, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return 0
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return 0
+			}
+		}
+		count += last - first + 1
+	}
+	return count
+}
+
+// customOption returns whether JOB_CUSTOM_CONFIG sets any of the options
+func customOption(settings batch.JobSettings, options ...string) bool {
+	for _, field := range strings.Fields(settings.CustomConfig) {
+		for _, option := range options {
+			if field == option || strings.HasPrefix(field, option+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -16,19 +16,31 @@ package slurm
 
 import (
 	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
 )
 
-func (r SlurmAdapter) RunPodSandbox(metadata *store.SandboxMetadata) error {
+// RunPodSandbox does nothing. With the pod allocation, the allocation is requested by the first container
+// of the pod, since the cluster credentials are in the container environment.
+func (r SlurmAdapter) RunPodSandbox(ctx context.Context, metadata *store.SandboxMetadata) error {
 	return nil
 }
 
-func (r SlurmAdapter) StopPodSandbox(sandbox *store.SandboxMetadata) error {
-	return nil
+// StopPodSandbox releases the allocation of the pod, if it has one
+func (r SlurmAdapter) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return r.releaseAllocation(ctx, sandbox.ID)
 }
-func (r SlurmAdapter) RemovePodSandbox(sandbox *store.SandboxMetadata) error {
+// RemovePodSandbox releases the allocation of the pod, and forgets the jobs and the heterogeneous job of
+// its containers
+func (r SlurmAdapter) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	if err := r.releaseAllocation(ctx, sandbox.ID); err != nil {
+		return err
+	}
+	r.jobs.remove(sandbox.ID)
+	r.hetJobs.remove(sandbox.ID)
 	return nil
 }
 
-func (r SlurmAdapter) PodSandboxStatus(sandbox *store.SandboxMetadata) error {
+func (r SlurmAdapter) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
 	return nil
 }
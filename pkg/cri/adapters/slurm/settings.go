@@ -0,0 +1,54 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+	"regexp"
+)
+
+var (
+	// slurmName matches the names of the partitions, accounts and QOS
+	slurmName = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+	// nodeConstraint matches the node features of a constraint with their operators, e.g. "[rack1|rack2]&ib"
+	nodeConstraint = regexp.MustCompile(`^[A-Za-z0-9_.\-&|,*\[\]()]+$`)
+	// nodeRange matches a node count or a min-max range of node counts, e.g. "2" or "2-4"
+	nodeRange = regexp.MustCompile(`^[1-9][0-9]*(-[1-9][0-9]*)?$`)
+)
+
+// jobSettingRules are the job settings which Slurm supports, with the formats of their values
+var jobSettingRules = []batch.JobSettingRule{
+	{batch.PartitionSetting, batch.ListOf(slurmName), "a list of partition names separated by commas"},
+	batch.TimeRule,
+	{batch.AccountSetting, slurmName.MatchString, "an account name"},
+	{batch.QOSSetting, slurmName.MatchString, "a QOS name"},
+	{batch.ConstraintSetting, nodeConstraint.MatchString, "a list of node features with the operators &, |, [], * and ()"},
+	batch.GresRule,
+	{batch.NodesSetting, nodeRange.MatchString, "a node count or a min-max range, e.g. 2-4"},
+	batch.CoresRule,
+	batch.CoresPerNodeRule,
+	batch.TasksPerNodeRule,
+	{batch.ArraySetting, validArray, "an array specification, e.g. 0-99%10 or 1,3,5-9:2"},
+	{batch.ArrayPolicySetting, validArrayPolicy, "the array policy " + ArrayPolicyAll + " or " + ArrayPolicyAny},
+	batch.CustomConfigRule,
+}
+
+// validateJobSettings checks the job settings of the container and the annotations of the dependencies and
+// the heterogeneous jobs, and names the annotation or the environment variable which sets the wrong ones
+func validateJobSettings(cm *store.ContainerMetadata) error {
+	return batch.ValidateJobSettings(cm, "Slurm", jobSettingRules, DependencyAnnotation, SequentialAnnotation,
+		HetJobAnnotation)
+}
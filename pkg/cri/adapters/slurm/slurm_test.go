@@ -21,11 +21,16 @@ import (
 	"testing"
 	"time"
 
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
 	"multi-cri/pkg/cri/common"
 	"multi-cri/pkg/cri/common/file"
 	"multi-cri/pkg/cri/store"
 
 	uuid "github.com/satori/go.uuid"
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
 func recoverEnv(t *testing.T) {
@@ -86,17 +91,17 @@ func TestIntegrationCreateStartContainer_integration(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if err := adap.PullImage(img); err != nil {
+	if err := adap.PullImage(context.Background(), img); err != nil {
 		t.Fatal(err)
 	}
 
 	c.Image = img
 
-	if err := adap.CreateContainer(c); err != nil {
+	if err := adap.CreateContainer(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
 
-	if err := adap.StartContainer(c); err != nil {
+	if err := adap.StartContainer(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
 	timeout := time.After(20 * time.Second)
@@ -109,7 +114,7 @@ func TestIntegrationCreateStartContainer_integration(t *testing.T) {
 			t.Fatal("timed out")
 			// Got a tick, we should check on doSomething()
 		case <-tick:
-			if err := adap.ContainerStatus(c); err != nil {
+			if err := adap.ContainerStatus(context.Background(), c); err != nil {
 				t.Fatal(err)
 			}
 			if c.State > 1 {
@@ -141,21 +146,21 @@ func TestIntegrationCreateStartStopContainer_integration(t *testing.T) {
 		t.Error(err)
 	}
 
-	if err := adap.PullImage(img); err != nil {
+	if err := adap.PullImage(context.Background(), img); err != nil {
 		t.Fatal(err)
 	}
 	c.Image = img
 
-	if err := adap.CreateContainer(c); err != nil {
+	if err := adap.CreateContainer(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
-	if err := adap.StartContainer(c); err != nil {
+	if err := adap.StartContainer(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
-	if err := adap.StopContainer(c); err != nil {
+	if err := adap.StopContainer(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
-	if err := adap.ContainerStatus(c); err != nil {
+	if err := adap.ContainerStatus(context.Background(), c); err != nil {
 		t.Fatal(err)
 	}
 	if c.State != 2 {
@@ -163,3 +168,40 @@ func TestIntegrationCreateStartStopContainer_integration(t *testing.T) {
 	}
 
 }
+
+func TestIntegrationConformance_integration(t *testing.T) {
+	defer recoverEnv(t)
+	password := common.GetEnv("TEST_SSH_PASSWORD", nil)
+	user := common.GetEnv("TEST_SSH_USER", nil)
+	host := common.GetEnv("TEST_SSH_HOST", nil)
+	port := common.GetEnv("TEST_SSH_PORT", nil)
+	if err := os.Setenv("CRI_SLURM_BUILD_IN_CLUSTER", "true"); err != nil {
+		t.Fatal(err)
+	}
+	conformance.Run(t, conformance.Config{
+		New: NewSlurmAdapter,
+		Fixtures: func(t *testing.T) *conformance.Fixtures {
+			sandbox, c, img := createStructures(user, password, host, port)
+			return &conformance.Fixtures{Sandbox: sandbox, Container: c, Image: img}
+		},
+		// Pending jobs are reported as created
+		StartedStates: []runtimeApi.ContainerState{
+			runtimeApi.ContainerState_CONTAINER_CREATED,
+			runtimeApi.ContainerState_CONTAINER_RUNNING,
+			runtimeApi.ContainerState_CONTAINER_EXITED,
+		},
+		Timeout: 2 * time.Minute,
+	})
+}
+
+//Test the adapter passes the conformance suite against the fake cluster
+func TestUnitConformance(t *testing.T) {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	conformance.Run(t, conformance.FakeClusterConfig(func() (adapters.AdapterInterface, error) {
+		return NewSlurmAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	}, cluster.Credentials()))
+}
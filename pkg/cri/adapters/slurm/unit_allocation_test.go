@@ -0,0 +1,243 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// fakeBuilder does not pull images, the fake cluster runs the commands without them
+type fakeBuilder struct{}
+
+func (fakeBuilder) PullImage(ctx context.Context, cm *store.ContainerMetadata) error {
+	return nil
+}
+
+func (fakeBuilder) PullImageInCluster(ctx context.Context, cm *store.ContainerMetadata) error {
+	return nil
+}
+
+func (fakeBuilder) GetImagePath(cm *store.ContainerMetadata) string {
+	return "image.sif"
+}
+
+// Test the allocation options are validated
+func TestUnitValidateAllocation(t *testing.T) {
+	if err := validateConfig(adapters.AdapterConfig{"allocation": AllocationPod}); err != nil {
+		t.Errorf("Pod allocation should be valid: %v", err)
+	}
+	for _, config := range []adapters.AdapterConfig{
+		{"allocation": "node"},
+		{"allocation": AllocationPod, "transport": TransportRest},
+	} {
+		if err := validateConfig(config); err == nil {
+			t.Errorf("Config %v should not be valid", config)
+		}
+	}
+	for _, settings := range []batch.JobSettings{{Array: "0-3"}, {MPIVersion: "3"}} {
+		if err := validateStep(settings); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Settings %+v should not run as a step: %v", settings, err)
+		}
+	}
+	options := stepOptions(batch.JobSettings{Cores: "4", CoresPerNode: "2", GPU: "gpu:1", Queue: "debug"}, nil)
+	if !reflect.DeepEqual(options, []string{"--ntasks=4", "--cpus-per-task=2", "--gres=gpu:1"}) {
+		t.Errorf("Wrong step options %v", options)
+	}
+}
+
+// startTestCluster starts a fake cluster, and returns the log file of its containers
+func startTestCluster(t *testing.T) (*fakecluster.Cluster, string) {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile, err := ioutil.TempFile("", "slurm-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Close()
+	return cluster, logFile.Name()
+}
+
+// testContainer creates and starts a container of the pod in the fake cluster
+func testContainer(adapter SlurmAdapter, cluster *fakecluster.Cluster, sandbox *store.SandboxMetadata, logFile, name string,
+	command ...string) (*store.ContainerMetadata, error) {
+	ctx := context.Background()
+	cm := &store.ContainerMetadata{ID: name, Name: name, PodSandbox: *sandbox, Command: command, LogFile: logFile,
+		Image: &store.ImageMetadata{RemotePath: "docker://alpine"}, Extra: make(map[string]string),
+		Environment: map[string]string{"CLUSTER_USERNAME": cluster.User, "CLUSTER_PASSWORD": cluster.Password,
+			"CLUSTER_HOST": cluster.Host, "CLUSTER_PORT": cluster.Port, "JOB_NUM_CORES": "1"}}
+	if err := adapter.CreateContainer(ctx, cm); err != nil {
+		return cm, err
+	}
+	return cm, adapter.StartContainer(ctx, cm)
+}
+
+// waitContainerState waits until the container is in the state
+func waitContainerState(t *testing.T, adapter SlurmAdapter, cm *store.ContainerMetadata, state runtimeApi.ContainerState) {
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if err := adapter.ContainerStatus(context.Background(), cm); err == nil && cm.State == state {
+			return
+		}
+	}
+	t.Fatalf("Container %s should be %s: %+v", cm.Name, state, cm)
+}
+
+// Test the containers of a pod run as steps of one allocation, which is released with the pod
+func TestUnitPodAllocation(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	ctx := context.Background()
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+		Allocation: AllocationPod, pods: newPodAllocations()}
+	sandbox := &store.SandboxMetadata{ID: "pod", Config: runtimeApi.PodSandboxConfig{
+		Metadata: &runtimeApi.PodSandboxMetadata{Name: "web"}}}
+	create := func(name string, command ...string) *store.ContainerMetadata {
+		cm, err := testContainer(adapter, cluster, sandbox, logFile, name, command...)
+		if err != nil {
+			t.Fatalf("Container %s should be started: %v", name, err)
+		}
+		return cm
+	}
+	waitState := func(cm *store.ContainerMetadata, state runtimeApi.ContainerState) {
+		waitContainerState(t, adapter, cm, state)
+	}
+
+	main := create("main", "false")
+	sidecar := create("sidecar", "sleep", "60")
+	jobs := cluster.Jobs()
+	if len(jobs) != 1 || jobs[0].Name != "web" || main.Extra["AllocationJobId"] != strconv.Itoa(jobs[0].ID) ||
+		sidecar.Pid != jobs[0].ID {
+		t.Fatalf("The containers should share one allocation: %+v %+v", jobs, sidecar)
+	}
+	waitState(main, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if main.ExitCode != 1 || main.Reason != "FAILED" {
+		t.Errorf("Container should exit with the code of its step: %+v", main)
+	}
+	waitState(sidecar, runtimeApi.ContainerState_CONTAINER_RUNNING)
+	if err := adapter.StopContainer(ctx, sidecar); err != nil {
+		t.Fatal(err)
+	}
+	waitState(sidecar, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if job, _ := cluster.Job(jobs[0].ID); job.State != fakecluster.StateRunning {
+		t.Errorf("Allocation should be kept until the pod stops: %+v", job)
+	}
+
+	if err := adapter.StopPodSandbox(ctx, sandbox); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := cluster.WaitJob(jobs[0].ID, 10*time.Second); err != nil || job.State != fakecluster.StateCancelled {
+		t.Errorf("Allocation should be released: %+v %v", job, err)
+	}
+	if err := adapter.RemovePodSandbox(ctx, sandbox); err != nil {
+		t.Errorf("Released allocation should be removed: %v", err)
+	}
+}
+
+// Test the allocation of a pod is used and released after restarts
+func TestUnitRestoreAllocation(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	ctx := context.Background()
+	sandbox := &store.SandboxMetadata{ID: "pod", Config: runtimeApi.PodSandboxConfig{
+		Metadata: &runtimeApi.PodSandboxMetadata{Name: "web"}}}
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+		Allocation: AllocationPod, pods: newPodAllocations()}
+	main, err := testContainer(adapter, cluster, sandbox, logFile, "main", "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+		Allocation: AllocationPod, pods: newPodAllocations()}
+	if err := restarted.Restore(ctx, []*store.ContainerMetadata{main}); err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := testContainer(restarted, cluster, sandbox, logFile, "sidecar", "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := cluster.Jobs()
+	if len(jobs) != 1 || sidecar.Extra["AllocationJobId"] != main.Extra["AllocationJobId"] {
+		t.Fatalf("The restored allocation should be shared: %+v %+v", jobs, sidecar)
+	}
+	waitContainerState(t, restarted, sidecar, runtimeApi.ContainerState_CONTAINER_RUNNING)
+	if err := restarted.StopPodSandbox(ctx, sandbox); err != nil {
+		t.Fatal(err)
+	}
+	if job, err := cluster.WaitJob(jobs[0].ID, 10*time.Second); err != nil || job.State != fakecluster.StateCancelled {
+		t.Errorf("Restored allocation should be released: %+v %v", job, err)
+	}
+}
+
+// Test the containers of a pod request one allocation, and the requests of a pod do not wait for other pods
+func TestUnitConcurrentAllocations(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+		Allocation: AllocationPod, pods: newPodAllocations()}
+	container := func(pod, name string) *store.ContainerMetadata {
+		return &store.ContainerMetadata{ID: name, Name: name, PodSandbox: store.SandboxMetadata{ID: pod}, LogFile: logFile,
+			Extra: map[string]string{"RMVolumePath": MOUNTHPATH}, Environment: cluster.Credentials()}
+	}
+	// The request of the busy pod holds its lock
+	busy := adapter.pods.pod("busy")
+	busy.lock.Lock()
+	defer busy.lock.Unlock()
+
+	ids := make(chan int32, 3)
+	for _, name := range []string{"main", "sidecar", "init"} {
+		go func(name string) {
+			jobId, err := adapter.requestAllocation(context.Background(), container("pod", name))
+			if err != nil {
+				t.Errorf("Allocation should be requested: %v", err)
+			}
+			ids <- jobId
+		}(name)
+	}
+	first := <-ids
+	for i := 0; i < 2; i++ {
+		select {
+		case jobId := <-ids:
+			if jobId != first || first == 0 {
+				t.Errorf("The containers of the pod should share one allocation: %d %d", first, jobId)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Allocation of the pod should not wait for the busy pod")
+		}
+	}
+	if jobs := cluster.Jobs(); len(jobs) != 1 {
+		t.Errorf("The pod should submit one allocation: %+v", jobs)
+	}
+}
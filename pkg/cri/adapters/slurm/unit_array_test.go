@@ -0,0 +1,149 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test the array specifications are parsed and validated without expanding their tasks
+func TestUnitParseArray(t *testing.T) {
+	for spec, expected := range map[string][]int{
+		"3":         {3},
+		"0-4%2":     {0, 1, 2, 3, 4},
+		"1,3,5-9:2": {1, 3, 5, 7, 9},
+		"4-6,0,5":   {0, 4, 5, 6},
+		"10-12:5%1": {10},
+	} {
+		array, err := parseArray(spec)
+		if err != nil || array.size() != len(expected) {
+			t.Errorf("Wrong tasks of %s: %v %v", spec, array, err)
+			continue
+		}
+		for _, task := range expected {
+			if !array.contains(task) {
+				t.Errorf("Array %s should contain task %d", spec, task)
+			}
+		}
+		if array.contains(11) || array.contains(13) {
+			t.Errorf("Array %s should not contain other tasks", spec)
+		}
+	}
+	for _, spec := range []string{"", "a", "5-1", "1-5:0", "1-5%", "1-5%0", "1;2", "0-2000000000", "4000001",
+		"0-3000000,0-3000000", "99999999999999999999"} {
+		if _, err := parseArray(spec); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Array %q should be invalid: %v", spec, err)
+		}
+	}
+	if array, err := parseArray("0-4000000"); err != nil || array.size() != maxArraySize {
+		t.Errorf("Array with the largest MaxArraySize should be valid: %v", err)
+	}
+	if validArrayPolicy("first") || !validArrayPolicy(ArrayPolicyAny) || !validArray("0-9") {
+		t.Errorf("Only the known policies should be valid")
+	}
+}
+
+// Test the array tasks are aggregated in a single state and exit code with both policies
+func TestUnitAggregateArray(t *testing.T) {
+	task := func(id, state string, exitCode int, start, end int64) *cmd.JobStatus {
+		return &cmd.JobStatus{ArrayTaskId: id, JobState: state, ExitCode: exitCode, StarTime: start, EndTime: end}
+	}
+	array, _ := parseArray("0-2")
+	for _, c := range []struct {
+		statuses []*cmd.JobStatus
+		policy   string
+		state    string
+		exitCode int
+		started  []int
+	}{
+		{[]*cmd.JobStatus{task("0-2", "PENDING", 0, 0, 0)}, ArrayPolicyAll, "PENDING", 0, nil},
+		{[]*cmd.JobStatus{task("0", "RUNNING", 0, 10, 0), task("1-2", "PENDING", 0, 0, 0)}, ArrayPolicyAll, "RUNNING", 0, []int{0}},
+		{[]*cmd.JobStatus{task("0", "COMPLETED", 0, 10, 20), task("2", "COMPLETED", 0, 5, 30), task("1", "COMPLETED", 0, 8, 25)},
+			"", "COMPLETED", 0, []int{0, 1, 2}},
+		{[]*cmd.JobStatus{task("0", "COMPLETED", 0, 10, 20), task("1", "FAILED", 3, 10, 20), task("2", "RUNNING", 0, 10, 0)},
+			ArrayPolicyAll, "RUNNING", 0, []int{0, 1, 2}},
+		{[]*cmd.JobStatus{task("0", "COMPLETED", 0, 10, 20), task("1", "FAILED", 3, 10, 20), task("2", "RUNNING", 0, 10, 0)},
+			ArrayPolicyAny, "FAILED", 3, []int{0, 1, 2}},
+		{[]*cmd.JobStatus{task("0", "COMPLETED", 0, 10, 20), task("1", "COMPLETED", 4, 10, 20), task("2", "TIMEOUT", 0, 10, 40)},
+			ArrayPolicyAll, "FAILED", 4, []int{0, 1, 2}},
+		{[]*cmd.JobStatus{task("0", "CANCELLED by 1000", 0, 10, 20), task("1", "COMPLETED", 0, 10, 20), task("2", "COMPLETED", 0, 10, 40)},
+			ArrayPolicyAll, "CANCELLED", 0, []int{0, 1, 2}},
+	} {
+		status, started := aggregateArray(array, c.statuses, c.policy)
+		if status.JobState != c.state || status.ExitCode != c.exitCode || !reflect.DeepEqual(started, c.started) {
+			t.Errorf("Wrong status of %s array: %+v %v", c.policy, status, started)
+		}
+	}
+	status, _ := aggregateArray(array, []*cmd.JobStatus{task("0", "COMPLETED", 0, 10, 20), task("2", "COMPLETED", 0, 5, 30),
+		task("1", "COMPLETED", 0, 8, 25)}, ArrayPolicyAll)
+	if status.StarTime != 5 || status.EndTime != 30 {
+		t.Errorf("Array should start with the first task and end with the last one: %+v", status)
+	}
+}
+
+// Test the array is submitted with an output file per task
+func TestUnitArrayHeaders(t *testing.T) {
+	jobConf := &cmd.JobConfig{}
+	setupBatchHeaders(batch.JobSettings{Name: "sweep", Array: "0-99%10"}, nil, jobConf)
+	expected := []cmd.JobConfigField{{"-J", "sweep"}, {"-o", ArrayStdoutFile}, {"-e", ArraySterrFile}, {"--array=0-99%10", ""}}
+	if !reflect.DeepEqual(jobConf.Headers, expected) {
+		t.Errorf("Wrong array headers: %v", jobConf.Headers)
+	}
+	paths := getRMArrayPaths("multi-cri/pod/container", ArrayStdoutFile, []int{0, 12})
+	if !reflect.DeepEqual(paths, []string{"multi-cri/pod/container/stdout_0.out", "multi-cri/pod/container/stdout_12.out"}) {
+		t.Errorf("Wrong output paths of the tasks: %v", paths)
+	}
+}
+
+// Test invalid arrays are rejected when the container is created, and the exited array shows the failed task
+func TestUnitArrayContainer(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	ctx := context.Background()
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, jobs: newPodJobs()}
+	cm := &store.ContainerMetadata{ID: "sweep", Name: "sweep", PodSandbox: store.SandboxMetadata{ID: "pod"},
+		Command: []string{"test", "$SLURM_ARRAY_TASK_ID", "=", "0"}, LogFile: logFile,
+		Image: &store.ImageMetadata{RemotePath: "docker://alpine"}, Extra: make(map[string]string),
+		Environment: map[string]string{"CLUSTER_USERNAME": cluster.User, "CLUSTER_PASSWORD": cluster.Password,
+			"CLUSTER_HOST": cluster.Host, "CLUSTER_PORT": cluster.Port, "JOB_ARRAY": "5-1"}}
+	if err := adapter.CreateContainer(ctx, cm); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Invalid arrays should not be created: %v", err)
+	}
+
+	cm.Environment["JOB_ARRAY"] = "0-1"
+	if err := adapter.CreateContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.StartContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	cm.State = runtimeApi.ContainerState_CONTAINER_RUNNING
+	waitContainerState(t, adapter, cm, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if cm.ExitCode != 1 || cm.Reason != "Array task 1 FAILED" {
+		t.Errorf("Array should fail with its failed task: %d %s", cm.ExitCode, cm.Reason)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test the dependencies of the containers are parsed from the pod annotations, and validated
+func TestUnitContainerDependencies(t *testing.T) {
+	annotations := map[string]string{
+		DependencyAnnotation + "compute": "preprocess",
+		DependencyAnnotation + "post":    "afterany:compute, afterok:preprocess",
+	}
+	dependencies, err := containerDependencies(annotations, "post")
+	if err != nil || len(dependencies) != 2 || dependencies[0] != (dependency{"afterany", "compute"}) ||
+		dependencies[1] != (dependency{DefaultDependencyType, "preprocess"}) {
+		t.Errorf("Wrong dependencies: %+v %v", dependencies, err)
+	}
+	if err := validateDependencies(annotations, "post"); err != nil {
+		t.Errorf("Dependencies should be valid: %v", err)
+	}
+	for name, value := range map[string]string{
+		"type":  "afterwards:compute",
+		"self":  "self",
+		"empty": "afterok:",
+	} {
+		if err := validateDependencies(map[string]string{DependencyAnnotation + name: value}, name); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Dependency %s of %s should not be valid: %v", value, name, err)
+		}
+	}
+	annotations[DependencyAnnotation+"preprocess"] = "post"
+	if err := validateDependencies(annotations, "compute"); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Circular dependencies should not be valid: %v", err)
+	}
+	if err := validateDependencies(map[string]string{SequentialAnnotation: "yes"}, "compute"); err == nil {
+		t.Errorf("Wrong sequential annotation should not be valid")
+	}
+}
+
+// Test the containers are submitted with the dependencies on the jobs of their pod, and the containers
+// whose dependencies can never be satisfied exit
+func TestUnitDependentContainers(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, jobs: newPodJobs()}
+	sandbox := &store.SandboxMetadata{ID: "pipeline", Config: runtimeApi.PodSandboxConfig{Annotations: map[string]string{
+		DependencyAnnotation + "compute": "preprocess",
+		DependencyAnnotation + "post":    "compute",
+		DependencyAnnotation + "cleanup": "afterany:compute",
+	}}}
+	create := func(name string, command ...string) *store.ContainerMetadata {
+		cm, err := testContainer(adapter, cluster, sandbox, logFile, name, command...)
+		if err != nil {
+			t.Fatalf("Container %s should be started: %v", name, err)
+		}
+		return cm
+	}
+
+	if _, err := testContainer(adapter, cluster, sandbox, logFile, "post", "true"); adapters.ErrorKindOf(err) != adapters.KindUnavailable {
+		t.Errorf("Container should not start before its dependencies: %v", err)
+	}
+	preprocess := create("preprocess", "sleep", "0.3")
+	compute := create("compute", "false")
+	post := create("post", "true")
+	cleanup := create("cleanup", "true")
+	if job, _ := cluster.Job(compute.Pid); job.Dependency != "afterok:"+strconv.Itoa(preprocess.Pid) {
+		t.Errorf("Wrong dependency of the job: %+v", job)
+	}
+	waitContainerState(t, adapter, compute, runtimeApi.ContainerState_CONTAINER_EXITED)
+	dependency, _ := cluster.Job(preprocess.Pid)
+	if job, _ := cluster.Job(compute.Pid); compute.ExitCode != 1 || job.StartTime.Before(dependency.EndTime) {
+		t.Errorf("Container should run after its dependency: %+v %+v", compute, job)
+	}
+	waitContainerState(t, adapter, cleanup, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if cleanup.ExitCode != 0 {
+		t.Errorf("Container should run after any end of its dependency: %+v", cleanup)
+	}
+	waitContainerState(t, adapter, post, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if post.ExitCode != 1 || post.Reason != ReasonDependencyNeverSatisfied {
+		t.Errorf("Container should exit when its dependency can never be satisfied: %+v", post)
+	}
+	if job, err := cluster.WaitJob(post.Pid, 10*time.Second); err != nil || job.State != fakecluster.StateCancelled {
+		t.Errorf("Job should be cancelled: %+v %v", job, err)
+	}
+	// Sequential containers depend on the container started before them
+	sandbox = &store.SandboxMetadata{ID: "sequential", Config: runtimeApi.PodSandboxConfig{
+		Annotations: map[string]string{SequentialAnnotation: "true"}}}
+	first := create("first", "true")
+	second := create("second", "true")
+	if job, _ := cluster.Job(second.Pid); job.Dependency != "afterok:"+strconv.Itoa(first.Pid) {
+		t.Errorf("Wrong dependency of the sequential job: %+v", job)
+	}
+}
+
+// Test the dependencies are resolved to the jobs of the stored containers after restarts, and wrong
+// dependencies are rejected before anything is created in the cluster
+func TestUnitRestoreDependencies(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, jobs: newPodJobs()}
+	sandbox := &store.SandboxMetadata{ID: "pipeline", Config: runtimeApi.PodSandboxConfig{Annotations: map[string]string{
+		DependencyAnnotation + "compute": "preprocess",
+		DependencyAnnotation + "loop":    "loop",
+	}}}
+	preprocess, err := testContainer(adapter, cluster, sandbox, logFile, "preprocess", "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	preprocess.StartedAt = time.Now().UnixNano()
+
+	restarted := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, jobs: newPodJobs()}
+	if err := restarted.Restore(context.Background(), []*store.ContainerMetadata{preprocess}); err != nil {
+		t.Fatal(err)
+	}
+	compute, err := testContainer(restarted, cluster, sandbox, logFile, "compute", "true")
+	if err != nil {
+		t.Fatalf("Container should depend on the restored job: %v", err)
+	}
+	if job, _ := cluster.Job(compute.Pid); job.Dependency != "afterok:"+strconv.Itoa(preprocess.Pid) {
+		t.Errorf("Wrong dependency of the job: %+v", job)
+	}
+	if _, err := testContainer(restarted, cluster, sandbox, logFile, "loop", "true"); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Wrong dependencies should not be valid: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cluster.Home, MOUNTHPATH, "pipeline", "compute")); err != nil {
+		t.Errorf("Container should be created in the cluster: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cluster.Home, MOUNTHPATH, "pipeline", "loop")); !os.IsNotExist(err) {
+		t.Errorf("Containers with wrong dependencies should not be created in the cluster: %v", err)
+	}
+}
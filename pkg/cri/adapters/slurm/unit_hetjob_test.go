@@ -0,0 +1,174 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test the heterogeneous jobs of the pods are validated, and every component has its own resources
+func TestUnitHetJobComponents(t *testing.T) {
+	for _, value := range []string{"sim", "sim,,analysis", "sim,analysis,sim"} {
+		if _, err := hetJobComponents(map[string]string{HetJobAnnotation: value}); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Heterogeneous job %q should not be valid: %v", value, err)
+		}
+	}
+	annotations := map[string]string{HetJobAnnotation: "sim, analysis"}
+	if offset := hetJobOffset(annotations, "analysis"); offset != 1 {
+		t.Errorf("Wrong offset of the component %d", offset)
+	}
+	container := func(name string, env map[string]string, annotations map[string]string) *store.ContainerMetadata {
+		return &store.ContainerMetadata{ID: name, Name: name, Environment: env, Extra: map[string]string{"RMPath": "multi-cri/pod/" + name},
+			Image: &store.ImageMetadata{RemotePath: "docker://alpine"}, PodSandbox: store.SandboxMetadata{ID: "pod", Config: runtimeApi.PodSandboxConfig{Annotations: annotations}}}
+	}
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, Allocation: AllocationJob}
+	for _, c := range []struct {
+		adapter     SlurmAdapter
+		env         map[string]string
+		annotations map[string]string
+	}{
+		{adapter: SlurmAdapter{Transport: TransportRest, Allocation: AllocationJob}},
+		{adapter: SlurmAdapter{Transport: TransportSSH, Allocation: AllocationPod}},
+		{adapter: adapter, env: map[string]string{"JOB_ARRAY": "0-3"}},
+		{adapter: adapter, annotations: map[string]string{SequentialAnnotation: "true"}},
+	} {
+		a := map[string]string{HetJobAnnotation: "sim,analysis"}
+		for k, v := range c.annotations {
+			a[k] = v
+		}
+		if err := c.adapter.validateHetJob(container("sim", c.env, a)); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Component should not be valid with %+v: %v", c, err)
+		}
+	}
+
+	jobConf := adapter.buildHetJob(container("analysis", nil, annotations), "multi-cri/pod", map[int]*store.ContainerMetadata{
+		0: container("sim", map[string]string{"JOB_QUEUE": "gpu", "JOB_GPU": "gpu:1", "MODE": "fast"}, annotations),
+		1: container("analysis", map[string]string{"JOB_QUEUE": "cpu", "JOB_NUM_CORES": "4"}, annotations),
+	})
+	if !reflect.DeepEqual(jobConf.Headers, []cmd.JobConfigField{{"-J", "pod"}, {"-o", StdoutFile}, {"-e", SterrFile},
+		{"-p", "gpu"}, {"--gres=gpu:1", ""}}) {
+		t.Errorf("Wrong headers of the first component %v", jobConf.Headers)
+	}
+	if !reflect.DeepEqual(jobConf.HetJobHeaders, [][]cmd.JobConfigField{{{"-p", "cpu"}, {"-n", "4"}}}) {
+		t.Errorf("Wrong headers of the components %v", jobConf.HetJobHeaders)
+	}
+	lines := strings.Split(jobConf.Command, "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], `(cd sim && export MODE="fast" && { srun --het-group=0 `) ||
+		!strings.HasPrefix(lines[1], "(cd analysis && { srun --het-group=1 ") || lines[2] != "wait" {
+		t.Errorf("Wrong command of the heterogeneous job %q", jobConf.Command)
+	}
+}
+
+// Test the containers of a heterogeneous job are submitted once every component starts, and their status
+// is the status of their component
+func TestUnitHetJob(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	ctx := context.Background()
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+		Allocation: AllocationJob, jobs: newPodJobs(), hetJobs: newPodHetJobs()}
+	sandbox := &store.SandboxMetadata{ID: "coupled", Config: runtimeApi.PodSandboxConfig{
+		Metadata:    &runtimeApi.PodSandboxMetadata{Name: "coupled"},
+		Annotations: map[string]string{HetJobAnnotation: "sim,analysis"}}}
+	create := func(name string, command ...string) *store.ContainerMetadata {
+		cm, err := testContainer(adapter, cluster, sandbox, logFile, name, command...)
+		if err != nil {
+			t.Fatalf("Container %s should be started: %v", name, err)
+		}
+		return cm
+	}
+
+	sim := create("sim", "false")
+	if err := adapter.ContainerStatus(ctx, sim); err != nil || sim.State != runtimeApi.ContainerState_CONTAINER_CREATED ||
+		len(cluster.Jobs()) != 0 {
+		t.Fatalf("Component should wait for the rest of the job: %+v %v", sim, err)
+	}
+	analysis := create("analysis", "sleep", "60")
+	jobs := cluster.Jobs()
+	if len(jobs) != 2 || jobs[0].Name != "coupled" || analysis.Pid != jobs[0].ID || jobs[1].HetJobID != jobs[0].ID {
+		t.Fatalf("The containers should be submitted as one heterogeneous job: %+v %+v", jobs, analysis)
+	}
+	waitContainerState(t, adapter, sim, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if sim.Pid != analysis.Pid || sim.ExitCode != 1 || sim.Reason != "FAILED" {
+		t.Errorf("Container should exit with the code of its component: %+v", sim)
+	}
+	waitContainerState(t, adapter, analysis, runtimeApi.ContainerState_CONTAINER_RUNNING)
+	if err := adapter.StopContainer(ctx, analysis); err != nil {
+		t.Fatal(err)
+	}
+	waitContainerState(t, adapter, analysis, runtimeApi.ContainerState_CONTAINER_EXITED)
+	if job, _ := cluster.Job(jobs[1].ID); job.State != fakecluster.StateCancelled {
+		t.Errorf("Component should be cancelled: %+v", job)
+	}
+	if err := adapter.RemovePodSandbox(ctx, sandbox); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adapter.hetJobs.lookup(sandbox.ID, sim.ID); ok {
+		t.Errorf("Heterogeneous job should be forgotten with the pod")
+	}
+}
+
+// Test the heterogeneous jobs are restored from their components after restarts
+func TestUnitRestoreHetJob(t *testing.T) {
+	cluster, logFile := startTestCluster(t)
+	defer cluster.Close()
+	defer os.Remove(logFile)
+
+	ctx := context.Background()
+	restart := func(containers ...*store.ContainerMetadata) SlurmAdapter {
+		adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH,
+			Allocation: AllocationJob, jobs: newPodJobs(), hetJobs: newPodHetJobs()}
+		if err := adapter.Restore(ctx, containers); err != nil {
+			t.Fatal(err)
+		}
+		return adapter
+	}
+	sandbox := &store.SandboxMetadata{ID: "coupled", Config: runtimeApi.PodSandboxConfig{
+		Annotations: map[string]string{HetJobAnnotation: "sim,analysis"}}}
+	sim, err := testContainer(restart(), cluster, sandbox, logFile, "sim", "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The waiting component is submitted with the component started after the restart
+	analysis, err := testContainer(restart(sim), cluster, sandbox, logFile, "analysis", "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := cluster.Jobs()
+	if len(jobs) != 2 || analysis.Pid != jobs[0].ID || analysis.Extra["HetJobContainers"] != "sim,analysis" {
+		t.Fatalf("The restored component should be submitted: %+v %+v", jobs, analysis)
+	}
+
+	// The submitted component finds its job from the component which submitted it
+	adapter := restart(sim, analysis)
+	waitContainerState(t, adapter, sim, runtimeApi.ContainerState_CONTAINER_RUNNING)
+	if sim.Pid != analysis.Pid {
+		t.Errorf("Component should find its restored job: %+v", sim)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"reflect"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test the resources of the containers are requested, unless the job settings set them
+func TestUnitResourceOptions(t *testing.T) {
+	limits := &runtimeApi.LinuxContainerResources{CpuPeriod: 100000, CpuQuota: 150000, MemoryLimitInBytes: 1536*1024*1024 + 1}
+	for _, c := range []struct {
+		settings  batch.JobSettings
+		resources *runtimeApi.LinuxContainerResources
+		options   []string
+	}{
+		{resources: nil, options: nil},
+		{resources: limits, options: []string{"--cpus-per-task=2", "--mem=1537M"}},
+		{resources: &runtimeApi.LinuxContainerResources{CpusetCpus: "0-3,7"}, options: []string{"--cpus-per-task=5"}},
+		{resources: &runtimeApi.LinuxContainerResources{CpusetCpus: "3-1"}, options: nil},
+		{settings: batch.JobSettings{Cores: "4"}, resources: limits, options: []string{"--mem=1537M"}},
+		{settings: batch.JobSettings{Nodes: "2"}, resources: limits, options: []string{"--mem=1537M"}},
+		{settings: batch.JobSettings{CustomConfig: "#SBATCH --mem-per-cpu=1G"}, resources: limits,
+			options: []string{"--cpus-per-task=2"}},
+	} {
+		if options := resourceOptions(c.settings, c.resources); !reflect.DeepEqual(options, c.options) {
+			t.Errorf("Wrong options of %+v %+v: %v", c.settings, c.resources, options)
+		}
+	}
+
+	cm := &store.ContainerMetadata{Config: runtimeApi.ContainerConfig{Linux: &runtimeApi.LinuxContainerConfig{Resources: limits}}}
+	jobConf := &cmd.JobConfig{}
+	setupBatchHeaders(batch.JobSettings{Name: "job", Queue: "debug"}, linuxResources(cm), jobConf)
+	if !reflect.DeepEqual(jobConf.Headers, []cmd.JobConfigField{{"-J", "job"}, {"-o", StdoutFile}, {"-e", SterrFile},
+		{"-p", "debug"}, {"--cpus-per-task=2", ""}, {"--mem=1537M", ""}}) {
+		t.Errorf("Wrong headers %v", jobConf.Headers)
+	}
+}
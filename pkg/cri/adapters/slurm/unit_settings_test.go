@@ -0,0 +1,101 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test the job settings of the annotations and the environment are validated, and the errors name their source
+func TestUnitValidateJobSettings(t *testing.T) {
+	newContainer := func(annotations, env map[string]string) *store.ContainerMetadata {
+		return &store.ContainerMetadata{Name: "compute", Config: runtimeApi.ContainerConfig{Annotations: annotations},
+			Environment: env, Extra: make(map[string]string)}
+	}
+	valid := newContainer(map[string]string{
+		batch.JobAnnotationPrefix + "partition":  "gpu,gpu-long",
+		batch.JobAnnotationPrefix + "time":       "1-12:00:00",
+		batch.JobAnnotationPrefix + "account":    "physics",
+		batch.JobAnnotationPrefix + "qos":        "high",
+		batch.JobAnnotationPrefix + "constraint": "[rack1|rack2]&ib",
+		batch.JobAnnotationPrefix + "gres":       "gpu:tesla:2,mps:100",
+		batch.JobAnnotationPrefix + "nodes":      "2-4",
+		batch.JobAnnotationPrefix + "array":      "0-9%2",
+	}, map[string]string{"JOB_QUEUE": "", "JOB_ARRAY_POLICY": ArrayPolicyAny})
+	valid.PodSandbox.Config.Annotations = map[string]string{DependencyAnnotation + "compute": "setup",
+		SequentialAnnotation: "true", HetJobAnnotation: "compute,analysis"}
+	if err := validateJobSettings(valid); err != nil {
+		t.Errorf("Job settings should be valid: %v", err)
+	}
+	for _, c := range []struct {
+		annotation, env, value, source string
+	}{
+		{annotation: "partition", value: "gpu long", source: "annotation multicri.atrio.io/partition of the container"},
+		{annotation: "time", value: "1h", source: "annotation multicri.atrio.io/time of the container"},
+		{annotation: "account", value: "a,b", source: "annotation multicri.atrio.io/account of the container"},
+		{annotation: "constraint", value: "intel ib", source: "annotation multicri.atrio.io/constraint of the container"},
+		{annotation: "gres", value: "gpu:tesla:0", source: "annotation multicri.atrio.io/gres of the container"},
+		{env: "JOB_QOS", value: "high!", source: "environment variable JOB_QOS"},
+		{env: "JOB_TIME_LIMIT", value: "1:2:3:4", source: "environment variable JOB_TIME_LIMIT"},
+		{annotation: "nodes", value: "4-", source: "annotation multicri.atrio.io/nodes of the container"},
+		{annotation: "array", value: "5-1", source: "annotation multicri.atrio.io/array of the container"},
+		{env: "JOB_ARRAY_POLICY", value: "first", source: "environment variable JOB_ARRAY_POLICY"},
+	} {
+		cm := newContainer(nil, map[string]string{})
+		if c.annotation != "" {
+			cm.Config.Annotations = map[string]string{batch.JobAnnotationPrefix + c.annotation: c.value}
+		} else {
+			cm.Environment[c.env] = c.value
+		}
+		err := validateJobSettings(cm)
+		if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument || !strings.Contains(err.Error(), c.source) ||
+			!strings.Contains(err.Error(), c.value) {
+			t.Errorf("Wrong %q should be an invalid argument of %s: %v", c.value, c.source, err)
+		}
+	}
+	unknown := newContainer(map[string]string{batch.JobAnnotationPrefix + "timelimit": "60"}, nil)
+	if err := validateJobSettings(unknown); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument ||
+		!strings.Contains(err.Error(), "multicri.atrio.io/timelimit") {
+		t.Errorf("Unknown annotations should be invalid arguments: %v", err)
+	}
+	pod := newContainer(nil, map[string]string{})
+	pod.PodSandbox.Config.Annotations = map[string]string{batch.JobAnnotationPrefix + "time": "forever"}
+	err := (SlurmAdapter{}).CreateContainer(context.Background(), pod)
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument || !strings.Contains(err.Error(), "of the pod") {
+		t.Errorf("Containers with wrong job settings should not be created: %v", err)
+	}
+}
+
+// Test the account, the QOS and the constraint are requested
+func TestUnitJobSettingHeaders(t *testing.T) {
+	jobConf := &cmd.JobConfig{}
+	settings := batch.JobSettings{Name: "compute", Account: "physics", QOS: "high", Constraint: "intel&ib"}
+	setupBatchHeaders(settings, nil, jobConf)
+	expected := []cmd.JobConfigField{{"-J", "compute"}, {"-o", StdoutFile}, {"-e", SterrFile}, {"--account=physics", ""},
+		{"--qos=high", ""}, {"--constraint=intel&ib", ""}}
+	if !reflect.DeepEqual(jobConf.Headers, expected) {
+		t.Errorf("Wrong job headers: %v", jobConf.Headers)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// Test only the CPUs and the memory which changed are updated
+func TestUnitResourceUpdate(t *testing.T) {
+	applied := jobResources{CPUs: 1, Memory: 1024}
+	requested := jobResources{CPUs: 2, Memory: 2048}
+	update, updated, changed := resourceUpdate(applied, requested)
+	if update.CPUsPerNode != 2 || update.MemoryPerNode != 2048 || updated != requested ||
+		strings.Join(changed, ", ") != "CPUs, memory" {
+		t.Errorf("Changed resources should be updated: %+v %+v %v", update, updated, changed)
+	}
+	update, updated, changed = resourceUpdate(applied, jobResources{Memory: 2048})
+	if update != (cmd.JobUpdate{MemoryPerNode: 2048}) || updated.CPUs != 1 || len(changed) != 1 {
+		t.Errorf("Resources which are not requested should be kept: %+v %+v %v", update, updated, changed)
+	}
+	if update, _, changed := resourceUpdate(applied, applied); update != (cmd.JobUpdate{}) || len(changed) != 0 {
+		t.Errorf("Unchanged resources should not be updated: %+v", update)
+	}
+}
+
+// Test the containers update the CPUs and the memory of the jobs which wait in the queue, and the running
+// jobs reject the updates
+func TestUnitUpdateContainerResources(t *testing.T) {
+	cluster, err := fakecluster.Start(fakecluster.Config{Plans: map[string]fakecluster.Plan{"held": {Hold: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	logFile, err := ioutil.TempFile("", "slurm-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logFile.Close()
+	defer os.Remove(logFile.Name())
+
+	ctx := context.Background()
+	adapter := SlurmAdapter{MountPath: MOUNTHPATH, Builder: fakeBuilder{}, Transport: TransportSSH, jobs: newPodJobs()}
+	sandbox := store.SandboxMetadata{ID: "resources"}
+	start := func(name string, command ...string) *store.ContainerMetadata {
+		cm := &store.ContainerMetadata{ID: name, Name: name, PodSandbox: sandbox, Command: command, LogFile: logFile.Name(),
+			Image: &store.ImageMetadata{RemotePath: "docker://alpine"}, Extra: make(map[string]string),
+			Environment: map[string]string{"CLUSTER_USERNAME": cluster.User, "CLUSTER_PASSWORD": cluster.Password,
+				"CLUSTER_HOST": cluster.Host, "CLUSTER_PORT": cluster.Port}}
+		cm.Config.Linux = &runtimeApi.LinuxContainerConfig{Resources: &runtimeApi.LinuxContainerResources{
+			MemoryLimitInBytes: 1 << 30}}
+		if err := adapter.CreateContainer(ctx, cm); err != nil {
+			t.Fatal(err)
+		}
+		if err := adapter.StartContainer(ctx, cm); err != nil {
+			t.Fatalf("Container %s should be started: %v", name, err)
+		}
+		return cm
+	}
+	// The runtime sets the Linux resources of the update in the container
+	update := func(cm *store.ContainerMetadata) error {
+		cm.Config.Linux.Resources = &runtimeApi.LinuxContainerResources{CpuQuota: 200000, CpuPeriod: 100000,
+			MemoryLimitInBytes: 2 << 30}
+		return adapter.UpdateContainerResources(ctx, cm)
+	}
+
+	held := start("held", "true")
+	if held.Extra["JobMemory"] != "1024" || held.Extra["JobCPUs"] != "" {
+		t.Errorf("Submitted resources should be kept: %v", held.Extra)
+	}
+	if err := update(held); err != nil {
+		t.Fatalf("Pending job should be updated: %v", err)
+	}
+	job, _ := cluster.Job(held.Pid)
+	if job.MinCPUsNode != 2 || job.MinMemoryNode != "2048" {
+		t.Errorf("Pending job should update its CPUs and memory: %+v", job)
+	}
+	if held.Extra["JobCPUs"] != "2" || held.Extra["JobMemory"] != "2048" {
+		t.Errorf("Updated resources should be kept: %v", held.Extra)
+	}
+
+	running := start("running", "sleep", "60")
+	waitContainerState(t, adapter, running, runtimeApi.ContainerState_CONTAINER_RUNNING)
+	if err := adapter.UpdateContainerResources(ctx, running); err != nil {
+		t.Errorf("Running job without changes should not fail: %v", err)
+	}
+	err = update(running)
+	if adapters.ErrorKindOf(err) != adapters.KindInvalidArgument || !strings.Contains(err.Error(), "CPUs, memory") {
+		t.Errorf("Running job should not update its CPUs and memory: %v", err)
+	}
+	if job, _ := cluster.Job(running.Pid); job.MinCPUsNode != 0 || job.MinMemoryNode != "1024M" {
+		t.Errorf("Running job should not be updated: %+v", job)
+	}
+	if running.Extra["JobMemory"] != "1024" || running.Extra["JobCPUs"] != "" {
+		t.Errorf("Running job should keep its resources: %v", running.Extra)
+	}
+	if err := adapter.StopContainer(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cluster.WaitJob(running.Pid, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := update(running); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Finished jobs should not be updated: %v", err)
+	}
+	step := &store.ContainerMetadata{Extra: map[string]string{"AllocationJobId": "1"}}
+	if err := adapter.UpdateContainerResources(ctx, step); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Steps should not be updated: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slurm
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/slurm/cmd"
+	"multi-cri/pkg/cri/store"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// jobResources are the resources of a job which the container updates after it is submitted. The CRI updates
+// only carry the Linux resources of the container, so they are its CPUs and its memory; the rest of the job
+// settings are fixed when the container is created. The resources which are not set are not requested.
+type jobResources struct {
+	CPUs int
+	// Memory is in MiB
+	Memory int64
+}
+
+// requestedResources returns the resources which the job of the container requests with its Linux resources,
+// which the runtime replaces with the ones of each update
+func requestedResources(settings batch.JobSettings, r *runtimeApi.LinuxContainerResources) jobResources {
+	return jobResources{CPUs: requestedCPUs(settings, r), Memory: requestedMemory(settings, r)}
+}
+
+// appliedResources returns the resources of the job of the container, as they were submitted or updated last
+func appliedResources(cm *store.ContainerMetadata) jobResources {
+	cpus, _ := strconv.Atoi(cm.Extra["JobCPUs"])
+	memory, _ := strconv.ParseInt(cm.Extra["JobMemory"], 10, 64)
+	return jobResources{CPUs: cpus, Memory: memory}
+}
+
+// keep sets the resources in the container metadata, where the runtime reports them in the verbose status
+func (j jobResources) keep(cm *store.ContainerMetadata) {
+	for key, value := range map[string]string{
+		"JobCPUs":   strconv.Itoa(j.CPUs),
+		"JobMemory": strconv.FormatInt(j.Memory, 10),
+	} {
+		if value == "0" {
+			delete(cm.Extra, key)
+		} else {
+			cm.Extra[key] = value
+		}
+	}
+}
+
+// resourceUpdate returns the update of the job from the applied to the requested resources, the resources
+// after the update, and the resources which change
+func resourceUpdate(applied, requested jobResources) (cmd.JobUpdate, jobResources, []string) {
+	var update cmd.JobUpdate
+	var changed []string
+	updated := applied
+	if requested.CPUs > 0 && requested.CPUs != applied.CPUs {
+		update.CPUsPerNode, updated.CPUs = requested.CPUs, requested.CPUs
+		changed = append(changed, "CPUs")
+	}
+	if requested.Memory > 0 && requested.Memory != applied.Memory {
+		update.MemoryPerNode, updated.Memory = requested.Memory, requested.Memory
+		changed = append(changed, "memory")
+	}
+	return update, updated, changed
+}
+
+// UpdateContainerResources updates the CPUs and the memory of the job of the container with scontrol update,
+// after the runtime sets the Linux resources of the update in the container. Only the resources which changed
+// since the job was submitted, or updated last, are updated, and only while the job waits in the queue: the
+// running jobs can not change them, so those updates are invalid arguments. The containers which did not
+// start yet, and the components which wait for their heterogeneous job, request the resources when their job
+// is submitted.
+func (s SlurmAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	if cm.Extra["AllocationJobId"] != "" {
+		return adapters.InvalidArgumentError("The resources of the steps of the pod allocation can not be updated")
+	}
+	jobClient, err := s.jobClient(cm)
+	if err != nil {
+		return err
+	}
+	requested := requestedResources(batch.ParseJobSettings(cm), linuxResources(cm))
+	jobRef := &cmd.JobReference{JobId: int32(cm.Pid)}
+	if cm.Extra["HetJobOffset"] != "" {
+		ref, ok, err := s.componentReference(cm)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			requested.keep(cm)
+			return nil
+		}
+		jobRef = ref
+	}
+	if jobRef.JobId == 0 {
+		return nil
+	}
+	update, updated, changed := resourceUpdate(appliedResources(cm), requested)
+	if len(changed) == 0 {
+		return nil
+	}
+	status, err := jobClient.Sstatus(ctx, jobRef)
+	if err != nil {
+		return err
+	}
+	switch status.JobState {
+	case "PENDING":
+	case "RUNNING":
+		return adapters.InvalidArgumentError("Running job %s can not update its %s", jobRef, strings.Join(changed, ", "))
+	default:
+		return adapters.InvalidArgumentError("Job %s is %s, its resources can not be updated", jobRef, status.JobState)
+	}
+	if err := jobClient.Supdate(ctx, *jobRef, update); err != nil {
+		return err
+	}
+	updated.keep(cm)
+	return nil
+}
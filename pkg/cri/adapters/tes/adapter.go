@@ -0,0 +1,92 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tes runs containers as tasks of a GA4GH Task Execution Service
+package tes
+
+import (
+	"fmt"
+	"net/url"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/common"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	TESADAPTERVERSION = "0.1.0"
+	TESNAME           = "Adapter TES"
+)
+
+type TESAdapter struct {
+	batch.NoStreaming
+	batch.NoSandbox
+	// URL and Token are the TES service and its bearer token, used when the container does not set them
+	URL   string
+	Token string
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "tes",
+		Description: "Runs containers as tasks of GA4GH Task Execution Service endpoints",
+		Options: map[string]string{
+			"url":   "Base URL of the TES service, without /v1/tasks (CRI_TES_URL)",
+			"token": "Bearer token of the TES service (CRI_TES_TOKEN)",
+		},
+		Validate: validateConfig,
+		New:      NewTESAdapterWithConfig,
+	})
+}
+
+func validateConfig(config adapters.AdapterConfig) error {
+	if u := config.Get("url", ""); u != "" {
+		if _, err := url.ParseRequestURI(u); err != nil {
+			return fmt.Errorf("url must be the URL of the TES service: %v", err)
+		}
+	}
+	return nil
+}
+
+func NewTESAdapter() (adapters.AdapterInterface, error) {
+	return NewTESAdapterWithConfig(adapters.AdapterConfig{})
+}
+
+func NewTESAdapterWithConfig(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+	empty := ""
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
+	return TESAdapter{
+		NoStreaming: batch.NoStreaming{Name: "TES"},
+		URL:         config.Get("url", common.GetEnv("CRI_TES_URL", &empty)),
+		Token:       config.Get("token", common.GetEnv("CRI_TES_TOKEN", &empty)),
+	}, nil
+}
+
+func (t TESAdapter) Version(ctx context.Context) (*runtimeApi.VersionResponse, error) {
+	return &runtimeApi.VersionResponse{
+		Version:           TESADAPTERVERSION,
+		RuntimeName:       TESNAME,
+		RuntimeVersion:    TESADAPTERVERSION,
+		RuntimeApiVersion: TESADAPTERVERSION,
+	}, nil
+}
+
+func (t TESAdapter) Capabilities() adapters.Capabilities {
+	return adapters.Capabilities{}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+)
+
+const (
+	// viewBasic returns the tasks without the executor output, viewFull with it
+	viewBasic = "BASIC"
+	viewFull  = "FULL"
+)
+
+type client struct {
+	url      string
+	token    string
+	user     string
+	password string
+	http     *http.Client
+}
+
+// newClient returns the client of the TES service of the container. The CLUSTER_TES_URL and
+// CLUSTER_TES_TOKEN variables of the container override the adapter ones, and CLUSTER_USERNAME and
+// CLUSTER_PASSWORD are used as basic credentials when there is no token.
+func (t TESAdapter) newClient(cm *store.ContainerMetadata) (*client, error) {
+	c := &client{url: t.URL, token: t.Token, http: &http.Client{}}
+	if value := cm.Environment["CLUSTER_TES_URL"]; value != "" {
+		c.url = value
+	}
+	if value := cm.Environment["CLUSTER_TES_TOKEN"]; value != "" {
+		c.token = value
+	}
+	if c.url == "" {
+		return nil, adapters.InvalidArgumentError("CLUSTER_TES_URL must be setup")
+	}
+	if _, err := url.ParseRequestURI(c.url); err != nil {
+		return nil, adapters.InvalidArgumentError("CLUSTER_TES_URL must be an URL: %v", err)
+	}
+	c.url = strings.TrimSuffix(c.url, "/")
+	if c.token == "" {
+		c.user = cm.Environment["CLUSTER_USERNAME"]
+		c.password = cm.Environment["CLUSTER_PASSWORD"]
+	}
+	return c, nil
+}
+
+// createTask submits the task and returns its id
+func (c *client) createTask(ctx context.Context, task *tesTask) (string, error) {
+	klog.V(4).Infof("Create task %s in %s", task.Name, c.url)
+	var response struct {
+		Id string `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/v1/tasks", task, &response); err != nil {
+		return "", err
+	}
+	if response.Id == "" {
+		return "", fmt.Errorf("Task id not found in the TES response")
+	}
+	return response.Id, nil
+}
+
+func (c *client) getTask(ctx context.Context, id, view string) (*tesTask, error) {
+	klog.V(4).Infof("Check status for task %s", id)
+	var task tesTask
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/tasks/%s?view=%s", url.PathEscape(id), view), nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *client) cancelTask(ctx context.Context, id string) error {
+	klog.V(4).Infof("Canceling task %s", id)
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/tasks/%s:cancel", url.PathEscape(id)), struct{}{}, nil)
+}
+
+func (c *client) do(ctx context.Context, method, endpoint string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	request, err := http.NewRequest(method, c.url+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return adapters.InvalidArgumentError("Wrong TES request %s %s: %v", method, endpoint, err)
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.user != "" {
+		request.SetBasicAuth(c.user, c.password)
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return adapters.WrapError(adapters.KindUnavailable, fmt.Errorf("TES service %s can not be reached: %w", c.url, err))
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return adapters.UnavailableError("TES response can not be read: %v", err)
+	}
+	if response.StatusCode >= 300 {
+		return tesError(method, endpoint, response.StatusCode, data)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("TES response of %s %s can not be parsed: %v", method, endpoint, err)
+	}
+	return nil
+}
+
+// tesError classifies the error responses by their HTTP status
+func tesError(method, endpoint string, status int, data []byte) error {
+	var body struct {
+		Message string `json:"message"`
+		Msg     string `json:"msg"`
+	}
+	message := strings.TrimSpace(string(data))
+	if json.Unmarshal(data, &body) == nil {
+		if body.Message != "" {
+			message = body.Message
+		} else if body.Msg != "" {
+			message = body.Msg
+		}
+	}
+	err := fmt.Errorf("TES %s %s fails with status %d: %s", method, endpoint, status, message)
+	switch status {
+	case http.StatusBadRequest:
+		return adapters.WrapError(adapters.KindInvalidArgument, err)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return adapters.WrapError(adapters.KindPermissionDenied, err)
+	case http.StatusNotFound:
+		return adapters.WrapError(adapters.KindNotFound, err)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return adapters.WrapError(adapters.KindUnavailable, err)
+	}
+	return err
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/common"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"k8s.io/klog"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// taskId is the container Extra key with the id of the TES task
+const taskId = "TESTaskId"
+
+// CreateContainer checks the task of the container can be built. TES tasks are created when they start.
+func (t TESAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	if _, err := t.newClient(cm); err != nil {
+		return err
+	}
+	_, err := buildTask(cm)
+	return err
+}
+
+func (t TESAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	client, err := t.newClient(cm)
+	if err != nil {
+		return err
+	}
+	task, err := buildTask(cm)
+	if err != nil {
+		return err
+	}
+	id, err := client.createTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	if cm.Extra == nil {
+		cm.Extra = make(map[string]string)
+	}
+	cm.Extra[taskId] = id
+	klog.Infof("Container %s started as TES task %s", cm.ID, id)
+	return nil
+}
+
+func (t TESAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	id := cm.Extra[taskId]
+	if id == "" {
+		return nil
+	}
+	client, err := t.newClient(cm)
+	if err != nil {
+		return err
+	}
+	err = client.cancelTask(ctx, id)
+	if adapters.IsNotFound(err) {
+		// The task was already removed
+		return nil
+	}
+	return err
+}
+
+func (t TESAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	id := cm.Extra[taskId]
+	if id == "" {
+		return nil
+	}
+	client, err := t.newClient(cm)
+	if err != nil {
+		return err
+	}
+	task, err := client.getTask(ctx, id, viewBasic)
+	if err != nil {
+		return err
+	}
+	status := taskStatus(task)
+	status.Apply(cm)
+	if cm.State == runtimeApi.ContainerState_CONTAINER_EXITED {
+		// Only the full view has the output of the executors
+		task, err := client.getTask(ctx, id, viewFull)
+		if err != nil {
+			klog.V(4).Infof("Output of task %s can not be read: %v", id, err)
+			return nil
+		}
+		writeLogs(cm.LogFile, task)
+	}
+	return nil
+}
+
+func (t TESAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("TES: ReopenContainerLog not implemented")
+}
+
+func (t TESAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	return adapters.UnimplementedError("TES: UpdateContainerResources not implemented")
+}
+
+// writeLogs writes the output of the executors of the last attempt in the container log, and the
+// system logs of the tasks which could not run
+func writeLogs(logPath string, task *tesTask) {
+	if len(task.Logs) == 0 {
+		return
+	}
+	stdout, stderr, err := common.CreateContainerLoggers(logPath, false, 0)
+	if err != nil {
+		klog.Errorf("failed to start container logger: %s", err)
+		return
+	}
+	defer func() {
+		stderr.Close()
+		stdout.Close()
+	}()
+	attempt := task.Logs[len(task.Logs)-1]
+	for _, executor := range attempt.Logs {
+		stdout.Write([]byte(executor.Stdout))
+		stderr.Write([]byte(executor.Stderr))
+	}
+	if task.State == "SYSTEM_ERROR" && len(attempt.SystemLogs) > 0 {
+		stderr.Write([]byte(strings.Join(attempt.SystemLogs, "\n") + "\n"))
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// PullImage only checks the image, the TES service pulls it when the executor starts
+func (t TESAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	if image.RepoType != store.DockerRepositoryImageRepo {
+		return adapters.InvalidArgumentError("Image repository type not supported by the TES adapter %s ", image.RemotePath)
+	}
+	image.Size = 1 //It must to be set, otherwise k8s fails
+	return nil
+}
+
+func (t TESAdapter) ListImages(ctx context.Context, images []*runtimeApi.Image) error {
+	return nil
+}
+
+func (t TESAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
+
+func (t TESAdapter) ImageFsInfo(ctx context.Context) (*runtimeApi.ImageFsInfoResponse, error) {
+	return nil, adapters.UnimplementedError("TES: ImageFsInfo not implemented")
+}
+
+func (t TESAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error {
+	return nil
+}
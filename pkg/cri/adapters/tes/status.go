@@ -0,0 +1,93 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"time"
+
+	"multi-cri/pkg/cri/adapters/batch"
+
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+const (
+	// cancelExitCode is the exit code of the cancelled tasks, as killed by SIGTERM
+	cancelExitCode = 143
+)
+
+func taskState(state string) runtimeApi.ContainerState {
+	switch state {
+	case "QUEUED", "INITIALIZING":
+		return runtimeApi.ContainerState_CONTAINER_CREATED
+	case "RUNNING", "PAUSED", "CANCELING":
+		return runtimeApi.ContainerState_CONTAINER_RUNNING
+	case "COMPLETE", "EXECUTOR_ERROR", "SYSTEM_ERROR", "CANCELED", "PREEMPTED":
+		return runtimeApi.ContainerState_CONTAINER_EXITED
+	default:
+		return runtimeApi.ContainerState_CONTAINER_UNKNOWN
+	}
+}
+
+// taskStatus translates the state and the last attempt of the task. The exit code is the one of the
+// executor, and the tasks which failed without executor exit code exit with 1.
+func taskStatus(task *tesTask) batch.JobStatus {
+	status := batch.JobStatus{State: taskState(task.State)}
+	var attempt tesTaskLog
+	if len(task.Logs) > 0 {
+		attempt = task.Logs[len(task.Logs)-1]
+	}
+	status.StartedAt = parseTime(attempt.StartTime)
+	status.FinishedAt = parseTime(attempt.EndTime)
+	exitCode := 0
+	if n := len(attempt.Logs); n > 0 {
+		executor := attempt.Logs[n-1]
+		exitCode = executor.ExitCode
+		if status.StartedAt == 0 {
+			status.StartedAt = parseTime(attempt.Logs[0].StartTime)
+		}
+		if status.FinishedAt == 0 {
+			status.FinishedAt = parseTime(executor.EndTime)
+		}
+	}
+	switch task.State {
+	case "COMPLETE":
+		status.ExitCode = exitCode
+	case "EXECUTOR_ERROR":
+		status.ExitCode = exitCode
+		if status.ExitCode == 0 {
+			status.ExitCode = 1
+		}
+		status.Reason = batch.ReasonError
+	case "SYSTEM_ERROR":
+		status.ExitCode = 1
+		status.Reason = batch.ReasonCannotRun
+	case "CANCELED", "PREEMPTED":
+		status.ExitCode = cancelExitCode
+		status.Reason = batch.ReasonKilled
+	}
+	return status
+}
+
+// parseTime returns the RFC 3339 time in nanoseconds, 0 when it is unknown
+func parseTime(value string) int64 {
+	if value == "" {
+		return 0
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return 0
+	}
+	return t.UnixNano()
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/store"
+)
+
+const (
+	fileType      = "FILE"
+	directoryType = "DIRECTORY"
+	// bytesPerGB converts the memory limit to the GB of the TES resources
+	bytesPerGB = 1e9
+)
+
+// tesTask is the task of the TES API v1. The state and the logs are set by the service.
+type tesTask struct {
+	Id           string            `json:"id,omitempty"`
+	State        string            `json:"state,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	Description  string            `json:"description,omitempty"`
+	Inputs       []tesFile         `json:"inputs,omitempty"`
+	Outputs      []tesFile         `json:"outputs,omitempty"`
+	Resources    *tesResources     `json:"resources,omitempty"`
+	Executors    []tesExecutor     `json:"executors"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	Logs         []tesTaskLog      `json:"logs,omitempty"`
+	CreationTime string            `json:"creation_time,omitempty"`
+}
+
+// tesFile is an input or an output of the task
+type tesFile struct {
+	Name string `json:"name,omitempty"`
+	Url  string `json:"url"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type tesResources struct {
+	CpuCores    int      `json:"cpu_cores,omitempty"`
+	RamGb       float64  `json:"ram_gb,omitempty"`
+	DiskGb      float64  `json:"disk_gb,omitempty"`
+	Preemptible bool     `json:"preemptible,omitempty"`
+	Zones       []string `json:"zones,omitempty"`
+}
+
+type tesExecutor struct {
+	Image   string            `json:"image"`
+	Command []string          `json:"command"`
+	Workdir string            `json:"workdir,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+type tesTaskLog struct {
+	Logs       []tesExecutorLog `json:"logs,omitempty"`
+	StartTime  string           `json:"start_time,omitempty"`
+	EndTime    string           `json:"end_time,omitempty"`
+	SystemLogs []string         `json:"system_logs,omitempty"`
+}
+
+type tesExecutorLog struct {
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+	Stdout    string `json:"stdout,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+	ExitCode  int    `json:"exit_code"`
+}
+
+// buildTask translates the container to a task with a single executor. The inputs, outputs and
+// resources which Kubernetes can not express are read from the TES_* variables of the container.
+func buildTask(cm *store.ContainerMetadata) (*tesTask, error) {
+	if cm.Image == nil || cm.Image.RepoType != store.DockerRepositoryImageRepo {
+		return nil, adapters.InvalidArgumentError("TES executors only run images of the docker repository")
+	}
+	command := append(append([]string{}, cm.Command...), cm.Args...)
+	if len(command) == 0 {
+		return nil, adapters.InvalidArgumentError("TES executors need a command, the container %s does not set it", cm.Name)
+	}
+	inputs, err := parseFiles(cm.Environment["TES_INPUTS"], "TES_INPUTS")
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := parseFiles(cm.Environment["TES_OUTPUTS"], "TES_OUTPUTS")
+	if err != nil {
+		return nil, err
+	}
+	resources, err := taskResources(cm)
+	if err != nil {
+		return nil, err
+	}
+	task := &tesTask{
+		Name:      cm.Name,
+		Inputs:    inputs,
+		Outputs:   outputs,
+		Resources: resources,
+		Executors: []tesExecutor{{
+			Image:   strings.TrimPrefix(cm.Image.RemotePath, "docker://"),
+			Command: command,
+			Workdir: cm.Config.WorkingDir,
+			Env:     taskEnvironment(cm),
+		}},
+		Tags: map[string]string{
+			"multicri-container-id": cm.ID,
+			"multicri-sandbox-id":   cm.PodSandbox.ID,
+		},
+	}
+	return task, nil
+}
+
+// parseFiles parses comma separated path=url pairs. Paths ending with / are directories.
+func parseFiles(value, variable string) ([]tesFile, error) {
+	var files []tesFile
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		split := strings.SplitN(pair, "=", 2)
+		if len(split) != 2 || split[1] == "" {
+			return nil, adapters.InvalidArgumentError("%s must be comma separated path=url pairs: %s", variable, pair)
+		}
+		if !path.IsAbs(split[0]) {
+			return nil, adapters.InvalidArgumentError("%s paths must be absolute: %s", variable, split[0])
+		}
+		file := tesFile{Path: path.Clean(split[0]), Url: split[1], Type: fileType}
+		if strings.HasSuffix(split[0], "/") {
+			file.Type = directoryType
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// taskResources returns the cores of the job settings, or of the CPU quota, the memory limit and the
+// TES_DISK_GB, TES_PREEMPTIBLE and TES_ZONES variables. It is nil when nothing is requested.
+func taskResources(cm *store.ContainerMetadata) (*tesResources, error) {
+	resources := &tesResources{CpuCores: batch.ParseJobSettings(cm).CoreCount()}
+	if linux := cm.Config.Linux; linux != nil && linux.Resources != nil {
+		r := linux.Resources
+		if resources.CpuCores == 0 && r.CpuQuota > 0 && r.CpuPeriod > 0 {
+			resources.CpuCores = int((r.CpuQuota + r.CpuPeriod - 1) / r.CpuPeriod)
+		}
+		resources.RamGb = float64(r.MemoryLimitInBytes) / bytesPerGB
+	}
+	var err error
+	if value := cm.Environment["TES_DISK_GB"]; value != "" {
+		if resources.DiskGb, err = strconv.ParseFloat(value, 64); err != nil || resources.DiskGb < 0 {
+			return nil, adapters.InvalidArgumentError("TES_DISK_GB must be a number of GB: %s", value)
+		}
+	}
+	if value := cm.Environment["TES_PREEMPTIBLE"]; value != "" {
+		if resources.Preemptible, err = strconv.ParseBool(value); err != nil {
+			return nil, adapters.InvalidArgumentError("TES_PREEMPTIBLE must be a boolean: %s", value)
+		}
+	}
+	for _, zone := range strings.Split(cm.Environment["TES_ZONES"], ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			resources.Zones = append(resources.Zones, zone)
+		}
+	}
+	if resources.CpuCores == 0 && resources.RamGb == 0 && resources.DiskGb == 0 && !resources.Preemptible && len(resources.Zones) == 0 {
+		return nil, nil
+	}
+	return resources, nil
+}
+
+// taskEnvironment returns the container variables without the cluster, job and TES settings
+func taskEnvironment(cm *store.ContainerMetadata) map[string]string {
+	env := batch.FilterEnvironment(cm)
+	for k := range env {
+		if strings.HasPrefix(k, "TES_") {
+			delete(env, k)
+		}
+	}
+	return env
+}
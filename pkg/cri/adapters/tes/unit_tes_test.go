@@ -0,0 +1,307 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/batch"
+	"multi-cri/pkg/cri/adapters/conformance"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+// mockTES is a TES service whose tasks advance one state, from QUEUED to RUNNING and COMPLETE, each
+// time they are read
+type mockTES struct {
+	lock  sync.Mutex
+	tasks map[string]*tesTask
+	token string
+}
+
+func newMockTES(token string) (*mockTES, *httptest.Server) {
+	m := &mockTES{tasks: make(map[string]*tesTask), token: token}
+	return m, httptest.NewServer(m)
+}
+
+func (m *mockTES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+m.token {
+		http.Error(w, `{"message": "invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/tasks")
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		var task tesTask
+		if err := json.NewDecoder(r.Body).Decode(&task); err != nil || len(task.Executors) == 0 {
+			http.Error(w, `{"message": "wrong task"}`, http.StatusBadRequest)
+			return
+		}
+		task.Id = fmt.Sprintf("task-%d", len(m.tasks)+1)
+		task.State = "QUEUED"
+		m.tasks[task.Id] = &task
+		json.NewEncoder(w).Encode(map[string]string{"id": task.Id})
+	case r.Method == http.MethodPost && strings.HasSuffix(path, ":cancel"):
+		task, ok := m.tasks[strings.TrimSuffix(strings.TrimPrefix(path, "/"), ":cancel")]
+		if !ok {
+			http.Error(w, `{"message": "task not found"}`, http.StatusNotFound)
+			return
+		}
+		if taskState(task.State) != runtimeApi.ContainerState_CONTAINER_EXITED {
+			task.State = "CANCELED"
+		}
+		w.Write([]byte("{}"))
+	case r.Method == http.MethodGet:
+		task, ok := m.tasks[strings.TrimPrefix(path, "/")]
+		if !ok {
+			http.Error(w, `{"message": "task not found"}`, http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("view") == viewBasic {
+			switch task.State {
+			case "QUEUED":
+				task.State = "RUNNING"
+				task.Logs = []tesTaskLog{{StartTime: "2019-10-14T10:00:00.5Z"}}
+			case "RUNNING":
+				task.State = "COMPLETE"
+				task.Logs[0].EndTime = "2019-10-14T10:01:00Z"
+				task.Logs[0].Logs = []tesExecutorLog{{Stdout: strings.Join(task.Executors[0].Command, " ") + "\n"}}
+			}
+		}
+		response := *task
+		if r.URL.Query().Get("view") == viewBasic {
+			// The basic view does not have the executor output
+			response.Logs = nil
+			for _, attempt := range task.Logs {
+				var executors []tesExecutorLog
+				for _, executor := range attempt.Logs {
+					executors = append(executors, tesExecutorLog{ExitCode: executor.ExitCode})
+				}
+				attempt.Logs = executors
+				response.Logs = append(response.Logs, attempt)
+			}
+		}
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, `{"message": "not found"}`, http.StatusNotFound)
+	}
+}
+
+func newContainer(t *testing.T, url string) *store.ContainerMetadata {
+	dir, err := ioutil.TempDir("", "multicri-tes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &store.ContainerMetadata{
+		ID:         "container1",
+		Name:       "tes-test",
+		PodSandbox: store.SandboxMetadata{ID: "pod1"},
+		Image:      &store.ImageMetadata{RemotePath: "docker://alpine:latest", RepoType: store.DockerRepositoryImageRepo},
+		Command:    []string{"echo"},
+		Args:       []string{"hello"},
+		LogFile:    filepath.Join(dir, "container1.log"),
+		Environment: map[string]string{
+			"CLUSTER_TES_URL": url,
+			"GREETING":        "hello",
+		},
+		Extra: map[string]string{},
+	}
+}
+
+//Test containers are translated to TES tasks
+func TestUnitBuildTask(t *testing.T) {
+	cm := newContainer(t, "http://localhost")
+	cm.Config.WorkingDir = "/data"
+	cm.Config.Linux = &runtimeApi.LinuxContainerConfig{Resources: &runtimeApi.LinuxContainerResources{
+		CpuQuota: 150000, CpuPeriod: 100000, MemoryLimitInBytes: 2e9}}
+	cm.Environment["TES_INPUTS"] = "/data/in.txt=s3://bucket/in.txt, /data/ref/=gs://bucket/ref"
+	cm.Environment["TES_OUTPUTS"] = "/data/out/=s3://bucket/out"
+	cm.Environment["TES_DISK_GB"] = "10.5"
+	cm.Environment["TES_ZONES"] = "us-east-1a,us-east-1b"
+	cm.Environment["JOB_QUEUE"] = "batch"
+	task, err := buildTask(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	executor := tesExecutor{Image: "alpine:latest", Command: []string{"echo", "hello"}, Workdir: "/data",
+		Env: map[string]string{"GREETING": "hello"}}
+	if !reflect.DeepEqual(task.Executors, []tesExecutor{executor}) {
+		t.Errorf("Wrong executors: %+v", task.Executors)
+	}
+	inputs := []tesFile{{Path: "/data/in.txt", Url: "s3://bucket/in.txt", Type: fileType},
+		{Path: "/data/ref", Url: "gs://bucket/ref", Type: directoryType}}
+	if !reflect.DeepEqual(task.Inputs, inputs) {
+		t.Errorf("Wrong inputs: %+v", task.Inputs)
+	}
+	if !reflect.DeepEqual(task.Outputs, []tesFile{{Path: "/data/out", Url: "s3://bucket/out", Type: directoryType}}) {
+		t.Errorf("Wrong outputs: %+v", task.Outputs)
+	}
+	resources := &tesResources{CpuCores: 2, RamGb: 2, DiskGb: 10.5, Zones: []string{"us-east-1a", "us-east-1b"}}
+	if !reflect.DeepEqual(task.Resources, resources) {
+		t.Errorf("Wrong resources: %+v", task.Resources)
+	}
+	if task.Tags["multicri-container-id"] != "container1" || task.Tags["multicri-sandbox-id"] != "pod1" {
+		t.Errorf("Wrong tags: %v", task.Tags)
+	}
+	cm.Environment["JOB_NUM_CORES"] = "8"
+	if task, _ := buildTask(cm); task.Resources.CpuCores != 8 {
+		t.Errorf("Job cores should be requested: %d", task.Resources.CpuCores)
+	}
+
+	for name, change := range map[string]func(cm *store.ContainerMetadata){
+		"relative input": func(cm *store.ContainerMetadata) { cm.Environment["TES_INPUTS"] = "in.txt=s3://bucket/in.txt" },
+		"output url":     func(cm *store.ContainerMetadata) { cm.Environment["TES_OUTPUTS"] = "/data/out" },
+		"disk":           func(cm *store.ContainerMetadata) { cm.Environment["TES_DISK_GB"] = "ten" },
+		"command":        func(cm *store.ContainerMetadata) { cm.Command, cm.Args = nil, nil },
+		"image":          func(cm *store.ContainerMetadata) { cm.Image.RepoType = store.SingularityRepositoryImageRepo },
+	} {
+		cm := newContainer(t, "http://localhost")
+		change(cm)
+		if _, err := buildTask(cm); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+			t.Errorf("Wrong %s should be an invalid argument: %v", name, err)
+		}
+	}
+	if task, err := buildTask(newContainer(t, "http://localhost")); err != nil || task.Resources != nil {
+		t.Errorf("Tasks without requests should not set resources: %+v %v", task, err)
+	}
+}
+
+//Test TES states and logs are translated to container statuses
+func TestUnitTaskStatus(t *testing.T) {
+	logs := []tesTaskLog{{StartTime: "2019-10-14T10:00:00.5Z", EndTime: "2019-10-14T10:01:00Z",
+		Logs: []tesExecutorLog{{ExitCode: 3}}}}
+	for _, c := range []struct {
+		state    string
+		logs     []tesTaskLog
+		expected runtimeApi.ContainerState
+		exitCode int
+		reason   string
+	}{
+		{"QUEUED", nil, runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{"INITIALIZING", nil, runtimeApi.ContainerState_CONTAINER_CREATED, 0, ""},
+		{"RUNNING", nil, runtimeApi.ContainerState_CONTAINER_RUNNING, 0, ""},
+		{"COMPLETE", []tesTaskLog{{Logs: []tesExecutorLog{{ExitCode: 0}}}}, runtimeApi.ContainerState_CONTAINER_EXITED, 0, ""},
+		{"EXECUTOR_ERROR", logs, runtimeApi.ContainerState_CONTAINER_EXITED, 3, batch.ReasonError},
+		{"EXECUTOR_ERROR", nil, runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonError},
+		{"SYSTEM_ERROR", nil, runtimeApi.ContainerState_CONTAINER_EXITED, 1, batch.ReasonCannotRun},
+		{"CANCELED", nil, runtimeApi.ContainerState_CONTAINER_EXITED, 143, batch.ReasonKilled},
+		{"UNKNOWN", nil, runtimeApi.ContainerState_CONTAINER_UNKNOWN, 0, ""},
+	} {
+		status := taskStatus(&tesTask{State: c.state, Logs: c.logs})
+		if status.State != c.expected || status.ExitCode != c.exitCode || status.Reason != c.reason {
+			t.Errorf("Status of %s wrong: %+v", c.state, status)
+		}
+	}
+	status := taskStatus(&tesTask{State: "EXECUTOR_ERROR", Logs: logs})
+	if status.StartedAt != 1571047200500000000 || status.FinishedAt != 1571047260000000000 {
+		t.Errorf("Times should be exact: %d %d", status.StartedAt, status.FinishedAt)
+	}
+}
+
+//Test tasks are created, polled until they complete, and their output is written in the container log
+func TestUnitTaskLifecycle(t *testing.T) {
+	mock, server := newMockTES("secret")
+	defer server.Close()
+	adapter, err := NewTESAdapterWithConfig(adapters.AdapterConfig{"token": "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := newContainer(t, server.URL)
+	defer os.RemoveAll(filepath.Dir(cm.LogFile))
+	ctx := context.Background()
+	if err := adapter.CreateContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.StartContainer(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Extra[taskId] != "task-1" {
+		t.Fatalf("Task id should be kept: %v", cm.Extra)
+	}
+	for _, expected := range []runtimeApi.ContainerState{runtimeApi.ContainerState_CONTAINER_RUNNING,
+		runtimeApi.ContainerState_CONTAINER_EXITED} {
+		if err := adapter.ContainerStatus(ctx, cm); err != nil {
+			t.Fatal(err)
+		}
+		if cm.State != expected {
+			t.Fatalf("Container should be %s: %s", expected, cm.State)
+		}
+	}
+	if cm.ExitCode != 0 || cm.Reason != batch.ReasonCompleted || cm.StartedAt != 1571047200500000000 {
+		t.Errorf("Container should complete: %+v", cm)
+	}
+	log, err := ioutil.ReadFile(cm.LogFile)
+	if err != nil || !strings.Contains(string(log), "echo hello") {
+		t.Errorf("Task output should be logged: %q %v", log, err)
+	}
+	if err := adapter.StopContainer(ctx, cm); err != nil {
+		t.Errorf("Finished tasks should be stopped: %v", err)
+	}
+	mock.lock.Lock()
+	if state := mock.tasks["task-1"].State; state != "COMPLETE" {
+		t.Errorf("Finished tasks should not be cancelled: %s", state)
+	}
+	mock.lock.Unlock()
+
+	cm.Extra[taskId] = "missing"
+	if err := adapter.StopContainer(ctx, cm); err != nil {
+		t.Errorf("Removed tasks should be stopped: %v", err)
+	}
+	if err := adapter.ContainerStatus(ctx, cm); !adapters.IsNotFound(err) {
+		t.Errorf("Missing tasks should not be found: %v", err)
+	}
+	cm.Environment["CLUSTER_TES_TOKEN"] = "wrong"
+	if err := adapter.StartContainer(ctx, cm); adapters.ErrorKindOf(err) != adapters.KindPermissionDenied {
+		t.Errorf("Wrong tokens should be denied: %v", err)
+	}
+	delete(cm.Environment, "CLUSTER_TES_URL")
+	if err := adapter.CreateContainer(ctx, cm); adapters.ErrorKindOf(err) != adapters.KindInvalidArgument {
+		t.Errorf("Containers without TES service should be invalid: %v", err)
+	}
+}
+
+//Test the adapter passes the conformance suite against the TES mock
+func TestUnitConformance(t *testing.T) {
+	_, server := newMockTES("secret")
+	defer server.Close()
+	conformance.Run(t, conformance.Config{
+		New: func() (adapters.AdapterInterface, error) {
+			return NewTESAdapterWithConfig(adapters.AdapterConfig{"url": server.URL, "token": "secret"})
+		},
+		StartedStates: []runtimeApi.ContainerState{
+			runtimeApi.ContainerState_CONTAINER_CREATED,
+			runtimeApi.ContainerState_CONTAINER_RUNNING,
+			runtimeApi.ContainerState_CONTAINER_EXITED,
+		},
+		Timeout:      10 * time.Second,
+		PollInterval: 10 * time.Millisecond,
+	})
+}
@@ -20,13 +20,17 @@ import (
 	osexec "os/exec"
 	"strings"
 
+	"golang.org/x/net/context"
 	"k8s.io/klog"
 )
 
 type SingularityCLI interface {
-	RunAsyncCommand(command []string) error
-	RunSyncCommand(command []string) ([]string, error)
-	SingularityPullImage(imagePath string, remoteImage string, auth string) error
+	RunAsyncCommand(ctx context.Context, command []string) error
+	RunSyncCommand(ctx context.Context, command []string) ([]string, error)
+	SingularityPullImage(ctx context.Context, imagePath string, remoteImage string, auth string) error
+	InstanceStart(ctx context.Context, imagePath string, instanceName string, args []string) error
+	InstanceStop(ctx context.Context, instanceName string) error
+	ExecCommand(instanceName string, args []string, command []string) *osexec.Cmd
 }
 
 type CLIConfig struct {
@@ -56,49 +60,79 @@ func NewSingularityCLI(singularityPath string, cfg CLIConfig) (SingularityCLI, e
 	return &cli{singularityPath: singularityPath, config: cfg}, nil
 }
 
-func (c *cli) SingularityPullImage(imagePath string, imageRemote string, auth string) error {
+func (c *cli) SingularityPullImage(ctx context.Context, imagePath string, imageRemote string, auth string) error {
 	var args []string
 	command, err := c.generateSingularityImageCommand("pull", imagePath, imageRemote, args, auth)
 	if err != nil {
 		return fmt.Errorf("Error building the singularity stop command")
 	}
-	err = c.RunAsyncCommand(command)
+	err = c.RunAsyncCommand(ctx, command)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// RunCommand runs singularity command related to the container management
-func (c *cli) RunAsyncCommand(command []string) error {
+// InstanceStart starts the image as a named instance, in which the container processes are executed
+func (c *cli) InstanceStart(ctx context.Context, imagePath string, instanceName string, args []string) error {
+	command := append(c.instanceCommand("start", args...), imagePath, instanceName)
+	if out, err := c.RunSyncCommand(ctx, command); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.Join(out, "\n"))
+	}
+	return nil
+}
+
+// InstanceStop stops the instance and its processes
+func (c *cli) InstanceStop(ctx context.Context, instanceName string) error {
+	if out, err := c.RunSyncCommand(ctx, c.instanceCommand("stop", instanceName)); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.Join(out, "\n"))
+	}
+	return nil
+}
+
+// ExecCommand returns the command which executes the command in the instance. It is not started,
+// so the caller sets its standard streams, environment and lifetime.
+func (c *cli) ExecCommand(instanceName string, args []string, command []string) *osexec.Cmd {
+	cmdArgs := append([]string{"exec"}, c.globalFlags...)
+	cmdArgs = append(cmdArgs, args...)
+	cmdArgs = append(cmdArgs, "instance://"+instanceName)
+	cmdArgs = append(cmdArgs, command...)
+	klog.V(4).Infof("singularity: creating cmd %v", cmdArgs)
+	return osexec.Command(c.singularityPath, cmdArgs...)
+}
+
+// RunCommand runs singularity command related to the container management.
+// The process is killed when the context is done.
+func (c *cli) RunAsyncCommand(ctx context.Context, command []string) error {
 	var err error
 	klog.V(4).Infof("singularity: calling cmd %v", command)
 	// Create command
-	cmd := osexec.Command(command[0], command[1:]...)
+	cmd := osexec.CommandContext(ctx, command[0], command[1:]...)
 	//Output to system by default
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	//execute
 	err = cmd.Run()
 	if err != nil {
-		klog.Warningf("singularity: cmd %v %v errored with %v", command, err)
-		return fmt.Errorf("failed to run %v: %v", command, err)
+		klog.Warningf("singularity: cmd %v errored with %v", command, err)
+		return fmt.Errorf("failed to run %v: %w", command, err)
 	}
 
 	return nil
 }
 
-// RunCommand runs singularity command related to the container management
-func (c *cli) RunSyncCommand(command []string) ([]string, error) {
+// RunCommand runs singularity command related to the container management.
+// The process is killed when the context is done.
+func (c *cli) RunSyncCommand(ctx context.Context, command []string) ([]string, error) {
 	klog.V(4).Infof("singularity: calling cmd %v", command)
 	// Create command
-	cmd := osexec.Command(command[0], command[1:]...)
+	cmd := osexec.CommandContext(ctx, command[0], command[1:]...)
 	//execute
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		klog.Warningf("singularity: cmd %v errored with %v", command, err)
 		return strings.Split(strings.TrimSpace(string(out)), "\n"),
-			fmt.Errorf("failed to run %v: %v", command, err)
+			fmt.Errorf("failed to run %v: %w", command, err)
 	}
 
 	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
@@ -123,3 +157,9 @@ func (c *cli) generateSingularityImageCommand(subCommand string, image string, i
 	cmd = append(append(cmd, c.globalFlags...), cmd_args...)
 	return cmd, nil
 }
+
+//Generate singularity instance command
+func (c *cli) instanceCommand(action string, args ...string) []string {
+	cmd := append([]string{c.singularityPath, "instance", action}, c.globalFlags...)
+	return append(cmd, args...)
+}
@@ -20,6 +20,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync/atomic"
 	"time"
 )
 
@@ -62,8 +63,14 @@ func CreatePathDirectory(path string) error {
 	return os.MkdirAll(path, 0755)
 }
 
+// tmpFiles makes the names of the temporary files unique in the process
+var tmpFiles uint64
+
+// GenerateTmpFile returns a new temporary file path. The files of the jobs submitted at the same time
+// must not be mixed.
 func GenerateTmpFile(path, name, extension string) string {
-	return fmt.Sprintf("%s/%s_job_%s.%s", path, name, time.Now().Format("20060102150405"), extension)
+	return fmt.Sprintf("%s/%s_job_%s_%d_%d.%s", path, name, time.Now().Format("20060102150405"),
+		os.Getpid(), atomic.AddUint64(&tmpFiles, 1), extension)
 }
 
 //Generate pod log directory path
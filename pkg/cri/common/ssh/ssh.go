@@ -27,9 +27,33 @@ import (
 
 	"github.com/tmc/scp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
 	"k8s.io/klog"
 )
 
+// ConnectionError is returned when the SSH server can not be reached or a session can not be opened
+type ConnectionError struct {
+	Err error
+}
+
+func (e *ConnectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// IsConnectionError returns whether the command failed because the SSH server could not be reached
+func IsConnectionError(err error) bool {
+	_, ok := err.(*ConnectionError)
+	return ok
+}
+
+func sessionError(err error) error {
+	return &ConnectionError{fmt.Errorf("Unable to get a session: %w", err)}
+}
+
 type SSH struct {
 	client   *ssh.Client
 	user     string
@@ -91,6 +115,13 @@ This method creates the connection that will support the ssh session and store
 this connection in the adapter.client field.
 */
 func (adapter *SSH) Connect() error {
+	return adapter.ConnectContext(context.Background())
+}
+
+/*
+This method creates the connection like Connect, but it gives up when the context is done.
+*/
+func (adapter *SSH) ConnectContext(ctx context.Context) error {
 	type HostKeyCallback func(hostname string, remote net.Addr, key ssh.PublicKey) error
 	var (
 		auth         []ssh.AuthMethod
@@ -123,9 +154,9 @@ func (adapter *SSH) Connect() error {
 	}
 	addr = fmt.Sprintf("%s:%s", adapter.host, adapter.port)
 	klog.V(4).Infof("Connecting to %s ...", addr)
-	client, err = ssh.Dial("tcp", addr, clientConfig)
+	client, err = dialContext(ctx, addr, clientConfig)
 	if err != nil {
-		return fmt.Errorf("Error connecting to %s:%s  : %s", adapter.host, adapter.port, err)
+		return fmt.Errorf("Error connecting to %s:%s  : %w", adapter.host, adapter.port, err)
 	}
 	klog.V(4).Infof("Connected successfully!!!")
 	adapter.client = client
@@ -137,17 +168,21 @@ This function creates a new session and return it. The method will create a new
 connection to support the new ssh session if it does not already exists.
 */
 func (adapter *SSH) GetSession(tty bool) (*ssh.Session, error) {
+	return adapter.getSession(context.Background(), tty)
+}
+
+func (adapter *SSH) getSession(ctx context.Context, tty bool) (*ssh.Session, error) {
 
 	klog.V(4).Infof("Openning a new session...")
 	if adapter.client == nil {
-		err := adapter.Connect()
+		err := adapter.ConnectContext(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("Unable to create a session, could not connect: %v", err)
+			return nil, &ConnectionError{fmt.Errorf("Unable to create a session, could not connect: %w", err)}
 		}
 	}
 	session, err := adapter.client.NewSession()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating a new session for %s@%s:%s : %v", adapter.user, adapter.host, adapter.port, err)
+		return nil, &ConnectionError{fmt.Errorf("Error creating a new session for %s@%s:%s : %v", adapter.user, adapter.host, adapter.port, err)}
 	}
 	if tty {
 		klog.V(4).Infof("Requesting Pseudo terminal (Pty)")
@@ -172,17 +207,19 @@ func (adapter *SSH) GetSession(tty bool) (*ssh.Session, error) {
 /*
 This function runs a command throgh ssh sincronously.  It accepts two io.WriteCloser
 interfaces where the stdout and stderr of the command will be written. In addition,  stdout and stderr of the
-command are returned as strings.
+command are returned as strings. The session is closed when the context is done, which aborts the command.
 */
-func (adapter SSH) Run(command string, stdout, stderr io.WriteCloser, tty bool) (string, string, error) {
-	session, err := adapter.GetSession(tty)
+func (adapter SSH) Run(ctx context.Context, command string, stdout, stderr io.WriteCloser, tty bool) (string, string, error) {
+	session, err := adapter.getSession(ctx, tty)
 	if err != nil {
-		return "", "", fmt.Errorf("Unable to get a session: %s", err)
+		return "", "", sessionError(err)
 	}
 	defer func() {
 		session.Close()
 		klog.V(4).Infof("Session closed.")
 	}()
+	stop := watchContext(ctx, session)
+	defer stop()
 
 	var stdoutBuf bytes.Buffer
 	var stderrBuf bytes.Buffer
@@ -192,6 +229,9 @@ func (adapter SSH) Run(command string, stdout, stderr io.WriteCloser, tty bool)
 	klog.V(4).Infof("Running command: %s", command)
 
 	err = session.Run(command)
+	if ctx.Err() != nil {
+		return stdoutBuf.String(), stderrBuf.String(), fmt.Errorf("Command %s aborted: %w", command, ctx.Err())
+	}
 
 	out := stdoutBuf.String()
 	errString := stderrBuf.String()
@@ -216,46 +256,94 @@ func (adapter SSH) Run(command string, stdout, stderr io.WriteCloser, tty bool)
 }
 
 /*
-This function runs a command through ssh asyncronously. The function accepts two
-io.WriteCloser interfaces where the command will ouput the stdout and stderr.
-The function also return the seassion that is running  the command. It is under
-the responability of the user of this function to wait for the command to end and
-close the session.
+This function runs a command through ssh and returns its stdout, stderr and exit status. Unlike Run, the
+output in the stderr and the exit status of the command are not errors, only the failures to run it.
+The session is closed when the context is done, which aborts the command.
 */
-func (adapter SSH) RunAsync(command string, stdout io.WriteCloser, stderr io.WriteCloser) (*ssh.Session, error) {
-	session, err := adapter.GetSession(true)
+func (adapter SSH) RunStatus(ctx context.Context, command string) (string, string, int, error) {
+	session, err := adapter.getSession(ctx, false)
 	if err != nil {
-		klog.Errorf("Unable to get a session: %s", err)
-		return nil, fmt.Errorf("Unable to get a session: %s", err)
+		return "", "", 0, sessionError(err)
+	}
+	defer func() {
+		session.Close()
+		klog.V(4).Infof("Session closed.")
+	}()
+	stop := watchContext(ctx, session)
+	defer stop()
+
+	var stdoutBuf bytes.Buffer
+	var stderrBuf bytes.Buffer
+	session.Stdout = &stdoutBuf
+	session.Stderr = &stderrBuf
+
+	klog.V(4).Infof("Running command: %s", command)
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return stdoutBuf.String(), stderrBuf.String(), 0, fmt.Errorf("Command %s aborted: %w", command, ctx.Err())
+	}
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return stdoutBuf.String(), stderrBuf.String(), exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return stdoutBuf.String(), stderrBuf.String(), 0, fmt.Errorf("Error running the command %s : %v", command, err)
 	}
+	klog.V(4).Infof("Command %s finished", command)
+	return stdoutBuf.String(), stderrBuf.String(), 0, nil
+}
+
+/*
+This function runs a command through ssh, writing its stdout and stderr while it runs, for long running
+commands such as the ones which follow logs. It returns when the command finishes, or when the context
+is done, which aborts the command.
+*/
+func (adapter SSH) Stream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	session, err := adapter.getSession(ctx, false)
+	if err != nil {
+		return sessionError(err)
+	}
+	defer func() {
+		session.Close()
+		klog.V(4).Infof("Session closed.")
+	}()
+	stop := watchContext(ctx, session)
+	defer stop()
 	session.Stdout = stdout
 	session.Stderr = stderr
-	klog.V(4).Infof("Launching command: %s", command)
-	err = session.Start(command)
+
+	klog.V(4).Infof("Streaming command: %s", command)
+	err = session.Run(command)
+	if ctx.Err() != nil {
+		return fmt.Errorf("Command %s aborted: %w", command, ctx.Err())
+	}
 	if err != nil {
-		klog.Errorf("Unable to launch the command %s : %s", command, err)
-		return nil, fmt.Errorf("Unable to launch the command %s : %s", command, err)
+		return fmt.Errorf("Error running the command %s : %v", command, err)
 	}
-	klog.V(4).Infof("Command: %s launched!", command)
-	return session, nil
+	return nil
 }
 
 /*
 This function copies a file from the local filesystem to the remote host through ssh.
 The file permissions are preserved and it overwrites the destination if already exists.
+The copy is aborted when the context is done.
 */
-func (adapter SSH) CopyTo(source, destination string) error {
-	session, err := adapter.GetSession(false)
+func (adapter SSH) CopyTo(ctx context.Context, source, destination string) error {
+	session, err := adapter.getSession(ctx, false)
 	if err != nil {
 		klog.Errorf("Unable to get a session: %s", err)
-		return fmt.Errorf("Unable to get a session: %v", err)
+		return sessionError(err)
 	}
 	klog.V(4).Infof("Copying file from %s to %s@%s:%s...", source, adapter.user, adapter.host, destination)
 	defer func() {
 		session.Close()
 		klog.V(4).Infof("Session closed.")
 	}()
+	stop := watchContext(ctx, session)
+	defer stop()
 	err = scp.CopyPath(source, destination, session)
+	if ctx.Err() != nil {
+		return fmt.Errorf("Copy of file %s aborted: %w", source, ctx.Err())
+	}
 	if err != nil {
 		return fmt.Errorf("Unable to copy file %s to %s:%s:%s: %v", source, adapter.host, adapter.port, destination, err)
 	}
@@ -266,12 +354,13 @@ func (adapter SSH) CopyTo(source, destination string) error {
 /*
 This function copies a file from the remote host to the local filesystem through ssh.
 The file permissions are preserved and it overwrites the destination if already exists.
+The copy is aborted when the context is done.
 */
-func (adapter SSH) CopyFrom(source, destination string) error {
+func (adapter SSH) CopyFrom(ctx context.Context, source, destination string) error {
 
 	klog.V(4).Infof("Getting file permissions of: %s@%s:%s", adapter.user, adapter.host, source)
 	cmd := fmt.Sprintf("stat -c \"%%a\" %s", source)
-	stdout, _, err := adapter.Run(cmd, nil, nil, false)
+	stdout, _, err := adapter.Run(ctx, cmd, nil, nil, false)
 	if err != nil {
 		klog.Errorf("Unable to get file permissions for file %s : %s", destination, err)
 		return fmt.Errorf("Unable to get file permissions for file %s : %s", destination, err)
@@ -284,15 +373,17 @@ func (adapter SSH) CopyFrom(source, destination string) error {
 	}
 	mode := os.FileMode(modeuint)
 	klog.V(4).Infof("Got %o file permissions", mode)
-	session, err := adapter.GetSession(false)
+	session, err := adapter.getSession(ctx, false)
 	if err != nil {
 		klog.Errorf("Unable to get a session: %s", err)
-		return fmt.Errorf("Unable to get a session: %s", err)
+		return sessionError(err)
 	}
 	defer func() {
 		session.Close()
 		klog.V(4).Infof("Session closed.")
 	}()
+	stop := watchContext(ctx, session)
+	defer stop()
 	remotefile, err := session.StdoutPipe()
 	if err != nil {
 		klog.Errorf("Unable to get a pipe from %s:%s: %s", adapter.host, adapter.port, err)
@@ -316,6 +407,9 @@ func (adapter SSH) CopyFrom(source, destination string) error {
 		return fmt.Errorf("Unable to launch the command %s : %s", cmd, err)
 	}
 	_, err = io.Copy(localfile, remotefile)
+	if ctx.Err() != nil {
+		return fmt.Errorf("Copy of file %s aborted: %w", source, ctx.Err())
+	}
 	if err != nil {
 		klog.Errorf("Unable to copy file %s : %s", source, err)
 		return fmt.Errorf("Unable to copy file %s : %s", source, err)
@@ -327,3 +421,53 @@ func (adapter SSH) CopyFrom(source, destination string) error {
 	klog.V(4).Infof("File %s@%s:%s copied succesfully!!", adapter.user, adapter.host, source)
 	return nil
 }
+
+// dialContext opens the ssh connection, giving up when the context is done
+func dialContext(ctx context.Context, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	d := net.Dialer{Timeout: config.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The handshake does not take a context, so the connection is closed to abort it
+	stop := make(chan struct{})
+	aborted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			aborted <- true
+		case <-stop:
+			aborted <- false
+		}
+	}()
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	close(stop)
+	if <-aborted {
+		if err == nil {
+			c.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// watchContext kills the remote command and closes the session when the context is done.
+// The returned function stops watching the context.
+func watchContext(ctx context.Context, session *ssh.Session) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			klog.V(4).Infof("Aborting ssh session: %v", ctx.Err())
+			session.Signal(ssh.SIGKILL) // nolint: errcheck
+			session.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
@@ -17,6 +17,7 @@ package ssh
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"os"
 	"strings"
 	"testing"
@@ -24,7 +25,8 @@ import (
 
 	"multi-cri/pkg/cri/common"
 
-	"k8s.io/klog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/context"
 )
 
 func recoverEnv(t *testing.T) {
@@ -91,7 +93,7 @@ func TestIntegrationRun(t *testing.T) {
 	port := common.GetEnv("TEST_SSH_PORT", nil)
 	adapter := NewSSH(user, host, port, &keypath, nil, nil)
 
-	stdout, _, err := adapter.Run("echo \"Hello ssh\"", nil, nil, false)
+	stdout, _, err := adapter.Run(context.Background(), "echo \"Hello ssh\"", nil, nil, false)
 	if err != nil {
 		t.Errorf("Error running command: %s", err)
 	}
@@ -113,13 +115,13 @@ func TestIntegrationCopy(t *testing.T) {
 
 	source := "testdata/testfile.txt"
 	destination := "testfile-copy.txt"
-	err := adapter.CopyTo(source, destination)
+	err := adapter.CopyTo(context.Background(), source, destination)
 	if err != nil {
 		t.Errorf("Error copying file to cluster: %s", err)
 	}
 	source = destination
 	destination = "/tmp/file.txt"
-	err = adapter.CopyFrom(source, destination)
+	err = adapter.CopyFrom(context.Background(), source, destination)
 	if err != nil {
 		t.Errorf("Error copying file from cluster: %s", err)
 	}
@@ -143,7 +145,7 @@ func TestIntegrationLog(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error opening output stderr file: %s", err)
 	}
-	stdoutString, _, err := adapter.Run("echo \"hello\" && sleep 1 && echo \"world\"", fout, ferr, false)
+	stdoutString, _, err := adapter.Run(context.Background(), "echo \"hello\" && sleep 1 && echo \"world\"", fout, ferr, false)
 	if err != nil {
 		t.Errorf("Error on Run: %s", err)
 	}
@@ -165,69 +167,30 @@ func TestIntegrationLog(t *testing.T) {
 	fout.Close()
 }
 
-func TestIntegrationAsync(t *testing.T) {
-	defer recoverEnv(t)
-	keypath := common.GetEnv("TEST_SSH_KEY_PATH", nil)
-	user := common.GetEnv("TEST_SSH_USER", nil)
-	host := common.GetEnv("TEST_SSH_HOST", nil)
-	port := common.GetEnv("TEST_SSH_PORT", nil)
-	adapter := NewSSH(user, host, port, &keypath, nil, nil)
-
-	fout, err := os.OpenFile("TestAsync-out.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+//Test the connection gives up when the context is done during the handshake of a silent server
+func TestUnitDialContextHandshake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Errorf("Error opening output log file: %s", err)
-	} else {
-		defer fout.Close()
-	}
-
-	ferr, err := os.OpenFile("TestAsync-err.txt", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		t.Errorf("Error opening output stderr file: %s", err)
-	} else {
-		defer fout.Close()
-	}
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	config := &ssh.ClientConfig{User: "user", Timeout: 30 * time.Second, HostKeyCallback: ssh.InsecureIgnoreHostKey()}
 	start := time.Now()
-	session, err := adapter.RunAsync("echo \"hello\" && sleep 30 && echo \"world\"", fout, ferr)
-	if err != nil {
-		t.Errorf("Error on runasync: %s", err)
-	} else {
-		defer session.Close()
-	}
-	end := time.Now()
-	eleapsed := end.Sub(start)
-	if eleapsed.Seconds() >= 30 {
-		t.Errorf("Command was not run async, eleapsed %f", eleapsed.Seconds())
-	}
-
-	stdoutbuffer := new(bytes.Buffer)
-	bytesread := int64(0)
-	for i := 0; i < 3 && bytesread < 6; i++ {
-		time.Sleep(5 * time.Second)
-		fout.Sync()
-		fout.Seek(0, 0)
-		bytesread, err = stdoutbuffer.ReadFrom(fout)
-		klog.Infof("Reading from stdout... : %s", stdoutbuffer.String())
+	if _, err := dialContext(ctx, listener.Addr().String(), config); err != context.DeadlineExceeded {
+		t.Errorf("Handshake must fail with the context error: %v", err)
 	}
-	if err != nil {
-		t.Errorf("Error reading the logs file")
-	}
-	if strings.Contains(stdoutbuffer.String(), "hello\n") {
-		t.Errorf("Error the output stdout does not match the expected (bytes read %d) : %s", bytesread, stdoutbuffer.String())
-	} else {
-		klog.Infof("Stdout buffer ok! %s", stdoutbuffer.String())
-	}
-	session.Wait()
-
-	fout.Sync()
-	fout.Seek(0, 0)
-	stdoutbuffer = new(bytes.Buffer)
-	bytesread, err = stdoutbuffer.ReadFrom(fout)
-	if err != nil {
-		t.Errorf("Error reading the logs file")
-	}
-	if strings.Contains(stdoutbuffer.String(), "hello\nworld\n") {
-		t.Errorf("Error the output stdout does not match the expected (bytes read %d) : %s", bytesread, stdoutbuffer.String())
-	} else {
-		klog.Infof("Stdout buffer ok! %s", stdoutbuffer.String())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Handshake must give up when the context is done, it took %s", elapsed)
 	}
 }
@@ -134,3 +134,16 @@ func (n *NetNS) ensureNSDelete() error {
 	}
 	return nil
 }
+
+// RunInNetNS runs f in the network namespace at path. The processes started by f are created in
+// the namespace, and the sockets opened by f belong to it.
+func RunInNetNS(path string, f func() error) error {
+	netns, err := cnins.GetNS(path)
+	if err != nil {
+		return fmt.Errorf("failed to open network namespace %s: %v", path, err)
+	}
+	defer netns.Close()
+	return netns.Do(func(cnins.NetNS) error {
+		return f()
+	})
+}
@@ -15,12 +15,15 @@
 package runtime
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"multi-cri/pkg/cri/store"
 
 	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/klog"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
@@ -34,7 +37,13 @@ func (r *MulticriRuntime) ReopenContainerLog(ctx context.Context, req *runtimeAp
 	if response != nil {
 		return response, err
 	}
-	return &runtimeApi.ReopenContainerLogResponse{}, r.adapter.ReopenContainerLog(c)
+	adapter, err := r.getCapableAdapter(c.PodSandbox.RuntimeHandler, "ReopenContainerLog")
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "ReopenContainerLog")
+	defer cancel()
+	return &runtimeApi.ReopenContainerLogResponse{}, adapterError(adapterCtx, adapter.ReopenContainerLog(adapterCtx, c))
 }
 
 func (r *MulticriRuntime) CreateContainer(ctx context.Context, req *runtimeApi.CreateContainerRequest) (*runtimeApi.CreateContainerResponse, error) {
@@ -54,8 +63,12 @@ func (r *MulticriRuntime) CreateContainer(ctx context.Context, req *runtimeApi.C
 	var container *store.ContainerMetadata
 
 	if response == nil {
+		adapter, err := r.getAdapter(sandbox.RuntimeHandler)
+		if err != nil {
+			return nil, err
+		}
 		name := req.GetConfig().GetMetadata().Name
-		image, err := r.imageStore.GetByID(req.Config.Image.Image)
+		image, err := r.imageStore.GetByRuntimeHandler(req.Config.Image.Image, r.runtimeHandlerName(sandbox.RuntimeHandler))
 		if err != nil {
 			return nil, fmt.Errorf("Image not found")
 		}
@@ -77,9 +90,11 @@ func (r *MulticriRuntime) CreateContainer(ctx context.Context, req *runtimeApi.C
 			sandbox, state, createdAt, image, req.GetConfig().Command, req.GetConfig().Args,
 			true, *req.GetConfig(), envVars, port, nil)
 
-		if err := r.adapter.CreateContainer(container); err != nil {
+		adapterCtx, cancel := r.adapterContext(ctx, "CreateContainer")
+		defer cancel()
+		if err := adapter.CreateContainer(adapterCtx, container); err != nil {
 			klog.V(4).Info(err)
-			return nil, err
+			return nil, adapterError(adapterCtx, err)
 		}
 
 		// It can be modified on the container creation
@@ -113,11 +128,17 @@ func (r *MulticriRuntime) StartContainer(ctx context.Context, req *runtimeApi.St
 	if cm.State == runtimeApi.ContainerState_CONTAINER_RUNNING {
 		return &runtimeApi.StartContainerResponse{}, fmt.Errorf("Container already started")
 	}
+	adapter, err := r.getAdapter(cm.PodSandbox.RuntimeHandler)
+	if err != nil {
+		return nil, err
+	}
 	if cm.State != runtimeApi.ContainerState_CONTAINER_CREATED {
 		return &runtimeApi.StartContainerResponse{}, fmt.Errorf("Container failed")
 	}
 
-	if err = r.adapter.StartContainer(cm); err != nil {
+	adapterCtx, cancel := r.adapterContext(ctx, "StartContainer")
+	defer cancel()
+	if err = adapter.StartContainer(adapterCtx, cm); err != nil {
 		klog.V(4).Info(err)
 		cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
 		cm.Reason = "Start container fails"
@@ -125,6 +146,7 @@ func (r *MulticriRuntime) StartContainer(ctx context.Context, req *runtimeApi.St
 		cm.FinishedAt = time.Now().Unix()
 		response = nil
 		cm.Reason = "ContainerCannotRun"
+		err = adapterError(adapterCtx, err)
 	} else {
 		cm.State = runtimeApi.ContainerState_CONTAINER_RUNNING
 		cm.StartedAt = int64(time.Now().UnixNano())
@@ -159,10 +181,16 @@ func (r *MulticriRuntime) StopContainer(ctx context.Context, req *runtimeApi.Sto
 		return &runtimeApi.StopContainerResponse{}, nil
 	}
 
-	if err = r.adapter.StopContainer(cm); err != nil {
-		klog.V(4).Info(err)
+	adapter, err := r.getAdapter(cm.PodSandbox.RuntimeHandler)
+	if err != nil {
 		return nil, err
 	}
+	adapterCtx, cancel := r.adapterContext(ctx, "StopContainer")
+	defer cancel()
+	if err = adapter.StopContainer(adapterCtx, cm); err != nil {
+		klog.V(4).Info(err)
+		return nil, adapterError(adapterCtx, err)
+	}
 
 	cm.State = runtimeApi.ContainerState_CONTAINER_EXITED
 	cm.FinishedAt = int64(time.Now().UnixNano())
@@ -173,8 +201,8 @@ func (r *MulticriRuntime) StopContainer(ctx context.Context, req *runtimeApi.Sto
 
 func (r *MulticriRuntime) ListContainers(ctx context.Context, req *runtimeApi.ListContainersRequest) (*runtimeApi.ListContainersResponse, error) {
 	klog.V(4).Infof("List containers")
-	babelCRI := r.remoteCRI.MulticriRuntimeName()
-	containers := r.containerStore.ListK8s(req.Filter.Id, req.Filter.PodSandboxId, req.Filter.LabelSelector, req.Filter.State, babelCRI)
+	localCRIs := r.remoteCRI.LocalRuntimeNames()
+	containers := r.containerStore.ListK8s(req.Filter.Id, req.Filter.PodSandboxId, req.Filter.LabelSelector, req.Filter.State, localCRIs)
 	remoteContainers, err := r.remoteCRI.ListContainers(ctx, req)
 	if err != nil {
 		return nil, err
@@ -202,10 +230,19 @@ func (r *MulticriRuntime) RemoveContainer(ctx context.Context, req *runtimeApi.R
 		if cm.State == runtimeApi.ContainerState_CONTAINER_RUNNING {
 			return &runtimeApi.RemoveContainerResponse{}, fmt.Errorf("Running containers can not be deleted %s", containerId)
 		}
-		if err := r.adapter.ReopenContainerLog(cm); err != nil {
-			klog.V(4).Info(err)
+		adapter, err := r.getAdapter(cm.PodSandbox.RuntimeHandler)
+		if err != nil {
 			return nil, err
 		}
+		// Adapters which can not reopen the logs have nothing to flush
+		if adapter.Capabilities().ReopenContainerLog {
+			adapterCtx, cancel := r.adapterContext(ctx, "ReopenContainerLog")
+			defer cancel()
+			if err := adapter.ReopenContainerLog(adapterCtx, cm); err != nil {
+				klog.V(4).Info(err)
+				return nil, adapterError(adapterCtx, err)
+			}
+		}
 	}
 
 	r.sandboxStore.RemoveContainer(cm.PodSandbox.ID, containerId)
@@ -225,15 +262,41 @@ func (r *MulticriRuntime) ContainerStatus(ctx context.Context, req *runtimeApi.C
 		return nil, err
 	}
 	if response == nil {
+		if errGet != nil {
+			return nil, status.Errorf(codes.NotFound, "Container %s not found", containerId)
+		}
 		if cm.State != runtimeApi.ContainerState_CONTAINER_EXITED {
-			if err = r.adapter.ContainerStatus(cm); err != nil {
+			adapter, err := r.getAdapter(cm.PodSandbox.RuntimeHandler)
+			if err != nil {
+				return nil, err
+			}
+			adapterCtx, cancel := r.adapterContext(ctx, "ContainerStatus")
+			defer cancel()
+			// The adapter updates a copy, so the container keeps its state when the backend can not be reached
+			updated := *cm
+			updated.Extra = make(map[string]string, len(cm.Extra))
+			for key, value := range cm.Extra {
+				updated.Extra[key] = value
+			}
+			if err = adapter.ContainerStatus(adapterCtx, &updated); err != nil {
 				klog.V(4).Info(err)
-				manageContainerError(cm, err)
+				if transientError(adapterCtx, err) {
+					return nil, adapterError(adapterCtx, err)
+				}
+				manageContainerError(&updated, err)
 			}
+			*cm = updated
 		}
 		r.containerStore.Update(cm)
 		containerStatus := store.GetK8sContainerStatus(cm)
 		response = &runtimeApi.ContainerStatusResponse{Status: &containerStatus}
+		if req.Verbose {
+			info, err := containerInfo(cm)
+			if err != nil {
+				return nil, err
+			}
+			response.Info = info
+		}
 	}
 	return response, nil
 }
@@ -246,7 +309,7 @@ func (r *MulticriRuntime) ListContainerStats(ctx context.Context, req *runtimeAp
 	}
 
 	stats := []*runtimeApi.ContainerStats{}
-	for _, K8Container := range r.containerStore.ListK8s(req.Filter.Id, req.Filter.GetPodSandboxId(), req.Filter.LabelSelector, nil, r.remoteCRI.MulticriRuntimeName()) {
+	for _, K8Container := range r.containerStore.ListK8s(req.Filter.Id, req.Filter.GetPodSandboxId(), req.Filter.LabelSelector, nil, r.remoteCRI.LocalRuntimeNames()) {
 		attributes := runtimeApi.ContainerAttributes{K8Container.Id,
 			K8Container.Metadata, K8Container.Labels,
 			K8Container.Annotations,
@@ -288,16 +351,58 @@ func (r *MulticriRuntime) ContainerStats(ctx context.Context, req *runtimeApi.Co
 
 func (r *MulticriRuntime) UpdateContainerResources(ctx context.Context, req *runtimeApi.UpdateContainerResourcesRequest) (*runtimeApi.UpdateContainerResourcesResponse, error) {
 	klog.V(4).Infof("Updating container resources%s", req.GetContainerId())
-	cm, err := r.containerStore.Get(req.GetContainerId())
+	cm, errGet := r.containerStore.Get(req.GetContainerId())
+	var runtimeClass string
+	if errGet == nil {
+		runtimeClass = cm.PodSandbox.RuntimeHandler
+	}
+	response, err := r.remoteCRI.UpdateContainerResources(runtimeClass, ctx, req)
+	if response != nil || err != nil {
+		return response, err
+	}
+	if errGet != nil {
+		return nil, status.Errorf(codes.NotFound, "Container %s not found", req.GetContainerId())
+	}
+	adapter, err := r.getCapableAdapter(cm.PodSandbox.RuntimeHandler, "UpdateContainerResources")
 	if err != nil {
-		cm = &store.ContainerMetadata{}
+		return nil, err
 	}
-	response, err := r.remoteCRI.UpdateContainerResources(cm.PodSandbox.RuntimeHandler, ctx, req)
-	if response != nil {
-		response = &runtimeApi.UpdateContainerResourcesResponse{}
+	//The adapter reads the new resources from the container, which keeps them when the update succeeds
+	linux := cm.Config.Linux
+	if req.GetLinux() != nil {
+		updated := &runtimeApi.LinuxContainerConfig{}
+		if linux != nil {
+			*updated = *linux
+		}
+		updated.Resources = req.GetLinux()
+		cm.Config.Linux = updated
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "UpdateContainerResources")
+	defer cancel()
+	if err := adapter.UpdateContainerResources(adapterCtx, cm); err != nil {
+		cm.Config.Linux = linux
+		return nil, adapterError(adapterCtx, err)
+	}
+	r.containerStore.Update(cm)
+	return &runtimeApi.UpdateContainerResourcesResponse{}, nil
+}
+
+// containerInfo returns the verbose information of the container: its resources, as last updated, and
+// the extra information of its adapter, such as the job resources of the batch adapters
+func containerInfo(cm *store.ContainerMetadata) (map[string]string, error) {
+	var resources *runtimeApi.LinuxContainerResources
+	if cm.Config.Linux != nil {
+		resources = cm.Config.Linux.Resources
+	}
+	info := make(map[string]string)
+	for key, value := range map[string]interface{}{"resources": resources, "extra": cm.Extra} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal container %s: %v", key, err)
+		}
+		info[key] = string(data)
 	}
-	//TODO: implement it
-	return response, r.adapter.UpdateContainerResources(cm)
+	return info, nil
 }
 
 func manageContainerError(cm *store.ContainerMetadata, err error) {
@@ -0,0 +1,63 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodes maps the adapter error kinds to gRPC status codes
+var errorCodes = map[adapters.ErrorKind]codes.Code{
+	adapters.KindNotFound:         codes.NotFound,
+	adapters.KindUnimplemented:    codes.Unimplemented,
+	adapters.KindUnavailable:      codes.Unavailable,
+	adapters.KindInvalidArgument:  codes.InvalidArgument,
+	adapters.KindPermissionDenied: codes.PermissionDenied,
+}
+
+// adapterError translates the error returned by the adapter to a gRPC status error, so the kubelet retries the
+// transient failures and fails fast on the user ones. Errors of operations cancelled or out of time keep the
+// context code whatever the adapter returned.
+func adapterError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Unknown
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		code = codes.DeadlineExceeded
+	case context.Canceled:
+		code = codes.Canceled
+	default:
+		if c, ok := errorCodes[adapters.ErrorKindOf(err)]; ok {
+			code = c
+		}
+	}
+	return status.Error(code, err.Error())
+}
+
+// transientError returns whether the error of the adapter is a transient failure, an unavailable backend or an
+// operation cancelled or out of time, which the kubelet retries
+func transientError(ctx context.Context, err error) bool {
+	return ctx.Err() != nil || err == context.DeadlineExceeded || err == context.Canceled ||
+		adapters.ErrorKindOf(err) == adapters.KindUnavailable
+}
@@ -39,7 +39,18 @@ func (r *MulticriRuntime) Attach(ctx context.Context, req *runtimeApi.AttachRequ
 	if container.State != runtimeApi.ContainerState_CONTAINER_RUNNING {
 		return nil, fmt.Errorf("Container is not started")
 	}
-	return r.adapter.Attach(container, req)
+	adapter, err := r.getCapableAdapter(container.PodSandbox.RuntimeHandler, "Attach")
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "Attach")
+	defer cancel()
+	response, err := adapter.Attach(adapterCtx, container, req)
+	if err == nil && response == nil {
+		// The adapter streams through the runtime streaming server
+		return r.streamServer.GetAttach(req)
+	}
+	return response, adapterError(adapterCtx, err)
 }
 
 func (r *MulticriRuntime) Exec(ctx context.Context, req *runtimeApi.ExecRequest) (*runtimeApi.ExecResponse, error) {
@@ -59,7 +70,18 @@ func (r *MulticriRuntime) Exec(ctx context.Context, req *runtimeApi.ExecRequest)
 	if container.State != runtimeApi.ContainerState_CONTAINER_RUNNING {
 		return nil, fmt.Errorf("Container is not started")
 	}
-	return r.adapter.Exec(container, req)
+	adapter, err := r.getCapableAdapter(container.PodSandbox.RuntimeHandler, "Exec")
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "Exec")
+	defer cancel()
+	response, err := adapter.Exec(adapterCtx, container, req)
+	if err == nil && response == nil {
+		// The adapter streams through the runtime streaming server
+		return r.streamServer.GetExec(req)
+	}
+	return response, adapterError(adapterCtx, err)
 }
 
 func (r *MulticriRuntime) ExecSync(ctx context.Context, req *runtimeApi.ExecSyncRequest) (*runtimeApi.ExecSyncResponse, error) {
@@ -80,5 +102,12 @@ func (r *MulticriRuntime) ExecSync(ctx context.Context, req *runtimeApi.ExecSync
 	if container.State != runtimeApi.ContainerState_CONTAINER_RUNNING {
 		return nil, fmt.Errorf("Container is not started")
 	}
-	return r.adapter.ExecSync(container, req.Cmd)
+	adapter, err := r.getCapableAdapter(container.PodSandbox.RuntimeHandler, "ExecSync")
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "ExecSync")
+	defer cancel()
+	response, err := adapter.ExecSync(adapterCtx, container, req.Cmd)
+	return response, adapterError(adapterCtx, err)
 }
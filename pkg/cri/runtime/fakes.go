@@ -18,7 +18,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
+	"multi-cri/pkg/cri/adapters"
 	"multi-cri/pkg/cri/network"
 	"multi-cri/pkg/cri/store"
 
@@ -95,6 +97,13 @@ func newFakeStreamServer() streaming.Server {
 
 //FAKE SINGULARITY SERVICE
 func NewFakeCRIService(adapterFails bool) CRIMulticriService {
+	return NewFakeCRIServiceWithHandlers(map[string]adapters.AdapterInterface{
+		remote.MulticriRuntimeHandler: &FakeAdapter{fails: adapterFails},
+	}, remote.MulticriRuntimeHandler)
+}
+
+// NewFakeCRIServiceWithHandlers creates a fake service serving one adapter per runtime handler
+func NewFakeCRIServiceWithHandlers(adapterList map[string]adapters.AdapterInterface, defaultHandler string) CRIMulticriService {
 	iStorage, err := store.NewImageStorage("", false)
 	if err != nil {
 		panic(err)
@@ -107,16 +116,27 @@ func NewFakeCRIService(adapterFails bool) CRIMulticriService {
 	if err != nil {
 		panic(err)
 	}
+	var handlers []string
+	for handler := range adapterList {
+		handlers = append(handlers, handler)
+	}
+	remoteCRI, err := remote.LoadRemoteRuntimeConfiguration("", handlers)
+	if err != nil {
+		panic(err)
+	}
 	f := multicriService{
 		netPlugin:        NewFackeCNIPlugin(),
 		sandboxStore:     sandboxStorage,
 		containerStore:   containerStorage,
 		imageStore:       iStorage,
-		adapter:          &FakeAdapter{fails: adapterFails},
+		adapters:         adapterList,
+		defaultHandler:   defaultHandler,
 		networkNamespace: &FakeNetworkManager{},
 		os:               &FakeOS{},
 		streamServer:     newFakeStreamServer(),
-		remoteCRI:        &remote.RemoteCRIConfiguration{},
+		remoteCRI:        remoteCRI,
+		timeouts:         adapterTimeouts{def: DefaultAdapterTimeout},
+		metrics:          wrapAdapters(adapterList, nil),
 	}
 
 	multicriRuntime := NewMulticriRuntime(&f)
@@ -199,7 +219,7 @@ func NewListContainerStatsRequest(filter runtimeapi.ContainerStatsFilter) runtim
 
 ///Exec Requests
 func NewAttachRequest(containerID string) runtimeapi.AttachRequest {
-	return runtimeapi.AttachRequest{ContainerId: containerID}
+	return runtimeapi.AttachRequest{ContainerId: containerID, Stdout: true}
 }
 
 func NewExecRequest(containerID string, cmd []string) runtimeapi.ExecRequest {
@@ -264,47 +284,158 @@ func (f *FakeOS) IsNotExist(err error) bool              { return true }
 
 type FakeAdapter struct {
 	fails bool
+	// capabilities of the adapter. Every operation is supported when it is nil
+	capabilities *adapters.Capabilities
+	// states of the started and stopped containers, as a backend would report them
+	states     map[string]runtimeapi.ContainerState
+	statesLock sync.Mutex
+	// restored are the ids of the containers given to Restore
+	restored []string
+	// statusErr is returned by ContainerStatus when it is set
+	statusErr error
+}
+
+func init() {
+	adapters.MustRegister(adapters.AdapterFactory{
+		Name:        "fake",
+		Description: "Adapter for testing purposes, it does not run anything",
+		Options:     map[string]string{"fails": "Make the adapter calls fail"},
+		New: func(config adapters.AdapterConfig) (adapters.AdapterInterface, error) {
+			fails, err := config.GetBool("fails", false)
+			if err != nil {
+				return nil, err
+			}
+			return &FakeAdapter{fails: fails}, nil
+		},
+	})
 }
 
-func (f *FakeAdapter) CreateContainer(cm *store.ContainerMetadata) error { return nil }
-func (f *FakeAdapter) StartContainer(cm *store.ContainerMetadata) error  { return nil }
-func (f *FakeAdapter) StopContainer(cm *store.ContainerMetadata) error {
+func (f *FakeAdapter) CreateContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	return nil
+}
+func (f *FakeAdapter) StartContainer(ctx context.Context, cm *store.ContainerMetadata) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.setState(cm.ID, runtimeapi.ContainerState_CONTAINER_RUNNING)
+	return nil
+}
+func (f *FakeAdapter) StopContainer(ctx context.Context, cm *store.ContainerMetadata) error {
 	if f.fails {
-		return fmt.Errorf("Adapter fails")
+		return adapters.UnavailableError("Adapter fails")
 	}
+	f.setState(cm.ID, runtimeapi.ContainerState_CONTAINER_EXITED)
 	return nil
 }
-func (f *FakeAdapter) ContainerStatus(cm *store.ContainerMetadata) error          { return nil }
-func (r *FakeAdapter) ReopenContainerLog(cm *store.ContainerMetadata) error       { return nil }
-func (r *FakeAdapter) UpdateContainerResources(cm *store.ContainerMetadata) error { return nil }
-func (f *FakeAdapter) ExecSync(cm *store.ContainerMetadata, command []string) (*runtimeapi.ExecSyncResponse, error) {
+func (f *FakeAdapter) ContainerStatus(ctx context.Context, cm *store.ContainerMetadata) error {
+	f.statesLock.Lock()
+	defer f.statesLock.Unlock()
+	if f.statusErr != nil {
+		return f.statusErr
+	}
+	if state, ok := f.states[cm.ID]; ok {
+		cm.State = state
+	}
+	return nil
+}
+
+func (f *FakeAdapter) Restore(ctx context.Context, containers []*store.ContainerMetadata) error {
+	if f.fails {
+		return adapters.UnavailableError("Adapter fails")
+	}
+	for _, cm := range containers {
+		f.restored = append(f.restored, cm.ID)
+	}
+	return nil
+}
+
+func (f *FakeAdapter) setState(id string, state runtimeapi.ContainerState) {
+	f.statesLock.Lock()
+	defer f.statesLock.Unlock()
+	if f.states == nil {
+		f.states = map[string]runtimeapi.ContainerState{}
+	}
+	f.states[id] = state
+}
+
+// unsupported returns the error of the operations which are not in the capabilities of the adapter
+func (f *FakeAdapter) unsupported(operation string) error {
+	if f.Capabilities().Supports(operation) {
+		return nil
+	}
+	return adapters.UnimplementedError("%s is not supported by the fake adapter", operation)
+}
+
+func (r *FakeAdapter) ReopenContainerLog(ctx context.Context, cm *store.ContainerMetadata) error {
+	return r.unsupported("ReopenContainerLog")
+}
+func (r *FakeAdapter) UpdateContainerResources(ctx context.Context, cm *store.ContainerMetadata) error {
+	if r.fails {
+		return adapters.UnavailableError("Adapter fails")
+	}
+	return r.unsupported("UpdateContainerResources")
+}
+func (f *FakeAdapter) ExecSync(ctx context.Context, cm *store.ContainerMetadata, command []string) (*runtimeapi.ExecSyncResponse, error) {
+	if err := f.unsupported("ExecSync"); err != nil {
+		return nil, err
+	}
 	return &runtimeapi.ExecSyncResponse{ExitCode: 1}, nil
 }
-func (f *FakeAdapter) Exec(cm *store.ContainerMetadata, req *runtimeapi.ExecRequest) (*runtimeapi.ExecResponse, error) {
+func (f *FakeAdapter) Exec(ctx context.Context, cm *store.ContainerMetadata, req *runtimeapi.ExecRequest) (*runtimeapi.ExecResponse, error) {
+	if err := f.unsupported("Exec"); err != nil {
+		return nil, err
+	}
 	return &runtimeapi.ExecResponse{}, nil
 }
 
-func (f *FakeAdapter) Attach(cm *store.ContainerMetadata, req *runtimeapi.AttachRequest) (*runtimeapi.AttachResponse, error) {
+func (f *FakeAdapter) Attach(ctx context.Context, cm *store.ContainerMetadata, req *runtimeapi.AttachRequest) (*runtimeapi.AttachResponse, error) {
+	if err := f.unsupported("Attach"); err != nil {
+		return nil, err
+	}
 	return nil, nil
 }
 
-func (f *FakeAdapter) PullImage(image *store.ImageMetadata) error {
+func (f *FakeAdapter) PullImage(ctx context.Context, image *store.ImageMetadata) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if image.RepoType == store.UnknownImageRepo {
+		return adapters.InvalidArgumentError("Unknown image repository for %s", image.ImageName)
+	}
 	image.LocalPath = "/tmp"
 	return nil
 }
-func (f *FakeAdapter) ListImages(images []*runtimeapi.Image) error  { return nil }
-func (f *FakeAdapter) ImageStatus(image *store.ImageMetadata) error { return nil }
-func (f *FakeAdapter) ImageFsInfo() (*runtimeapi.ImageFsInfoResponse, error) {
+func (f *FakeAdapter) ListImages(ctx context.Context, images []*runtimeapi.Image) error  { return nil }
+func (f *FakeAdapter) ImageStatus(ctx context.Context, image *store.ImageMetadata) error { return nil }
+func (f *FakeAdapter) ImageFsInfo(ctx context.Context) (*runtimeapi.ImageFsInfoResponse, error) {
+	if err := f.unsupported("ImageFsInfo"); err != nil {
+		return nil, err
+	}
 	return nil, fmt.Errorf("ImageFsInfo still not implemented")
 }
-func (f *FakeAdapter) RemoveImage(image *store.ImageMetadata) error { return nil }
+func (f *FakeAdapter) RemoveImage(ctx context.Context, image *store.ImageMetadata) error { return nil }
+
+func (f *FakeAdapter) Capabilities() adapters.Capabilities {
+	if f.capabilities != nil {
+		return *f.capabilities
+	}
+	return adapters.AllCapabilities()
+}
 
 func (f *FakeAdapter) NewStreamRuntime(c store.ContainerStoreInterface) streaming.Runtime { return nil }
 
-func (r *FakeAdapter) Version() (*runtimeapi.VersionResponse, error) {
+func (r *FakeAdapter) Version(ctx context.Context) (*runtimeapi.VersionResponse, error) {
 	return &runtimeapi.VersionResponse{Version: FAKEVERSION}, nil
 }
-func (r *FakeAdapter) RunPodSandbox(sandbox *store.SandboxMetadata) error    { return nil }
-func (r *FakeAdapter) StopPodSandbox(sandbox *store.SandboxMetadata) error   { return nil }
-func (r *FakeAdapter) RemovePodSandbox(sandbox *store.SandboxMetadata) error { return nil }
-func (r *FakeAdapter) PodSandboxStatus(sandbox *store.SandboxMetadata) error { return nil }
+func (r *FakeAdapter) RunPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+func (r *FakeAdapter) StopPodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+func (r *FakeAdapter) RemovePodSandbox(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
+func (r *FakeAdapter) PodSandboxStatus(ctx context.Context, sandbox *store.SandboxMetadata) error {
+	return nil
+}
@@ -27,6 +27,7 @@ import (
 func (r *MulticriRuntime) PullImage(ctx context.Context, req *runtimeApi.PullImageRequest) (res *runtimeApi.PullImageResponse, err error) {
 	klog.V(4).Infof("Pulling image... %s", req.Image.Image)
 
+	runtimeHandler := r.runtimeHandlerName(r.remoteCRI.LocalImageHandler(req.Image.Image))
 	response, err := r.remoteCRI.PullImage(ctx, req)
 	if response != nil {
 		return response, err
@@ -35,18 +36,25 @@ func (r *MulticriRuntime) PullImage(ctx context.Context, req *runtimeApi.PullIma
 		return nil, err
 	}
 
+	adapter, err := r.getAdapter(runtimeHandler)
+	if err != nil {
+		return nil, err
+	}
 	imageMetadata, err := r.imageStore.CreateImageMetadata(req)
 	if err != nil {
 		return nil, err
 	}
+	imageMetadata.RuntimeHandler = runtimeHandler
 
 	if err := store.ParseImage(imageMetadata); err != nil {
 		return nil, err
 	}
 
-	if err = r.adapter.PullImage(imageMetadata); err != nil {
+	adapterCtx, cancel := r.adapterContext(ctx, "PullImage")
+	defer cancel()
+	if err = adapter.PullImage(adapterCtx, imageMetadata); err != nil {
 		r.imageStore.Remove(imageMetadata.ID)
-		return &runtimeApi.PullImageResponse{}, err
+		return &runtimeApi.PullImageResponse{}, adapterError(adapterCtx, err)
 	}
 
 	r.imageStore.Update(imageMetadata)
@@ -61,8 +69,12 @@ func (r *MulticriRuntime) ListImages(ctx context.Context, req *runtimeApi.ListIm
 	remoteList, err := r.remoteCRI.ListImages(ctx, req)
 	images = append(images, remoteList...)
 
-	if err := r.adapter.ListImages(images); err != nil {
-		return nil, err
+	adapterCtx, cancel := r.adapterContext(ctx, "ListImages")
+	defer cancel()
+	for _, adapter := range r.adapters {
+		if err := adapter.ListImages(adapterCtx, images); err != nil {
+			return nil, adapterError(adapterCtx, err)
+		}
 	}
 
 	return &runtimeApi.ListImagesResponse{Images: images}, nil
@@ -71,6 +83,7 @@ func (r *MulticriRuntime) ListImages(ctx context.Context, req *runtimeApi.ListIm
 func (r *MulticriRuntime) ImageStatus(ctx context.Context, req *runtimeApi.ImageStatusRequest) (*runtimeApi.ImageStatusResponse, error) {
 	klog.V(4).Infof("Getting image status... %s", req.Image.Image)
 
+	runtimeHandler := r.runtimeHandlerName(r.remoteCRI.LocalImageHandler(req.Image.Image))
 	response, err := r.remoteCRI.ImageStatus(ctx, req)
 	if response != nil {
 		return response, err
@@ -79,19 +92,26 @@ func (r *MulticriRuntime) ImageStatus(ctx context.Context, req *runtimeApi.Image
 		return nil, err
 	}
 
-	image, err := r.imageStore.GetByID(req.Image.Image)
+	image, err := r.imageStore.GetByRuntimeHandler(req.Image.Image, runtimeHandler)
 	if err != nil {
 		return &runtimeApi.ImageStatusResponse{}, nil
 	}
-	if err := r.adapter.ImageStatus(image); err != nil {
+	adapter, err := r.getAdapter(image.RuntimeHandler)
+	if err != nil {
 		return nil, err
 	}
+	adapterCtx, cancel := r.adapterContext(ctx, "ImageStatus")
+	defer cancel()
+	if err := adapter.ImageStatus(adapterCtx, image); err != nil {
+		return nil, adapterError(adapterCtx, err)
+	}
 	imageStatus := store.ParseToK8sImage(image)
 	return &runtimeApi.ImageStatusResponse{Image: imageStatus}, nil
 }
 
 func (r *MulticriRuntime) RemoveImage(ctx context.Context, req *runtimeApi.RemoveImageRequest) (_ *runtimeApi.RemoveImageResponse, err error) {
 	klog.V(4).Infof("Removing image... %s", req.Image.Image)
+	runtimeHandler := r.runtimeHandlerName(r.remoteCRI.LocalImageHandler(req.Image.Image))
 	response, err := r.remoteCRI.RemoveImage(ctx, req)
 	if response != nil {
 		return response, err
@@ -100,14 +120,19 @@ func (r *MulticriRuntime) RemoveImage(ctx context.Context, req *runtimeApi.Remov
 		return nil, err
 	}
 
-	image, err := r.imageStore.GetByID(req.Image.Image)
+	image, err := r.imageStore.GetByRuntimeHandler(req.Image.Image, runtimeHandler)
 	if err != nil {
 		return nil, fmt.Errorf("Image not found")
 	}
-
-	if err := r.adapter.RemoveImage(image); err != nil {
+	adapter, err := r.getAdapter(image.RuntimeHandler)
+	if err != nil {
 		return nil, err
 	}
+	adapterCtx, cancel := r.adapterContext(ctx, "RemoveImage")
+	defer cancel()
+	if err := adapter.RemoveImage(adapterCtx, image); err != nil {
+		return nil, adapterError(adapterCtx, err)
+	}
 	r.imageStore.Remove(image.ID)
 	klog.V(4).Infof("Image %s successfully removed", req.Image.Image)
 	return &runtimeApi.RemoveImageResponse{}, nil
@@ -122,5 +147,12 @@ func (r *MulticriRuntime) ImageFsInfo(ctx context.Context, req *runtimeApi.Image
 	if remoteRuntime != nil {
 		return remoteRuntime.ImageFsInfo(ctx, req)
 	}
-	return r.adapter.ImageFsInfo()
+	adapter, err := r.getCapableAdapter(r.defaultHandler, "ImageFsInfo")
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "ImageFsInfo")
+	defer cancel()
+	response, err := adapter.ImageFsInfo(adapterCtx)
+	return response, adapterError(adapterCtx, err)
 }
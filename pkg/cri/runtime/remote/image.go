@@ -52,10 +52,23 @@ func (r RemoteCRIConfiguration) ListImages(ctx context.Context, req *runtimeApi.
 	return remotePods, nil
 }
 
+// LocalImageHandler returns the local runtime handler set as prefix of the image name,
+// like "multicri-slurm/docker://centos". It returns an empty string if there is no prefix.
+func (r RemoteCRIConfiguration) LocalImageHandler(image string) string {
+	if strings.HasPrefix(image, MulticriRuntimeHandler+"/") {
+		return MulticriRuntimeHandler
+	}
+	for handler := range r.localHandlers {
+		if strings.HasPrefix(image, handler+"/") {
+			return handler
+		}
+	}
+	return ""
+}
+
 func (r RemoteCRIConfiguration) getImageManager(image string) (*RemoteCRIObject, string) {
-	imageMulticri := MulticriRuntimeHandler + "/"
-	if strings.HasPrefix(image, imageMulticri) {
-		return nil, strings.TrimPrefix(image, imageMulticri)
+	if handler := r.LocalImageHandler(image); handler != "" {
+		return nil, strings.TrimPrefix(image, handler+"/")
 	}
 	for k, v := range r.remoteCRIList {
 		if strings.HasPrefix(image, k) {
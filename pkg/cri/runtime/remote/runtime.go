@@ -40,6 +40,8 @@ type RemoteCRIObject struct {
 
 type RemoteCRIConfiguration struct {
 	remoteCRIList map[string]*RemoteCRIObject
+	// localHandlers are the runtime handlers served by the local adapters
+	localHandlers map[string]bool
 }
 
 // NewRemoteRuntimeService creates a new runtimeApi.RuntimeServiceClient.
@@ -90,8 +92,14 @@ func getRemoteCRI(remoteRuntimeEndpoint, remoteImageEndpoint string, durantion t
 	return &RemoteCRIObject{rs, is}, err
 }
 
-func LoadRemoteRuntimeConfiguration(config string) (*RemoteCRIConfiguration, error) {
+// LoadRemoteRuntimeConfiguration connects to the remote CRIs. The localHandlers are the runtime handlers
+// served by the local adapters, so they are never forwarded to a remote CRI.
+func LoadRemoteRuntimeConfiguration(config string, localHandlers []string) (*RemoteCRIConfiguration, error) {
 	list := make(map[string]*RemoteCRIObject)
+	locals := make(map[string]bool)
+	for _, handler := range localHandlers {
+		locals[handler] = true
+	}
 	if config != "" {
 		runtimePlugins := strings.Split(config, ",")
 
@@ -100,6 +108,9 @@ func LoadRemoteRuntimeConfiguration(config string) (*RemoteCRIConfiguration, err
 			if len(pluginSplit) != 2 {
 				return nil, fmt.Errorf("Bad format for remote runtime. %s ", plugin)
 			}
+			if locals[pluginSplit[0]] {
+				return nil, fmt.Errorf("Runtime handler %s is already served by a local adapter", pluginSplit[0])
+			}
 			cri, err := getRemoteCRI(pluginSplit[1], pluginSplit[1], time.Duration(time.Second*ConnectionTimeoutSeconds))
 			if err != nil {
 				return nil, err
@@ -107,11 +118,16 @@ func LoadRemoteRuntimeConfiguration(config string) (*RemoteCRIConfiguration, err
 			list[pluginSplit[0]] = cri
 		}
 	}
-	return &RemoteCRIConfiguration{list}, nil
+	return &RemoteCRIConfiguration{list, locals}, nil
+}
+
+// IsLocalRuntime returns true if the runtime handler is served by a local adapter
+func (r RemoteCRIConfiguration) IsLocalRuntime(runtimeHandlerName string) bool {
+	return runtimeHandlerName == MulticriRuntimeHandler || r.localHandlers[runtimeHandlerName]
 }
 
 func (r RemoteCRIConfiguration) GetRemoteRuntime(runtimeHandlerName string) (*RemoteCRIObject, error) {
-	if runtimeHandlerName == MulticriRuntimeHandler || len(r.remoteCRIList) == 0 {
+	if r.IsLocalRuntime(runtimeHandlerName) || len(r.remoteCRIList) == 0 {
 		return nil, nil
 	}
 	if runtimeHandlerName == "" {
@@ -123,9 +139,17 @@ func (r RemoteCRIConfiguration) GetRemoteRuntime(runtimeHandlerName string) (*Re
 	return nil, fmt.Errorf("RemoteRuntime %s not found", runtimeHandlerName)
 }
 
-func (r RemoteCRIConfiguration) MulticriRuntimeName() string {
-	if _, ok := r.remoteCRIList["default"]; ok {
-		return MulticriRuntimeHandler
+// LocalRuntimeNames returns the runtime handlers of the pods managed by the local adapters.
+// The empty handler is local only when there is not a default remote runtime.
+func (r RemoteCRIConfiguration) LocalRuntimeNames() []string {
+	names := []string{MulticriRuntimeHandler}
+	for handler := range r.localHandlers {
+		if handler != MulticriRuntimeHandler {
+			names = append(names, handler)
+		}
+	}
+	if _, ok := r.remoteCRIList["default"]; !ok {
+		names = append(names, "")
 	}
-	return ""
+	return names
 }
@@ -15,12 +15,14 @@
 package runtime
 
 import (
+	"encoding/json"
 	"fmt"
 
-	"multi-cri/pkg/cri/adapters"
-	"multi-cri/pkg/cri/store"
 	"net"
 
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/middleware"
+
 	"golang.org/x/net/context"
 	k8snet "k8s.io/apimachinery/pkg/util/net"
 	runtimeApi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
@@ -36,8 +38,15 @@ func NewMulticriRuntime(c *multicriService) CRIMulticriService {
 	return &MulticriRuntime{multicriService: c}
 }
 
-func (r *MulticriRuntime) Version(_ context.Context, req *runtimeApi.VersionRequest) (*runtimeApi.VersionResponse, error) {
-	return r.adapter.Version()
+func (r *MulticriRuntime) Version(ctx context.Context, req *runtimeApi.VersionRequest) (*runtimeApi.VersionResponse, error) {
+	adapter, err := r.getAdapter(r.defaultHandler)
+	if err != nil {
+		return nil, err
+	}
+	adapterCtx, cancel := r.adapterContext(ctx, "Version")
+	defer cancel()
+	response, err := adapter.Version(adapterCtx)
+	return response, adapterError(adapterCtx, err)
 }
 
 func (r *MulticriRuntime) Status(_ context.Context, req *runtimeApi.StatusRequest) (*runtimeApi.StatusResponse, error) {
@@ -56,19 +65,42 @@ func (r *MulticriRuntime) Status(_ context.Context, req *runtimeApi.StatusReques
 			networkCondition.Message = fmt.Sprintf("Network plugin returns error: %v", err)
 		}
 	}
-	return &runtimeApi.StatusResponse{
+	response := &runtimeApi.StatusResponse{
 		Status: &runtimeApi.RuntimeStatus{Conditions: []*runtimeApi.RuntimeCondition{
 			runtimeCondition,
 			networkCondition,
 		}},
-	}, nil
+	}
+	if req.Verbose {
+		capabilities := make(map[string]adapters.Capabilities)
+		for handler, adapter := range r.adapters {
+			capabilities[handler] = adapter.Capabilities()
+		}
+		info, err := json.Marshal(capabilities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal adapter capabilities: %v", err)
+		}
+		response.Info = map[string]string{"capabilities": string(info)}
+		if len(r.metrics) > 0 {
+			metrics := make(map[string]map[string]middleware.MethodMetrics)
+			for handler, m := range r.metrics {
+				metrics[handler] = m.Snapshot()
+			}
+			info, err := json.Marshal(metrics)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal adapter metrics: %v", err)
+			}
+			response.Info["metrics"] = string(info)
+		}
+	}
+	return response, nil
 }
 
 func (r *MulticriRuntime) UpdateRuntimeConfig(_ context.Context, req *runtimeApi.UpdateRuntimeConfigRequest) (*runtimeApi.UpdateRuntimeConfigResponse, error) {
 	return &runtimeApi.UpdateRuntimeConfigResponse{}, nil
 }
 
-func NewStreamServer(a adapters.AdapterInterface, c store.ContainerStoreInterface, addr, port string) (streaming.Server, error) {
+func NewStreamServer(runtime streaming.Runtime, addr, port string) (streaming.Server, error) {
 	if addr == "" {
 		a, err := k8snet.ChooseBindAddress(nil)
 		if err != nil {
@@ -78,6 +110,5 @@ func NewStreamServer(a adapters.AdapterInterface, c store.ContainerStoreInterfac
 	}
 	config := streaming.DefaultConfig
 	config.Addr = net.JoinHostPort(addr, port)
-	runtime := a.NewStreamRuntime(c)
 	return streaming.NewServer(config, runtime)
 }
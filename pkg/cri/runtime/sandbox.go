@@ -74,9 +74,15 @@ func (r *MulticriRuntime) RunPodSandbox(ctx context.Context, req *runtimeApi.Run
 			klog.Errorf("Error when creating the log directory %s.", req.Config.LogDirectory)
 		}
 		sandbox.LogPath = errPath
-		if err := r.adapter.RunPodSandbox(sandbox); err != nil {
+		adapter, err := r.getAdapter(sandbox.RuntimeHandler)
+		if err != nil {
 			return nil, err
 		}
+		adapterCtx, cancel := r.adapterContext(ctx, "RunPodSandbox")
+		defer cancel()
+		if err := adapter.RunPodSandbox(adapterCtx, sandbox); err != nil {
+			return nil, adapterError(adapterCtx, err)
+		}
 		response = &runtimeApi.RunPodSandboxResponse{PodSandboxId: sandbox.ID}
 	}
 
@@ -104,9 +110,15 @@ func (r *MulticriRuntime) StopPodSandbox(ctx context.Context, req *runtimeApi.St
 				return nil, err
 			}
 		}
-		if err := r.adapter.StopPodSandbox(sandbox); err != nil {
+		adapter, err := r.getAdapter(sandbox.RuntimeHandler)
+		if err != nil {
 			return nil, err
 		}
+		adapterCtx, cancel := r.adapterContext(ctx, "StopPodSandbox")
+		defer cancel()
+		if err := adapter.StopPodSandbox(adapterCtx, sandbox); err != nil {
+			return nil, adapterError(adapterCtx, err)
+		}
 		response = &runtimeApi.StopPodSandboxResponse{}
 	}
 	if errGet == nil {
@@ -137,9 +149,15 @@ func (r *MulticriRuntime) RemovePodSandbox(ctx context.Context, req *runtimeApi.
 			return nil, fmt.Errorf("Sandbox %q is running. First stop it ", req.GetPodSandboxId())
 			//todo(jorgesece): manage force remove
 		}
-		if err := r.adapter.RemovePodSandbox(sandbox); err != nil {
+		adapter, err := r.getAdapter(sandbox.RuntimeHandler)
+		if err != nil {
 			return nil, err
 		}
+		adapterCtx, cancel := r.adapterContext(ctx, "RemovePodSandbox")
+		defer cancel()
+		if err := adapter.RemovePodSandbox(adapterCtx, sandbox); err != nil {
+			return nil, adapterError(adapterCtx, err)
+		}
 		response = &runtimeApi.RemovePodSandboxResponse{}
 	}
 
@@ -169,9 +187,15 @@ func (r *MulticriRuntime) PodSandboxStatus(ctx context.Context, req *runtimeApi.
 			ip = "127.0.0.1"
 			sandbox.State = runtimeApi.PodSandboxState_SANDBOX_READY
 		}
-		if err := r.adapter.PodSandboxStatus(sandbox); err != nil {
+		adapter, err := r.getAdapter(sandbox.RuntimeHandler)
+		if err != nil {
 			return nil, err
 		}
+		adapterCtx, cancel := r.adapterContext(ctx, "PodSandboxStatus")
+		defer cancel()
+		if err := adapter.PodSandboxStatus(adapterCtx, sandbox); err != nil {
+			return nil, adapterError(adapterCtx, err)
+		}
 		status := store.ParseToK8sSandboxStatus(sandbox, ip)
 		response = &runtimeApi.PodSandboxStatusResponse{Status: status}
 	}
@@ -180,7 +204,7 @@ func (r *MulticriRuntime) PodSandboxStatus(ctx context.Context, req *runtimeApi.
 
 func (r *MulticriRuntime) ListPodSandbox(ctx context.Context, req *runtimeApi.ListPodSandboxRequest) (*runtimeApi.ListPodSandboxResponse, error) {
 	klog.V(4).Infof("Listing sandboxes")
-	localResult := r.sandboxStore.ListK8s(req.Filter, r.remoteCRI.MulticriRuntimeName())
+	localResult := r.sandboxStore.ListK8s(req.Filter, r.remoteCRI.LocalRuntimeNames())
 	remotePods, err := r.remoteCRI.ListPodSandbox(ctx, req)
 	if err != nil {
 		return nil, err
@@ -208,6 +232,9 @@ func (r *MulticriRuntime) PortForward(ctx context.Context, req *runtimeApi.PortF
 	if errGet != nil {
 		return nil, fmt.Errorf("Sandbox not found when portforwarding it")
 	}
+	if _, err := r.getCapableAdapter(sandbox.RuntimeHandler, "PortForward"); err != nil {
+		return nil, err
+	}
 	return r.streamServer.GetPortForward(req)
 }
 
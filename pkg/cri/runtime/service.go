@@ -16,21 +16,27 @@ package runtime
 
 import (
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strings"
 	"syscall"
+	"time"
 
 	"multi-cri/pkg/cri/network"
 	"multi-cri/pkg/cri/store"
 	osinterface "multi-cri/pkg/os"
 
 	"multi-cri/pkg/cri/adapters"
-	slurmAdapter "multi-cri/pkg/cri/adapters/slurm"
+	"multi-cri/pkg/cri/adapters/middleware"
 
 	"multi-cri/pkg/cri/runtime/remote"
 
 	"github.com/cri-o/ocicni/pkg/ocicni"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"k8s.io/klog"
 	runtimeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
@@ -51,8 +57,10 @@ type CRIMulticriService interface {
 
 // multicriService implements CRIMulticriService.
 type multicriService struct {
-	// Exec path
-	adapter adapters.AdapterInterface
+	// adapters maps each local runtime handler to the adapter serving it
+	adapters map[string]adapters.AdapterInterface
+	// defaultHandler is the runtime handler used by pods without runtime handler
+	defaultHandler string
 	// serverAddress is the grpc server unix path.
 	serverAddress string
 	// server is the grpc server.
@@ -80,20 +88,157 @@ type multicriService struct {
 	// os is an interface for all required os operations.
 	os        osinterface.OS
 	remoteCRI *remote.RemoteCRIConfiguration
+	// timeouts contains the deadline of each adapter operation
+	timeouts adapterTimeouts
+	// metrics contains the adapter call metrics of each runtime handler
+	metrics map[string]*middleware.Metrics
+	// closers release the processes and connections of the adapters when the service stops
+	closers []io.Closer
 }
 
-func loadAdapter(adapterName string) (adapters.AdapterInterface, error) {
-	if adapterName == "fake" {
-		return &FakeAdapter{fails: false}, nil
-	} else if adapterName == "slurm" {
-		return slurmAdapter.NewSlurmAdapter()
-	} else {
-		return nil, fmt.Errorf("Adapter not found")
+// runtimeHandlerAdapter links a runtime handler to the name of its adapter
+type runtimeHandlerAdapter struct {
+	handler string
+	adapter string
+}
+
+// parseRuntimeHandlers parses the runtime handlers in "handler:adapter,handler:adapter" format.
+// When it is empty, the adapterName adapter serves the multicri runtime handler.
+func parseRuntimeHandlers(adapterName, runtimeHandlers string) ([]runtimeHandlerAdapter, error) {
+	if strings.TrimSpace(runtimeHandlers) == "" {
+		return []runtimeHandlerAdapter{{remote.MulticriRuntimeHandler, adapterName}}, nil
+	}
+	var out []runtimeHandlerAdapter
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(runtimeHandlers, ",") {
+		split := strings.Split(item, ":")
+		if len(split) != 2 || strings.TrimSpace(split[0]) == "" || strings.TrimSpace(split[1]) == "" {
+			return nil, fmt.Errorf("Bad format for runtime handler %q. It must be handler:adapter", item)
+		}
+		handler := strings.TrimSpace(split[0])
+		if seen[handler] {
+			return nil, fmt.Errorf("Runtime handler %s is configured twice", handler)
+		}
+		seen[handler] = true
+		out = append(out, runtimeHandlerAdapter{handler, strings.TrimSpace(split[1])})
+	}
+	return out, nil
+}
+
+// handlerAdapterConfig selects the options of each runtime handler. Options prefixed with
+// "handler." only apply to that handler and take precedence over the rest, which apply to
+// every adapter accepting them.
+func handlerAdapterConfig(config adapters.AdapterConfig, handlers []runtimeHandlerAdapter) (map[string]adapters.AdapterConfig, error) {
+	out := make(map[string]adapters.AdapterConfig)
+	for _, h := range handlers {
+		out[h.handler] = adapters.AdapterConfig{}
+	}
+	scoped := make(map[string]string)
+	for key, value := range config {
+		if h := handlerOfOption(key, handlers); h != "" {
+			scoped[key] = h
+			continue
+		}
+		accepted := false
+		for _, h := range handlers {
+			factory, ok := adapters.LookupAdapter(h.adapter)
+			if !ok {
+				continue
+			}
+			if _, known := factory.Options[key]; known || factory.Options == nil {
+				out[h.handler][key] = value
+				accepted = true
+			}
+		}
+		if !accepted {
+			if len(handlers) > 1 {
+				return nil, fmt.Errorf("Option %q is not accepted by any configured adapter", key)
+			}
+			// The adapter reports the unknown option
+			out[handlers[0].handler][key] = value
+		}
+	}
+	for key, handler := range scoped {
+		out[handler][strings.TrimPrefix(key, handler+".")] = config[key]
+	}
+	return out, nil
+}
+
+// handlerOfOption returns the runtime handler set as prefix of the option, if any
+func handlerOfOption(key string, handlers []runtimeHandlerAdapter) string {
+	for _, h := range handlers {
+		if strings.HasPrefix(key, h.handler+".") {
+			return h.handler
+		}
 	}
+	return ""
+}
+
+// loadAdapters builds one adapter per runtime handler. The first handler is the default one.
+func loadAdapters(adapterName, adapterConfig, runtimeHandlers string) (map[string]adapters.AdapterInterface, string, error) {
+	config, err := adapters.ParseAdapterConfig(adapterConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	handlers, err := parseRuntimeHandlers(adapterName, runtimeHandlers)
+	if err != nil {
+		return nil, "", err
+	}
+	configs, err := handlerAdapterConfig(config, handlers)
+	if err != nil {
+		return nil, "", err
+	}
+	out := make(map[string]adapters.AdapterInterface)
+	for _, h := range handlers {
+		a, err := adapters.NewAdapter(h.adapter, configs[h.handler])
+		if err != nil {
+			return nil, "", fmt.Errorf("Runtime handler %s: %v", h.handler, err)
+		}
+		klog.V(2).Infof("Runtime handler %s served by adapter %s", h.handler, h.adapter)
+		out[h.handler] = a
+	}
+	return out, handlers[0].handler, nil
+}
+
+// restoreAdapters gives the stored containers to the adapters which restore their state. It is called
+// before wrapAdapters, since the middleware does not forward Restore.
+func restoreAdapters(criAdapters map[string]adapters.AdapterInterface, defaultHandler string, containers store.ContainerStoreInterface) error {
+	byHandler := make(map[string][]*store.ContainerMetadata)
+	for _, cm := range containers.List("", "") {
+		handler := localRuntimeHandler(criAdapters, defaultHandler, cm.PodSandbox.RuntimeHandler)
+		byHandler[handler] = append(byHandler[handler], cm)
+	}
+	for handler, a := range criAdapters {
+		restorer, ok := a.(adapters.Restorer)
+		if !ok {
+			continue
+		}
+		if err := restorer.Restore(context.Background(), byHandler[handler]); err != nil {
+			return fmt.Errorf("Runtime handler %s: %v", handler, err)
+		}
+	}
+	return nil
+}
+
+// wrapAdapters adds the metrics, retry and logging middleware to the adapters. Metrics include the retries,
+// while every attempt is logged.
+func wrapAdapters(criAdapters map[string]adapters.AdapterInterface, retries map[string]middleware.RetryPolicy) map[string]*middleware.Metrics {
+	metrics := make(map[string]*middleware.Metrics)
+	for handler, a := range criAdapters {
+		metrics[handler] = middleware.NewMetrics()
+		criAdapters[handler] = middleware.Wrap(a,
+			metrics[handler].Interceptor(),
+			middleware.Retry(retries),
+			middleware.Logging(handler),
+		)
+	}
+	return metrics
 }
 
 func NewMulticriService(
 	adapterName,
+	adapterConfig,
+	runtimeHandlers,
 	socketPath,
 	networkPluginBinDir,
 	networkPluginConfDir,
@@ -105,11 +250,29 @@ func NewMulticriService(
 	enablePodPersistence bool,
 	enableNetworkPersistence bool,
 	remoteCRIEndpoints string,
+	adapterTimeout time.Duration,
+	adapterTimeoutsConfig string,
+	adapterRetries string,
+	adapterRetryBackoff time.Duration,
 ) (CRIMulticriService, error) {
-	criAdapter, err := loadAdapter(adapterName)
+	timeouts, err := parseAdapterTimeouts(adapterTimeout, adapterTimeoutsConfig)
 	if err != nil {
 		return nil, err
 	}
+	retries, err := middleware.ParseRetryPolicies(adapterRetries, adapterRetryBackoff)
+	if err != nil {
+		return nil, err
+	}
+	criAdapters, defaultHandler, err := loadAdapters(adapterName, adapterConfig, runtimeHandlers)
+	if err != nil {
+		return nil, err
+	}
+	var closers []io.Closer
+	for _, a := range criAdapters {
+		if closer, ok := a.(io.Closer); ok {
+			closers = append(closers, closer)
+		}
+	}
 	if cgroupPath != "" {
 		_, err := loadCgroup(cgroupPath)
 		if err != nil {
@@ -132,8 +295,16 @@ func NewMulticriService(
 	if err != nil {
 		return nil, err
 	}
+	if err := restoreAdapters(criAdapters, defaultHandler, containerStore); err != nil {
+		return nil, err
+	}
+	metrics := wrapAdapters(criAdapters, retries)
 
-	remoteCRI, err := remote.LoadRemoteRuntimeConfiguration(remoteCRIEndpoints)
+	var localHandlers []string
+	for handler := range criAdapters {
+		localHandlers = append(localHandlers, handler)
+	}
+	remoteCRI, err := remote.LoadRemoteRuntimeConfiguration(remoteCRIEndpoints, localHandlers)
 	if err != nil {
 		return nil, err
 	}
@@ -144,10 +315,14 @@ func NewMulticriService(
 		sandboxStore:     sandboxStore,
 		containerStore:   containerStore,
 		imageStore:       imageStore,
-		adapter:          criAdapter,
+		adapters:         criAdapters,
+		defaultHandler:   defaultHandler,
 		cgroupPath:       cgroupPath,
 		os:               osinterface.RealOS{},
 		remoteCRI:        remoteCRI,
+		timeouts:         timeouts,
+		metrics:          metrics,
+		closers:          closers,
 	}
 	if enableNetworkPersistence {
 		netPlugin, err := ocicni.InitCNI(networkPluginConfDir, networkPluginBinDir)
@@ -161,7 +336,7 @@ func NewMulticriService(
 		}
 	}
 	// prepare streaming server
-	c.streamServer, err = NewStreamServer(criAdapter, c.containerStore, streamAddress, streamPort)
+	c.streamServer, err = NewStreamServer(newHandlerStreamRuntime(c), streamAddress, streamPort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create stream server: %v", err)
 	}
@@ -232,6 +407,51 @@ func (c *multicriService) Stop() {
 	klog.V(2).Info("Stop multi-cri service")
 	c.streamServer.Stop() // nolint: errcheck
 	c.server.Stop()
+	for _, closer := range c.closers {
+		if err := closer.Close(); err != nil {
+			klog.Errorf("Failed to close adapter: %v", err)
+		}
+	}
+}
+
+// runtimeHandlerName returns the local runtime handler that serves the pods of that runtime handler.
+// Pods without runtime handler, or with the multicri one when it is not configured, use the default handler.
+func (c *multicriService) runtimeHandlerName(runtimeHandler string) string {
+	return localRuntimeHandler(c.adapters, c.defaultHandler, runtimeHandler)
+}
+
+// localRuntimeHandler is runtimeHandlerName for the adapters of the handlers, before the service is built
+func localRuntimeHandler(criAdapters map[string]adapters.AdapterInterface, defaultHandler, runtimeHandler string) string {
+	if _, ok := criAdapters[runtimeHandler]; ok {
+		return runtimeHandler
+	}
+	if runtimeHandler == "" || runtimeHandler == remote.MulticriRuntimeHandler {
+		return defaultHandler
+	}
+	return runtimeHandler
+}
+
+// getAdapter returns the adapter that serves the runtime handler
+func (c *multicriService) getAdapter(runtimeHandler string) (adapters.AdapterInterface, error) {
+	name := c.runtimeHandlerName(runtimeHandler)
+	if a, ok := c.adapters[name]; ok {
+		return a, nil
+	}
+	return nil, fmt.Errorf("There is not any adapter for runtime handler %q", runtimeHandler)
+}
+
+// getCapableAdapter returns the adapter that serves the runtime handler, or a codes.Unimplemented
+// error when the adapter does not support the operation
+func (c *multicriService) getCapableAdapter(runtimeHandler, operation string) (adapters.AdapterInterface, error) {
+	adapter, err := c.getAdapter(runtimeHandler)
+	if err != nil {
+		return nil, err
+	}
+	if !adapter.Capabilities().Supports(operation) {
+		return nil, status.Errorf(codes.Unimplemented, "%s is not supported by runtime handler %q",
+			operation, c.runtimeHandlerName(runtimeHandler))
+	}
+	return adapter, nil
 }
 
 func (c *multicriService) GetContainer(sandBoxId string, containerID string) (*store.ContainerMetadata, error) {
@@ -0,0 +1,84 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io"
+
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/kubernetes/pkg/kubelet/server/streaming"
+)
+
+// handlerStreamRuntime sends the streaming requests to the stream runtime of the adapter
+// that serves the runtime handler of the pod.
+type handlerStreamRuntime struct {
+	service  *multicriService
+	runtimes map[string]streaming.Runtime
+}
+
+func newHandlerStreamRuntime(c *multicriService) streaming.Runtime {
+	runtimes := make(map[string]streaming.Runtime)
+	for handler, adapter := range c.adapters {
+		runtimes[handler] = adapter.NewStreamRuntime(c.containerStore)
+	}
+	return &handlerStreamRuntime{service: c, runtimes: runtimes}
+}
+
+func (h *handlerStreamRuntime) getRuntime(runtimeHandler string) (streaming.Runtime, error) {
+	runtimeHandler = h.service.runtimeHandlerName(runtimeHandler)
+	if runtime, ok := h.runtimes[runtimeHandler]; ok && runtime != nil {
+		return runtime, nil
+	}
+	return nil, fmt.Errorf("Streaming is not available for runtime handler %q", runtimeHandler)
+}
+
+func (h *handlerStreamRuntime) containerRuntime(containerID string) (streaming.Runtime, error) {
+	container, err := h.service.containerStore.Get(containerID)
+	if err != nil {
+		return nil, fmt.Errorf("Container %s not found", containerID)
+	}
+	return h.getRuntime(container.PodSandbox.RuntimeHandler)
+}
+
+func (h *handlerStreamRuntime) Attach(containerID string, in io.Reader, out, err io.WriteCloser, tty bool,
+	resize <-chan remotecommand.TerminalSize) error {
+	runtime, e := h.containerRuntime(containerID)
+	if e != nil {
+		return e
+	}
+	return runtime.Attach(containerID, in, out, err, tty, resize)
+}
+
+func (h *handlerStreamRuntime) Exec(containerID string, cmd []string, stdin io.Reader, stdout, stderr io.WriteCloser,
+	tty bool, resize <-chan remotecommand.TerminalSize) error {
+	runtime, err := h.containerRuntime(containerID)
+	if err != nil {
+		return err
+	}
+	return runtime.Exec(containerID, cmd, stdin, stdout, stderr, tty, resize)
+}
+
+func (h *handlerStreamRuntime) PortForward(podSandboxID string, port int32, stream io.ReadWriteCloser) error {
+	sandbox, err := h.service.sandboxStore.Get(podSandboxID)
+	if err != nil {
+		return fmt.Errorf("Sandbox %s not found", podSandboxID)
+	}
+	runtime, err := h.getRuntime(sandbox.RuntimeHandler)
+	if err != nil {
+		return err
+	}
+	return runtime.PortForward(podSandboxID, port, stream)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	// DefaultAdapterTimeout is the deadline of the adapter operations without their own timeout
+	DefaultAdapterTimeout = 2 * time.Minute
+	// DefaultAdapterTimeouts sets the timeout of the operations slower than the default one
+	DefaultAdapterTimeouts = "PullImage=30m"
+)
+
+// adapterTimeouts contains the deadline of each adapter operation
+type adapterTimeouts struct {
+	def time.Duration
+	ops map[string]time.Duration
+}
+
+// parseAdapterTimeouts parses the operation timeouts in "Operation=duration,Operation=duration" format.
+// The operations are named after the AdapterInterface methods. A zero duration disables the deadline.
+func parseAdapterTimeouts(def time.Duration, timeouts string) (adapterTimeouts, error) {
+	if def < 0 {
+		return adapterTimeouts{}, fmt.Errorf("Adapter timeout can not be negative")
+	}
+	out := adapterTimeouts{def: def, ops: make(map[string]time.Duration)}
+	if strings.TrimSpace(timeouts) == "" {
+		return out, nil
+	}
+	for _, timeout := range strings.Split(timeouts, ",") {
+		kv := strings.SplitN(timeout, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return adapterTimeouts{}, fmt.Errorf("Bad format for adapter timeout %q. It must be operation=duration", timeout)
+		}
+		op := strings.TrimSpace(kv[0])
+		if !adapters.IsOperation(op) {
+			return adapterTimeouts{}, fmt.Errorf("Unknown adapter operation %s in adapter timeout %q", op, timeout)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil || d < 0 {
+			return adapterTimeouts{}, fmt.Errorf("Bad duration for adapter timeout %q", timeout)
+		}
+		out.ops[op] = d
+	}
+	return out, nil
+}
+
+// timeout returns the deadline of the adapter operation
+func (t adapterTimeouts) timeout(op string) time.Duration {
+	if d, ok := t.ops[op]; ok {
+		return d
+	}
+	return t.def
+}
+
+// adapterContext returns the context passed to the adapter for the operation. It is cancelled with the
+// CRI request, or when the operation timeout expires.
+func (c *multicriService) adapterContext(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if d := c.timeouts.timeout(op); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
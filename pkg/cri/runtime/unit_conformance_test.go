@@ -0,0 +1,39 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/conformance"
+)
+
+//Test the fake adapter passes the conformance suite, with every capability and with none of them
+func TestUnitConformance(t *testing.T) {
+	for name, capabilities := range map[string]*adapters.Capabilities{"all": nil, "none": {}} {
+		capabilities := capabilities
+		t.Run(name, func(t *testing.T) {
+			conformance.Run(t, conformance.Config{
+				New: func() (adapters.AdapterInterface, error) {
+					return &FakeAdapter{capabilities: capabilities}, nil
+				},
+				Timeout:      time.Second,
+				PollInterval: time.Millisecond,
+			})
+		})
+	}
+}
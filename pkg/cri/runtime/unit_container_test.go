@@ -20,6 +20,8 @@ import (
 
 	"multi-cri/pkg/cri/store"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	runtimeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
 )
 
@@ -357,3 +359,36 @@ func TestUnitContainerListStats(t *testing.T) {
 	}
 
 }
+
+//Test container resources are updated, reported in the verbose status, and kept when the adapter fails
+func TestUnitUpdateContainerResources(t *testing.T) {
+	for _, fails := range []bool{false, true} {
+		service := NewFakeCRIService(fails)
+		containerId, err := createContaier("testUpdate", service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resources := &runtimeapi.LinuxContainerResources{CpuQuota: 200000, CpuPeriod: 100000}
+		req := runtimeapi.UpdateContainerResourcesRequest{ContainerId: containerId, Linux: resources}
+		_, err = service.UpdateContainerResources(nil, &req)
+		if (err != nil) != fails {
+			t.Fatalf("Update should fail only when the adapter fails (%t): %v", fails, err)
+		}
+		statusReq := runtimeapi.ContainerStatusRequest{ContainerId: containerId, Verbose: true}
+		out, err := service.ContainerStatus(nil, &statusReq)
+		if err != nil {
+			t.Fatal("Can not retrieve container status: ", err)
+		}
+		updated := strings.Contains(out.Info["resources"], `"cpu_quota":200000`)
+		if updated == fails {
+			t.Errorf("Resources should be updated only when the adapter does not fail (%t): %s", fails, out.Info["resources"])
+		}
+		if _, ok := out.Info["extra"]; !ok {
+			t.Errorf("Verbose status should have the extra information: %v", out.Info)
+		}
+		unknownReq := runtimeapi.UpdateContainerResourcesRequest{ContainerId: "unknown", Linux: resources}
+		if _, err := service.UpdateContainerResources(nil, &unknownReq); status.Code(err) != codes.NotFound {
+			t.Errorf("Unknown containers should fail with code NotFound: %v", err)
+		}
+	}
+}
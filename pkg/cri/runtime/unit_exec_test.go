@@ -30,10 +30,14 @@ func TestUnitAttach(t *testing.T) {
 		t.Fatal(err)
 	}
 	req := NewAttachRequest(containerId)
-	_, err = service.Attach(nil, &req)
+	response, err := service.Attach(nil, &req)
 	if err != nil {
 		t.Fatal("Attach fail ", err)
 	}
+	//The fake adapter streams through the runtime streaming server
+	if response.Url == "" {
+		t.Errorf("Attach should be served by the streaming server")
+	}
 }
 
 func TestUnitExecContainerNotRunning(t *testing.T) {
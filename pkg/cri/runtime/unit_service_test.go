@@ -0,0 +1,312 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/middleware"
+	"multi-cri/pkg/cri/runtime/remote"
+	"multi-cri/pkg/cri/store"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	runtimeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test containers are sent to the adapter of their runtime handler
+func TestUnitRuntimeHandlers(t *testing.T) {
+	service := NewFakeCRIServiceWithHandlers(map[string]adapters.AdapterInterface{
+		"multicri-ok":    &FakeAdapter{fails: false},
+		"multicri-fails": &FakeAdapter{fails: true},
+	}, "multicri-ok")
+	for handler, shouldFail := range map[string]bool{"multicri-ok": false, "multicri-fails": true, "": false, remote.MulticriRuntimeHandler: false} {
+		pod := "pod-" + handler
+		sandboxReq := NewCreateSandboxRequest(pod, handler)
+		if _, err := service.RunPodSandbox(nil, &sandboxReq); err != nil {
+			t.Fatalf("Create sandbox fails for handler %s: %v", handler, err)
+		}
+		imageName := FAKEIMAGE_DOCKER
+		if handler != "" {
+			imageName = handler + "/" + imageName
+		}
+		image, err := pullImage(imageName, service)
+		if err != nil {
+			t.Fatalf("Pull image fails for handler %s: %v", handler, err)
+		}
+		containerReq := NewCreateContainerRequest(pod, "test", image.ImageRef)
+		container, err := service.CreateContainer(nil, &containerReq)
+		if err != nil {
+			t.Fatalf("Create container fails for handler %s: %v", handler, err)
+		}
+		startReq := NewContainerStartRequest(container.ContainerId)
+		if _, err := service.StartContainer(nil, &startReq); err != nil {
+			t.Fatalf("Start container fails for handler %s: %v", handler, err)
+		}
+		stopReq := NewContainerStopRequest(container.ContainerId)
+		_, err = service.StopContainer(nil, &stopReq)
+		if shouldFail && err == nil {
+			t.Errorf("Stop container should fail for handler %s", handler)
+		}
+		if !shouldFail && err != nil {
+			t.Errorf("Stop container fails for handler %s: %v", handler, err)
+		}
+	}
+	out, err := service.ListPodSandbox(nil, &runtimeapi.ListPodSandboxRequest{})
+	if err != nil || len(out.Items) != 4 {
+		t.Errorf("All the local sandboxes should be listed: %v", out)
+	}
+	sandboxReq := NewCreateSandboxRequest("unknown", "other")
+	if _, err := service.RunPodSandbox(nil, &sandboxReq); err == nil {
+		t.Errorf("Sandboxes of unknown runtime handlers should fail")
+	}
+}
+
+//Test runtime handler and adapter options parsing
+func TestUnitParseRuntimeHandlers(t *testing.T) {
+	handlers, err := parseRuntimeHandlers("fake", "")
+	if err != nil || len(handlers) != 1 || handlers[0].handler != remote.MulticriRuntimeHandler || handlers[0].adapter != "fake" {
+		t.Errorf("Default runtime handler wrong: %v %v", handlers, err)
+	}
+	handlers, err = parseRuntimeHandlers("fake", "a:fake, b:fake")
+	if err != nil || len(handlers) != 2 || handlers[1].handler != "b" {
+		t.Errorf("Runtime handlers wrong: %v %v", handlers, err)
+	}
+	for _, bad := range []string{"a", "a:fake,a:fake", ":fake"} {
+		if _, err := parseRuntimeHandlers("fake", bad); err == nil {
+			t.Errorf("Runtime handlers %q should fail", bad)
+		}
+	}
+	configs, err := handlerAdapterConfig(adapters.AdapterConfig{"fails": "true", "b.fails": "false"}, handlers)
+	if err != nil || configs["a"]["fails"] != "true" || configs["b"]["fails"] != "false" {
+		t.Errorf("Adapter options wrong: %v %v", configs, err)
+	}
+	if _, err := handlerAdapterConfig(adapters.AdapterConfig{"unknown": "1"}, handlers); err == nil {
+		t.Errorf("Unknown options should fail")
+	}
+}
+
+//Test adapter operation deadlines
+func TestUnitAdapterTimeouts(t *testing.T) {
+	timeouts, err := parseAdapterTimeouts(time.Minute, DefaultAdapterTimeouts+", StopContainer=0")
+	if err != nil || timeouts.timeout("PullImage") != 30*time.Minute || timeouts.timeout("StartContainer") != time.Minute {
+		t.Errorf("Adapter timeouts wrong: %v %v", timeouts, err)
+	}
+	for _, bad := range []string{"PullImage", "PullImage=1x", "=1m", "PullImage=-1m", "PullImages=1m", "Capabilities=1m"} {
+		if _, err := parseAdapterTimeouts(time.Minute, bad); err == nil {
+			t.Errorf("Adapter timeouts %q should fail", bad)
+		}
+	}
+	service := &multicriService{timeouts: timeouts}
+	ctx, cancel := service.adapterContext(nil, "StartContainer")
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("Operation deadline wrong: %v", deadline)
+	}
+	ctx, cancel = service.adapterContext(nil, "StopContainer")
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("Zero timeout should not set a deadline")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Errorf("Adapter context should be cancelled")
+	}
+}
+
+//Test adapter errors are translated to gRPC status codes
+func TestUnitAdapterErrors(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, c := range []struct {
+		ctx  context.Context
+		err  error
+		code codes.Code
+	}{
+		{context.Background(), adapters.NotFoundError("job not found"), codes.NotFound},
+		{context.Background(), adapters.UnimplementedError("exec"), codes.Unimplemented},
+		{context.Background(), adapters.UnavailableError("cluster down"), codes.Unavailable},
+		{context.Background(), adapters.InvalidArgumentError("bad JOB_QUEUE"), codes.InvalidArgument},
+		{context.Background(), adapters.PermissionDeniedError("bad key"), codes.PermissionDenied},
+		{context.Background(), fmt.Errorf("other"), codes.Unknown},
+		{cancelled, adapters.UnavailableError("aborted"), codes.Canceled},
+	} {
+		if code := status.Code(adapterError(c.ctx, c.err)); code != c.code {
+			t.Errorf("Error %q should have code %s instead of %s", c.err, c.code, code)
+		}
+	}
+	if adapterError(context.Background(), nil) != nil {
+		t.Errorf("Nil errors should not be translated")
+	}
+
+	service := NewFakeCRIService(true)
+	containerId, err := createContaier("codes", service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The failing adapter can not start it, and only running containers are stopped by the adapter
+	containerStore := service.(*MulticriRuntime).containerStore
+	cm, err := containerStore.Get(containerId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.State = runtimeapi.ContainerState_CONTAINER_RUNNING
+	containerStore.Update(cm)
+	stopReq := NewContainerStopRequest(containerId)
+	if _, err := service.StopContainer(nil, &stopReq); status.Code(err) != codes.Unavailable {
+		t.Errorf("Stop container should fail with code Unavailable: %v", err)
+	}
+	// Transient status errors keep the container running, and the definitive ones exit it
+	adapter := &FakeAdapter{statusErr: adapters.UnavailableError("cluster down")}
+	service = NewFakeCRIServiceWithHandlers(map[string]adapters.AdapterInterface{"fake": adapter}, "fake")
+	if containerId, err = createContaier("status", service); err != nil {
+		t.Fatal(err)
+	}
+	containerStore = service.(*MulticriRuntime).containerStore
+	if cm, err = containerStore.Get(containerId); err != nil {
+		t.Fatal(err)
+	}
+	cm.State = runtimeapi.ContainerState_CONTAINER_RUNNING
+	statusReq := NewContainerStatusRequest(containerId)
+	if _, err := service.ContainerStatus(nil, &statusReq); status.Code(err) != codes.Unavailable ||
+		cm.State != runtimeapi.ContainerState_CONTAINER_RUNNING {
+		t.Errorf("Unavailable status should keep the container running: %v %v", err, cm.State)
+	}
+	adapter.statusErr = adapters.NotFoundError("job not found")
+	response, err := service.ContainerStatus(nil, &statusReq)
+	if err != nil || response.Status.State != runtimeapi.ContainerState_CONTAINER_EXITED || response.Status.ExitCode != 1 {
+		t.Errorf("Unknown jobs should exit the container: %v %v", response, err)
+	}
+	unknownReq := NewContainerStatusRequest("unknown")
+	if _, err := service.ContainerStatus(nil, &unknownReq); status.Code(err) != codes.NotFound {
+		t.Errorf("Unknown containers should fail with code NotFound: %v", err)
+	}
+}
+
+//Test unsupported operations are rejected and capabilities are reported
+func TestUnitCapabilities(t *testing.T) {
+	service := NewFakeCRIServiceWithHandlers(map[string]adapters.AdapterInterface{
+		"full":  &FakeAdapter{},
+		"batch": &FakeAdapter{capabilities: &adapters.Capabilities{}},
+	}, "full")
+	sandboxReq := NewCreateSandboxRequest("pod-batch", "batch")
+	if _, err := service.RunPodSandbox(nil, &sandboxReq); err != nil {
+		t.Fatal(err)
+	}
+	image, err := pullImage("batch/"+FAKEIMAGE_DOCKER, service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerReq := NewCreateContainerRequest("pod-batch", "test", image.ImageRef)
+	container, err := service.CreateContainer(nil, &containerReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startReq := NewContainerStartRequest(container.ContainerId)
+	if _, err := service.StartContainer(nil, &startReq); err != nil {
+		t.Fatal(err)
+	}
+	execReq := NewExecSyncRequest(container.ContainerId, []string{"hostname"})
+	if _, err := service.ExecSync(nil, &execReq); status.Code(err) != codes.Unimplemented {
+		t.Errorf("ExecSync should fail with code Unimplemented: %v", err)
+	}
+	stopReq := NewContainerStopRequest(container.ContainerId)
+	if _, err := service.StopContainer(nil, &stopReq); err != nil {
+		t.Fatal(err)
+	}
+	removeReq := NewContainerRemoveRequest(container.ContainerId)
+	if _, err := service.RemoveContainer(nil, &removeReq); err != nil {
+		t.Errorf("Containers of adapters which can not reopen logs must be removed: %v", err)
+	}
+
+	out, err := service.Status(nil, &runtimeapi.StatusRequest{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capabilities := map[string]adapters.Capabilities{}
+	if err := json.Unmarshal([]byte(out.Info["capabilities"]), &capabilities); err != nil {
+		t.Fatalf("Capabilities info wrong: %v %v", out.Info, err)
+	}
+	if !capabilities["full"].ExecSync || capabilities["batch"].ExecSync {
+		t.Errorf("Capabilities info wrong: %v", capabilities)
+	}
+}
+
+//Test the adapter call metrics are shown in the verbose status
+func TestUnitAdapterMetrics(t *testing.T) {
+	service := NewFakeCRIService(true)
+	sandboxReq := NewCreateSandboxRequest("pod-metrics", "")
+	if _, err := service.RunPodSandbox(nil, &sandboxReq); err != nil {
+		t.Fatal(err)
+	}
+	image, err := pullImage(FAKEIMAGE_DOCKER, service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	containerReq := NewCreateContainerRequest("pod-metrics", "test", image.ImageRef)
+	container, err := service.CreateContainer(nil, &containerReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startReq := NewContainerStartRequest(container.ContainerId)
+	if _, err := service.StartContainer(nil, &startReq); err != nil {
+		t.Fatal(err)
+	}
+	stopReq := NewContainerStopRequest(container.ContainerId)
+	if _, err := service.StopContainer(nil, &stopReq); err == nil {
+		t.Fatal("StopContainer should fail")
+	}
+
+	out, err := service.Status(nil, &runtimeapi.StatusRequest{Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]map[string]middleware.MethodMetrics{}
+	if err := json.Unmarshal([]byte(out.Info["metrics"]), &metrics); err != nil {
+		t.Fatalf("Metrics info wrong: %v %v", out.Info, err)
+	}
+	handler := metrics[remote.MulticriRuntimeHandler]
+	if handler["CreateContainer"].Calls != 1 || len(handler["CreateContainer"].Errors) != 0 {
+		t.Errorf("CreateContainer metrics wrong: %+v", handler["CreateContainer"])
+	}
+	if handler["StopContainer"].Calls != 1 || handler["StopContainer"].Errors[string(adapters.KindUnavailable)] != 1 {
+		t.Errorf("StopContainer metrics wrong: %+v", handler["StopContainer"])
+	}
+}
+
+//Test the stored containers are restored by the adapter of their runtime handler
+func TestUnitRestoreAdapters(t *testing.T) {
+	containers, err := store.NewContainerStorage("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, handler := range map[string]string{"default": "", "multicri": remote.MulticriRuntimeHandler, "other": "b"} {
+		containers.Add(&store.ContainerMetadata{ID: id, PodSandbox: store.SandboxMetadata{RuntimeHandler: handler}})
+	}
+	a, b := &FakeAdapter{}, &FakeAdapter{}
+	if err := restoreAdapters(map[string]adapters.AdapterInterface{"a": a, "b": b}, "a", containers); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.restored) != 2 || len(b.restored) != 1 || b.restored[0] != "other" {
+		t.Errorf("Containers restored by the wrong adapters: %v %v", a.restored, b.restored)
+	}
+	if err := restoreAdapters(map[string]adapters.AdapterInterface{"a": &FakeAdapter{fails: true}}, "a", containers); err == nil {
+		t.Errorf("Restore errors should fail")
+	}
+}
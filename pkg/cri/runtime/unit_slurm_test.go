@@ -0,0 +1,116 @@
+// Copyright (c) 2019 Atrio, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"multi-cri/pkg/cri/adapters"
+	"multi-cri/pkg/cri/adapters/slurm"
+	"multi-cri/pkg/cri/adapters/slurm/fakecluster"
+	"multi-cri/pkg/cri/runtime/remote"
+
+	runtimeapi "k8s.io/kubernetes/pkg/kubelet/apis/cri/runtime/v1alpha2"
+)
+
+//Test the Slurm adapter through the CRI against a fake cluster, from the container creation to its exit
+func TestUnitSlurmContainerLifecycle(t *testing.T) {
+	cluster, err := fakecluster.Start(fakecluster.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	adapter, err := slurm.NewSlurmAdapterWithConfig(adapters.AdapterConfig{"build-in-cluster": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewFakeCRIServiceWithHandlers(map[string]adapters.AdapterInterface{
+		remote.MulticriRuntimeHandler: adapter,
+	}, remote.MulticriRuntimeHandler)
+
+	logDir, err := ioutil.TempDir("", "multicri-slurm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(logDir)
+	sandboxReq := NewCreateSandboxRequest("slurm-pod", "")
+	sandboxReq.Config.LogDirectory = logDir
+	pod, err := service.RunPodSandbox(nil, &sandboxReq)
+	if err != nil {
+		t.Fatal("Run sandbox fails:", err)
+	}
+	imageResponse, err := pullImage(FAKEIMAGE_DOCKER, service)
+	if err != nil {
+		t.Fatal("Pull image fails:", err)
+	}
+
+	containerReq := NewCreateContainerRequest(pod.PodSandboxId, "hello", imageResponse.ImageRef)
+	containerReq.Config.Command = []string{"echo", "hello"}
+	containerReq.Config.Envs = []*runtimeapi.KeyValue{
+		{Key: "CLUSTER_USERNAME", Value: cluster.User},
+		{Key: "CLUSTER_PASSWORD", Value: cluster.Password},
+		{Key: "CLUSTER_HOST", Value: cluster.Host},
+		{Key: "CLUSTER_PORT", Value: cluster.Port},
+	}
+	container, err := service.CreateContainer(nil, &containerReq)
+	if err != nil {
+		t.Fatal("Create container fails:", err)
+	}
+	startReq := NewContainerStartRequest(container.ContainerId)
+	if _, err := service.StartContainer(nil, &startReq); err != nil {
+		t.Fatal("Start container fails:", err)
+	}
+
+	statusReq := NewContainerStatusRequest(container.ContainerId)
+	var status *runtimeapi.ContainerStatus
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		response, err := service.ContainerStatus(nil, &statusReq)
+		if err != nil {
+			t.Fatal("Can not retrieve container status:", err)
+		}
+		status = response.Status
+		if status.State == runtimeapi.ContainerState_CONTAINER_EXITED {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Container is %s after 30s", status.State)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	if status.ExitCode != 0 || status.Reason != "completed" {
+		t.Fatalf("Container must complete, exit code %d and reason %s", status.ExitCode, status.Reason)
+	}
+
+	jobs := cluster.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("One job must be submitted, found %d", len(jobs))
+	}
+	if jobs[0].State != fakecluster.StateCompleted {
+		t.Fatalf("Job must be completed, found %s", jobs[0].State)
+	}
+	output, err := ioutil.ReadFile(filepath.Join(jobs[0].WorkDir, jobs[0].Stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(output)) != "hello" {
+		t.Fatalf("Job output must be hello, found %q", output)
+	}
+}
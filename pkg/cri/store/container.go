@@ -30,7 +30,7 @@ type ContainerStoreInterface interface {
 	Get(ID string) (*ContainerMetadata, error)
 	List(Id string, filterPodSandboxId string) []*ContainerMetadata
 	ListK8s(Id string, filterPodSandboxId string, filterLabelSelector map[string]string,
-		filterState *runtimeApi.ContainerStateValue, localCRI string) []*runtimeApi.Container
+		filterState *runtimeApi.ContainerStateValue, localCRIs []string) []*runtimeApi.Container
 	CreateContainerMetadata(name string, podSandbox *SandboxMetadata, state runtimeApi.ContainerState,
 		createdAt int64, image *ImageMetadata, command []string, args []string, isService bool,
 		config runtimeApi.ContainerConfig, envVars map[string]string, port int, id *string,
@@ -139,7 +139,7 @@ func (cs *ContainerStorage) CreateContainerMetadata(name string, podSandbox *San
 }
 
 func (cs *ContainerStorage) ListK8s(Id string, filterPodSandboxId string, filterLabelSelector map[string]string,
-	filterState *runtimeApi.ContainerStateValue, localCRI string) []*runtimeApi.Container {
+	filterState *runtimeApi.ContainerStateValue, localCRIs []string) []*runtimeApi.Container {
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
 	var containers []*runtimeApi.Container
@@ -173,7 +173,7 @@ func (cs *ContainerStorage) ListK8s(Id string, filterPodSandboxId string, filter
 		if Id != "" && Id != container.ID {
 			continue
 		}
-		if container.PodSandbox.RuntimeHandler != localCRI {
+		if !isLocalRuntime(container.PodSandbox.RuntimeHandler, localCRIs) {
 			continue
 		}
 		containers = append(containers, ParseToK8sContainer(container))
@@ -44,6 +44,7 @@ type ImageStoreInterface interface {
 	Remove(ID string)
 	Get(ID string) (*ImageMetadata, error)
 	GetByID(ID string) (*ImageMetadata, error)
+	GetByRuntimeHandler(ID, runtimeHandler string) (*ImageMetadata, error)
 	GetByPath(path string) (*ImageMetadata, error)
 	CreateImageMetadata(imageName *runtimeApi.PullImageRequest) (*ImageMetadata, error)
 	ListK8s(filter *runtimeApi.ImageFilter) []*runtimeApi.Image
@@ -129,10 +130,19 @@ func (im *ImageStorage) Get(ID string) (*ImageMetadata, error) {
 }
 
 func (im *ImageStorage) GetByID(ID string) (*ImageMetadata, error) {
+	return im.GetByRuntimeHandler(ID, "")
+}
+
+// GetByRuntimeHandler looks for an image pulled by the adapter of the runtime handler.
+// Images without runtime handler match any of them.
+func (im *ImageStorage) GetByRuntimeHandler(ID, runtimeHandler string) (*ImageMetadata, error) {
 	im.lock.Lock()
 	defer im.lock.Unlock()
 	var imageFound *ImageMetadata
 	for imageId, image := range im.ImagePool {
+		if runtimeHandler != "" && image.RuntimeHandler != "" && image.RuntimeHandler != runtimeHandler {
+			continue
+		}
 		if strings.Contains(image.ImageName, ID) || strings.Contains(imageId, ID) || tagInImage(image, ID) || digestInImage(image, ID) {
 			imageFound = image
 			break
@@ -108,6 +108,8 @@ type ImageMetadata struct {
 	RepoType RepoType
 	//Repo
 	Auth ImageAuth
+	// RuntimeHandler is the runtime handler of the adapter that pulled the image
+	RuntimeHandler string
 }
 
 type RepoType int
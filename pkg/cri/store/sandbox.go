@@ -31,7 +31,7 @@ type SandboxStoreInterface interface {
 	RemoveContainer(ID, containerId string)
 	Get(ID string) (*SandboxMetadata, error)
 	List() map[string]*SandboxMetadata
-	ListK8s(filter *runtimeApi.PodSandboxFilter, locaCRI string) []*runtimeApi.PodSandbox
+	ListK8s(filter *runtimeApi.PodSandboxFilter, localCRIs []string) []*runtimeApi.PodSandbox
 	CreateSandboxMetadata(state runtimeApi.PodSandboxState, config runtimeApi.PodSandboxConfig, runtimeHandler string) *SandboxMetadata
 }
 
@@ -121,7 +121,7 @@ func (ss *SandboxStorage) Get(ID string) (*SandboxMetadata, error) {
 	return value, nil
 }
 
-func (ss *SandboxStorage) ListK8s(filter *runtimeApi.PodSandboxFilter, localCRI string) []*runtimeApi.PodSandbox {
+func (ss *SandboxStorage) ListK8s(filter *runtimeApi.PodSandboxFilter, localCRIs []string) []*runtimeApi.PodSandbox {
 	ss.lock.Lock()
 	defer ss.lock.Unlock()
 	var result []*runtimeApi.PodSandbox
@@ -151,7 +151,7 @@ func (ss *SandboxStorage) ListK8s(filter *runtimeApi.PodSandboxFilter, localCRI
 		if filterState < 100 && filterState != sandbox.State {
 			continue
 		}
-		if sandbox.RuntimeHandler != localCRI {
+		if !isLocalRuntime(sandbox.RuntimeHandler, localCRIs) {
 			continue
 		}
 		result = append(result, ParseToK8sSandbox(sandbox))
@@ -223,3 +223,13 @@ func ParseToK8sSandboxStatus(sandbox *SandboxMetadata, ip string) *runtimeApi.Po
 	}
 
 }
+
+// isLocalRuntime checks if the runtime handler is one of the handlers served by the local adapters
+func isLocalRuntime(runtimeHandler string, localCRIs []string) bool {
+	for _, localCRI := range localCRIs {
+		if runtimeHandler == localCRI {
+			return true
+		}
+	}
+	return false
+}